@@ -1,5 +1,22 @@
-DROP SCHEMA "public" CASCADE;
-CREATE SCHEMA "public";
+/* Tables of every migration (latest first), telemetry partitions are dropped with telemetry */
+DROP TABLE IF EXISTS pipeline_versions CASCADE;
+DROP TABLE IF EXISTS pipeline_webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS pipeline_webhooks CASCADE;
+DROP TABLE IF EXISTS pipeline_schedules CASCADE;
+DROP TABLE IF EXISTS pipeline_runs CASCADE;
+DROP TABLE IF EXISTS invocations CASCADE;
+DROP TABLE IF EXISTS telemetry CASCADE;
+DROP TABLE IF EXISTS device_shadows CASCADE;
+DROP TABLE IF EXISTS device_key_events CASCADE;
+DROP TABLE IF EXISTS invitations CASCADE;
+DROP TABLE IF EXISTS sessions CASCADE;
+DROP TABLE IF EXISTS collaborators CASCADE;
+DROP TABLE IF EXISTS pipelines CASCADE;
+DROP TABLE IF EXISTS external_keys CASCADE;
+DROP TABLE IF EXISTS endpoints CASCADE;
+DROP TABLE IF EXISTS devices CASCADE;
+DROP TABLE IF EXISTS projects CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
 
-GRANT ALL ON SCHEMA public TO admin;
-GRANT ALL ON SCHEMA public TO public;
+/* Migration bookkeeping (see cmd/wyrm migrate) */
+DROP TABLE IF EXISTS schema_migrations;
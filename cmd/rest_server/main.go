@@ -1,25 +1,48 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
+	// Timezones of pipeline schedules do not depend on the host zoneinfo
+	_ "time/tzdata"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+	"github.com/tnynlabs/wyrm/pkg/apispec"
 	"github.com/tnynlabs/wyrm/pkg/devices"
 	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/events"
 	"github.com/tnynlabs/wyrm/pkg/http/rest"
 	"github.com/tnynlabs/wyrm/pkg/http/rest/middleware"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
 	"github.com/tnynlabs/wyrm/pkg/pipelines"
 	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
 	"github.com/tnynlabs/wyrm/pkg/storage/postgres"
+	"github.com/tnynlabs/wyrm/pkg/telemetry"
+	telemetryProtobuf "github.com/tnynlabs/wyrm/pkg/telemetry/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/templates"
 	"github.com/tnynlabs/wyrm/pkg/tunnels"
+	"github.com/tnynlabs/wyrm/pkg/tunnels/protobuf"
 	"github.com/tnynlabs/wyrm/pkg/users"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	inMemory := flag.Bool("memory", false, "Use in memory storage instead of postgres (data is lost on exit)")
+	migrate := flag.Bool("migrate", false, "Apply pending database migrations before starting")
+	flag.Parse()
+
 	if devFlag := os.Getenv("ENV_FILE"); devFlag == "1" {
 		// Load environment variables from .env file
 		err := godotenv.Load(".env")
@@ -28,38 +51,191 @@ func main() {
 		}
 	}
 
-	db, err := postgres.GetFromEnv()
+	var (
+		userRepo     users.Repository
+		projectRepo  projects.Repository
+		deviceRepo   devices.Repository
+		endpointRepo endpoints.Repository
+		pipelineRepo pipelines.Repository
+		sessionRepo  sessions.Repository
+
+		invitationRepo projects.InvitationRepository
+		apiKeyRepo     apikeys.Repository
+		shadowRepo     shadows.Repository
+		telemetryRepo  telemetry.Repository
+		invocationRepo invocations.Repository
+		runRepo        pipelines.RunRepository
+		versionRepo    pipelines.VersionRepository
+		scheduleRepo   pipelines.ScheduleRepository
+		webhookRepo    pipelines.WebhookRepository
+		leader         pipelines.Leader
+		bus            events.Bus
+	)
+	if *inMemory {
+		log.Println("Using in memory storage")
+		db := memory.CreateDB()
+		userRepo = memory.CreateUserRepository(db)
+		projectRepo = memory.CreateProjectRepository(db)
+		deviceRepo = memory.CreateDeviceRepository(db)
+		endpointRepo = memory.CreateEndpointRepository(db)
+		pipelineRepo = memory.CreatePipelineRepository(db)
+		sessionRepo = memory.CreateSessionRepository(db)
+		invitationRepo = memory.CreateInvitationRepository(db)
+		apiKeyRepo = memory.CreateAPIKeyRepository(db)
+		shadowRepo = memory.CreateShadowRepository(db)
+		telemetryRepo = memory.CreateTelemetryRepository(db)
+		invocationRepo = memory.CreateInvocationRepository(db)
+		runRepo = memory.CreatePipelineRunRepository(db)
+		versionRepo = memory.CreatePipelineVersionRepository(db)
+		scheduleRepo = memory.CreatePipelineScheduleRepository(db)
+		webhookRepo = memory.CreatePipelineWebhookRepository(db)
+		leader = memory.CreateSchedulerLeader()
+		// In memory storage is not shared between replicas,
+		// neither are their events
+		bus = events.CreateBus()
+	} else {
+		db, err := postgres.GetFromEnv()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if *migrate {
+			migrator, err := postgres.CreateMigrator(db, log.Writer())
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if err = migrator.Up(); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		userRepo = postgres.CreateUserRepository(db)
+		projectRepo = postgres.CreateProjectRepository(db)
+		deviceRepo = postgres.CreateDeviceRepository(db)
+		endpointRepo = postgres.CreateEndpointRepository(db)
+		pipelineRepo = postgres.CreatePipelineRepository(db)
+		sessionRepo = postgres.CreateSessionRepository(db)
+		invitationRepo = postgres.CreateInvitationRepository(db)
+		apiKeyRepo = postgres.CreateAPIKeyRepository(db)
+		shadowRepo = postgres.CreateShadowRepository(db)
+		telemetryRepo = postgres.CreateTelemetryRepository(db)
+		invocationRepo = postgres.CreateInvocationRepository(db)
+		runRepo = postgres.CreatePipelineRunRepository(db)
+		versionRepo = postgres.CreatePipelineVersionRepository(db)
+		scheduleRepo = postgres.CreatePipelineScheduleRepository(db)
+		webhookRepo = postgres.CreatePipelineWebhookRepository(db)
+		leader = postgres.CreateSchedulerLeader(db)
+		bus, err = postgres.CreateEventBus(db, postgres.ConnInfoFromEnv())
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	pwdHasher, err := users.HasherFromName(os.Getenv("PWD_HASH_ALGO"))
 	if err != nil {
 		log.Fatalln(err)
 	}
+	userService := users.CreateServiceWithHasher(userRepo, pwdHasher)
 
-	userRepo := postgres.CreateUserRepository(db)
-	userService := users.CreateService(userRepo)
-	userHandler := rest.CreateUserHandler(userService)
+	sessionTTL := durationFromEnv("SESSION_TTL", sessions.DefaultTTL)
+	sessionRotation := durationFromEnv("SESSION_ROTATION_INTERVAL", sessions.DefaultRotationInterval)
+	sessionService := sessions.CreateService(sessionRepo, sessionTTL, sessionRotation)
+	sessionHandler := rest.CreateSessionHandler(sessionService)
 
-	projectRepo := postgres.CreateProjectRepository(db)
-	projectService := projects.CreateService(projectRepo)
+	userHandler := rest.CreateUserHandler(userService, sessionService)
+
+	projectService := projects.CreateService(projectRepo, invitationRepo)
 	projectHandler := rest.CreateProjectHandler(projectService, userService)
 
-	deviceRepo := postgres.CreateDeviceRepository(db)
-	deviceService := devices.CreateDeviceService(deviceRepo)
+	apiKeyService := apikeys.CreateService(apiKeyRepo, projectService)
+	apiKeyHandler := rest.CreateAPIKeyHandler(apiKeyService)
+
+	// Streams the events of all replicas to clients by project
+	eventHub := events.CreateHub(bus)
+	eventHandler := rest.CreateEventHandler(eventHub)
+
+	tunnelAddr := os.Getenv("TUNNEL_HOST") + ":" + os.Getenv("TUNNEL_PORT")
+	tunnelService := tunnels.CreateHttpGrpcService(tunnelAddr)
+
+	deviceService := devices.CreateDeviceService(deviceRepo, tunnelService, bus)
 	deviceHandler := rest.CreateDeviceHandler(deviceService)
 
-	endpointRepo := postgres.CreateEndpointRepository(db)
-	endpointService := endpoints.CreateEndpointService(endpointRepo)
+	endpointService := endpoints.CreateEndpointService(endpointRepo, deviceService, bus)
 	endpointHandler := rest.CreateEndpointHandler(endpointService)
 
-	pipelineWorkerAddr := os.Getenv("PIPELINE_HOST") + ":" + os.Getenv("PIPELINE_PORT")
-	pipelineRepo := postgres.CreatePipelineRepository(db)
-	pipelineService, err := pipelines.CreateService(pipelineRepo, pipelineWorkerAddr)
-	if err != nil {
-		log.Fatalln(err)
+	apiSpecService := apispec.CreateService(projectService, deviceService, endpointService)
+	apiSpecHandler := rest.CreateAPISpecHandler(apiSpecService)
+
+	invocationWorkers := intFromEnv("INVOCATION_WORKERS", invocations.DefaultWorkers)
+	invocationService := invocations.CreateService(invocationRepo, deviceService, tunnelService, bus, invocationWorkers)
+	invocationHandler := rest.CreateInvocationHandler(invocationService)
+	grpcHandler := rest.CreateGrpcHandler(tunnelService, invocationService, endpointService)
+
+	shadowService := shadows.CreateService(shadowRepo, deviceService, tunnelService)
+	shadowHandler := rest.CreateShadowHandler(shadowService)
+
+	telemetryService := telemetry.CreateService(telemetryRepo, deviceService, bus)
+	telemetryHandler := rest.CreateTelemetryHandler(telemetryService)
+
+	// Devices push telemetry to this grpc server (authenticated with their auth key)
+	telemetryAddr := ":" + os.Getenv("TELEMETRY_PORT")
+	if telemetryAddr == ":" {
+		telemetryAddr = ":9092"
+	}
+	go serveTelemetry(telemetryAddr, telemetry.CreateIngestServer(telemetryService))
+
+	// Tunnel managers report device connectivity and shadow state to this (internal) grpc server,
+	// authenticated with REGISTRY_TOKEN. It only listens on localhost unless REGISTRY_ADDR is set
+	// (e.g. to the address of the internal network).
+	registryAddr := os.Getenv("REGISTRY_ADDR")
+	if registryAddr == "" {
+		registryAddr = "localhost:" + os.Getenv("REGISTRY_PORT")
+		if registryAddr == "localhost:" {
+			registryAddr = "localhost:9091"
+		}
+	}
+	if registryToken := os.Getenv("REGISTRY_TOKEN"); registryToken != "" {
+		go serveRegistry(registryAddr, registryToken, tunnels.CreateRegistryServer(deviceService, shadowService, bus))
+	} else {
+		log.Println("REGISTRY_TOKEN is not set, the device registry is disabled (device presence is not tracked)")
+	}
+
+	// PIPELINE_WORKER=embedded runs pipelines inside the API server
+	// instead of the external worker (PIPELINE_HOST:PIPELINE_PORT)
+	var pipelineService pipelines.Service
+	switch pipelineWorker := os.Getenv("PIPELINE_WORKER"); pipelineWorker {
+	case "embedded":
+		log.Println("Using embedded pipeline worker")
+		// http-request nodes can only reach internal addresses of
+		// PIPELINE_HTTP_ALLOWED_NETWORKS (comma separated CIDRs)
+		allowedNetworks, err := pipelines.ParseNetworks(os.Getenv("PIPELINE_HTTP_ALLOWED_NETWORKS"))
+		if err != nil {
+			log.Fatalf("Invalid PIPELINE_HTTP_ALLOWED_NETWORKS (error: %v)", err)
+		}
+		worker, err := pipelines.CreateEmbeddedWorker(tunnelService, endpointService, allowedNetworks)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		pipelineService = pipelines.CreateServiceWithWorker(pipelineRepo, runRepo, versionRepo, scheduleRepo, webhookRepo, deviceService, endpointService, bus, worker)
+	case "", "remote":
+		pipelineWorkerAddr := os.Getenv("PIPELINE_HOST") + ":" + os.Getenv("PIPELINE_PORT")
+		pipelineService, err = pipelines.CreateService(pipelineRepo, runRepo, versionRepo, scheduleRepo, webhookRepo, deviceService, endpointService, bus, pipelineWorkerAddr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	default:
+		log.Fatalf("Invalid PIPELINE_WORKER %q (expected \"remote\" or \"embedded\")", pipelineWorker)
 	}
 	pipelineHandler := rest.CreatePipelineHandler(pipelineService, projectService)
 
-	tunnelAddr := os.Getenv("TUNNEL_HOST") + ":" + os.Getenv("TUNNEL_PORT")
-	tunnelService := tunnels.CreateHttpGrpcService(tunnelAddr)
-	grpcHandler := rest.CreateGrpcHandler(tunnelService)
+	templateService := templates.CreateService(projectService, deviceService, endpointService, pipelineService)
+	templateHandler := rest.CreateTemplateHandler(templateService)
+
+	// Only one replica (the leader) starts scheduled runs
+	schedulerInterval := durationFromEnv("SCHEDULER_INTERVAL", pipelines.DefaultSchedulerInterval)
+	scheduler := pipelines.CreateScheduler(pipelineService, scheduleRepo, leader, schedulerInterval)
+	go scheduler.Run(context.Background())
+
+	eventDispatcher := pipelines.CreateEventDispatcher(pipelineService, bus, pipelines.DefaultTriggerReloadInterval)
+	go eventDispatcher.Run(context.Background())
 
 	r := chi.NewRouter()
 
@@ -74,58 +250,225 @@ func main() {
 		}))
 	}
 
+	auth := middleware.Auth(userService, sessionService, apiKeyService)
+	self := middleware.RequireSelf("userID")
+	// API keys are scoped to a project, account level routes need a session
+	session := middleware.RequireSession
+	// authorize checks the caller's role in the project owning the resource in the URL
+	authorize := func(resourceType projects.ResourceType, param string) func(projects.Role) func(http.Handler) http.Handler {
+		return func(role projects.Role) func(http.Handler) http.Handler {
+			return middleware.Authorize(projectService, resourceType, param, role)
+		}
+	}
+	projectRole := authorize(projects.ProjectResource, "projectID")
+	deviceRole := authorize(projects.DeviceResource, "deviceID")
+	endpointRole := authorize(projects.EndpointResource, "endpointID")
+	pipelineRole := authorize(projects.PipelineResource, "pipelineID")
+	invocationRole := authorize(projects.InvocationResource, "invocationID")
+	runRole := authorize(projects.RunResource, "runID")
+	scheduleRole := authorize(projects.ScheduleResource, "scheduleID")
+
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Post("/register", userHandler.RegisterWithPwd)
 		r.Post("/login", userHandler.LoginWithEmailPwd)
 		r.Post("/logout", userHandler.Logout)
 
+		// Called by devices (authenticated with the "X-Device-Key" header)
+		r.Post("/telemetry", telemetryHandler.Ingest)
+
 		r.Route("/users/{userID}", func(r chi.Router) {
-			r.Use(middleware.Auth(userService))
+			r.Use(auth, session)
 			r.Get("/", userHandler.Get)
-			r.Patch("/", userHandler.Update)
-			r.Delete("/", userHandler.Delete)
+			r.With(self).Patch("/", userHandler.Update)
+			r.With(self).Delete("/", userHandler.Delete)
+
+			r.With(self).Post("/projects", projectHandler.Create)
+			r.With(self).Get("/projects", projectHandler.GetAllowed)
+
+			r.With(self).Get("/invitations", projectHandler.GetUserInvitations)
+
+			r.With(self).Get("/sessions", sessionHandler.GetActive)
+			r.With(self).Delete("/sessions", sessionHandler.RevokeAll)
+			r.With(self).Delete("/sessions/{sessionID}", sessionHandler.Revoke)
 
-			r.Post("/projects", projectHandler.Create)
-			r.Get("/projects", projectHandler.GetAllowed)
+			r.With(self).Post("/api-keys", apiKeyHandler.Create)
+			r.With(self).Get("/api-keys", apiKeyHandler.GetAll)
+			r.With(self).Delete("/api-keys/{keyID}", apiKeyHandler.Revoke)
 		})
 		r.Route("/projects/{projectID}", func(r chi.Router) {
-			r.Use(middleware.Auth(userService))
-			r.Get("/", projectHandler.Get)
-			r.Patch("/", projectHandler.Update)
-			r.Delete("/", projectHandler.Delete)
-			r.Post("/collaborators", projectHandler.AddCollaborator)
+			r.Use(auth)
+			r.With(projectRole(projects.RoleViewer)).Get("/", projectHandler.Get)
+			r.With(projectRole(projects.RoleAdmin)).Patch("/", projectHandler.Update)
+			r.With(projectRole(projects.RoleOwner)).Delete("/", projectHandler.Delete)
 
-			r.Post("/devices", deviceHandler.Create)
-			r.Get("/devices", deviceHandler.GetByProjectID)
+			r.With(projectRole(projects.RoleViewer)).Get("/collaborators", projectHandler.GetCollaborators)
+			r.With(projectRole(projects.RoleAdmin)).Post("/collaborators", projectHandler.Invite)
+			r.With(projectRole(projects.RoleOwner)).Post("/collaborators/transfer-ownership", projectHandler.TransferOwnership)
+			r.With(projectRole(projects.RoleAdmin)).Patch("/collaborators/{userID}", projectHandler.UpdateRole)
+			// Viewers may remove themselves (leave), the service checks the rest
+			r.With(projectRole(projects.RoleViewer)).Delete("/collaborators/{userID}", projectHandler.RemoveCollaborator)
+			r.With(projectRole(projects.RoleAdmin)).Get("/invitations", projectHandler.GetInvitations)
+			r.With(projectRole(projects.RoleAdmin)).Delete("/invitations/{invitationID}", projectHandler.RevokeInvitation)
 
-			r.Post("/pipelines", pipelineHandler.Create)
-			r.Get("/pipelines", pipelineHandler.GetByProjectID)
+			r.With(projectRole(projects.RoleDeveloper)).Post("/devices", deviceHandler.Create)
+			r.With(projectRole(projects.RoleViewer)).Get("/devices", deviceHandler.GetByProjectID)
+			r.With(projectRole(projects.RoleViewer)).Get("/openapi.json", apiSpecHandler.Get)
+
+			r.With(projectRole(projects.RoleDeveloper)).Post("/pipelines", pipelineHandler.Create)
+			r.With(projectRole(projects.RoleViewer)).Get("/pipelines", pipelineHandler.GetByProjectID)
+			r.With(projectRole(projects.RoleDeveloper)).Post("/pipelines/import", pipelineHandler.Import)
+
+			r.With(projectRole(projects.RoleViewer)).Get("/template", templateHandler.Export)
+			r.With(projectRole(projects.RoleDeveloper)).Post("/template/apply", templateHandler.Apply)
+
+			// WebSocket or Server-Sent Events
+			r.With(projectRole(projects.RoleViewer)).Get("/events", eventHandler.Stream)
+		})
+		r.Route("/invitations", func(r chi.Router) {
+			// The invitee is not a collaborator yet, the token proves the invitation
+			r.Use(auth, session)
+			r.Post("/accept", projectHandler.AcceptInvitation)
+			r.Post("/decline", projectHandler.DeclineInvitation)
 		})
 		r.Route("/pipelines/{pipelineID}", func(r chi.Router) {
-			// r.Use(middleware.Auth(userService))
-			r.Get("/", pipelineHandler.Get)
-			r.Patch("/", pipelineHandler.Update)
-			r.Delete("/", pipelineHandler.Delete)
-			r.HandleFunc("/webhook", pipelineHandler.Webhook)
+			// Webhooks are triggered by external services (no user session),
+			// requests are authenticated with the webhook signature
+			r.Post("/webhook", pipelineHandler.Webhook)
+
+			r.Group(func(r chi.Router) {
+				r.Use(auth)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/", pipelineHandler.Get)
+				r.With(pipelineRole(projects.RoleDeveloper)).Patch("/", pipelineHandler.Update)
+				r.With(pipelineRole(projects.RoleDeveloper)).Delete("/", pipelineHandler.Delete)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/export", pipelineHandler.Export)
+
+				r.With(pipelineRole(projects.RoleDeveloper)).Post("/runs", pipelineHandler.Run)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/runs", pipelineHandler.GetRuns)
+				r.With(pipelineRole(projects.RoleDeveloper)).Post("/simulate", pipelineHandler.Simulate)
+
+				r.With(pipelineRole(projects.RoleViewer)).Get("/versions", pipelineHandler.GetVersions)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/versions/{version}", pipelineHandler.GetVersion)
+				r.With(pipelineRole(projects.RoleDeveloper)).Post("/versions/{version}/rollback", pipelineHandler.Rollback)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/diff", pipelineHandler.DiffVersions)
+
+				r.With(pipelineRole(projects.RoleDeveloper)).Post("/schedules", pipelineHandler.CreateSchedule)
+				r.With(pipelineRole(projects.RoleViewer)).Get("/schedules", pipelineHandler.GetSchedules)
+
+				// The webhook settings hold its secret
+				r.With(pipelineRole(projects.RoleDeveloper)).Get("/webhook/settings", pipelineHandler.GetWebhook)
+				r.With(pipelineRole(projects.RoleDeveloper)).Patch("/webhook/settings", pipelineHandler.UpdateWebhook)
+				r.With(pipelineRole(projects.RoleDeveloper)).Post("/webhook/rotate-secret", pipelineHandler.RotateWebhookSecret)
+			})
 		})
 		r.Route("/devices/{deviceID}", func(r chi.Router) {
-			r.Get("/", deviceHandler.Get)
-			r.Patch("/", deviceHandler.Update)
-			r.Delete("/", deviceHandler.Delete)
+			r.Use(auth)
+			r.With(deviceRole(projects.RoleViewer)).Get("/", deviceHandler.Get)
+			r.With(deviceRole(projects.RoleDeveloper)).Patch("/", deviceHandler.Update)
+			r.With(deviceRole(projects.RoleDeveloper)).Delete("/", deviceHandler.Delete)
+
+			r.With(deviceRole(projects.RoleDeveloper)).Post("/rotate-key", deviceHandler.RotateKey)
+			r.With(deviceRole(projects.RoleDeveloper)).Post("/revoke", deviceHandler.RevokeKey)
+			r.With(deviceRole(projects.RoleDeveloper)).Get("/key-events", deviceHandler.GetKeyEvents)
+
+			r.With(deviceRole(projects.RoleViewer)).Get("/shadow", shadowHandler.Get)
+			r.With(deviceRole(projects.RoleDeveloper)).Patch("/shadow", shadowHandler.UpdateDesired)
 
-			r.Post("/endpoints", endpointHandler.Create)
-			r.Get("/endpoints", endpointHandler.GetbyDeviceID)
+			r.With(deviceRole(projects.RoleViewer)).Get("/telemetry", telemetryHandler.Get)
 
-			r.HandleFunc("/invoke/{pattern}", grpcHandler.InvokeDevice)
+			r.With(deviceRole(projects.RoleDeveloper)).Post("/endpoints", endpointHandler.Create)
+			r.With(deviceRole(projects.RoleViewer)).Get("/endpoints", endpointHandler.GetbyDeviceID)
+
+			r.With(middleware.Invocation, deviceRole(projects.RoleDeveloper)).HandleFunc("/invoke/{pattern}", grpcHandler.InvokeDevice)
+			r.With(deviceRole(projects.RoleViewer)).Get("/invocations", invocationHandler.GetByDeviceID)
+		})
+
+		r.Route("/invocations/{invocationID}", func(r chi.Router) {
+			r.Use(auth)
+			r.With(invocationRole(projects.RoleViewer)).Get("/", invocationHandler.Get)
+		})
+
+		r.Route("/runs/{runID}", func(r chi.Router) {
+			r.Use(auth)
+			r.With(runRole(projects.RoleViewer)).Get("/", pipelineHandler.GetRun)
+		})
+
+		r.Route("/schedules/{scheduleID}", func(r chi.Router) {
+			r.Use(auth)
+			r.With(scheduleRole(projects.RoleViewer)).Get("/", pipelineHandler.GetSchedule)
+			r.With(scheduleRole(projects.RoleDeveloper)).Patch("/", pipelineHandler.UpdateSchedule)
+			r.With(scheduleRole(projects.RoleDeveloper)).Delete("/", pipelineHandler.DeleteSchedule)
 		})
 
 		r.Route("/endpoints/{endpointID}", func(r chi.Router) {
-			r.Get("/", endpointHandler.Get)
-			r.Patch("/", endpointHandler.Update)
-			r.Delete("/", endpointHandler.Delete)
+			r.Use(auth)
+			r.With(endpointRole(projects.RoleViewer)).Get("/", endpointHandler.Get)
+			r.With(endpointRole(projects.RoleDeveloper)).Patch("/", endpointHandler.Update)
+			r.With(endpointRole(projects.RoleDeveloper)).Delete("/", endpointHandler.Delete)
 		})
 	})
 
 	log.Println("Server running...")
 	http.ListenAndServe(":8080", r)
 }
+
+// serveRegistry serves the device registry to the tunnel managers holding token
+func serveRegistry(addr string, token string, registry protobuf.DeviceRegistryServer) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed listening for tunnel managers (error: %v)", err)
+	}
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(tunnels.RegistryAuth(token)))
+	protobuf.RegisterDeviceRegistryServer(server, registry)
+	log.Printf("Device registry running on %s...", addr)
+	if err = server.Serve(lis); err != nil {
+		log.Fatalf("Device registry stopped (error: %v)", err)
+	}
+}
+
+// serveTelemetry serves telemetry ingestion to devices
+func serveTelemetry(addr string, ingest telemetryProtobuf.TelemetryIngestServer) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed listening for telemetry (error: %v)", err)
+	}
+
+	server := grpc.NewServer()
+	telemetryProtobuf.RegisterTelemetryIngestServer(server, ingest)
+	log.Printf("Telemetry ingestion running on %s...", addr)
+	if err = server.Serve(lis); err != nil {
+		log.Fatalf("Telemetry ingestion stopped (error: %v)", err)
+	}
+}
+
+// durationFromEnv parses a duration (e.g. "720h") from the environment
+// and falls back to def if the variable is not set
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid duration in %s (error: %v)", key, err)
+	}
+
+	return d
+}
+
+// intFromEnv parses an integer from the environment
+// and falls back to def if the variable is not set
+func intFromEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid integer in %s (error: %v)", key, err)
+	}
+
+	return n
+}
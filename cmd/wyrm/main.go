@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/tnynlabs/wyrm/pkg/storage/postgres"
+)
+
+const usage = `Usage:
+    wyrm migrate up [-dry-run]
+    wyrm migrate down [-dry-run] [-steps n]
+    wyrm migrate status`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "migrate" {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if devFlag := os.Getenv("ENV_FILE"); devFlag == "1" {
+		// Load environment variables from .env file
+		err := godotenv.Load(".env")
+		if err != nil {
+			log.Fatalf("Error loading .env file (error: %v)", err)
+		}
+	}
+
+	cmd := os.Args[2]
+	flags := flag.NewFlagSet("migrate "+cmd, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Print pending SQL without executing it")
+	steps := flags.Int("steps", 1, "Number of migrations to revert")
+	flags.Parse(os.Args[3:])
+
+	db, err := postgres.GetFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading db instance (error: %v)", err)
+	}
+
+	migrator, err := postgres.CreateMigrator(db, os.Stdout)
+	if err != nil {
+		log.Fatalf("Error loading migrations (error: %v)", err)
+	}
+	migrator.DryRun = *dryRun
+
+	switch cmd {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down(*steps)
+	case "status":
+		err = printStatus(migrator)
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Error running migrations (error: %v)", err)
+	}
+}
+
+func printStatus(migrator *postgres.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, appliedAt)
+	}
+
+	return nil
+}
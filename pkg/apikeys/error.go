@@ -0,0 +1,10 @@
+package apikeys
+
+import "github.com/tnynlabs/wyrm/pkg/utils"
+
+const (
+	InvalidInputCode   = utils.ServiceErrCode("INVALID_INPUT")
+	InvalidAPIKeyCode  = utils.ServiceErrCode("INVALID_API_KEY")
+	APIKeyNotFoundCode = utils.ServiceErrCode("API_KEY_NOT_FOUND")
+	ForbiddenCode      = utils.ServiceErrCode("FORBIDDEN")
+)
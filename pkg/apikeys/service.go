@@ -0,0 +1,253 @@
+package apikeys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// KeyPrefix every API key starts with it (tells API keys and session tokens apart)
+	KeyPrefix = "wyrm_"
+
+	// displayPrefixLength how much of the key is stored in clear to identify it
+	displayPrefixLength = len(KeyPrefix) + 6
+
+	// lastUsedResolution avoids a write on every authenticated request
+	lastUsedResolution = time.Minute
+)
+
+// Scope what an API key is allowed to do in its project
+type Scope string
+
+const (
+	// ScopeRead read only access
+	ScopeRead = Scope("read")
+	// ScopeInvoke read access and invoking devices (nothing can be modified)
+	ScopeInvoke = Scope("invoke")
+	// ScopeAdmin everything a project admin can do
+	ScopeAdmin = Scope("admin")
+)
+
+// scopeRoles the highest project role a key with the scope can act as
+var scopeRoles = map[Scope]projects.Role{
+	ScopeRead:   projects.RoleViewer,
+	ScopeInvoke: projects.RoleViewer,
+	ScopeAdmin:  projects.RoleAdmin,
+}
+
+// IsValid reports if the scope is a known one
+func (s Scope) IsValid() bool {
+	_, ok := scopeRoles[s]
+	return ok
+}
+
+// Role returns the highest project role a key with the scope can act as
+// (outside of invocations, see Key.Allows)
+func (s Scope) Role() projects.Role {
+	return scopeRoles[s]
+}
+
+// requiredRole the role a user needs in the project to create a key with the scope
+func (s Scope) requiredRole() projects.Role {
+	if s == ScopeInvoke {
+		return projects.RoleDeveloper
+	}
+	return s.Role()
+}
+
+// Key an API key of a user restricted to a single project
+// Note: only the hash of the key is stored
+type Key struct {
+	ID         int64
+	UserID     int64
+	ProjectID  int64
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scope      Scope
+	CreatedAt  time.Time
+	ExpiresAt  time.Time // zero value means the key never expires
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+
+	// Key is the plain API key, only set right after creation
+	Key string
+}
+
+// IsActive reports if the key was not revoked and did not expire
+func (k *Key) IsActive(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// Allows reports if the key can act with the required role in the project.
+// invocation tells whether the action is an invocation (e.g. invoking a device endpoint)
+// which keys with the invoke (or admin) scope are allowed to perform.
+func (k *Key) Allows(projectID int64, required projects.Role, invocation bool) bool {
+	if k.ProjectID != projectID {
+		return false
+	}
+	if invocation && k.Scope == ScopeInvoke {
+		return projects.RoleDeveloper.Includes(required)
+	}
+	return k.Scope.Role().Includes(required)
+}
+
+// Repository defines the apikeys.Repository operations
+type Repository interface {
+	GetByID(keyID int64) (*Key, error)
+	GetByHash(keyHash string) (*Key, error)
+	// GetByUserID returns non revoked keys of the user (including expired ones)
+	GetByUserID(userID int64) ([]Key, error)
+	Create(k Key) (*Key, error)
+	UpdateLastUsed(keyID int64, lastUsedAt time.Time) error
+	Revoke(keyID int64, revokedAt time.Time) error
+}
+
+// Service defines the apikeys.Service operations
+type Service interface {
+	// Create mints a new key for the user (the returned key has Key set).
+	// The user needs at least the role the scope grants in the project.
+	Create(userID int64, k Key) (*Key, error)
+	// Authenticate validates a plain key and marks it as used
+	Authenticate(key string) (*Key, error)
+	GetByUserID(userID int64) ([]Key, error)
+	// Revoke revokes a key of the given user
+	Revoke(userID int64, keyID int64) error
+}
+
+type service struct {
+	apiKeyRepo     Repository
+	projectService projects.Service
+}
+
+// CreateService Create new instance of API Key Service
+func CreateService(repo Repository, projectService projects.Service) Service {
+	return &service{repo, projectService}
+}
+
+// IsAPIKey reports if the token looks like an API key (rather than a session token)
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix)
+}
+
+func (s *service) Create(userID int64, k Key) (*Key, error) {
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Name is required",
+		}
+	}
+	if !k.Scope.IsValid() {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid scope (should be read, invoke or admin)",
+		}
+	}
+
+	now := time.Now()
+	if !k.ExpiresAt.IsZero() && !k.ExpiresAt.After(now) {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Expiry should be in the future",
+		}
+	}
+
+	resource := projects.Resource{Type: projects.ProjectResource, ID: k.ProjectID}
+	_, err := s.projectService.Authorize(userID, resource, k.Scope.requiredRole())
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Not allowed to create keys with this scope in this project",
+		}
+	}
+
+	key := KeyPrefix + utils.GenString(32)
+	apiKey, err := s.apiKeyRepo.Create(Key{
+		UserID:    userID,
+		ProjectID: k.ProjectID,
+		Name:      k.Name,
+		Prefix:    key[:displayPrefixLength],
+		KeyHash:   hashKey(key),
+		Scope:     k.Scope,
+		CreatedAt: now,
+		ExpiresAt: k.ExpiresAt,
+	})
+	if err != nil {
+		log.Printf("Failed creating API key (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating API key",
+		}
+	}
+	apiKey.Key = key
+
+	return apiKey, nil
+}
+
+func (s *service) Authenticate(key string) (*Key, error) {
+	now := time.Now()
+	apiKey, err := s.apiKeyRepo.GetByHash(hashKey(key))
+	if err != nil || !apiKey.IsActive(now) {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidAPIKeyCode,
+			Message: "Invalid, revoked or expired API key",
+		}
+	}
+
+	if now.Sub(apiKey.LastUsedAt) >= lastUsedResolution {
+		err = s.apiKeyRepo.UpdateLastUsed(apiKey.ID, now)
+		if err != nil {
+			log.Printf("Failed updating API key %d (error: %v)", apiKey.ID, err)
+		} else {
+			apiKey.LastUsedAt = now
+		}
+	}
+
+	return apiKey, nil
+}
+
+func (s *service) GetByUserID(userID int64) ([]Key, error) {
+	keys, err := s.apiKeyRepo.GetByUserID(userID)
+	if err != nil {
+		log.Printf("Failed listing API keys (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed listing API keys",
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *service) Revoke(userID int64, keyID int64) error {
+	apiKey, err := s.apiKeyRepo.GetByID(keyID)
+	if err != nil || apiKey.UserID != userID {
+		return &utils.ServiceErr{
+			Code:    APIKeyNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	err = s.apiKeyRepo.Revoke(keyID, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    APIKeyNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return nil
+}
+
+// hashKey keys are random (high entropy) so a fast hash is enough
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
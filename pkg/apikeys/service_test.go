@@ -0,0 +1,181 @@
+package apikeys_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+func TestKeyAllows(t *testing.T) {
+	tests := []struct {
+		scope      apikeys.Scope
+		required   projects.Role
+		invocation bool
+		want       bool
+	}{
+		{apikeys.ScopeRead, projects.RoleViewer, false, true},
+		{apikeys.ScopeRead, projects.RoleDeveloper, false, false},
+		{apikeys.ScopeRead, projects.RoleDeveloper, true, false},
+		{apikeys.ScopeInvoke, projects.RoleViewer, false, true},
+		{apikeys.ScopeInvoke, projects.RoleDeveloper, false, false},
+		{apikeys.ScopeInvoke, projects.RoleDeveloper, true, true},
+		{apikeys.ScopeInvoke, projects.RoleAdmin, true, false},
+		{apikeys.ScopeAdmin, projects.RoleAdmin, false, true},
+		{apikeys.ScopeAdmin, projects.RoleDeveloper, true, true},
+		{apikeys.ScopeAdmin, projects.RoleOwner, false, false},
+	}
+	for _, tt := range tests {
+		k := apikeys.Key{ProjectID: 1, Scope: tt.scope}
+		if got := k.Allows(1, tt.required, tt.invocation); got != tt.want {
+			t.Errorf("%s key Allows(%s, invocation=%v) = %v, want %v", tt.scope, tt.required, tt.invocation, got, tt.want)
+		}
+	}
+
+	k := apikeys.Key{ProjectID: 1, Scope: apikeys.ScopeAdmin}
+	if k.Allows(2, projects.RoleViewer, false) {
+		t.Error("key allowed in another project")
+	}
+}
+
+func TestKeyIsActive(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		key  apikeys.Key
+		want bool
+	}{
+		{"no expiry", apikeys.Key{}, true},
+		{"not expired", apikeys.Key{ExpiresAt: now.Add(time.Hour)}, true},
+		{"expired", apikeys.Key{ExpiresAt: now}, false},
+		{"revoked", apikeys.Key{RevokedAt: now.Add(-time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.key.IsActive(now); got != tt.want {
+			t.Errorf("IsActive(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fixture a project owned by owner with a collaborator per role
+type fixture struct {
+	keys      apikeys.Service
+	keyRepo   apikeys.Repository
+	projectID int64
+	userIDs   map[projects.Role]int64
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db := memory.CreateDB()
+	projectService := projects.CreateService(memory.CreateProjectRepository(db), memory.CreateInvitationRepository(db))
+	keyRepo := memory.CreateAPIKeyRepository(db)
+
+	projectID, userIDs := memtest.CreateProject(t, db, projectService)
+	return &fixture{
+		keys:      apikeys.CreateService(keyRepo, projectService),
+		keyRepo:   keyRepo,
+		projectID: projectID,
+		userIDs:   userIDs,
+	}
+}
+
+func TestCreateRequiresRole(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		role  projects.Role
+		scope apikeys.Scope
+		code  utils.ServiceErrCode
+	}{
+		{projects.RoleViewer, apikeys.ScopeRead, ""},
+		{projects.RoleViewer, apikeys.ScopeInvoke, apikeys.ForbiddenCode},
+		{projects.RoleDeveloper, apikeys.ScopeInvoke, ""},
+		{projects.RoleDeveloper, apikeys.ScopeAdmin, apikeys.ForbiddenCode},
+		{projects.RoleAdmin, apikeys.ScopeAdmin, ""},
+		{projects.RoleOwner, apikeys.ScopeAdmin, ""},
+		{projects.RoleOwner, apikeys.Scope("owner"), apikeys.InvalidInputCode},
+	}
+	for _, tt := range tests {
+		_, err := f.keys.Create(f.userIDs[tt.role], apikeys.Key{ProjectID: f.projectID, Name: "key", Scope: tt.scope})
+		if code := memtest.ErrCode(err); code != tt.code {
+			t.Errorf("%s creating a %s key: got error code %q, want %q (error: %v)", tt.role, tt.scope, code, tt.code, err)
+		}
+	}
+
+	_, err := f.keys.Create(f.userIDs[projects.RoleOwner], apikeys.Key{ProjectID: f.projectID + 1, Name: "key", Scope: apikeys.ScopeRead})
+	if code := memtest.ErrCode(err); code != apikeys.ForbiddenCode {
+		t.Errorf("creating a key in another project: got error code %q, want %q", code, apikeys.ForbiddenCode)
+	}
+	_, err = f.keys.Create(f.userIDs[projects.RoleOwner], apikeys.Key{
+		ProjectID: f.projectID,
+		Name:      "key",
+		Scope:     apikeys.ScopeRead,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if code := memtest.ErrCode(err); code != apikeys.InvalidInputCode {
+		t.Errorf("creating an expired key: got error code %q, want %q", code, apikeys.InvalidInputCode)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	f := newFixture(t)
+	ownerID := f.userIDs[projects.RoleOwner]
+
+	created, err := f.keys.Create(ownerID, apikeys.Key{ProjectID: f.projectID, Name: "key", Scope: apikeys.ScopeInvoke})
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+	if !apikeys.IsAPIKey(created.Key) || strings.Contains(created.KeyHash, created.Key) {
+		t.Fatalf("unexpected key %q (hash %q)", created.Key, created.KeyHash)
+	}
+
+	k, err := f.keys.Authenticate(created.Key)
+	if err != nil {
+		t.Fatalf("authenticating: %v", err)
+	}
+	if k.ID != created.ID || k.Scope != apikeys.ScopeInvoke || k.LastUsedAt.IsZero() {
+		t.Errorf("authenticated key = %+v, want key %d marked as used", k, created.ID)
+	}
+
+	if _, err := f.keys.Authenticate(created.Key + "x"); memtest.ErrCode(err) != apikeys.InvalidAPIKeyCode {
+		t.Errorf("authenticating an unknown key: got error %v, want %s", err, apikeys.InvalidAPIKeyCode)
+	}
+
+	// Only the owner of the key can revoke it
+	if err := f.keys.Revoke(f.userIDs[projects.RoleAdmin], created.ID); memtest.ErrCode(err) != apikeys.APIKeyNotFoundCode {
+		t.Errorf("revoking the key of another user: got error %v, want %s", err, apikeys.APIKeyNotFoundCode)
+	}
+	if err := f.keys.Revoke(ownerID, created.ID); err != nil {
+		t.Fatalf("revoking key: %v", err)
+	}
+	if _, err := f.keys.Authenticate(created.Key); memtest.ErrCode(err) != apikeys.InvalidAPIKeyCode {
+		t.Errorf("authenticating a revoked key: got error %v, want %s", err, apikeys.InvalidAPIKeyCode)
+	}
+
+	// Keys can not be created already expired, store one directly
+	expired := apikeys.KeyPrefix + "expiredexpiredexpiredexpired"
+	hash := sha256.Sum256([]byte(expired))
+	_, err = f.keyRepo.Create(apikeys.Key{
+		UserID:    ownerID,
+		ProjectID: f.projectID,
+		Name:      "expired",
+		KeyHash:   hex.EncodeToString(hash[:]),
+		Scope:     apikeys.ScopeRead,
+		CreatedAt: time.Now().Add(-time.Hour),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("storing key: %v", err)
+	}
+	if _, err := f.keys.Authenticate(expired); memtest.ErrCode(err) != apikeys.InvalidAPIKeyCode {
+		t.Errorf("authenticating an expired key: got error %v, want %s", err, apikeys.InvalidAPIKeyCode)
+	}
+}
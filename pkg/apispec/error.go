@@ -0,0 +1,7 @@
+package apispec
+
+import "github.com/tnynlabs/wyrm/pkg/utils"
+
+const (
+	ProjectNotFoundCode = utils.ServiceErrCode("PROJECT_NOT_FOUND")
+)
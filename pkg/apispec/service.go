@@ -0,0 +1,301 @@
+package apispec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// OpenAPIVersion version of the generated documents (3.1 embeds
+// JSON Schema 2020-12 so endpoint schemas can be used as is)
+const OpenAPIVersion = "3.1.0"
+
+type Service interface {
+	// Generate builds an OpenAPI document describing the invocation of every
+	// endpoint registered by devices of the project. serverURL is the base URL
+	// of the wyrm API (e.g. https://wyrm.io/api/v1).
+	Generate(projectID int64, serverURL string) (*Document, error)
+}
+
+type service struct {
+	projectService  projects.Service
+	deviceService   devices.Service
+	endpointService endpoints.Service
+}
+
+func CreateService(pService projects.Service, dService devices.Service, eService endpoints.Service) Service {
+	return &service{pService, dService, eService}
+}
+
+func (s *service) Generate(projectID int64, serverURL string) (*Document, error) {
+	project, err := s.projectService.GetByID(projectID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid project ID",
+		}
+	}
+
+	projectDevices, err := s.deviceService.GetByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(projectDevices, func(i, j int) bool {
+		return projectDevices[i].ID < projectDevices[j].ID
+	})
+
+	doc := &Document{
+		OpenAPI: OpenAPIVersion,
+		Info: Info{
+			Title:       project.DisplayName,
+			Description: project.Description,
+			Version:     "1.0.0",
+		},
+		Servers:    []Server{{URL: serverURL}},
+		Paths:      make(map[string]PathItem),
+		Components: baseComponents(),
+		Security: []map[string][]string{
+			{"ApiKeyAuth": {}},
+			{"BearerAuth": {}},
+		},
+	}
+
+	operationIDs := make(map[string]bool)
+	tags := make(map[string]bool)
+	for _, d := range projectDevices {
+		deviceEndpoints, err := s.endpointService.GetbyDeviceID(d.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		tag := deviceTag(tags, d)
+		doc.Tags = append(doc.Tags, Tag{Name: tag, Description: d.Description})
+
+		for _, ep := range deviceEndpoints {
+			path := fmt.Sprintf("/devices/%d/invoke/%s", d.ID, url.PathEscape(ep.Pattern))
+			operationID := uniqueOperationID(operationIDs, d, ep)
+
+			method := strings.ToLower(ep.Method)
+			if method == "" || method == endpoints.AnyMethod {
+				// Any method is accepted, payloads are usually POSTed
+				method = "post"
+			}
+
+			op, err := endpointOperation(doc, operationID, tag, method, ep)
+			if err != nil {
+				return nil, err
+			}
+
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = PathItem{}
+			}
+			doc.Paths[path][method] = op
+		}
+	}
+
+	return doc, nil
+}
+
+// endpointOperation describes invoking ep, the endpoint schemas
+// are added to the document components
+func endpointOperation(doc *Document, operationID string, tag string, method string, ep endpoints.Endpoint) (*Operation, error) {
+	op := &Operation{
+		OperationID: operationID,
+		Summary:     ep.DisplayName,
+		Description: ep.Description,
+		Tags:        []string{tag},
+		Parameters: []Parameter{{
+			Name:        "async",
+			In:          "query",
+			Description: "Queue the invocation and reply right away (poll it using GET /invocations/{invocation_id})",
+			Schema:      json.RawMessage(`{"type":"boolean"}`),
+		}},
+		Responses: map[string]*Response{
+			"202": {Ref: "#/components/responses/Accepted"},
+			"400": {Ref: "#/components/responses/Error"},
+			"404": {Ref: "#/components/responses/Error"},
+			"405": {Ref: "#/components/responses/Error"},
+			"502": {Ref: "#/components/responses/Error"},
+			"503": {Ref: "#/components/responses/Error"},
+			"504": {Ref: "#/components/responses/Error"},
+		},
+	}
+
+	// The device response is forwarded as a string
+	responseSchema := map[string]interface{}{"type": "string"}
+	if ep.ResponseSchema != "" {
+		schemaName := operationID + "_response"
+		schema, err := componentSchema(schemaName, ep.ResponseSchema)
+		if err != nil {
+			return nil, err
+		}
+		doc.Components.Schemas[schemaName] = schema
+
+		responseSchema["contentMediaType"] = "application/json"
+		responseSchema["contentSchema"] = ref("schemas", schemaName)
+	}
+	op.Responses["200"] = &Response{
+		Description: "Device response",
+		Content: map[string]MediaType{
+			"application/json": {Schema: resultSchema(map[string]interface{}{
+				"response": responseSchema,
+			})},
+		},
+	}
+
+	if ep.RequestSchema != "" {
+		schemaName := operationID + "_request"
+		schema, err := componentSchema(schemaName, ep.RequestSchema)
+		if err != nil {
+			return nil, err
+		}
+		doc.Components.Schemas[schemaName] = schema
+
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: ref("schemas", schemaName)},
+			},
+		}
+	} else if method != "get" && method != "delete" {
+		// No contract, the payload is forwarded as is
+		op.RequestBody = &RequestBody{
+			Content: map[string]MediaType{
+				"text/plain": {Schema: map[string]interface{}{"type": "string"}},
+			},
+		}
+	}
+
+	return op, nil
+}
+
+// componentSchema decodes an endpoint schema to be embedded in the document.
+// Schemas get an $id (if they do not have one) so that their internal
+// references (e.g. "#/definitions/...") keep resolving against the schema
+// instead of the whole document.
+func componentSchema(name string, source string) (interface{}, error) {
+	var schema interface{}
+	err := json.Unmarshal([]byte(source), &schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if object, ok := schema.(map[string]interface{}); ok {
+		if _, ok := object["$id"]; !ok {
+			object["$id"] = "urn:wyrm:schema:" + name
+		}
+	}
+
+	return schema, nil
+}
+
+// resultSchema schema of the wyrm response envelope
+func resultSchema(result map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"result": map[string]interface{}{
+				"type":       "object",
+				"properties": result,
+			},
+			"error": map[string]interface{}{"type": "null"},
+		},
+	}
+}
+
+func baseComponents() Components {
+	errorSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"result": map[string]interface{}{"type": "null"},
+			"error": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"code":    map[string]interface{}{"type": "string"},
+					"message": map[string]interface{}{"type": "string"},
+					"details": map[string]interface{}{},
+				},
+				"required": []string{"code", "message"},
+			},
+		},
+	}
+
+	invocationSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":     map[string]interface{}{"type": "integer"},
+			"status": map[string]interface{}{"type": "string"},
+		},
+	}
+
+	return Components{
+		Schemas: map[string]interface{}{
+			"Error":      errorSchema,
+			"Invocation": invocationSchema,
+		},
+		Responses: map[string]*Response{
+			"Error": {
+				Description: "Invocation failed (see the error code)",
+				Content: map[string]MediaType{
+					"application/json": {Schema: ref("schemas", "Error")},
+				},
+			},
+			"Accepted": {
+				Description: "Invocation queued (async=true)",
+				Content: map[string]MediaType{
+					"application/json": {Schema: resultSchema(map[string]interface{}{
+						"invocation": ref("schemas", "Invocation"),
+					})},
+				},
+			},
+		},
+		SecuritySchemes: map[string]SecurityScheme{
+			"ApiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			"BearerAuth": {Type: "http", Scheme: "bearer"},
+		},
+	}
+}
+
+var nonIdentifierRegex = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// identifier turns s into a snake case identifier usable by client generators
+func identifier(s string) string {
+	return strings.Trim(strings.ToLower(nonIdentifierRegex.ReplaceAllString(s, "_")), "_")
+}
+
+// deviceTag names the group of operations of the device
+// (suffixed with the device ID if the name is already taken)
+func deviceTag(used map[string]bool, d devices.Device) string {
+	tag := d.DisplayName
+	if tag == "" || used[tag] {
+		tag = strings.TrimSpace(fmt.Sprintf("%s #%d", tag, d.ID))
+	}
+	used[tag] = true
+
+	return tag
+}
+
+// uniqueOperationID derives the operation ID from the device and endpoint
+// names (device names are not unique so the device ID is the fallback)
+func uniqueOperationID(used map[string]bool, d devices.Device, ep endpoints.Endpoint) string {
+	deviceName := identifier(d.DisplayName)
+	if deviceName == "" {
+		deviceName = fmt.Sprintf("device_%d", d.ID)
+	}
+
+	operationID := deviceName + "_" + identifier(ep.Pattern)
+	if used[operationID] {
+		operationID = fmt.Sprintf("%s_%d", operationID, ep.ID)
+	}
+	used[operationID] = true
+
+	return operationID
+}
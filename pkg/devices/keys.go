@@ -0,0 +1,117 @@
+package devices
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// MaxKeyGracePeriod longest time an old key can keep working after a rotation
+const MaxKeyGracePeriod = time.Hour * 24 * 7
+
+// KeyAction kind of change made to a device key
+type KeyAction string
+
+const (
+	KeyRotated = KeyAction("rotated")
+	KeyRevoked = KeyAction("revoked")
+)
+
+// KeyEvent an entry of the device key audit trail
+type KeyEvent struct {
+	ID          int64
+	DeviceID    int64
+	Action      KeyAction
+	ActorID     int64 // zero value if the user was deleted
+	GracePeriod time.Duration
+	CreatedAt   time.Time
+}
+
+func (s *service) RotateKey(deviceID int64, actorID int64, gracePeriod time.Duration) (*Device, error) {
+	if gracePeriod < 0 || gracePeriod > MaxKeyGracePeriod {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Grace period should be between 0 and 7 days",
+		}
+	}
+
+	now := time.Now()
+	previousKeyExpiresAt := time.Time{}
+	if gracePeriod > 0 {
+		previousKeyExpiresAt = now.Add(gracePeriod)
+	}
+
+	device, err := s.deviceRepo.RotateKey(deviceID, utils.GenString(64), previousKeyExpiresAt)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	s.recordKeyEvent(KeyEvent{
+		DeviceID:    deviceID,
+		Action:      KeyRotated,
+		ActorID:     actorID,
+		GracePeriod: gracePeriod,
+		CreatedAt:   now,
+	})
+	s.notifyTunnels(deviceID, gracePeriod)
+	s.publish(events.DeviceUpdated, device)
+
+	return device, nil
+}
+
+func (s *service) RevokeKey(deviceID int64, actorID int64) (*Device, error) {
+	now := time.Now()
+	device, err := s.deviceRepo.RevokeKey(deviceID, now)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	s.recordKeyEvent(KeyEvent{
+		DeviceID:  deviceID,
+		Action:    KeyRevoked,
+		ActorID:   actorID,
+		CreatedAt: now,
+	})
+	s.notifyTunnels(deviceID, 0)
+	s.publish(events.DeviceUpdated, device)
+
+	return device, nil
+}
+
+func (s *service) GetKeyEvents(deviceID int64) ([]KeyEvent, error) {
+	events, err := s.deviceRepo.GetKeyEvents(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	return events, nil
+}
+
+// recordKeyEvent the key change already happened so failures are only logged
+func (s *service) recordKeyEvent(e KeyEvent) {
+	_, err := s.deviceRepo.CreateKeyEvent(e)
+	if err != nil {
+		log.Printf("Failed recording %s key event of device %d (error: %v)", e.Action, e.DeviceID, err)
+	}
+}
+
+// notifyTunnels the key change already happened so failures are only logged
+// (an unreachable tunnel manager can not hold live tunnels either way)
+func (s *service) notifyTunnels(deviceID int64, gracePeriod time.Duration) {
+	err := s.tunnelNotifier.RevokeDevice(context.Background(), deviceID, gracePeriod)
+	if err != nil {
+		log.Printf("Failed notifying tunnel manager about device %d (error: %v)", deviceID, err)
+	}
+}
@@ -0,0 +1,163 @@
+package devices_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+// revocations records the key changes notified to the tunnel manager
+type revocations struct {
+	gracePeriods []time.Duration
+}
+
+func (r *revocations) RevokeDevice(ctx context.Context, deviceID int64, gracePeriod time.Duration) error {
+	r.gracePeriods = append(r.gracePeriods, gracePeriod)
+	return nil
+}
+
+// newDevice returns the service, its repository and a device with its
+// key (actorID is the user who created the project of the device)
+func newDevice(t *testing.T) (devices.Service, devices.Repository, *revocations, *devices.Device, int64) {
+	t.Helper()
+	db := memory.CreateDB()
+	repo := memory.CreateDeviceRepository(db)
+	notifier := &revocations{}
+	service := devices.CreateDeviceService(repo, notifier, events.CreateBus())
+
+	user, err := memory.CreateUserRepository(db).Create(users.User{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	project, err := memory.CreateProjectRepository(db).Create(projects.Project{DisplayName: "project", CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	device, err := service.Create(devices.Device{ProjectID: project.ID, DisplayName: "device"})
+	if err != nil {
+		t.Fatalf("creating device: %v", err)
+	}
+	return service, repo, notifier, device, user.ID
+}
+
+func TestRotateKeyGracePeriod(t *testing.T) {
+	service, repo, notifier, device, actorID := newDevice(t)
+	oldKey := device.AuthKey
+
+	if _, err := service.RotateKey(device.ID, actorID, devices.MaxKeyGracePeriod+time.Second); memtest.ErrCode(err) != devices.InvalidInputCode {
+		t.Errorf("rotating with a too long grace period: got error %v, want %s", err, devices.InvalidInputCode)
+	}
+
+	rotated, err := service.RotateKey(device.ID, actorID, time.Hour)
+	if err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	if rotated.AuthKey == oldKey || rotated.PreviousKeyExpiresAt.IsZero() {
+		t.Fatalf("rotated device = %+v, want a new key and an expiring previous key", rotated)
+	}
+
+	for _, key := range []string{rotated.AuthKey, oldKey} {
+		if d, err := service.GetByKey(key); err != nil || d.ID != device.ID {
+			t.Errorf("GetByKey during the grace period = %v (error: %v), want device %d", d, err, device.ID)
+		}
+	}
+	expiresAt := rotated.PreviousKeyExpiresAt
+	if _, err := repo.GetByKey(oldKey, expiresAt.Add(-time.Second)); err != nil {
+		t.Errorf("previous key rejected before it expires: %v", err)
+	}
+	if _, err := repo.GetByKey(oldKey, expiresAt); err == nil {
+		t.Error("previous key accepted once expired")
+	}
+	if _, err := repo.GetByKey(rotated.AuthKey, expiresAt); err != nil {
+		t.Errorf("current key rejected after the grace period: %v", err)
+	}
+
+	// Rotating without grace period drops the previous key right away
+	if _, err := service.RotateKey(device.ID, actorID, 0); err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	for _, key := range []string{oldKey, rotated.AuthKey} {
+		if _, err := service.GetByKey(key); memtest.ErrCode(err) != devices.DeviceNotFoundCode {
+			t.Errorf("GetByKey with a replaced key: got error %v, want %s", err, devices.DeviceNotFoundCode)
+		}
+	}
+
+	if want := []time.Duration{time.Hour, 0}; len(notifier.gracePeriods) != 2 || notifier.gracePeriods[0] != want[0] || notifier.gracePeriods[1] != want[1] {
+		t.Errorf("notified grace periods %v, want %v", notifier.gracePeriods, want)
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	service, _, _, device, actorID := newDevice(t)
+	oldKey := device.AuthKey
+
+	rotated, err := service.RotateKey(device.ID, actorID, time.Hour)
+	if err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	if _, err := service.RevokeKey(device.ID, actorID); err != nil {
+		t.Fatalf("revoking key: %v", err)
+	}
+
+	// Revoking also ends the grace period of the previous key
+	for _, key := range []string{rotated.AuthKey, oldKey} {
+		if _, err := service.GetByKey(key); memtest.ErrCode(err) != devices.DeviceNotFoundCode {
+			t.Errorf("GetByKey with a revoked key: got error %v, want %s", err, devices.DeviceNotFoundCode)
+		}
+	}
+
+	// A new key works again, the revoked key never becomes the previous key
+	restored, err := service.RotateKey(device.ID, actorID, time.Hour)
+	if err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	if _, err := service.GetByKey(restored.AuthKey); err != nil {
+		t.Errorf("GetByKey with the key replacing a revoked key: %v", err)
+	}
+	if _, err := service.GetByKey(rotated.AuthKey); err == nil {
+		t.Error("revoked key accepted after a rotation")
+	}
+
+	if _, err := service.RevokeKey(device.ID+1, actorID); memtest.ErrCode(err) != devices.DeviceNotFoundCode {
+		t.Errorf("revoking the key of a missing device: got error %v, want %s", err, devices.DeviceNotFoundCode)
+	}
+}
+
+func TestKeyEvents(t *testing.T) {
+	service, _, _, device, actorID := newDevice(t)
+
+	if _, err := service.RotateKey(device.ID, actorID, time.Hour); err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+	if _, err := service.RevokeKey(device.ID, actorID); err != nil {
+		t.Fatalf("revoking key: %v", err)
+	}
+	if _, err := service.RotateKey(device.ID, actorID, 0); err != nil {
+		t.Fatalf("rotating key: %v", err)
+	}
+
+	keyEvents, err := service.GetKeyEvents(device.ID)
+	if err != nil {
+		t.Fatalf("getting key events: %v", err)
+	}
+	want := []devices.KeyEvent{
+		{Action: devices.KeyRotated, GracePeriod: 0},
+		{Action: devices.KeyRevoked},
+		{Action: devices.KeyRotated, GracePeriod: time.Hour},
+	}
+	if len(keyEvents) != len(want) {
+		t.Fatalf("got %d key events, want %d", len(keyEvents), len(want))
+	}
+	for i, e := range keyEvents {
+		if e.DeviceID != device.ID || e.ActorID != actorID || e.Action != want[i].Action || e.GracePeriod != want[i].GracePeriod || e.CreatedAt.IsZero() {
+			t.Errorf("key event %d = %+v, want %s with grace period %v by user %d", i, e, want[i].Action, want[i].GracePeriod, actorID)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package devices
+
+import (
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// Status connectivity of a device as reported by the tunnel manager
+type Status string
+
+const (
+	StatusOnline  = Status("online")
+	StatusOffline = Status("offline")
+)
+
+// IsValid reports if the status is a known one
+func (s Status) IsValid() bool {
+	return s == StatusOnline || s == StatusOffline
+}
+
+// Connection a live tunnel of a device as reported by the tunnel manager
+type Connection struct {
+	// ID unique ID of the tunnel (events of older tunnels are ignored)
+	ID         string
+	ManagerID  string
+	RemoteAddr string
+	Metadata   map[string]string
+}
+
+func (s *service) SetOnline(deviceID int64, conn Connection) error {
+	if conn.ID == "" {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Connection ID is required",
+		}
+	}
+
+	_, err := s.deviceRepo.SetOnline(deviceID, conn, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) SetOffline(deviceID int64, connectionID string) error {
+	err := s.deviceRepo.SetOffline(deviceID, connectionID, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) Heartbeat(deviceID int64, connectionID string) error {
+	err := s.deviceRepo.Heartbeat(deviceID, connectionID, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) SetManagerOffline(managerID string) error {
+	err := s.deviceRepo.SetManagerOffline(managerID, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed updating devices of the tunnel manager",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) GetByProjectIDAndStatus(projectID int64, status Status) ([]Device, error) {
+	if !status.IsValid() {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid status (should be online or offline)",
+		}
+	}
+
+	projectDevices, err := s.GetByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []Device{}
+	for _, d := range projectDevices {
+		if d.Status == status {
+			filtered = append(filtered, d)
+		}
+	}
+
+	return filtered, nil
+}
@@ -1,8 +1,10 @@
 package devices
 
 import (
+	"context"
 	"time"
 
+	"github.com/tnynlabs/wyrm/pkg/events"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 )
 
@@ -21,16 +23,47 @@ type Device struct {
 
 	// Note: Never show in output
 	AuthKey string
+
+	// Key rotation and revocation state (only changed by RotateKey/RevokeKey)
+	// Note: the previous key keeps working until PreviousKeyExpiresAt
+	PreviousAuthKey      string
+	PreviousKeyExpiresAt time.Time
+	KeyRevokedAt         time.Time
+
+	// Presence state (only changed by the tunnel manager reports)
+	Status      Status
+	LastSeenAt  time.Time
+	ConnectedAt time.Time
+	Connection  Connection
 }
 
 //Defines devices.Repository for Storage Implementation
 type Repository interface {
 	GetByID(deviceID int64) (*Device, error)
-	GetByKey(authKey string) (*Device, error)
+	// GetByKey matches the current key (unless revoked) or the previous key (until it expires)
+	GetByKey(authKey string, now time.Time) (*Device, error)
 	Create(d Device) (*Device, error)
 	Update(deviceID int64, d Device) (*Device, error)
 	Delete(deviceID int64) error
 	GetByProjectID(projectID int64) ([]Device, error)
+
+	// RotateKey replaces the key of the device (clearing any revocation). The current key
+	// becomes the previous key until previousKeyExpiresAt (zero value drops it right away)
+	RotateKey(deviceID int64, newKey string, previousKeyExpiresAt time.Time) (*Device, error)
+	// RevokeKey disables the current and previous keys of the device
+	RevokeKey(deviceID int64, revokedAt time.Time) (*Device, error)
+	CreateKeyEvent(e KeyEvent) (*KeyEvent, error)
+	// GetKeyEvents returns the key audit trail of the device (latest first)
+	GetKeyEvents(deviceID int64) ([]KeyEvent, error)
+
+	// SetOnline marks the device as connected through conn
+	SetOnline(deviceID int64, conn Connection, now time.Time) (*Device, error)
+	// SetOffline marks the device as disconnected (only if connectionID is its current connection)
+	SetOffline(deviceID int64, connectionID string, now time.Time) error
+	// Heartbeat updates the last seen time (only if connectionID is its current connection)
+	Heartbeat(deviceID int64, connectionID string, now time.Time) error
+	// SetManagerOffline marks devices connected through the tunnel manager as disconnected
+	SetManagerOffline(managerID string, now time.Time) error
 }
 
 type Service interface {
@@ -40,14 +73,39 @@ type Service interface {
 	Update(deviceID int64, d Device) (*Device, error)
 	Delete(deviceID int64) error
 	GetByProjectID(projectID int64) ([]Device, error)
+
+	// RotateKey generates a new key for the device. The old key keeps
+	// working (and live tunnels using it stay up) for gracePeriod
+	RotateKey(deviceID int64, actorID int64, gracePeriod time.Duration) (*Device, error)
+	// RevokeKey disables the device key and drops live tunnels of the device
+	// (a new key can be generated using RotateKey)
+	RevokeKey(deviceID int64, actorID int64) (*Device, error)
+	GetKeyEvents(deviceID int64) ([]KeyEvent, error)
+
+	// SetOnline, SetOffline, Heartbeat and SetManagerOffline record
+	// connectivity reports of the tunnel manager
+	SetOnline(deviceID int64, conn Connection) error
+	SetOffline(deviceID int64, connectionID string) error
+	Heartbeat(deviceID int64, connectionID string) error
+	SetManagerOffline(managerID string) error
+	GetByProjectIDAndStatus(projectID int64, status Status) ([]Device, error)
+}
+
+// TunnelNotifier notifies the tunnel manager about device key changes
+type TunnelNotifier interface {
+	// RevokeDevice drops live tunnels of the device after gracePeriod
+	RevokeDevice(ctx context.Context, deviceID int64, gracePeriod time.Duration) error
 }
 
 type service struct {
-	deviceRepo Repository
+	deviceRepo     Repository
+	tunnelNotifier TunnelNotifier
+	bus            events.Bus
 }
 
-func CreateDeviceService(deviceRepo Repository) Service {
-	return &service{deviceRepo}
+// CreateDeviceService device changes are published to bus
+func CreateDeviceService(deviceRepo Repository, tunnelNotifier TunnelNotifier, bus events.Bus) Service {
+	return &service{deviceRepo, tunnelNotifier, bus}
 }
 
 func (s *service) GetByID(deviceID int64) (*Device, error) {
@@ -63,7 +121,7 @@ func (s *service) GetByID(deviceID int64) (*Device, error) {
 }
 
 func (s *service) GetByKey(authKey string) (*Device, error) {
-	device, err := s.deviceRepo.GetByKey(authKey)
+	device, err := s.deviceRepo.GetByKey(authKey, time.Now())
 	if err != nil {
 		return nil, &utils.ServiceErr{
 			Code: DeviceNotFoundCode,
@@ -83,6 +141,7 @@ func (s *service) Create(d Device) (*Device, error) {
 			Message: "Invalid input",
 		}
 	}
+	s.publish(events.DeviceCreated, device)
 
 	return device, nil
 }
@@ -95,18 +154,23 @@ func (s *service) Update(deviceID int64, d Device) (*Device, error) {
 			Message: "Invalid input",
 		}
 	}
+	s.publish(events.DeviceUpdated, device)
 
 	return device, nil
 }
 
 func (s *service) Delete(deviceID int64) error {
-	err := s.deviceRepo.Delete(deviceID)
+	device, err := s.deviceRepo.GetByID(deviceID)
+	if err == nil {
+		err = s.deviceRepo.Delete(deviceID)
+	}
 	if err != nil {
 		return &utils.ServiceErr{
 			Code:    DeviceNotFoundCode,
 			Message: "Invalid Device ID",
 		}
 	}
+	s.publish(events.DeviceDeleted, device)
 
 	return nil
 }
@@ -122,3 +186,14 @@ func (s *service) GetByProjectID(projectID int64) ([]Device, error) {
 
 	return devices, nil
 }
+
+// publish publishes a change of the device (with its id only, as the
+// device holds its auth key)
+func (s *service) publish(t events.Type, d *Device) {
+	s.bus.Publish(events.Event{
+		Type:      t,
+		ProjectID: d.ProjectID,
+		DeviceID:  d.ID,
+		Data:      events.ResourceData(d.ID),
+	})
+}
@@ -6,4 +6,7 @@ const (
 	DeviceNotFoundCode   = utils.ServiceErrCode("DEVICE_NOT_FOUND")
 	EndpointNotFoundCode = utils.ServiceErrCode("ENDPOINT_NOT_FOUND")
 	InvalidInputCode     = utils.ServiceErrCode("INVALID_INPUT")
+	MethodNotAllowedCode = utils.ServiceErrCode("METHOD_NOT_ALLOWED")
+	ValidationFailedCode = utils.ServiceErrCode("VALIDATION_FAILED")
+	InvalidResponseCode  = utils.ServiceErrCode("INVALID_RESPONSE")
 )
@@ -0,0 +1,172 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// AnyMethod allows invoking the endpoint using any HTTP method
+const AnyMethod = "*"
+
+var allowedMethods = map[string]bool{
+	AnyMethod: true,
+	"GET":     true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+}
+
+// ValidationIssue a single violation of an endpoint schema
+type ValidationIssue struct {
+	// Location JSON pointer to the invalid value ("" is the whole document)
+	Location string `json:"location"`
+	// SchemaLocation JSON pointer to the violated schema keyword
+	SchemaLocation string `json:"schema_location"`
+	Message        string `json:"message"`
+}
+
+// schemaCache compiled schemas keyed by their source, schemas are
+// compiled once instead of on every invocation
+type schemaCache struct {
+	mu      sync.RWMutex
+	schemas map[string]*jsonschema.Schema
+}
+
+func (c *schemaCache) get(source string) (*jsonschema.Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[source]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := compileSchema(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.schemas[source] = schema
+	c.mu.Unlock()
+
+	return schema, nil
+}
+
+// schemaURL base URL schemas are compiled with (only
+// used for error messages and resolving relative $ref)
+const schemaURL = "mem://endpoint/schema.json"
+
+func compileSchema(source string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	// Schemas are user provided, never resolve $ref to files or remote URLs
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("Loading %s is not allowed", s)
+	}
+
+	err := compiler.AddResource(schemaURL, strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+
+	return compiler.Compile(schemaURL)
+}
+
+func validateContract(ep Endpoint) error {
+	if ep.Method != "" && !allowedMethods[ep.Method] {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Method should be one of GET, POST, PUT, PATCH, DELETE or *",
+		}
+	}
+
+	if ep.RequestSchema != "" {
+		if _, err := compileSchema(ep.RequestSchema); err != nil {
+			return &utils.ServiceErr{
+				Code:    InvalidInputCode,
+				Message: fmt.Sprintf("Invalid request schema (%s)", err),
+			}
+		}
+	}
+
+	if ep.ResponseSchema != "" {
+		if _, err := compileSchema(ep.ResponseSchema); err != nil {
+			return &utils.ServiceErr{
+				Code:    InvalidInputCode,
+				Message: fmt.Sprintf("Invalid response schema (%s)", err),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateDocument validates data against the schema source and
+// returns the violations found (nil if data is valid)
+func (c *schemaCache) validateDocument(source string, data string) ([]ValidationIssue, error) {
+	schema, err := c.get(source)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	err = decoder.Decode(&doc)
+	if err == nil && decoder.More() {
+		err = errors.New("Unexpected data after the JSON document")
+	}
+	if err != nil {
+		return []ValidationIssue{{Message: "Invalid JSON"}}, nil
+	}
+
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil, nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	return toValidationIssues(validationErr), nil
+}
+
+// toValidationIssues flattens the validation error tree into
+// its leaves (the actual violations)
+func toValidationIssues(validationErr *jsonschema.ValidationError) []ValidationIssue {
+	issues := []ValidationIssue{}
+
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			issues = append(issues, ValidationIssue{
+				Location:       e.InstanceLocation,
+				SchemaLocation: e.KeywordLocation,
+				Message:        e.Message,
+			})
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationErr)
+
+	return issues
+}
+
+// normalizeMethod upper cases the method so that methods are
+// stored the same way net/http reports them
+func normalizeMethod(method string) string {
+	return strings.ToUpper(strings.TrimSpace(method))
+}
@@ -1,8 +1,13 @@
 package endpoints
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/tunnels"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 )
 
@@ -18,6 +23,30 @@ type Endpoint struct {
 	Description string
 	DisplayName string
 	Pattern     string
+	// Timeout max time to wait for the device to respond
+	// to invocations (zero means the default timeout)
+	Timeout time.Duration
+	// Method HTTP method allowed to invoke the endpoint
+	// (empty or AnyMethod allows all methods)
+	Method string
+	// RequestSchema JSON Schema invocation payloads must conform to
+	// (empty disables validation)
+	RequestSchema string
+	// ResponseSchema JSON Schema device responses must conform to
+	// (empty disables validation)
+	ResponseSchema string
+}
+
+// MaxTimeout max invocation timeout of an endpoint
+const MaxTimeout = 5 * time.Minute
+
+// InvokeTimeout max time to wait for the device to respond to an
+// invocation of the endpoint (its own timeout, else the default one)
+func (ep Endpoint) InvokeTimeout() time.Duration {
+	if ep.Timeout != 0 {
+		return ep.Timeout
+	}
+	return tunnels.DefaultInvokeTimeout
 }
 
 type Repository interface {
@@ -26,6 +55,7 @@ type Repository interface {
 	Delete(endpointID int64) error
 	Update(endpointID int64, ep Endpoint) (*Endpoint, error)
 	GetbyDeviceID(deviceID int64) ([]Endpoint, error)
+	GetByPattern(deviceID int64, pattern string) (*Endpoint, error)
 }
 
 type Service interface {
@@ -34,17 +64,40 @@ type Service interface {
 	Delete(endpointID int64) error
 	Update(endpointID int64, ep Endpoint) (*Endpoint, error)
 	GetbyDeviceID(deviceID int64) ([]Endpoint, error)
+	// GetByPattern returns the endpoint of the device registered with pattern
+	GetByPattern(deviceID int64, pattern string) (*Endpoint, error)
+	// ValidateRequest checks an invocation of the endpoint against its
+	// method restriction and request schema
+	ValidateRequest(ep Endpoint, method string, data string) error
+	// ValidateResponse checks a device response against the endpoint
+	// response schema
+	ValidateResponse(ep Endpoint, data string) error
 }
 
 type service struct {
-	endpointRepo Repository
+	endpointRepo  Repository
+	schemas       *schemaCache
+	deviceService devices.Service
+	bus           events.Bus
 }
 
-func CreateEndpointService(endpointRepo Repository) Service {
-	return &service{endpointRepo}
+// CreateEndpointService endpoint changes are published to bus (in the
+// project of the endpoint device)
+func CreateEndpointService(endpointRepo Repository, deviceService devices.Service, bus events.Bus) Service {
+	schemas := &schemaCache{schemas: make(map[string]*jsonschema.Schema)}
+	return &service{endpointRepo, schemas, deviceService, bus}
 }
 
 func (s *service) Create(ep Endpoint) (*Endpoint, error) {
+	if ep.Timeout < 0 || ep.Timeout > MaxTimeout {
+		return nil, invalidTimeoutErr
+	}
+
+	ep.Method = normalizeMethod(ep.Method)
+	if err := validateContract(ep); err != nil {
+		return nil, err
+	}
+
 	endpoint, err := s.endpointRepo.Create(ep)
 	if err != nil {
 		return nil, &utils.ServiceErr{
@@ -52,6 +105,7 @@ func (s *service) Create(ep Endpoint) (*Endpoint, error) {
 			Message: "Invalid input",
 		}
 	}
+	s.publish(events.EndpointCreated, endpoint)
 
 	return endpoint, nil
 }
@@ -69,18 +123,31 @@ func (s *service) GetByID(endpointID int64) (*Endpoint, error) {
 }
 
 func (s *service) Delete(endpointID int64) error {
-	err := s.endpointRepo.Delete(endpointID)
+	endpoint, err := s.endpointRepo.GetByID(endpointID)
+	if err == nil {
+		err = s.endpointRepo.Delete(endpointID)
+	}
 	if err != nil {
 		return &utils.ServiceErr{
 			Code:    EndpointNotFoundCode,
 			Message: "Invalid ID",
 		}
 	}
+	s.publish(events.EndpointDeleted, endpoint)
 
 	return nil
 }
 
 func (s *service) Update(endpointID int64, ep Endpoint) (*Endpoint, error) {
+	if ep.Timeout < 0 || ep.Timeout > MaxTimeout {
+		return nil, invalidTimeoutErr
+	}
+
+	ep.Method = normalizeMethod(ep.Method)
+	if err := validateContract(ep); err != nil {
+		return nil, err
+	}
+
 	endpoint, err := s.endpointRepo.Update(endpointID, ep)
 	if err != nil {
 		return nil, &utils.ServiceErr{
@@ -88,10 +155,27 @@ func (s *service) Update(endpointID int64, ep Endpoint) (*Endpoint, error) {
 			Message: "Invalid ID",
 		}
 	}
+	s.publish(events.EndpointUpdated, endpoint)
 
 	return endpoint, nil
 }
 
+// publish publishes a change of the endpoint to the project of its device
+func (s *service) publish(t events.Type, ep *Endpoint) {
+	device, err := s.deviceService.GetByID(ep.DeviceID)
+	if err != nil {
+		return
+	}
+
+	s.bus.Publish(events.Event{
+		Type:      t,
+		ProjectID: device.ProjectID,
+		DeviceID:  device.ID,
+		Pattern:   ep.Pattern,
+		Data:      events.ResourceData(ep.ID),
+	})
+}
+
 func (s *service) GetbyDeviceID(deviceID int64) ([]Endpoint, error) {
 	endpoints, err := s.endpointRepo.GetbyDeviceID(deviceID)
 	if err != nil {
@@ -103,3 +187,73 @@ func (s *service) GetbyDeviceID(deviceID int64) ([]Endpoint, error) {
 
 	return endpoints, nil
 }
+
+func (s *service) GetByPattern(deviceID int64, pattern string) (*Endpoint, error) {
+	endpoint, err := s.endpointRepo.GetByPattern(deviceID, pattern)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    EndpointNotFoundCode,
+			Message: "No endpoint registered with this pattern",
+		}
+	}
+
+	return endpoint, nil
+}
+
+func (s *service) ValidateRequest(ep Endpoint, method string, data string) error {
+	if ep.Method != "" && ep.Method != AnyMethod && ep.Method != method {
+		return &utils.ServiceErr{
+			Code:    MethodNotAllowedCode,
+			Message: fmt.Sprintf("Endpoint only accepts %s requests", ep.Method),
+		}
+	}
+
+	if ep.RequestSchema == "" {
+		return nil
+	}
+
+	issues, err := s.schemas.validateDocument(ep.RequestSchema, data)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "An unexpected error occurred",
+		}
+	}
+	if issues != nil {
+		return &utils.ServiceErr{
+			Code:    ValidationFailedCode,
+			Message: "Payload does not match the endpoint request schema",
+			Details: issues,
+		}
+	}
+
+	return nil
+}
+
+func (s *service) ValidateResponse(ep Endpoint, data string) error {
+	if ep.ResponseSchema == "" {
+		return nil
+	}
+
+	issues, err := s.schemas.validateDocument(ep.ResponseSchema, data)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "An unexpected error occurred",
+		}
+	}
+	if issues != nil {
+		return &utils.ServiceErr{
+			Code:    InvalidResponseCode,
+			Message: "Device response does not match the endpoint response schema",
+			Details: issues,
+		}
+	}
+
+	return nil
+}
+
+var invalidTimeoutErr = &utils.ServiceErr{
+	Code:    InvalidInputCode,
+	Message: "Timeout should be between 0 and 5 minutes",
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer number of events a subscriber can fall behind
+// before new events are dropped for it
+const subscriberBuffer = 4096
+
+// Type kind of event
+type Type string
+
+const (
+	// DeviceConnected a tunnel of the device was opened
+	DeviceConnected = Type("device.connected")
+	// DeviceDisconnected the tunnel of the device was closed
+	DeviceDisconnected = Type("device.disconnected")
+	// DeviceMessage the device sent a message on a pattern (on its own,
+	// not as the response of an invocation)
+	DeviceMessage = Type("device.message")
+	// TelemetryReceived a telemetry datapoint of the device was stored
+	TelemetryReceived = Type("telemetry")
+
+	// Resource changes, their data only holds the id of the resource
+	// (clients fetch the resource from the API if needed)
+
+	DeviceCreated   = Type("device.created")
+	DeviceUpdated   = Type("device.updated")
+	DeviceDeleted   = Type("device.deleted")
+	EndpointCreated = Type("endpoint.created")
+	EndpointUpdated = Type("endpoint.updated")
+	EndpointDeleted = Type("endpoint.deleted")
+	PipelineCreated = Type("pipeline.created")
+	PipelineUpdated = Type("pipeline.updated")
+	PipelineDeleted = Type("pipeline.deleted")
+
+	// InvocationCompleted an asynchronous invocation of the device
+	// succeeded or failed (data is the invocation)
+	InvocationCompleted = Type("invocation.completed")
+
+	// RunStarted a pipeline run started (data is the run)
+	RunStarted = Type("run.started")
+	// RunNode a node of a pipeline run finished (data is the node result)
+	RunNode = Type("run.node")
+	// RunCompleted a pipeline run succeeded or failed (data is the run)
+	RunCompleted = Type("run.completed")
+)
+
+// IsDeviceEvent reports if the type is a known device event type
+func (t Type) IsDeviceEvent() bool {
+	switch t {
+	case DeviceConnected, DeviceDisconnected, DeviceMessage, TelemetryReceived:
+		return true
+	}
+	return false
+}
+
+// Event something that happened in a project
+type Event struct {
+	Type      Type  `json:"type"`
+	ProjectID int64 `json:"project_id"`
+	DeviceID  int64 `json:"device_id,omitempty"`
+	// Pattern of device messages
+	Pattern string `json:"pattern,omitempty"`
+	// Data JSON encoded event data (e.g. the message or the datapoint)
+	Data json.RawMessage `json:"data,omitempty"`
+	// Truncated the data was dropped as it was too large
+	// to be delivered (e.g. across API replicas)
+	Truncated bool      `json:"truncated,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// RawData encodes data for Event.Data (data that is not valid JSON
+// is encoded as a JSON string)
+func RawData(data string) json.RawMessage {
+	if data == "" {
+		return nil
+	}
+	if json.Valid([]byte(data)) {
+		return json.RawMessage(data)
+	}
+
+	quoted, _ := json.Marshal(data)
+	return json.RawMessage(quoted)
+}
+
+// ResourceData encodes the data of resource change events
+func ResourceData(id int64) json.RawMessage {
+	data, _ := json.Marshal(map[string]int64{"id": id})
+	return data
+}
+
+// Bus publishes events to subscribers
+type Bus interface {
+	// Publish never blocks, events are dropped for subscribers that fall behind
+	Publish(e Event)
+	// Subscribe calls handler with every event published by this API
+	// replica (one event at a time, in publishing order) until
+	// unsubscribe is called
+	Subscribe(handler func(Event)) (unsubscribe func())
+	// SubscribeAll is Subscribe for the events published by any API replica
+	// (e.g. to stream them to clients, which may be connected to any replica)
+	SubscribeAll(handler func(Event)) (unsubscribe func())
+}
+
+type bus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan Event
+	nextID      int
+}
+
+// CreateBus Create new instance of an in process events.Bus
+// (for a single API replica, SubscribeAll is Subscribe)
+func CreateBus() Bus {
+	return &bus{subscribers: make(map[int]chan Event)}
+}
+
+func (b *bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for id, events := range b.subscribers {
+		select {
+		case events <- e:
+		default:
+			log.Printf("Dropped %s event of project %d (subscriber %d is behind)", e.Type, e.ProjectID, id)
+		}
+	}
+}
+
+func (b *bus) Subscribe(handler func(Event)) func() {
+	events := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = events
+	b.mu.Unlock()
+
+	go func() {
+		for e := range events {
+			handler(e)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			b.mu.Unlock()
+			close(events)
+		})
+	}
+}
+
+func (b *bus) SubscribeAll(handler func(Event)) func() {
+	return b.Subscribe(handler)
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// streamBuffer number of events a stream can fall behind before it is closed
+const streamBuffer = 256
+
+// Hub streams the events of the bus (published by any API replica)
+// to clients by project
+type Hub interface {
+	// Subscribe returns the events of the project until unsubscribe is
+	// called. The channel is closed if the client falls behind (clients
+	// are expected to reconnect and refetch the resources they show).
+	Subscribe(projectID int64) (stream <-chan Event, unsubscribe func())
+}
+
+type hub struct {
+	mu      sync.Mutex
+	streams map[int64]map[chan Event]struct{}
+}
+
+// CreateHub Create new instance of events.Hub, subscribed to the bus
+// for the lifetime of the process
+func CreateHub(bus Bus) Hub {
+	h := &hub{streams: make(map[int64]map[chan Event]struct{})}
+	bus.SubscribeAll(h.dispatch)
+
+	return h
+}
+
+func (h *hub) dispatch(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for stream := range h.streams[e.ProjectID] {
+		select {
+		case stream <- e:
+		default:
+			log.Printf("Closed an event stream of project %d (the client is behind)", e.ProjectID)
+			h.remove(e.ProjectID, stream)
+		}
+	}
+}
+
+func (h *hub) Subscribe(projectID int64) (<-chan Event, func()) {
+	stream := make(chan Event, streamBuffer)
+
+	h.mu.Lock()
+	if h.streams[projectID] == nil {
+		h.streams[projectID] = make(map[chan Event]struct{})
+	}
+	h.streams[projectID][stream] = struct{}{}
+	h.mu.Unlock()
+
+	return stream, func() {
+		h.mu.Lock()
+		h.remove(projectID, stream)
+		h.mu.Unlock()
+	}
+}
+
+// remove closes the stream unless it was removed already (h.mu is held)
+func (h *hub) remove(projectID int64, stream chan Event) {
+	streams := h.streams[projectID]
+	if _, ok := streams[stream]; !ok {
+		return
+	}
+
+	delete(streams, stream)
+	if len(streams) == 0 {
+		delete(h.streams, projectID)
+	}
+	close(stream)
+}
@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// APIKeyHandler rest handler for the API keys of the authenticated user
+type APIKeyHandler struct {
+	apiKeyService apikeys.Service
+}
+
+func CreateAPIKeyHandler(apiKeyService apikeys.Service) APIKeyHandler {
+	return APIKeyHandler{apiKeyService}
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	ProjectID int64      `json:"project_id"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Create mints a new API key (the key itself is only returned once)
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	req := createAPIKeyRequest{}
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	keyData := apikeys.Key{
+		Name:      req.Name,
+		ProjectID: req.ProjectID,
+		Scope:     apikeys.Scope(req.Scope),
+	}
+	if req.ExpiresAt != nil {
+		keyData.ExpiresAt = *req.ExpiresAt
+	}
+
+	apiKey, err := h.apiKeyService.Create(user.ID, keyData)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case apikeys.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case apikeys.ForbiddenCode:
+			SendError(w, r, *serviceErr, http.StatusForbidden)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"api_key": fromAPIKey(*apiKey),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// GetAll lists non revoked API keys of the authenticated user
+func (h *APIKeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	keys, err := h.apiKeyService.GetByUserID(user.ID)
+	if err != nil {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	restKeys := make([]*apiKeyRest, len(keys))
+	for i := 0; i < len(keys); i++ {
+		restKeys[i] = fromAPIKey(keys[i])
+	}
+
+	result := &map[string]interface{}{
+		"api_keys": restKeys,
+	}
+
+	SendResponse(w, r, result)
+}
+
+// Revoke revokes an API key of the authenticated user
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	err = h.apiKeyService.Revoke(user.ID, keyID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case apikeys.APIKeyNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+type apiKeyRest struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	ProjectID  int64      `json:"project_id"`
+	Scope      string     `json:"scope"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func fromAPIKey(k apikeys.Key) *apiKeyRest {
+	kRest := apiKeyRest{
+		ID:        k.ID,
+		Name:      k.Name,
+		ProjectID: k.ProjectID,
+		Scope:     string(k.Scope),
+		Prefix:    k.Prefix,
+		CreatedAt: k.CreatedAt,
+		Key:       k.Key,
+	}
+	if !k.ExpiresAt.IsZero() {
+		kRest.ExpiresAt = &k.ExpiresAt
+	}
+	if !k.LastUsedAt.IsZero() {
+		kRest.LastUsedAt = &k.LastUsedAt
+	}
+
+	return &kRest
+}
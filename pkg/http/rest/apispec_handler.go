@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/apispec"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type APISpecHandler struct {
+	apiSpecService apispec.Service
+}
+
+func CreateAPISpecHandler(aService apispec.Service) APISpecHandler {
+	return APISpecHandler{aService}
+}
+
+// Get replies with the OpenAPI document of the project devices endpoints
+// (the document itself, not wrapped in a result, so that client generators
+// can consume the URL directly)
+func (aHandler *APISpecHandler) Get(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	doc, err := aHandler.apiSpecService.Generate(projectID, apiBaseURL(r))
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case apispec.ProjectNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, doc)
+}
+
+// apiBaseURL rebuilds the URL the API is mounted at (e.g. https://wyrm.io/api/v1)
+// from a request to one of the /projects routes
+func apiBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	prefix := r.URL.Path
+	if i := strings.Index(prefix, "/projects/"); i >= 0 {
+		prefix = prefix[:i]
+	}
+
+	return scheme + "://" + r.Host + prefix
+}
@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AuthCookieName name of the cookie holding the session token
+const AuthCookieName = "auth_key"
+
+// APIKeyHeader name of the request header that may hold an API key
+const APIKeyHeader = "X-API-Key"
+
+// DeviceKeyHeader name of the request header holding the device auth key
+// (used by routes called by devices, e.g. telemetry ingestion)
+const DeviceKeyHeader = "X-Device-Key"
+
+// AuthToken tries to retreive the auth token (session token or API key) from a
+// cookie named "auth_key" and falls back to the "Authorization" request header
+// then to the "X-API-Key" request header.
+// Example: "Authorization: BEARER <KEY>".
+func AuthToken(r *http.Request) string {
+	cookie, err := r.Cookie(AuthCookieName)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	bearer := r.Header.Get("Authorization")
+	if len(bearer) > 7 && strings.ToUpper(bearer[0:6]) == "BEARER" {
+		return bearer[7:]
+	}
+	return r.Header.Get(APIKeyHeader)
+}
+
+// SetAuthCookie stores the session token in an HttpOnly cookie
+func SetAuthCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearAuthCookie removes the session cookie from the client
+func ClearAuthCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookieName,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clientIP returns the remote address of the request without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,363 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type inviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type updateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type transferOwnershipRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+type invitationTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// GetCollaborators lists the collaborators of a project with their roles
+func (h *ProjectHandler) GetCollaborators(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	collaborators, err := h.projectService.GetCollaborators(projectID)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	restCollaborators := make([]*collaboratorRest, len(collaborators))
+	for i := 0; i < len(collaborators); i++ {
+		restCollaborators[i] = fromCollaborator(collaborators[i])
+	}
+
+	result := &map[string]interface{}{
+		"collaborators": restCollaborators,
+	}
+	SendResponse(w, r, result)
+}
+
+// Invite invites someone (registered or not) to join the project by email
+func (h *ProjectHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := inviteRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	authorization, authorized := r.Context().Value(AuthorizationCtxKey{}).(*projects.Authorization)
+	if !ok || !authorized {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	inv, err := h.projectService.Invite(user.ID, authorization.Role, projectID, req.Email, projects.Role(req.Role))
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invitation": fromInvitation(*inv),
+	}
+	SendResponse(w, r, result)
+}
+
+// UpdateRole changes the role of a collaborator
+func (h *ProjectHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := updateRoleRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	actor, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	authorization, authorized := r.Context().Value(AuthorizationCtxKey{}).(*projects.Authorization)
+	if !ok || !authorized {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	err = h.projectService.UpdateCollaboratorRole(actor.ID, authorization.Role, projectID, userID, projects.Role(req.Role))
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+// RemoveCollaborator removes a collaborator from the project (or lets a collaborator leave it)
+func (h *ProjectHandler) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	actor, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	authorization, authorized := r.Context().Value(AuthorizationCtxKey{}).(*projects.Authorization)
+	if !ok || !authorized {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	err = h.projectService.RemoveCollaborator(actor.ID, authorization.Role, projectID, userID)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+// TransferOwnership makes another collaborator the owner (the current owner becomes an admin)
+func (h *ProjectHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := transferOwnershipRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	actor, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	err = h.projectService.TransferOwnership(actor.ID, projectID, req.UserID)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+// GetInvitations lists pending invitations of a project
+func (h *ProjectHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	invitations, err := h.projectService.GetPendingInvitations(projectID)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invitations": fromInvitations(invitations),
+	}
+	SendResponse(w, r, result)
+}
+
+// RevokeInvitation cancels a pending invitation of a project
+func (h *ProjectHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	invitationID, err := strconv.ParseInt(chi.URLParam(r, "invitationID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	err = h.projectService.RevokeInvitation(projectID, invitationID)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+// GetUserInvitations lists pending invitations sent to the authenticated user's email
+func (h *ProjectHandler) GetUserInvitations(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	invitations, err := h.projectService.GetPendingInvitationsByEmail(user.Email)
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invitations": fromInvitations(invitations),
+	}
+	SendResponse(w, r, result)
+}
+
+// AcceptInvitation joins the invitation's project as the authenticated user
+func (h *ProjectHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	h.respondToInvitation(w, r, true)
+}
+
+// DeclineInvitation declines an invitation sent to the authenticated user
+func (h *ProjectHandler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	h.respondToInvitation(w, r, false)
+}
+
+func (h *ProjectHandler) respondToInvitation(w http.ResponseWriter, r *http.Request, accept bool) {
+	req := invitationTokenRequest{}
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	var inv *projects.Invitation
+	if accept {
+		inv, err = h.projectService.AcceptInvitation(user.ID, user.Email, req.Token)
+	} else {
+		inv, err = h.projectService.DeclineInvitation(user.Email, req.Token)
+	}
+	if err != nil {
+		sendCollaboratorErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invitation": fromInvitation(*inv),
+	}
+	SendResponse(w, r, result)
+}
+
+// sendCollaboratorErr maps collaborator/invitation service errors to http errors
+func sendCollaboratorErr(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case projects.InvalidInputCode, projects.DuplicateCollaboratorCode:
+		SendError(w, r, *serviceErr, http.StatusBadRequest)
+	case projects.ForbiddenCode:
+		SendError(w, r, *serviceErr, http.StatusForbidden)
+	case projects.ProjectNotFoundCode, projects.CollaboratorNotFoundCode, projects.InvitationNotFoundCode:
+		SendError(w, r, *serviceErr, http.StatusNotFound)
+	default:
+		SendUnexpectedErr(w, r)
+	}
+}
+
+type collaboratorRest struct {
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func fromCollaborator(c projects.Collaborator) *collaboratorRest {
+	return &collaboratorRest{
+		UserID:      c.UserID,
+		Name:        c.Name,
+		DisplayName: c.DisplayName,
+		Email:       c.Email,
+		Role:        string(c.Role),
+		CreatedAt:   c.CreatedAt,
+	}
+}
+
+type invitationRest struct {
+	ID          int64      `json:"id"`
+	ProjectID   int64      `json:"project_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	InvitedBy   int64      `json:"invited_by"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+	Token       string     `json:"token,omitempty"`
+}
+
+func fromInvitation(inv projects.Invitation) *invitationRest {
+	invRest := invitationRest{
+		ID:        inv.ID,
+		ProjectID: inv.ProjectID,
+		Email:     inv.Email,
+		Role:      string(inv.Role),
+		InvitedBy: inv.InvitedBy,
+		Status:    string(inv.Status),
+		CreatedAt: inv.CreatedAt,
+		ExpiresAt: inv.ExpiresAt,
+		Token:     inv.Token,
+	}
+	if !inv.RespondedAt.IsZero() {
+		invRest.RespondedAt = &inv.RespondedAt
+	}
+
+	return &invRest
+}
+
+func fromInvitations(invitations []projects.Invitation) []*invitationRest {
+	restInvitations := make([]*invitationRest, len(invitations))
+	for i := 0; i < len(invitations); i++ {
+		restInvitations[i] = fromInvitation(invitations[i])
+	}
+
+	return restInvitations
+}
@@ -4,3 +4,18 @@ package rest
 // instance in the request context if it exists.
 // Example: ctx.Value(UserCtxKey{}).
 type UserCtxKey struct{}
+
+// SessionCtxKey should be used to get/set the session used to
+// authenticate the request (if it exists).
+// Example: ctx.Value(SessionCtxKey{}).
+type SessionCtxKey struct{}
+
+// AuthorizationCtxKey should be used to get/set the result of the project
+// authorization check (*projects.Authorization) if it exists.
+// Example: ctx.Value(AuthorizationCtxKey{}).
+type AuthorizationCtxKey struct{}
+
+// APIKeyCtxKey should be used to get/set the API key used to
+// authenticate the request (if it exists).
+// Example: ctx.Value(APIKeyCtxKey{}).
+type APIKeyCtxKey struct{}
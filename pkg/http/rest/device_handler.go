@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/users"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 )
 
@@ -97,6 +99,9 @@ func (dHandler *DeviceHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Devices can not be moved across projects (authorization is project based)
+	deviceData.ProjectID = nil
+
 	//Only updatable fields are set in device object
 	device, err := dHandler.deviceService.Update(deviceID, toDevice(deviceData))
 	if err != nil {
@@ -148,15 +153,23 @@ func (dHandler *DeviceHandler) GetByProjectID(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	projectDevices, err := dHandler.deviceService.GetByProjectID(projectID)
+	var projectDevices []devices.Device
+	if status := r.URL.Query().Get("status"); status != "" {
+		projectDevices, err = dHandler.deviceService.GetByProjectIDAndStatus(projectID, devices.Status(status))
+	} else {
+		projectDevices, err = dHandler.deviceService.GetByProjectID(projectID)
+	}
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
+		case devices.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
 		case devices.ProjectNotFoundCode:
 			SendError(w, r, *serviceErr, http.StatusNotFound)
 		default:
 			SendUnexpectedErr(w, r)
 		}
+		return
 	}
 
 	restDevices := make([]deviceRest, len(projectDevices))
@@ -173,6 +186,135 @@ func (dHandler *DeviceHandler) GetByProjectID(w http.ResponseWriter, r *http.Req
 
 }
 
+type rotateKeyRequest struct {
+	GracePeriodSeconds int64 `json:"grace_period_seconds"`
+}
+
+// RotateKey generates a new device key (the body is optional, the old key stops working
+// right away unless a grace period is given)
+func (dHandler *DeviceHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := rotateKeyRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil && err != io.EOF {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	gracePeriod := time.Duration(req.GracePeriodSeconds) * time.Second
+	device, err := dHandler.deviceService.RotateKey(deviceID, user.ID, gracePeriod)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case devices.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case devices.DeviceNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"device": fromDevice(*device),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// RevokeKey disables the device key and drops its live tunnels
+func (dHandler *DeviceHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	device, err := dHandler.deviceService.RevokeKey(deviceID, user.ID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case devices.DeviceNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"device": fromDevice(*device),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// GetKeyEvents returns the key audit trail of the device
+func (dHandler *DeviceHandler) GetKeyEvents(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	events, err := dHandler.deviceService.GetKeyEvents(deviceID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case devices.DeviceNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	restEvents := make([]keyEventRest, len(events))
+	for i := 0; i < len(events); i++ {
+		restEvents[i] = keyEventRest{
+			ID:                 events[i].ID,
+			Action:             string(events[i].Action),
+			GracePeriodSeconds: int64(events[i].GracePeriod / time.Second),
+			CreatedAt:          events[i].CreatedAt,
+		}
+		if events[i].ActorID != 0 {
+			restEvents[i].ActorID = &events[i].ActorID
+		}
+	}
+
+	result := &map[string]interface{}{
+		"key_events": restEvents,
+	}
+
+	SendResponse(w, r, result)
+}
+
+type keyEventRest struct {
+	ID                 int64     `json:"id"`
+	Action             string    `json:"action"`
+	ActorID            *int64    `json:"actor_id,omitempty"`
+	GracePeriodSeconds int64     `json:"grace_period_seconds"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
 //Device Json Definition
 type deviceRest struct {
 	ID          *int64     `json:"id,omitempty"`
@@ -182,6 +324,20 @@ type deviceRest struct {
 	DisplayName *string    `json:"display_name,omitempty"`
 	AuthKey     *string    `json:"auth_key,omitempty"`
 	Description *string    `json:"description,omitempty"`
+
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+	KeyRevokedAt         *time.Time `json:"key_revoked_at,omitempty"`
+
+	Status      *string         `json:"status,omitempty"`
+	LastSeenAt  *time.Time      `json:"last_seen_at,omitempty"`
+	ConnectedAt *time.Time      `json:"connected_at,omitempty"`
+	Connection  *connectionRest `json:"connection,omitempty"`
+}
+
+// connectionRest metadata of the current (or last) tunnel of a device
+type connectionRest struct {
+	RemoteAddr string            `json:"remote_addr,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
 func toDevice(dRest deviceRest) devices.Device {
@@ -215,6 +371,25 @@ func fromDevice(d devices.Device) deviceRest {
 	if !d.UpdatedAt.IsZero() {
 		dRest.UpdatedAt = &d.UpdatedAt
 	}
+	if !d.PreviousKeyExpiresAt.IsZero() {
+		dRest.PreviousKeyExpiresAt = &d.PreviousKeyExpiresAt
+	}
+	if !d.KeyRevokedAt.IsZero() {
+		dRest.KeyRevokedAt = &d.KeyRevokedAt
+	}
+
+	status := string(d.Status)
+	dRest.Status = &status
+	if !d.LastSeenAt.IsZero() {
+		dRest.LastSeenAt = &d.LastSeenAt
+	}
+	if !d.ConnectedAt.IsZero() {
+		dRest.ConnectedAt = &d.ConnectedAt
+		dRest.Connection = &connectionRest{
+			RemoteAddr: d.Connection.RemoteAddr,
+			Metadata:   d.Connection.Metadata,
+		}
+	}
 
 	return dRest
 }
@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// Portable documents (pipeline bundles and project templates) are
+// exchanged as JSON or YAML
+
+// decodeDocument decodes a JSON or YAML request body into v
+// (unknown fields are rejected to catch typos in hand written documents)
+func decodeDocument(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML
+	data, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func sendInvalidDocumentErr(w http.ResponseWriter, r *http.Request, err error) {
+	SendError(w, r, utils.ServiceErr{
+		Code:    "INVALID_JSON",
+		Message: fmt.Sprintf("Invalid JSON or YAML document (%v)", err),
+	}, http.StatusBadRequest)
+}
+
+// sendDocument replies with the document itself (not wrapped in a result) as
+// YAML if the "format" query parameter is "yaml" (or YAML is accepted), as
+// JSON otherwise. name is the suggested file name (without extension).
+func sendDocument(w http.ResponseWriter, r *http.Request, v interface{}, name string) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "yaml") {
+			format = "yaml"
+		}
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".json"))
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			SendUnexpectedErr(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".yaml"))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	default:
+		SendError(w, r, utils.ServiceErr{
+			Code:    "INVALID_INPUT",
+			Message: "format should be json or yaml",
+		}, http.StatusBadRequest)
+	}
+}
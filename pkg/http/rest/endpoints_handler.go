@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -97,12 +98,17 @@ func (ep *EndpointHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Endpoints can not be moved across devices (authorization is project based)
+	endpointData.DeviceID = nil
+
 	endpoint, err := ep.endpointService.Update(endpointID, toEndpoint(endpointData))
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
 		case endpoints.InvalidInputCode:
 			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case endpoints.EndpointNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
 		default:
 			SendUnexpectedErr(w, r)
 		}
@@ -181,6 +187,11 @@ type endpointRest struct {
 	DisplayName *string    `json:"display_name,omitempty"`
 	Description *string    `json:"description,omitempty"`
 	Pattern     *string    `json:"pattern,omitempty"`
+	TimeoutMs   *int64     `json:"timeout_ms,omitempty"`
+	Method      *string    `json:"method,omitempty"`
+
+	RequestSchema  json.RawMessage `json:"request_schema,omitempty"`
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty"`
 }
 
 func toEndpoint(epRest endpointRest) endpoints.Endpoint {
@@ -202,6 +213,22 @@ func toEndpoint(epRest endpointRest) endpoints.Endpoint {
 		ep.Pattern = *epRest.Pattern
 	}
 
+	if epRest.TimeoutMs != nil {
+		ep.Timeout = time.Duration(*epRest.TimeoutMs) * time.Millisecond
+	}
+
+	if epRest.Method != nil {
+		ep.Method = *epRest.Method
+	}
+
+	if len(epRest.RequestSchema) != 0 && string(epRest.RequestSchema) != "null" {
+		ep.RequestSchema = string(epRest.RequestSchema)
+	}
+
+	if len(epRest.ResponseSchema) != 0 && string(epRest.ResponseSchema) != "null" {
+		ep.ResponseSchema = string(epRest.ResponseSchema)
+	}
+
 	return ep
 }
 
@@ -219,5 +246,22 @@ func fromEndpoint(ep endpoints.Endpoint) endpointRest {
 		epRest.UpdatedAt = &ep.UpdatedAt
 	}
 
+	if ep.Timeout != 0 {
+		timeoutMs := int64(ep.Timeout / time.Millisecond)
+		epRest.TimeoutMs = &timeoutMs
+	}
+
+	if ep.Method != "" {
+		epRest.Method = &ep.Method
+	}
+
+	if ep.RequestSchema != "" {
+		epRest.RequestSchema = json.RawMessage(ep.RequestSchema)
+	}
+
+	if ep.ResponseSchema != "" {
+		epRest.ResponseSchema = json.RawMessage(ep.ResponseSchema)
+	}
+
 	return epRest
 }
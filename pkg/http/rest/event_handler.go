@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+	"github.com/tnynlabs/wyrm/pkg/events"
+)
+
+const (
+	// heartbeatInterval how often idle streams are kept alive
+	// (SSE comments and WebSocket pings)
+	heartbeatInterval = 30 * time.Second
+	// pongWait how long a WebSocket client has to answer a ping
+	pongWait = heartbeatInterval + 10*time.Second
+	// writeWait max time to write a message to a WebSocket client
+	writeWait = 10 * time.Second
+)
+
+// eventUpgrader only accepts same origin WebSocket connections
+// (browsers authenticate them with the auth cookie)
+var eventUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+type EventHandler struct {
+	hub events.Hub
+}
+
+func CreateEventHandler(hub events.Hub) EventHandler {
+	return EventHandler{hub}
+}
+
+// Stream streams the events of the project over WebSocket (if the request
+// is a WebSocket upgrade) or Server-Sent Events. "types" optionally filters
+// the events by type, e.g. "device.*,run.completed".
+func (h *EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	filter := parseTypeFilter(r.URL.Query().Get("types"))
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.streamWebSocket(w, r, projectID, filter)
+		return
+	}
+	h.streamSSE(w, r, projectID, filter)
+}
+
+func (h *EventHandler) streamSSE(w http.ResponseWriter, r *http.Request, projectID int64, filter typeFilter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	stream, unsubscribe := h.hub.Subscribe(projectID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disables response buffering of reverse proxies (e.g. nginx)
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-stream:
+			if !ok {
+				// The client fell behind, EventSource clients reconnect on their own
+				return
+			}
+			if !filter.match(e.Type) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("Failed encoding %s event (error: %v)", e.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (h *EventHandler) streamWebSocket(w http.ResponseWriter, r *http.Request, projectID int64, filter typeFilter) {
+	// Upgrade replies with an error itself
+	conn, err := eventUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	stream, unsubscribe := h.hub.Subscribe(projectID)
+	defer unsubscribe()
+
+	// Clients do not send messages, reading detects closed
+	// connections and handles pongs
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-stream:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Client fell behind")
+				conn.WriteMessage(websocket.CloseMessage, message)
+				return
+			}
+			if !filter.match(e.Type) {
+				continue
+			}
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			if err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// typeFilter event types (or type prefixes ending with ".*")
+// a stream is limited to, nil matches all events
+type typeFilter []string
+
+func parseTypeFilter(types string) typeFilter {
+	var filter typeFilter
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter = append(filter, t)
+		}
+	}
+	return filter
+}
+
+func (f typeFilter) match(t events.Type) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, pattern := range f {
+		if strings.HasSuffix(pattern, ".*") {
+			if strings.HasPrefix(string(t), strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if string(t) == pattern {
+			return true
+		}
+	}
+	return false
+}
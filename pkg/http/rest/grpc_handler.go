@@ -1,21 +1,28 @@
 package rest
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
 	"github.com/tnynlabs/wyrm/pkg/tunnels"
+	"github.com/tnynlabs/wyrm/pkg/users"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 )
 
 type GrpcHandler struct {
-	httpGrpcService tunnels.Service
+	httpGrpcService   tunnels.Service
+	invocationService invocations.Service
+	endpointService   endpoints.Service
 }
 
-func CreateGrpcHandler(tService tunnels.Service) GrpcHandler {
-	return GrpcHandler{tService}
+func CreateGrpcHandler(tService tunnels.Service, iService invocations.Service, eService endpoints.Service) GrpcHandler {
+	return GrpcHandler{tService, iService, eService}
 }
 
 func (gHandler *GrpcHandler) InvokeDevice(w http.ResponseWriter, r *http.Request) {
@@ -35,21 +42,96 @@ func (gHandler *GrpcHandler) InvokeDevice(w http.ResponseWriter, r *http.Request
 
 	invokeRequest := string(body[:])
 
-	invokeResponse, err := gHandler.httpGrpcService.InvokeDevice(deviceID, pattern, invokeRequest)
+	endpoint, err := gHandler.endpointService.GetByPattern(deviceID, pattern)
+	if err != nil {
+		sendContractErr(w, r, err)
+		return
+	}
+
+	err = gHandler.endpointService.ValidateRequest(*endpoint, r.Method, invokeRequest)
+	if err != nil {
+		sendContractErr(w, r, err)
+		return
+	}
+
+	timeout := endpoint.InvokeTimeout()
+
+	if r.URL.Query().Get("async") == "true" {
+		gHandler.invokeAsync(w, r, deviceID, pattern, invokeRequest, timeout)
+		return
+	}
+
+	// Canceled when the device does not respond in time or the client disconnects
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	invokeResponse, err := gHandler.httpGrpcService.InvokeDevice(ctx, deviceID, pattern, invokeRequest)
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
 		case tunnels.ConnectionErrorCode:
 			SendError(w, r, *serviceErr, http.StatusBadGateway)
+		case tunnels.DeviceOfflineCode:
+			SendError(w, r, *serviceErr, http.StatusServiceUnavailable)
+		case tunnels.TimeoutCode:
+			SendError(w, r, *serviceErr, http.StatusGatewayTimeout)
+		case tunnels.CanceledCode:
+			// The client is gone, nobody reads the response
 		default:
 			SendUnexpectedErr(w, r)
 		}
 		return
 	}
 
+	err = gHandler.endpointService.ValidateResponse(*endpoint, invokeResponse.Data)
+	if err != nil {
+		sendContractErr(w, r, err)
+		return
+	}
+
 	result := &map[string]interface{}{
 		"response": invokeResponse.Data,
 	}
 
 	SendResponse(w, r, result)
 }
+
+// invokeAsync records the invocation and replies right away (202) with the
+// invocation, its status can then be polled using GET /invocations/{invocationID}
+func (gHandler *GrpcHandler) invokeAsync(w http.ResponseWriter, r *http.Request, deviceID int64, pattern string, data string, timeout time.Duration) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	inv, err := gHandler.invocationService.Enqueue(deviceID, pattern, data, timeout, user.ID)
+	if err != nil {
+		sendInvocationErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invocation": fromInvocation(*inv),
+	}
+
+	SendAccepted(w, r, result)
+}
+
+// sendContractErr replies with errors of looking up the invoked endpoint
+// and validating the invocation against its contract
+func sendContractErr(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case endpoints.EndpointNotFoundCode:
+		SendError(w, r, *serviceErr, http.StatusNotFound)
+	case endpoints.MethodNotAllowedCode:
+		SendError(w, r, *serviceErr, http.StatusMethodNotAllowed)
+	case endpoints.ValidationFailedCode:
+		SendError(w, r, *serviceErr, http.StatusBadRequest)
+	case endpoints.InvalidResponseCode:
+		SendError(w, r, *serviceErr, http.StatusBadGateway)
+	default:
+		SendUnexpectedErr(w, r)
+	}
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type InvocationHandler struct {
+	invocationService invocations.Service
+}
+
+func CreateInvocationHandler(iService invocations.Service) InvocationHandler {
+	return InvocationHandler{iService}
+}
+
+func (iHandler *InvocationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	invocationID, err := strconv.ParseInt(chi.URLParam(r, "invocationID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	inv, err := iHandler.invocationService.GetByID(invocationID)
+	if err != nil {
+		sendInvocationErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"invocation": fromInvocation(*inv),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// GetByDeviceID returns the invocation history of the device (latest first)
+// paginated with the "limit" and "offset" query parameters
+func (iHandler *InvocationHandler) GetByDeviceID(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	var limit, offset int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+	}
+	if value := r.URL.Query().Get("offset"); value != "" && err == nil {
+		offset, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		SendError(w, r, utils.ServiceErr{
+			Code:    invocations.InvalidInputCode,
+			Message: "limit and offset should be integers",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	deviceInvocations, err := iHandler.invocationService.GetByDeviceID(deviceID, limit, offset)
+	if err != nil {
+		sendInvocationErr(w, r, err)
+		return
+	}
+
+	restInvocations := make([]invocationRest, len(deviceInvocations))
+	for i := 0; i < len(deviceInvocations); i++ {
+		restInvocations[i] = fromInvocation(deviceInvocations[i])
+	}
+
+	result := &map[string]interface{}{
+		"invocations": restInvocations,
+	}
+
+	SendResponse(w, r, result)
+}
+
+func sendInvocationErr(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case invocations.InvalidInputCode:
+		SendError(w, r, *serviceErr, http.StatusBadRequest)
+	case invocations.DeviceNotFoundCode, invocations.InvocationNotFoundCode:
+		SendError(w, r, *serviceErr, http.StatusNotFound)
+	default:
+		SendUnexpectedErr(w, r)
+	}
+}
+
+type invocationRest struct {
+	ID            int64      `json:"id"`
+	DeviceID      int64      `json:"device_id"`
+	CreatedBy     *int64     `json:"created_by,omitempty"`
+	Pattern       string     `json:"pattern"`
+	Request       string     `json:"request"`
+	TimeoutMs     *int64     `json:"timeout_ms,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	Response      *string    `json:"response,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+func fromInvocation(inv invocations.Invocation) invocationRest {
+	iRest := invocationRest{
+		ID:        inv.ID,
+		DeviceID:  inv.DeviceID,
+		Pattern:   inv.Pattern,
+		Request:   inv.Request,
+		Status:    string(inv.Status),
+		Attempts:  inv.Attempts,
+		CreatedAt: inv.CreatedAt,
+	}
+	if inv.CreatedBy != 0 {
+		iRest.CreatedBy = &inv.CreatedBy
+	}
+	if inv.Timeout != 0 {
+		timeoutMs := int64(inv.Timeout / time.Millisecond)
+		iRest.TimeoutMs = &timeoutMs
+	}
+	if inv.Status == invocations.StatusSucceeded {
+		iRest.Response = &inv.Response
+	}
+	if inv.Error != "" {
+		iRest.Error = &inv.Error
+	}
+	if !inv.StartedAt.IsZero() {
+		iRest.StartedAt = &inv.StartedAt
+	}
+	if !inv.FinishedAt.IsZero() {
+		iRest.FinishedAt = &inv.FinishedAt
+	}
+	if inv.Status == invocations.StatusQueued {
+		iRest.NextAttemptAt = &inv.NextAttemptAt
+	}
+
+	return iRest
+}
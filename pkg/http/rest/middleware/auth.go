@@ -3,32 +3,37 @@ package middleware
 import (
 	"context"
 	"net/http"
-	"strings"
 
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
 	"github.com/tnynlabs/wyrm/pkg/http/rest"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/sessions"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 
 	"github.com/tnynlabs/wyrm/pkg/users"
 )
 
-// Auth checks the request for credentials (either in cookie "auth_key" or header "Authorization").
-// If authentication is successful the user instance will be added to the request context which
-// could be accessed from handlers (e.g. r.Context().Value(UserCtxKey{})).
+// Auth checks the request for a session token or an API key (either in cookie "auth_key",
+// header "Authorization" or header "X-API-Key").
+// If authentication is successful the user and session (or API key) instances will be added
+// to the request context which could be accessed from handlers (e.g. r.Context().Value(UserCtxKey{})).
+// If the session token was rotated the new token is set in the "auth_key" cookie
+// and in the "X-Auth-Token" response header.
 // If authentication is unsuccessful an appropriate error will be returned with status code 401.
-func Auth(userService users.Service) func(next http.Handler) http.Handler {
+func Auth(userService users.Service, sessionService sessions.Service, apiKeyService apikeys.Service) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// retreive auth key from cookie or header
-			authKey := keyFromCookie(r)
-			if authKey == "" {
-				authKey = keyFromHeader(r)
+			token := rest.AuthToken(r)
+			if apikeys.IsAPIKey(token) {
+				authAPIKey(w, r, next, userService, apiKeyService, token)
+				return
 			}
 
-			user, err := userService.GetByKey(authKey)
+			sess, err := sessionService.Authenticate(token)
 			if err != nil {
 				serviceErr := utils.ToServiceErr(err)
 				switch serviceErr.Code {
-				case users.UserNotFoundCode:
+				case sessions.InvalidSessionCode:
 					rest.SendError(w, r, *serviceErr, http.StatusUnauthorized)
 				default:
 					rest.SendUnexpectedErr(w, r)
@@ -36,27 +41,75 @@ func Auth(userService users.Service) func(next http.Handler) http.Handler {
 				return
 			}
 
+			user, ok := authUser(w, r, userService, sess.UserID)
+			if !ok {
+				return
+			}
+
+			if sess.Token != "" {
+				rest.SetAuthCookie(w, r, sess.Token, sess.ExpiresAt)
+				w.Header().Set("X-Auth-Token", sess.Token)
+			}
+
 			ctx := context.WithValue(r.Context(), rest.UserCtxKey{}, user)
+			ctx = context.WithValue(ctx, rest.SessionCtxKey{}, sess)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// keyFromCookie tries to retreive the key string from a cookie named "auth_key".
-func keyFromCookie(r *http.Request) string {
-	cookie, err := r.Cookie("auth_key")
+// RequireSession rejects calls (with status code 403) authenticated using an API key.
+// It guards account level routes (e.g. sessions, API keys) which keys are never scoped for.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(rest.SessionCtxKey{}).(*sessions.Session); !ok {
+			rest.SendError(w, r, utils.ServiceErr{
+				Code:    projects.ForbiddenCode,
+				Message: "API keys can not access this route",
+			}, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func authAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler,
+	userService users.Service, apiKeyService apikeys.Service, token string) {
+	apiKey, err := apiKeyService.Authenticate(token)
 	if err != nil {
-		return ""
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case apikeys.InvalidAPIKeyCode:
+			rest.SendError(w, r, *serviceErr, http.StatusUnauthorized)
+		default:
+			rest.SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	user, ok := authUser(w, r, userService, apiKey.UserID)
+	if !ok {
+		return
 	}
-	return cookie.Value
+
+	ctx := context.WithValue(r.Context(), rest.UserCtxKey{}, user)
+	ctx = context.WithValue(ctx, rest.APIKeyCtxKey{}, apiKey)
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
-// keyFromHeader tries to retreive the key string from the "Authorization" reqeust header.
-// Example: "Authorization: BEARER <KEY>".
-func keyFromHeader(r *http.Request) string {
-	bearer := r.Header.Get("Authorization")
-	if len(bearer) > 7 && strings.ToUpper(bearer[0:6]) == "BEARER" {
-		return bearer[7:]
+func authUser(w http.ResponseWriter, r *http.Request, userService users.Service, userID int64) (*users.User, bool) {
+	user, err := userService.GetByID(userID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case users.UserNotFoundCode:
+			rest.SendError(w, r, *serviceErr, http.StatusUnauthorized)
+		default:
+			rest.SendUnexpectedErr(w, r)
+		}
+		return nil, false
 	}
-	return ""
+
+	return user, true
 }
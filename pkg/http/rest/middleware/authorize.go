@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+	"github.com/tnynlabs/wyrm/pkg/http/rest"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// Authorize checks that the authenticated user (see Auth) has at least the required role
+// in the project owning the resource identified by the URL parameter param.
+// On success the *projects.Authorization is added to the request context
+// (e.g. r.Context().Value(AuthorizationCtxKey{})).
+// Requests authenticated using an API key are also limited to the key's project and scope.
+// Unauthorized calls are rejected with status code 403.
+func Authorize(projectService projects.Service, resourceType projects.ResourceType, param string, required projects.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(rest.UserCtxKey{}).(*users.User)
+			if !ok {
+				rest.SendUnexpectedErr(w, r)
+				return
+			}
+
+			resourceID, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+			if err != nil {
+				rest.SendError(w, r, utils.ServiceErr{
+					Code:    projects.ResourceNotFoundCode,
+					Message: "Invalid ID (IDs should be integers)",
+				}, http.StatusNotFound)
+				return
+			}
+
+			resource := projects.Resource{Type: resourceType, ID: resourceID}
+			authorization, err := projectService.Authorize(user.ID, resource, required)
+			if err != nil {
+				serviceErr := utils.ToServiceErr(err)
+				switch serviceErr.Code {
+				case projects.ResourceNotFoundCode:
+					rest.SendError(w, r, *serviceErr, http.StatusNotFound)
+				case projects.ForbiddenCode:
+					rest.SendError(w, r, *serviceErr, http.StatusForbidden)
+				default:
+					rest.SendUnexpectedErr(w, r)
+				}
+				return
+			}
+
+			if apiKey, ok := r.Context().Value(rest.APIKeyCtxKey{}).(*apikeys.Key); ok {
+				_, invocation := r.Context().Value(invocationCtxKey{}).(bool)
+				if !apiKey.Allows(authorization.ProjectID, required, invocation) {
+					rest.SendError(w, r, utils.ServiceErr{
+						Code:    projects.ForbiddenCode,
+						Message: "API key scope does not allow this action",
+					}, http.StatusForbidden)
+					return
+				}
+				if !invocation && !apiKey.Scope.Role().Includes(authorization.Role) {
+					authorization.Role = apiKey.Scope.Role()
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), rest.AuthorizationCtxKey{}, authorization)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type invocationCtxKey struct{}
+
+// Invocation marks the route as an invocation (e.g. invoking a device endpoint) which
+// API keys with the invoke scope are allowed to call. It should be used before Authorize.
+func Invocation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), invocationCtxKey{}, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireSelf rejects calls (with status code 403) where the URL parameter param does not
+// refer to the authenticated user (i.e. it is neither "me" nor the user's own ID).
+// "me" is replaced by the user's ID so handlers can always parse an integer ID.
+func RequireSelf(param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(rest.UserCtxKey{}).(*users.User)
+			if !ok {
+				rest.SendUnexpectedErr(w, r)
+				return
+			}
+
+			userIDRaw := chi.URLParam(r, param)
+			if userIDRaw != "me" && userIDRaw != strconv.FormatInt(user.ID, 10) {
+				rest.SendError(w, r, utils.ServiceErr{
+					Code:    projects.ForbiddenCode,
+					Message: "Not allowed to access this user",
+				}, http.StatusForbidden)
+				return
+			}
+
+			rctx := chi.RouteContext(r.Context())
+			for i, key := range rctx.URLParams.Keys {
+				if key == param {
+					rctx.URLParams.Values[i] = strconv.FormatInt(user.ID, 10)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
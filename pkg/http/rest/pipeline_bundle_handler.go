@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// Export replies with the pipeline bundle as JSON or YAML (see sendDocument)
+func (h *PipelineHandler) Export(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	bundle, err := h.pipelineService.Export(r.Context(), pipelineID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		case pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	sendDocument(w, r, bundle, fmt.Sprintf("pipeline-%d", pipelineID))
+}
+
+type importPipelineRequest struct {
+	Bundle pipelines.Bundle `json:"bundle"`
+	// Devices maps device references of the bundle to device ids
+	// (unmapped references are matched by device display name)
+	Devices map[string]int64 `json:"devices"`
+}
+
+// Import creates a pipeline in the project from a bundle
+// (the request body may be JSON or YAML)
+func (h *PipelineHandler) Import(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	project, err := h.projectService.GetByID(projectID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case projects.ProjectNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	req := importPipelineRequest{}
+	err = decodeDocument(r, &req)
+	if err != nil {
+		sendInvalidDocumentErr(w, r, err)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	pipeline, err := h.pipelineService.Import(r.Context(), project.ID, user.ID, req.Bundle, req.Devices)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode, pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.ProjectNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"pipeline": fromPipeline(*pipeline),
+	}
+
+	SendResponse(w, r, result)
+}
@@ -1,8 +1,6 @@
 package rest
 
 import (
-	"io/ioutil"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -30,7 +28,7 @@ func (h *PipelineHandler) Get(w http.ResponseWriter, r *http.Request) {
 		SendError(w, r, invalidIDErr, http.StatusNotFound)
 		return
 	}
-	pipeline, err := h.pipelineService.GetByID(pipelineID)
+	pipeline, err := h.pipelineService.GetByID(r.Context(), pipelineID)
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
@@ -81,11 +79,11 @@ func (h *PipelineHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	pipelineData.CreatedBy = &user.ID
 	pipelineData.ProjectID = &project.ID
-	pipeline, err := h.pipelineService.Create(*toPipeline(pipelineData))
+	pipeline, err := h.pipelineService.Create(r.Context(), *toPipeline(pipelineData))
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
-		case pipelines.InvalidInputCode:
+		case pipelines.InvalidInputCode, pipelines.InvalidPipelineCode:
 			SendError(w, r, *serviceErr, http.StatusBadRequest)
 		default:
 			SendUnexpectedErr(w, r)
@@ -111,11 +109,18 @@ func (h *PipelineHandler) Update(w http.ResponseWriter, r *http.Request) {
 		SendInvalidJSONErr(w, r)
 		return
 	}
-	pipeline, err := h.pipelineService.Update(pipelineID, *toPipeline(pipelineData))
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+	p := toPipeline(pipelineData)
+	p.UpdatedBy = user.ID
+	pipeline, err := h.pipelineService.Update(r.Context(), pipelineID, *p)
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
-		case pipelines.InvalidInputCode:
+		case pipelines.InvalidInputCode, pipelines.InvalidPipelineCode:
 			SendError(w, r, *serviceErr, http.StatusBadRequest)
 		case pipelines.PipelineNotFoundCode:
 			SendError(w, r, *serviceErr, http.StatusNotFound)
@@ -138,7 +143,7 @@ func (h *PipelineHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		SendError(w, r, invalidIDErr, http.StatusNotFound)
 		return
 	}
-	err = h.pipelineService.Delete(int64(pipelineID))
+	err = h.pipelineService.Delete(r.Context(), int64(pipelineID))
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
@@ -159,7 +164,7 @@ func (h *PipelineHandler) GetByProjectID(w http.ResponseWriter, r *http.Request)
 		SendError(w, r, invalidIDErr, http.StatusNotFound)
 		return
 	}
-	projectPipelines, err := h.pipelineService.GetByProjectID(projectID)
+	projectPipelines, err := h.pipelineService.GetByProjectID(r.Context(), projectID)
 	if err != nil {
 		serviceErr := utils.ToServiceErr(err)
 		switch serviceErr.Code {
@@ -183,52 +188,6 @@ func (h *PipelineHandler) GetByProjectID(w http.ResponseWriter, r *http.Request)
 	SendResponse(w, r, result)
 }
 
-func (h *PipelineHandler) Webhook(w http.ResponseWriter, r *http.Request) {
-	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
-	if err != nil {
-		SendError(w, r, invalidIDErr, http.StatusNotFound)
-		return
-	}
-
-	log.Println(h.pipelineService)
-	// Check that pipeline exists
-	_, err = h.pipelineService.GetByID(pipelineID)
-	log.Println(err)
-	if err != nil {
-		serviceErr := utils.ToServiceErr(err)
-		switch serviceErr.Code {
-		case pipelines.PipelineNotFoundCode:
-			SendError(w, r, *serviceErr, http.StatusNotFound)
-		default:
-			SendUnexpectedErr(w, r)
-		}
-		return
-	}
-
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		SendUnexpectedErr(w, r)
-		return
-	}
-
-	payload := string(body[:])
-	log.Println(payload)
-	err = h.pipelineService.RunPipeline(pipelineID, payload)
-	if err != nil {
-		serviceErr := utils.ToServiceErr(err)
-		switch serviceErr.Code {
-		case pipelines.WorkerConnectionErrorCode:
-			SendError(w, r, *serviceErr, http.StatusBadRequest)
-		default:
-			SendUnexpectedErr(w, r)
-		}
-		return
-	}
-
-	SendResponse(w, r, nil)
-}
-
 type pipelineRest struct {
 	ID          *int64     `json:"id,omitempty"`
 	DisplayName *string    `json:"display_name,omitempty"`
@@ -238,6 +197,10 @@ type pipelineRest struct {
 	CreatedBy   *int64     `json:"created_by,omitempty"`
 	CreatedAt   *time.Time `json:"created_at,omitempty"`
 	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	// Version current version number (read only)
+	Version *int `json:"version,omitempty"`
+	// NextRunAt earliest next scheduled run (read only)
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
 }
 
 func toPipeline(pRest pipelineRest) *pipelines.Pipeline {
@@ -271,12 +234,16 @@ func fromPipeline(p pipelines.Pipeline) *pipelineRest {
 		ProjectID:   &p.ProjectID,
 		CreatedAt:   &p.CreatedAt,
 		CreatedBy:   &p.CreatedBy,
+		Version:     &p.Version,
 	}
 	if !p.UpdatedAt.IsZero() {
 		pRest.UpdatedAt = &p.UpdatedAt
 	} else {
 		pRest.UpdatedAt = nil
 	}
+	if !p.NextRunAt.IsZero() {
+		pRest.NextRunAt = &p.NextRunAt
+	}
 
 	return &pRest
 }
@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// Run runs the pipeline manually (the request body is the run payload)
+func (h *PipelineHandler) Run(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	h.runPipeline(w, r, pipelineID, pipelines.TriggerManual, string(body))
+}
+
+func (h *PipelineHandler) runPipeline(w http.ResponseWriter, r *http.Request, pipelineID int64, trigger pipelines.Trigger, payload string) {
+	run, err := h.pipelineService.RunPipeline(r.Context(), pipelineID, trigger, payload)
+	if err != nil {
+		sendRunErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"run": fromRun(*run),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// sendRunErr sends the error of a failed pipeline run
+func sendRunErr(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case pipelines.PipelineNotFoundCode:
+		SendError(w, r, *serviceErr, http.StatusNotFound)
+	case pipelines.WorkerConnectionErrorCode, pipelines.InvalidPipelineCode:
+		SendError(w, r, *serviceErr, http.StatusBadRequest)
+	case pipelines.TimeoutCode:
+		SendError(w, r, *serviceErr, http.StatusGatewayTimeout)
+	case pipelines.CanceledCode:
+		// The client is gone, nobody reads the response
+	default:
+		SendUnexpectedErr(w, r)
+	}
+}
+
+// GetRuns returns the run history of the pipeline (latest first)
+// paginated with the "limit" and "offset" query parameters
+func (h *PipelineHandler) GetRuns(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	var limit, offset int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+	}
+	if value := r.URL.Query().Get("offset"); value != "" && err == nil {
+		offset, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		SendError(w, r, utils.ServiceErr{
+			Code:    pipelines.InvalidInputCode,
+			Message: "limit and offset should be integers",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.pipelineService.GetRuns(r.Context(), pipelineID, limit, offset)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	restRuns := make([]runRest, len(runs))
+	for i := 0; i < len(runs); i++ {
+		restRuns[i] = fromRun(runs[i])
+	}
+
+	result := &map[string]interface{}{
+		"runs": restRuns,
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (h *PipelineHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(chi.URLParam(r, "runID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	run, err := h.pipelineService.GetRun(r.Context(), runID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.RunNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"run": fromRun(*run),
+	}
+
+	SendResponse(w, r, result)
+}
+
+type runRest struct {
+	// ID omitted for simulations (not recorded)
+	ID         int64 `json:"id,omitempty"`
+	PipelineID int64 `json:"pipeline_id"`
+	// PipelineVersion omitted for runs recorded before pipelines were versioned
+	PipelineVersion *int             `json:"pipeline_version,omitempty"`
+	Trigger         string           `json:"trigger"`
+	Payload         string           `json:"payload"`
+	Status          string           `json:"status"`
+	Output          *json.RawMessage `json:"output,omitempty"`
+	Error           *string          `json:"error,omitempty"`
+	Nodes           []nodeResultRest `json:"nodes"`
+	StartedAt       time.Time        `json:"started_at"`
+	FinishedAt      *time.Time       `json:"finished_at,omitempty"`
+}
+
+type nodeResultRest struct {
+	NodeID     string           `json:"node_id"`
+	Status     string           `json:"status"`
+	Input      *json.RawMessage `json:"input,omitempty"`
+	Output     *json.RawMessage `json:"output,omitempty"`
+	Error      *string          `json:"error,omitempty"`
+	Branch     *string          `json:"branch,omitempty"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+func fromRun(run pipelines.Run) runRest {
+	rRest := runRest{
+		ID:         run.ID,
+		PipelineID: run.PipelineID,
+		Trigger:    string(run.Trigger),
+		Payload:    run.Payload,
+		Status:     string(run.Status),
+		Output:     jsonValue(run.Output),
+		Nodes:      make([]nodeResultRest, len(run.Nodes)),
+		StartedAt:  run.StartedAt,
+	}
+	if run.PipelineVersion != 0 {
+		rRest.PipelineVersion = &run.PipelineVersion
+	}
+	if run.Error != "" {
+		rRest.Error = &run.Error
+	}
+	if !run.FinishedAt.IsZero() {
+		rRest.FinishedAt = &run.FinishedAt
+	}
+
+	for i, node := range run.Nodes {
+		nRest := nodeResultRest{
+			NodeID: node.NodeID,
+			Status: string(node.Status),
+			Input:  jsonValue(node.Input),
+			Output: jsonValue(node.Output),
+		}
+		if node.Error != "" {
+			nRest.Error = &run.Nodes[i].Error
+		}
+		if node.Branch != "" {
+			nRest.Branch = &run.Nodes[i].Branch
+		}
+		if !node.StartedAt.IsZero() {
+			nRest.StartedAt = &run.Nodes[i].StartedAt
+		}
+		if !node.FinishedAt.IsZero() {
+			nRest.FinishedAt = &run.Nodes[i].FinishedAt
+		}
+		rRest.Nodes[i] = nRest
+	}
+
+	return rRest
+}
+
+// jsonValue embeds JSON encoded values as is (values that are
+// not valid JSON are embedded as strings)
+func jsonValue(value string) *json.RawMessage {
+	if value == "" {
+		return nil
+	}
+
+	raw := json.RawMessage(value)
+	if !json.Valid(raw) {
+		quoted, _ := json.Marshal(value)
+		raw = json.RawMessage(quoted)
+	}
+
+	return &raw
+}
@@ -0,0 +1,226 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+func (h *PipelineHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	scheduleData := scheduleRest{}
+	err = render.DecodeJSON(r.Body, &scheduleData)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	// Schedules are enabled unless stated otherwise
+	sch := pipelines.Schedule{Enabled: true}
+	applySchedule(&sch, scheduleData)
+
+	schedule, err := h.pipelineService.CreateSchedule(r.Context(), pipelineID, sch)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"schedule": fromSchedule(*schedule),
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (h *PipelineHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	schedules, err := h.pipelineService.GetSchedules(r.Context(), pipelineID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	restSchedules := make([]*scheduleRest, len(schedules))
+	for i := 0; i < len(schedules); i++ {
+		restSchedules[i] = fromSchedule(schedules[i])
+	}
+
+	result := &map[string]interface{}{
+		"schedules": restSchedules,
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (h *PipelineHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.ParseInt(chi.URLParam(r, "scheduleID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	schedule, err := h.pipelineService.GetSchedule(r.Context(), scheduleID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.ScheduleNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"schedule": fromSchedule(*schedule),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// UpdateSchedule updates the fields present in the request body
+func (h *PipelineHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.ParseInt(chi.URLParam(r, "scheduleID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	scheduleData := scheduleRest{}
+	err = render.DecodeJSON(r.Body, &scheduleData)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	schedule, err := h.pipelineService.GetSchedule(r.Context(), scheduleID)
+	if err == nil {
+		applySchedule(schedule, scheduleData)
+		schedule, err = h.pipelineService.UpdateSchedule(r.Context(), scheduleID, *schedule)
+	}
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.ScheduleNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"schedule": fromSchedule(*schedule),
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (h *PipelineHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.ParseInt(chi.URLParam(r, "scheduleID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	err = h.pipelineService.DeleteSchedule(r.Context(), scheduleID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.ScheduleNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	SendResponse(w, r, nil)
+}
+
+type scheduleRest struct {
+	ID         *int64     `json:"id,omitempty"`
+	PipelineID *int64     `json:"pipeline_id,omitempty"`
+	Cron       *string    `json:"cron,omitempty"`
+	Timezone   *string    `json:"timezone,omitempty"`
+	MissedRuns *string    `json:"missed_runs,omitempty"`
+	Payload    *string    `json:"payload,omitempty"`
+	Enabled    *bool      `json:"enabled,omitempty"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// applySchedule sets the writable fields present in sRest
+func applySchedule(sch *pipelines.Schedule, sRest scheduleRest) {
+	if sRest.Cron != nil {
+		sch.Cron = *sRest.Cron
+	}
+	if sRest.Timezone != nil {
+		sch.Timezone = *sRest.Timezone
+	}
+	if sRest.MissedRuns != nil {
+		sch.MissedRuns = pipelines.MissedRunPolicy(*sRest.MissedRuns)
+	}
+	if sRest.Payload != nil {
+		sch.Payload = *sRest.Payload
+	}
+	if sRest.Enabled != nil {
+		sch.Enabled = *sRest.Enabled
+	}
+}
+
+func fromSchedule(sch pipelines.Schedule) *scheduleRest {
+	missedRuns := string(sch.MissedRuns)
+	sRest := scheduleRest{
+		ID:         &sch.ID,
+		PipelineID: &sch.PipelineID,
+		Cron:       &sch.Cron,
+		Timezone:   &sch.Timezone,
+		MissedRuns: &missedRuns,
+		Payload:    &sch.Payload,
+		Enabled:    &sch.Enabled,
+		NextRunAt:  &sch.NextRunAt,
+		CreatedAt:  &sch.CreatedAt,
+	}
+	if !sch.LastRunAt.IsZero() {
+		sRest.LastRunAt = &sch.LastRunAt
+	}
+	if !sch.UpdatedAt.IsZero() {
+		sRest.UpdatedAt = &sch.UpdatedAt
+	}
+
+	return &sRest
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type simulateRequest struct {
+	// Payload the pipeline is triggered with
+	Payload json.RawMessage `json:"payload"`
+	// Data draft graph to simulate instead of the saved one
+	Data  string              `json:"data"`
+	Mocks map[string]mockRest `json:"mocks"`
+}
+
+type mockRest struct {
+	Response json.RawMessage `json:"response"`
+	Error    string          `json:"error"`
+}
+
+// Simulate runs the pipeline with mocked device and HTTP responses
+// and returns the trace of the run (nothing is invoked nor recorded)
+func (h *PipelineHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := simulateRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	mocks := make(map[string]pipelines.Mock, len(req.Mocks))
+	for nodeID, mock := range req.Mocks {
+		mocks[nodeID] = pipelines.Mock{
+			Response: string(mock.Response),
+			Error:    mock.Error,
+		}
+	}
+
+	run, err := h.pipelineService.Simulate(r.Context(), pipelineID, req.Data, string(req.Payload), mocks)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		default:
+			sendRunErr(w, r, err)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"run": fromRun(*run),
+	}
+
+	SendResponse(w, r, result)
+}
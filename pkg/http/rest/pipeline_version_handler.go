@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// GetVersions returns the version history of the pipeline (latest first)
+// paginated with the "limit" and "offset" query parameters
+func (h *PipelineHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	var limit, offset int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+	}
+	if value := r.URL.Query().Get("offset"); value != "" && err == nil {
+		offset, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		SendError(w, r, utils.ServiceErr{
+			Code:    pipelines.InvalidInputCode,
+			Message: "limit and offset should be integers",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	versions, err := h.pipelineService.GetVersions(r.Context(), pipelineID, limit, offset)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	restVersions := make([]versionRest, len(versions))
+	for i := 0; i < len(versions); i++ {
+		restVersions[i] = fromVersion(versions[i])
+	}
+
+	result := &map[string]interface{}{
+		"versions": restVersions,
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (h *PipelineHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	v, err := h.pipelineService.GetVersion(r.Context(), pipelineID, version)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.PipelineNotFoundCode, pipelines.VersionNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"version": fromVersion(*v),
+	}
+
+	SendResponse(w, r, result)
+}
+
+// DiffVersions compares the versions given by the "from" and "to" query
+// parameters ("to" defaults to the current version)
+func (h *PipelineHandler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	var from, to int
+	from, err = strconv.Atoi(r.URL.Query().Get("from"))
+	if value := r.URL.Query().Get("to"); value != "" && err == nil {
+		to, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		SendError(w, r, utils.ServiceErr{
+			Code:    pipelines.InvalidInputCode,
+			Message: "from (required) and to should be version numbers",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.pipelineService.DiffVersions(r.Context(), pipelineID, from, to)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode, pipelines.VersionNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"diff": diff,
+	}
+
+	SendResponse(w, r, result)
+}
+
+// Rollback restores a previous version of the pipeline (as a new version)
+func (h *PipelineHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	pipeline, err := h.pipelineService.Rollback(r.Context(), pipelineID, version, user.ID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode, pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode, pipelines.VersionNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"pipeline": fromPipeline(*pipeline),
+	}
+
+	SendResponse(w, r, result)
+}
+
+type versionRest struct {
+	PipelineID  int64  `json:"pipeline_id"`
+	Version     int    `json:"version"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+	Data        string `json:"data"`
+	// CreatedBy omitted if the author was deleted
+	CreatedBy *int64    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func fromVersion(v pipelines.Version) versionRest {
+	vRest := versionRest{
+		PipelineID:  v.PipelineID,
+		Version:     v.Version,
+		DisplayName: v.DisplayName,
+		Description: v.Description,
+		Data:        v.Data,
+		CreatedAt:   v.CreatedAt,
+	}
+	if v.CreatedBy != 0 {
+		vRest.CreatedBy = &v.CreatedBy
+	}
+
+	return vRest
+}
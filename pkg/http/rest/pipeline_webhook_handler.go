@@ -0,0 +1,222 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// maxWebhookBodySize max size (in bytes) of a webhook request body
+const maxWebhookBodySize = 1 << 20
+
+// Webhook starts a run of the pipeline for an external service (the request
+// body is the run payload) and returns the started run without waiting for it.
+// Requests are authenticated with the webhook signature instead of a user session.
+func (h *PipelineHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBodySize)
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		SendError(w, r, webhookBodyTooLargeErr, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	run, replayed, err := h.pipelineService.HandleWebhook(r.Context(), pipelineID, pipelines.WebhookRequest{
+		Body:           body,
+		Signature:      r.Header.Get(pipelines.WebhookSignatureHeader),
+		IdempotencyKey: r.Header.Get(pipelines.WebhookIdempotencyHeader),
+		RemoteIP:       clientIP(r),
+	})
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.InvalidSignatureCode:
+			SendError(w, r, *serviceErr, http.StatusUnauthorized)
+		case pipelines.WebhookDisabledCode, pipelines.ForbiddenIPCode:
+			SendError(w, r, *serviceErr, http.StatusForbidden)
+		case pipelines.DeliveryInProgressCode:
+			SendError(w, r, *serviceErr, http.StatusConflict)
+		case pipelines.RunNotFoundCode:
+			SendUnexpectedErr(w, r)
+		default:
+			sendRunErr(w, r, err)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"run": fromRun(*run),
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+		SendResponse(w, r, result)
+		return
+	}
+
+	SendAccepted(w, r, result)
+}
+
+// GetWebhook returns the webhook settings (including the secret)
+func (h *PipelineHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	webhook, err := h.pipelineService.GetWebhook(r.Context(), pipelineID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"webhook": fromWebhook(*webhook),
+	}
+
+	SendResponse(w, r, result)
+}
+
+type updateWebhookRequest struct {
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+// UpdateWebhook replaces the IP allowlist of the webhook
+// (an empty list allows any address)
+func (h *PipelineHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := updateWebhookRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	webhook, err := h.pipelineService.UpdateWebhook(r.Context(), pipelineID, req.AllowedIPs)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"webhook": fromWebhook(*webhook),
+	}
+
+	SendResponse(w, r, result)
+}
+
+type rotateSecretRequest struct {
+	GracePeriodSeconds int64 `json:"grace_period_seconds"`
+}
+
+// RotateWebhookSecret generates a new webhook secret (the body is optional, the
+// old secret stops working right away unless a grace period is given)
+func (h *PipelineHandler) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	pipelineID, err := strconv.ParseInt(chi.URLParam(r, "pipelineID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := rotateSecretRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil && err != io.EOF {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	gracePeriod := time.Duration(req.GracePeriodSeconds) * time.Second
+	webhook, err := h.pipelineService.RotateWebhookSecret(r.Context(), pipelineID, gracePeriod)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case pipelines.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case pipelines.PipelineNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"webhook": fromWebhook(*webhook),
+	}
+
+	SendResponse(w, r, result)
+}
+
+type webhookRest struct {
+	PipelineID              *int64     `json:"pipeline_id,omitempty"`
+	Enabled                 *bool      `json:"enabled,omitempty"`
+	Secret                  *string    `json:"secret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	AllowedIPs              []string   `json:"allowed_ips"`
+	CreatedAt               *time.Time `json:"created_at,omitempty"`
+	UpdatedAt               *time.Time `json:"updated_at,omitempty"`
+}
+
+func fromWebhook(wh pipelines.Webhook) *webhookRest {
+	enabled := wh.Secret != ""
+	whRest := webhookRest{
+		PipelineID: &wh.PipelineID,
+		Enabled:    &enabled,
+		AllowedIPs: wh.AllowedIPs,
+	}
+	if enabled {
+		whRest.Secret = &wh.Secret
+	}
+	if wh.PreviousSecret != "" && time.Now().Before(wh.PreviousSecretExpiresAt) {
+		whRest.PreviousSecretExpiresAt = &wh.PreviousSecretExpiresAt
+	}
+	if !wh.CreatedAt.IsZero() {
+		whRest.CreatedAt = &wh.CreatedAt
+	}
+	if !wh.UpdatedAt.IsZero() {
+		whRest.UpdatedAt = &wh.UpdatedAt
+	}
+
+	return &whRest
+}
+
+var webhookBodyTooLargeErr = utils.ServiceErr{
+	Code:    pipelines.InvalidInputCode,
+	Message: fmt.Sprintf("Webhook body should be at most %d bytes", maxWebhookBodySize),
+}
@@ -11,6 +11,7 @@ import (
 type restErr struct {
 	Code    utils.ServiceErrCode `json:"code"`
 	Message string               `json:"message"`
+	Details interface{}          `json:"details,omitempty"`
 }
 
 type response struct {
@@ -27,12 +28,24 @@ func SendResponse(w http.ResponseWriter, r *http.Request, result *map[string]int
 	render.JSON(w, r, resp)
 }
 
+// SendAccepted is SendResponse with status code 202
+// (the request continues in the background)
+func SendAccepted(w http.ResponseWriter, r *http.Request, result *map[string]interface{}) {
+	resp := response{
+		Result: result,
+		Err:    nil,
+	}
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, resp)
+}
+
 func SendError(w http.ResponseWriter, r *http.Request, err utils.ServiceErr, status int) {
 	resp := response{
 		Result: nil,
 		Err: &restErr{
 			Code:    err.Code,
 			Message: err.Message,
+			Details: err.Details,
 		},
 	}
 	render.Status(r, status)
@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// SessionHandler rest handler for the sessions of the authenticated user
+type SessionHandler struct {
+	sessionService sessions.Service
+}
+
+func CreateSessionHandler(sessionService sessions.Service) SessionHandler {
+	return SessionHandler{sessionService}
+}
+
+// GetActive lists active sessions of the authenticated user
+func (h *SessionHandler) GetActive(w http.ResponseWriter, r *http.Request) {
+	user, current, ok := authenticatedSession(w, r)
+	if !ok {
+		return
+	}
+
+	userSessions, err := h.sessionService.GetActiveByUserID(user.ID)
+	if err != nil {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	restSessions := make([]*sessionRest, len(userSessions))
+	for i := 0; i < len(userSessions); i++ {
+		restSessions[i] = fromSession(userSessions[i], current.ID)
+	}
+
+	result := &map[string]interface{}{
+		"sessions": restSessions,
+	}
+
+	SendResponse(w, r, result)
+}
+
+// Revoke kills a single session of the authenticated user
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user, current, ok := authenticatedSession(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	err = h.sessionService.Revoke(user.ID, sessionID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case sessions.SessionNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	if sessionID == current.ID {
+		ClearAuthCookie(w, r)
+	}
+
+	SendResponse(w, r, nil)
+}
+
+// RevokeAll kills every session of the authenticated user (including the current one)
+func (h *SessionHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	user, _, ok := authenticatedSession(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.sessionService.RevokeAll(user.ID)
+	if err != nil {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	ClearAuthCookie(w, r)
+	SendResponse(w, r, nil)
+}
+
+// authenticatedSession retrieves the authenticated user and session from the request context
+func authenticatedSession(w http.ResponseWriter, r *http.Request) (*users.User, *sessions.Session, bool) {
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return nil, nil, false
+	}
+	sess, ok := r.Context().Value(SessionCtxKey{}).(*sessions.Session)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return nil, nil, false
+	}
+
+	return user, sess, true
+}
+
+type sessionRest struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func fromSession(s sessions.Session, currentSessionID int64) *sessionRest {
+	return &sessionRest{
+		ID:         s.ID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentSessionID,
+	}
+}
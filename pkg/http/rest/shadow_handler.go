@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type ShadowHandler struct {
+	shadowService shadows.Service
+}
+
+func CreateShadowHandler(sService shadows.Service) ShadowHandler {
+	return ShadowHandler{sService}
+}
+
+func (sHandler *ShadowHandler) Get(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	shadow, err := sHandler.shadowService.Get(deviceID)
+	if err != nil {
+		sendShadowErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"shadow": fromShadow(*shadow),
+	}
+
+	SendResponse(w, r, result)
+}
+
+func (sHandler *ShadowHandler) UpdateDesired(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	req := updateDesiredRequest{}
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		SendInvalidJSONErr(w, r)
+		return
+	}
+
+	if req.Version == nil {
+		SendError(w, r, utils.ServiceErr{
+			Code:    shadows.InvalidInputCode,
+			Message: "version is required (use the version returned by GET)",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	shadow, err := sHandler.shadowService.UpdateDesired(deviceID, req.Desired, *req.Version)
+	if err != nil {
+		sendShadowErr(w, r, err)
+		return
+	}
+
+	result := &map[string]interface{}{
+		"shadow": fromShadow(*shadow),
+	}
+
+	SendResponse(w, r, result)
+}
+
+func sendShadowErr(w http.ResponseWriter, r *http.Request, err error) {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case shadows.InvalidInputCode:
+		SendError(w, r, *serviceErr, http.StatusBadRequest)
+	case shadows.DeviceNotFoundCode:
+		SendError(w, r, *serviceErr, http.StatusNotFound)
+	case shadows.VersionConflictCode:
+		SendError(w, r, *serviceErr, http.StatusConflict)
+	default:
+		SendUnexpectedErr(w, r)
+	}
+}
+
+type updateDesiredRequest struct {
+	Desired shadows.Document `json:"desired"`
+	Version *int64           `json:"version"`
+}
+
+type shadowRest struct {
+	Desired           shadows.Document `json:"desired"`
+	Reported          shadows.Document `json:"reported"`
+	Delta             shadows.Document `json:"delta"`
+	Version           int64            `json:"version"`
+	DesiredUpdatedAt  *time.Time       `json:"desired_updated_at,omitempty"`
+	ReportedUpdatedAt *time.Time       `json:"reported_updated_at,omitempty"`
+}
+
+func fromShadow(s shadows.Shadow) shadowRest {
+	sRest := shadowRest{
+		Desired:  s.Desired,
+		Reported: s.Reported,
+		Delta:    s.Delta(),
+		Version:  s.Version,
+	}
+	if !s.DesiredUpdatedAt.IsZero() {
+		sRest.DesiredUpdatedAt = &s.DesiredUpdatedAt
+	}
+	if !s.ReportedUpdatedAt.IsZero() {
+		sRest.ReportedUpdatedAt = &s.ReportedUpdatedAt
+	}
+
+	return sRest
+}
@@ -0,0 +1,246 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/tnynlabs/wyrm/pkg/telemetry"
+	"github.com/tnynlabs/wyrm/pkg/telemetry/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxTelemetryBodySize max size (in bytes) of an ingestion request body
+const maxTelemetryBodySize = 1 << 20
+
+// protobufContentType content type of protobuf encoded ingestion batches
+const protobufContentType = "application/x-protobuf"
+
+type TelemetryHandler struct {
+	telemetryService telemetry.Service
+}
+
+func CreateTelemetryHandler(tService telemetry.Service) TelemetryHandler {
+	return TelemetryHandler{tService}
+}
+
+// Ingest stores a batch of datapoints pushed by the device owning the "X-Device-Key" auth key.
+// The batch is either JSON or a protobuf.DatapointBatch (Content-Type: application/x-protobuf).
+func (tHandler *TelemetryHandler) Ingest(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTelemetryBodySize)
+
+	var points []telemetry.Datapoint
+	if strings.HasPrefix(r.Header.Get("Content-Type"), protobufContentType) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			SendError(w, r, invalidBatchErr, http.StatusBadRequest)
+			return
+		}
+
+		batch := protobuf.DatapointBatch{}
+		if err = proto.Unmarshal(body, &batch); err != nil {
+			SendError(w, r, invalidBatchErr, http.StatusBadRequest)
+			return
+		}
+		points = telemetry.FromProtobuf(batch.GetDatapoints())
+	} else {
+		batch := datapointBatchRest{}
+		err := render.DecodeJSON(r.Body, &batch)
+		if err != nil {
+			SendInvalidJSONErr(w, r)
+			return
+		}
+		points = toDatapoints(batch.Datapoints)
+	}
+
+	accepted, err := tHandler.telemetryService.Ingest(r.Header.Get(DeviceKeyHeader), points)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case telemetry.InvalidAuthKeyCode:
+			SendError(w, r, *serviceErr, http.StatusUnauthorized)
+		case telemetry.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	result := &map[string]interface{}{
+		"accepted": accepted,
+	}
+
+	SendResponse(w, r, result)
+}
+
+// Get returns the datapoints of the device, or their min/max/avg
+// per bucket if the "bucket" query parameter (e.g. "5m") is set.
+func (tHandler *TelemetryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	q, queryErr := parseTelemetryQuery(r)
+	if queryErr != nil {
+		SendError(w, r, *queryErr, http.StatusBadRequest)
+		return
+	}
+	q.DeviceID = deviceID
+
+	var result *map[string]interface{}
+	var count int
+	if q.Bucket != 0 {
+		var aggregates []telemetry.Aggregate
+		aggregates, err = tHandler.telemetryService.GetAggregates(q)
+		if err == nil {
+			result = &map[string]interface{}{
+				"aggregates": fromAggregates(aggregates),
+			}
+			count = len(aggregates)
+		}
+	} else {
+		var points []telemetry.Datapoint
+		points, err = tHandler.telemetryService.Get(q)
+		if err == nil {
+			result = &map[string]interface{}{
+				"datapoints": fromDatapoints(points),
+			}
+			count = len(points)
+		}
+	}
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case telemetry.InvalidInputCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case telemetry.DeviceNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	// A full page may be followed by more results
+	(*result)["next_offset"] = nil
+	if count == q.Limit {
+		(*result)["next_offset"] = q.Offset + count
+	}
+
+	SendResponse(w, r, result)
+}
+
+// parseTelemetryQuery parses the "from", "to" (RFC 3339), "metric",
+// "bucket" (duration), "limit" and "offset" query parameters
+func parseTelemetryQuery(r *http.Request) (telemetry.Query, *utils.ServiceErr) {
+	params := r.URL.Query()
+	q := telemetry.Query{
+		Metric: params.Get("metric"),
+		Limit:  telemetry.DefaultLimit,
+	}
+
+	var err error
+	if from := params.Get("from"); from != "" {
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return q, &utils.ServiceErr{Code: telemetry.InvalidInputCode, Message: "from should be an RFC 3339 time"}
+		}
+	}
+	if to := params.Get("to"); to != "" {
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return q, &utils.ServiceErr{Code: telemetry.InvalidInputCode, Message: "to should be an RFC 3339 time"}
+		}
+	}
+	if bucket := params.Get("bucket"); bucket != "" {
+		if q.Bucket, err = time.ParseDuration(bucket); err != nil || q.Bucket <= 0 {
+			return q, &utils.ServiceErr{Code: telemetry.InvalidInputCode, Message: "bucket should be a duration (e.g. 30s, 5m, 1h)"}
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 {
+			return q, &utils.ServiceErr{Code: telemetry.InvalidInputCode, Message: "limit should be a positive integer"}
+		}
+	}
+	if offset := params.Get("offset"); offset != "" {
+		if q.Offset, err = strconv.Atoi(offset); err != nil {
+			return q, &utils.ServiceErr{Code: telemetry.InvalidInputCode, Message: "offset should be an integer"}
+		}
+	}
+
+	return q, nil
+}
+
+var invalidBatchErr = utils.ServiceErr{
+	Code:    telemetry.InvalidInputCode,
+	Message: "Invalid protobuf datapoint batch",
+}
+
+type datapointBatchRest struct {
+	Datapoints []datapointRest `json:"datapoints"`
+}
+
+type datapointRest struct {
+	Metric    string     `json:"metric"`
+	Value     float64    `json:"value"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+type aggregateRest struct {
+	Metric      string    `json:"metric"`
+	BucketStart time.Time `json:"bucket_start"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+	Count       int64     `json:"count"`
+}
+
+func toDatapoints(pointsRest []datapointRest) []telemetry.Datapoint {
+	points := make([]telemetry.Datapoint, len(pointsRest))
+	for i, p := range pointsRest {
+		points[i] = telemetry.Datapoint{
+			Metric: p.Metric,
+			Value:  p.Value,
+		}
+		if p.Timestamp != nil {
+			points[i].Timestamp = *p.Timestamp
+		}
+	}
+
+	return points
+}
+
+func fromDatapoints(points []telemetry.Datapoint) []datapointRest {
+	pointsRest := make([]datapointRest, len(points))
+	for i := range points {
+		pointsRest[i] = datapointRest{
+			Metric:    points[i].Metric,
+			Value:     points[i].Value,
+			Timestamp: &points[i].Timestamp,
+		}
+	}
+
+	return pointsRest
+}
+
+func fromAggregates(aggregates []telemetry.Aggregate) []aggregateRest {
+	aggregatesRest := make([]aggregateRest, len(aggregates))
+	for i, a := range aggregates {
+		aggregatesRest[i] = aggregateRest{
+			Metric:      a.Metric,
+			BucketStart: a.BucketStart,
+			Min:         a.Min,
+			Max:         a.Max,
+			Avg:         a.Avg,
+			Count:       a.Count,
+		}
+	}
+
+	return aggregatesRest
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/templates"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+type TemplateHandler struct {
+	templateService templates.Service
+}
+
+func CreateTemplateHandler(tService templates.Service) TemplateHandler {
+	return TemplateHandler{tService}
+}
+
+// Export replies with the project template as JSON or YAML (see sendDocument)
+func (h *TemplateHandler) Export(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	template, err := h.templateService.Export(r.Context(), projectID)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case templates.ProjectNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		case pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	sendDocument(w, r, template, fmt.Sprintf("project-%d-template", projectID))
+}
+
+// Apply creates the devices, endpoints and pipelines of a template in the
+// project (the request body is the template as JSON or YAML)
+func (h *TemplateHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	projectID, err := strconv.ParseInt(chi.URLParam(r, "projectID"), 10, 64)
+	if err != nil {
+		SendError(w, r, invalidIDErr, http.StatusNotFound)
+		return
+	}
+
+	template := templates.Template{}
+	err = decodeDocument(r, &template)
+	if err != nil {
+		sendInvalidDocumentErr(w, r, err)
+		return
+	}
+
+	user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
+	deployment, err := h.templateService.Apply(r.Context(), projectID, user.ID, template)
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case templates.InvalidInputCode, pipelines.InvalidPipelineCode:
+			SendError(w, r, *serviceErr, http.StatusBadRequest)
+		case templates.ProjectNotFoundCode:
+			SendError(w, r, *serviceErr, http.StatusNotFound)
+		default:
+			SendUnexpectedErr(w, r)
+		}
+		return
+	}
+
+	restDevices := make([]deviceRest, len(deployment.Devices))
+	for i := 0; i < len(deployment.Devices); i++ {
+		restDevices[i] = fromDevice(deployment.Devices[i])
+	}
+	restEndpoints := make([]endpointRest, len(deployment.Endpoints))
+	for i := 0; i < len(deployment.Endpoints); i++ {
+		restEndpoints[i] = fromEndpoint(deployment.Endpoints[i])
+	}
+	restPipelines := make([]*pipelineRest, len(deployment.Pipelines))
+	for i := 0; i < len(deployment.Pipelines); i++ {
+		restPipelines[i] = fromPipeline(deployment.Pipelines[i])
+	}
+
+	result := &map[string]interface{}{
+		"devices":   restDevices,
+		"endpoints": restEndpoints,
+		"pipelines": restPipelines,
+	}
+
+	SendResponse(w, r, result)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/tnynlabs/wyrm/pkg/sessions"
 	"github.com/tnynlabs/wyrm/pkg/users"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 
@@ -14,11 +15,12 @@ import (
 
 // UserHandler user rest handler
 type UserHandler struct {
-	userService users.Service
+	userService    users.Service
+	sessionService sessions.Service
 }
 
-func CreateUserHandler(userService users.Service) UserHandler {
-	return UserHandler{userService}
+func CreateUserHandler(userService users.Service, sessionService sessions.Service) UserHandler {
+	return UserHandler{userService, sessionService}
 }
 
 func (h *UserHandler) RegisterWithPwd(w http.ResponseWriter, r *http.Request) {
@@ -76,51 +78,53 @@ func (h *UserHandler) LoginWithEmailPwd(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// TODO: add SecureOnly
-	authKeyCookie := http.Cookie{
-		Name:		"auth_key",
-		Value:		user.AuthKey,
-		Expires:	time.Now().Add(time.Hour * 42000),
-		HttpOnly:	false,
+	sess, err := h.sessionService.Create(user.ID, r.UserAgent(), clientIP(r))
+	if err != nil {
+		SendUnexpectedErr(w, r)
+		return
 	}
 
-	http.SetCookie(w, &authKeyCookie)
+	SetAuthCookie(w, r, sess.Token, sess.ExpiresAt)
 
+	// The token is also returned for clients using the "Authorization" header
 	result := &map[string]interface{}{
-		"user": fromUser(*user),
+		"user":    fromUser(*user),
+		"session": fromSession(*sess, sess.ID),
+		"token":   sess.Token,
 	}
 
 	SendResponse(w, r, result)
 }
 
+// Logout revokes the current session (if any) and clears the auth cookie
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	authKeyCookie := http.Cookie{
-		Name:		"auth_key",
-		Expires:	time.Unix(0, 0),
-		HttpOnly:	false,
+	if token := AuthToken(r); token != "" {
+		// Invalid or already revoked sessions are ignored
+		h.sessionService.RevokeByToken(token)
 	}
 
-	http.SetCookie(w, &authKeyCookie)
+	ClearAuthCookie(w, r)
 	SendResponse(w, r, nil)
 }
 
 func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	caller, ok := r.Context().Value(UserCtxKey{}).(*users.User)
+	if !ok {
+		SendUnexpectedErr(w, r)
+		return
+	}
+
 	userIDRaw := chi.URLParam(r, "userID")
-	if userIDRaw == "me" {
+	if userIDRaw == "me" || userIDRaw == strconv.FormatInt(caller.ID, 10) {
 		// Retrieve all fields
-		user, ok := r.Context().Value(UserCtxKey{}).(*users.User)
-		if !ok {
-			SendUnexpectedErr(w, r)
-			return
-		}
 		result := &map[string]interface{}{
-			"user": fromUser(*user),
+			"user": fromUser(*caller),
 		}
 		SendResponse(w, r, result)
 		return
 	}
 
-	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	userID, err := strconv.ParseInt(userIDRaw, 10, 64)
 	if err != nil {
 		SendError(w, r, invalidIDErr, http.StatusNotFound)
 		return
@@ -139,7 +143,7 @@ func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userData := fromUser(*user)
-	// omit sensitive values
+	// omit sensitive values (only the user itself can see them)
 	userData.CreatedAt = nil
 	userData.UpdatedAt = nil
 	userData.Email = nil
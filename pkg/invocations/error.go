@@ -0,0 +1,22 @@
+package invocations
+
+import (
+	"errors"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	InvalidInputCode       = utils.ServiceErrCode("INVALID_INPUT")
+	DeviceNotFoundCode     = utils.ServiceErrCode("DEVICE_NOT_FOUND")
+	InvocationNotFoundCode = utils.ServiceErrCode("INVOCATION_NOT_FOUND")
+)
+
+// ErrNotQueued should be returned by repositories when claiming an
+// invocation that is not queued (e.g. already claimed by another replica)
+var ErrNotQueued = errors.New("invocation is not queued")
+
+// ErrAttemptExpired should be returned by repositories when storing the
+// outcome of an attempt the invocation is no longer leased to (its lease
+// expired and it was released by the sweeper)
+var ErrAttemptExpired = errors.New("invocation attempt expired")
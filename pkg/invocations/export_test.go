@@ -0,0 +1,35 @@
+package invocations
+
+import (
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/tunnels"
+)
+
+// Internals of the service for the external tests (which use the memory storage)
+
+const LeaseDuration = leaseDuration
+
+var Backoff = backoff
+
+// CreateIdleService creates a service without workers nor sweeper,
+// the tests run attempts themselves
+func CreateIdleService(repo Repository, deviceService devices.Service, tunnelService tunnels.Service, bus events.Bus) Service {
+	return &service{
+		invocationRepo: repo,
+		deviceService:  deviceService,
+		tunnelService:  tunnelService,
+		bus:            bus,
+		queue:          make(chan int64, queueSize),
+	}
+}
+
+// RunAttempt runs the next attempt of the invocation (as a worker would)
+func RunAttempt(s Service, invocationID int64) {
+	s.(*service).run(invocationID)
+}
+
+// ReleaseStale releases invocations with expired leases (as the sweeper would)
+func ReleaseStale(s Service) {
+	s.(*service).releaseStale()
+}
@@ -0,0 +1,399 @@
+package invocations
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/tunnels"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// DefaultWorkers number of invocations executed concurrently (per API replica)
+	DefaultWorkers = 8
+	// MaxAttempts how many times an invocation is tried before it fails
+	MaxAttempts = 3
+	// BaseBackoff delay before the first retry (doubled after every attempt)
+	BaseBackoff = 2 * time.Second
+	// MaxBackoff max delay between attempts
+	MaxBackoff = time.Minute
+	// MaxTimeout max timeout of an attempt (same as endpoints.MaxTimeout)
+	MaxTimeout = 5 * time.Minute
+
+	// leaseDuration how long a claimed invocation is left running before
+	// the sweeper assumes its worker is gone (e.g. the API was restarted)
+	// and releases it, longer than any attempt can take
+	leaseDuration = MaxTimeout + time.Minute
+
+	// queueSize number of invocations waiting for a worker, invocations
+	// that do not fit are picked up by the next sweep
+	queueSize = 1000
+	// sweepInterval how often queued invocations are picked up from the storage
+	// (after restarts, full queues or by other replicas) and expired leases released
+	sweepInterval = 30 * time.Second
+	// sweepBatch max number of invocations picked up by a sweep
+	sweepBatch = 100
+
+	// DefaultLimit and MaxLimit page sizes of invocation histories
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Status progress of an invocation
+type Status string
+
+const (
+	StatusQueued    = Status("queued")
+	StatusRunning   = Status("running")
+	StatusSucceeded = Status("succeeded")
+	StatusFailed    = Status("failed")
+)
+
+// Invocation a device invocation executed in the background
+type Invocation struct {
+	ID       int64
+	DeviceID int64
+	// CreatedBy id of the user that requested the invocation
+	CreatedBy int64
+	Pattern   string
+	Request   string
+	// Timeout of each attempt (zero means the tunnels default timeout)
+	Timeout time.Duration
+
+	Status   Status
+	Attempts int
+	Response string
+	// Error of the latest failed attempt
+	Error string
+
+	CreatedAt time.Time
+	StartedAt time.Time
+	// ClaimedAt start of the latest attempt (the lease of running invocations)
+	ClaimedAt     time.Time
+	FinishedAt    time.Time
+	NextAttemptAt time.Time
+}
+
+// Repository defines the invocations.Repository operations
+type Repository interface {
+	Create(inv Invocation) (*Invocation, error)
+	GetByID(invocationID int64) (*Invocation, error)
+	// GetByDeviceID returns the invocations of the device (latest first)
+	GetByDeviceID(deviceID int64, limit int, offset int) ([]Invocation, error)
+	// GetDue returns (at most limit) queued invocations with a next attempt before now
+	GetDue(now time.Time, limit int) ([]Invocation, error)
+	// Claim marks a queued invocation as running (claimed at now) and counts
+	// the attempt, ErrNotQueued is returned if the invocation is not queued
+	Claim(invocationID int64, now time.Time) (*Invocation, error)
+	// Update stores the outcome of an attempt (status, response, error,
+	// finished at and next attempt at). ErrAttemptExpired is returned if the
+	// invocation is no longer running the attempt (inv.Attempts).
+	Update(inv Invocation) error
+	// ReleaseStale re-queues running invocations claimed before claimedBefore,
+	// or fails them if they reached maxAttempts, with reason as their error.
+	// The released invocations are returned.
+	ReleaseStale(claimedBefore time.Time, now time.Time, maxAttempts int, reason string) ([]Invocation, error)
+}
+
+// Service defines the invocations.Service operations
+type Service interface {
+	// Enqueue records an invocation of the device that is executed in the background
+	// (each attempt is limited to timeout, zero means the default timeout).
+	// Attempts that fail with transient errors (offline device, timeout or
+	// unreachable tunnel manager) are retried.
+	Enqueue(deviceID int64, pattern string, data string, timeout time.Duration, createdBy int64) (*Invocation, error)
+	GetByID(invocationID int64) (*Invocation, error)
+	GetByDeviceID(deviceID int64, limit int, offset int) ([]Invocation, error)
+}
+
+type service struct {
+	invocationRepo Repository
+	deviceService  devices.Service
+	tunnelService  tunnels.Service
+	bus            events.Bus
+
+	queue chan int64
+}
+
+// CreateService Create new instance of Invocation Service and
+// start its workers (and the sweeper picking up queued invocations).
+// Completed invocations are published to bus.
+func CreateService(repo Repository, deviceService devices.Service, tunnelService tunnels.Service, bus events.Bus, workers int) Service {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	s := &service{
+		invocationRepo: repo,
+		deviceService:  deviceService,
+		tunnelService:  tunnelService,
+		bus:            bus,
+		queue:          make(chan int64, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	go s.sweep()
+
+	return s
+}
+
+func (s *service) Enqueue(deviceID int64, pattern string, data string, timeout time.Duration, createdBy int64) (*Invocation, error) {
+	if pattern == "" {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Pattern is required",
+		}
+	}
+
+	if timeout < 0 || timeout > MaxTimeout {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid timeout",
+		}
+	}
+
+	_, err := s.deviceService.GetByID(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	now := time.Now()
+	inv, err := s.invocationRepo.Create(Invocation{
+		DeviceID:      deviceID,
+		CreatedBy:     createdBy,
+		Pattern:       pattern,
+		Request:       data,
+		Timeout:       timeout,
+		Status:        StatusQueued,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	})
+	if err != nil {
+		log.Printf("Failed creating invocation of device %d (error: %v)", deviceID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating invocation",
+		}
+	}
+
+	s.push(inv.ID)
+
+	return inv, nil
+}
+
+func (s *service) GetByID(invocationID int64) (*Invocation, error) {
+	inv, err := s.invocationRepo.GetByID(invocationID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvocationNotFoundCode,
+			Message: "Invalid Invocation ID",
+		}
+	}
+
+	return inv, nil
+}
+
+func (s *service) GetByDeviceID(deviceID int64, limit int, offset int) ([]Invocation, error) {
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if limit < 0 || limit > MaxLimit || offset < 0 {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid limit or offset",
+		}
+	}
+
+	_, err := s.deviceService.GetByID(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	invs, err := s.invocationRepo.GetByDeviceID(deviceID, limit, offset)
+	if err != nil {
+		log.Printf("Failed getting invocations of device %d (error: %v)", deviceID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting invocations",
+		}
+	}
+
+	return invs, nil
+}
+
+// push hands the invocation to a worker (without blocking, the
+// sweeper picks it up later if the queue is full)
+func (s *service) push(invocationID int64) {
+	select {
+	case s.queue <- invocationID:
+	default:
+	}
+}
+
+func (s *service) work() {
+	for invocationID := range s.queue {
+		s.run(invocationID)
+	}
+}
+
+// run executes a single attempt of the invocation and schedules
+// a retry if it fails with a transient error
+func (s *service) run(invocationID int64) {
+	inv, err := s.invocationRepo.Claim(invocationID, time.Now())
+	if err != nil {
+		if err != ErrNotQueued {
+			log.Printf("Failed claiming invocation %d (error: %v)", invocationID, err)
+		}
+		return
+	}
+
+	// Attempts always end before the lease expires
+	timeout := tunnels.DefaultInvokeTimeout
+	if inv.Timeout > 0 {
+		timeout = inv.Timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	resp, err := s.tunnelService.InvokeDevice(ctx, inv.DeviceID, inv.Pattern, inv.Request)
+	cancel()
+	now := time.Now()
+	var retryIn time.Duration
+	switch {
+	case err == nil:
+		inv.Status = StatusSucceeded
+		inv.Response = resp.Data
+		inv.Error = ""
+		inv.FinishedAt = now
+	case isTransient(err) && inv.Attempts < MaxAttempts:
+		retryIn = backoff(inv.Attempts)
+		inv.Status = StatusQueued
+		inv.Error = utils.ToServiceErr(err).Message
+		inv.NextAttemptAt = now.Add(retryIn)
+	default:
+		inv.Status = StatusFailed
+		inv.Error = utils.ToServiceErr(err).Message
+		inv.FinishedAt = now
+	}
+
+	err = s.invocationRepo.Update(*inv)
+	if err == ErrAttemptExpired {
+		// Released by the sweeper while running, the outcome is dropped
+		log.Printf("Dropped outcome of expired attempt %d of invocation %d", inv.Attempts, invocationID)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed updating invocation %d (error: %v)", invocationID, err)
+		return
+	}
+
+	if inv.Status == StatusQueued {
+		time.AfterFunc(retryIn, func() { s.push(invocationID) })
+		return
+	}
+	s.publishCompleted(*inv)
+}
+
+// completedEvent data of invocation events
+type completedEvent struct {
+	ID       int64  `json:"id"`
+	Pattern  string `json:"pattern"`
+	Status   Status `json:"status"`
+	Attempts int    `json:"attempts"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (s *service) publishCompleted(inv Invocation) {
+	device, err := s.deviceService.GetByID(inv.DeviceID)
+	if err != nil {
+		return
+	}
+
+	data, _ := json.Marshal(completedEvent{
+		ID:       inv.ID,
+		Pattern:  inv.Pattern,
+		Status:   inv.Status,
+		Attempts: inv.Attempts,
+		Response: inv.Response,
+		Error:    inv.Error,
+	})
+	s.bus.Publish(events.Event{
+		Type:      events.InvocationCompleted,
+		ProjectID: device.ProjectID,
+		DeviceID:  device.ID,
+		Pattern:   inv.Pattern,
+		Data:      data,
+	})
+}
+
+// isTransient reports whether a failed attempt may succeed when retried
+// (errors of the device itself, e.g. unknown patterns, are not retried)
+func isTransient(err error) bool {
+	switch utils.ToServiceErr(err).Code {
+	case tunnels.DeviceOfflineCode, tunnels.TimeoutCode, tunnels.ConnectionErrorCode:
+		return true
+	default:
+		return false
+	}
+}
+
+// sweep periodically releases invocations with expired leases
+// and pushes due invocations to the workers
+func (s *service) sweep() {
+	for {
+		s.releaseStale()
+
+		due, err := s.invocationRepo.GetDue(time.Now(), sweepBatch)
+		if err != nil {
+			log.Printf("Failed getting queued invocations (error: %v)", err)
+		}
+		for _, inv := range due {
+			s.push(inv.ID)
+		}
+
+		time.Sleep(sweepInterval)
+	}
+}
+
+// releaseStale re-queues (or fails) invocations left running by workers
+// that are gone, e.g. the API replica running them was restarted
+func (s *service) releaseStale() {
+	now := time.Now()
+	released, err := s.invocationRepo.ReleaseStale(now.Add(-leaseDuration), now, MaxAttempts,
+		"Attempt did not complete (the API was stopped while running it)")
+	if err != nil {
+		log.Printf("Failed releasing stale invocations (error: %v)", err)
+		return
+	}
+
+	for _, inv := range released {
+		if inv.Status == StatusQueued {
+			s.push(inv.ID)
+			continue
+		}
+		s.publishCompleted(inv)
+	}
+}
+
+// backoff delay before the next attempt after the given number of attempts
+func backoff(attempts int) time.Duration {
+	delay := BaseBackoff
+	for i := 1; i < attempts && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+
+	return delay
+}
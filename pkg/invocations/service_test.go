@@ -0,0 +1,216 @@
+package invocations_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/tunnels"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// scriptedTunnels fails the invocations with the given errors (in order)
+// and then responds "ok". during (if set) runs while the device is invoked.
+type scriptedTunnels struct {
+	tunnels.Service
+	errs   []error
+	during func()
+	calls  int
+}
+
+func (s *scriptedTunnels) InvokeDevice(ctx context.Context, deviceID int64, pattern string, data string) (*tunnels.InvokeResponse, error) {
+	s.calls++
+	if s.during != nil {
+		s.during()
+	}
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return &tunnels.InvokeResponse{Data: "ok"}, nil
+}
+
+var (
+	offlineErr  = &utils.ServiceErr{Code: tunnels.DeviceOfflineCode, Message: "Device is not connected"}
+	canceledErr = &utils.ServiceErr{Code: tunnels.CanceledCode, Message: "Request canceled"}
+)
+
+// newInvocation returns a service (without workers) and a queued invocation
+func newInvocation(t *testing.T, tunnelService tunnels.Service) (invocations.Service, invocations.Repository, *invocations.Invocation) {
+	t.Helper()
+	db := memory.CreateDB()
+	repo := memory.CreateInvocationRepository(db)
+	bus := events.CreateBus()
+	deviceService := devices.CreateDeviceService(memory.CreateDeviceRepository(db), nil, bus)
+	service := invocations.CreateIdleService(repo, deviceService, tunnelService, bus)
+
+	user, err := memory.CreateUserRepository(db).Create(users.User{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	project, err := memory.CreateProjectRepository(db).Create(projects.Project{DisplayName: "project", CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	device, err := deviceService.Create(devices.Device{ProjectID: project.ID, DisplayName: "device"})
+	if err != nil {
+		t.Fatalf("creating device: %v", err)
+	}
+	inv, err := service.Enqueue(device.ID, "led", "on", 0, user.ID)
+	if err != nil {
+		t.Fatalf("enqueuing invocation: %v", err)
+	}
+
+	return service, repo, inv
+}
+
+func getInvocation(t *testing.T, service invocations.Service, invocationID int64) *invocations.Invocation {
+	t.Helper()
+	inv, err := service.GetByID(invocationID)
+	if err != nil {
+		t.Fatalf("getting invocation: %v", err)
+	}
+	return inv
+}
+
+func TestRetryTransientFailure(t *testing.T) {
+	tunnelService := &scriptedTunnels{errs: []error{offlineErr}}
+	service, _, inv := newInvocation(t, tunnelService)
+
+	before := time.Now()
+	invocations.RunAttempt(service, inv.ID)
+	retried := getInvocation(t, service, inv.ID)
+	if retried.Status != invocations.StatusQueued || retried.Attempts != 1 || retried.Error != offlineErr.Message {
+		t.Fatalf("invocation after a transient failure = %+v, want queued after 1 attempt", retried)
+	}
+	if retried.NextAttemptAt.Before(before.Add(invocations.BaseBackoff)) {
+		t.Errorf("next attempt at %v, want at least %v after the attempt", retried.NextAttemptAt, invocations.BaseBackoff)
+	}
+
+	invocations.RunAttempt(service, inv.ID)
+	succeeded := getInvocation(t, service, inv.ID)
+	if succeeded.Status != invocations.StatusSucceeded || succeeded.Attempts != 2 || succeeded.Response != "ok" || succeeded.Error != "" {
+		t.Errorf("invocation after a retry = %+v, want succeeded after 2 attempts", succeeded)
+	}
+
+	// Completed invocations are not run again
+	invocations.RunAttempt(service, inv.ID)
+	if tunnelService.calls != 2 {
+		t.Errorf("device invoked %d times, want 2", tunnelService.calls)
+	}
+}
+
+func TestPermanentFailure(t *testing.T) {
+	service, _, inv := newInvocation(t, &scriptedTunnels{errs: []error{canceledErr}})
+
+	invocations.RunAttempt(service, inv.ID)
+	failed := getInvocation(t, service, inv.ID)
+	if failed.Status != invocations.StatusFailed || failed.Attempts != 1 || failed.Error != canceledErr.Message || failed.FinishedAt.IsZero() {
+		t.Errorf("invocation after a permanent failure = %+v, want failed after 1 attempt", failed)
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	errs := []error{}
+	for i := 0; i < invocations.MaxAttempts+1; i++ {
+		errs = append(errs, offlineErr)
+	}
+	tunnelService := &scriptedTunnels{errs: errs}
+	service, _, inv := newInvocation(t, tunnelService)
+
+	for i := 0; i < invocations.MaxAttempts+1; i++ {
+		invocations.RunAttempt(service, inv.ID)
+	}
+	failed := getInvocation(t, service, inv.ID)
+	if failed.Status != invocations.StatusFailed || failed.Attempts != invocations.MaxAttempts || failed.Error != offlineErr.Message {
+		t.Errorf("invocation after %d transient failures = %+v, want failed after %d attempts", invocations.MaxAttempts, failed, invocations.MaxAttempts)
+	}
+	if tunnelService.calls != invocations.MaxAttempts {
+		t.Errorf("device invoked %d times, want %d", tunnelService.calls, invocations.MaxAttempts)
+	}
+}
+
+func TestExpiredAttemptIsDropped(t *testing.T) {
+	tunnelService := &scriptedTunnels{}
+	service, repo, inv := newInvocation(t, tunnelService)
+
+	// The lease expires while the device is invoked
+	tunnelService.during = func() {
+		now := time.Now()
+		if _, err := repo.ReleaseStale(now.Add(time.Second), now, invocations.MaxAttempts, "released"); err != nil {
+			t.Errorf("releasing invocation: %v", err)
+		}
+	}
+	invocations.RunAttempt(service, inv.ID)
+	released := getInvocation(t, service, inv.ID)
+	if released.Status != invocations.StatusQueued || released.Response != "" || released.Error != "released" {
+		t.Errorf("invocation after an expired attempt = %+v, want released without the attempt outcome", released)
+	}
+
+	tunnelService.during = nil
+	invocations.RunAttempt(service, inv.ID)
+	if succeeded := getInvocation(t, service, inv.ID); succeeded.Status != invocations.StatusSucceeded || succeeded.Attempts != 2 {
+		t.Errorf("invocation after the next attempt = %+v, want succeeded after 2 attempts", succeeded)
+	}
+}
+
+func TestReleaseStale(t *testing.T) {
+	service, repo, inv := newInvocation(t, &scriptedTunnels{})
+
+	// Claimed by a worker that is gone
+	if _, err := repo.Claim(inv.ID, time.Now().Add(-invocations.LeaseDuration-time.Second)); err != nil {
+		t.Fatalf("claiming invocation: %v", err)
+	}
+	invocations.ReleaseStale(service)
+	if released := getInvocation(t, service, inv.ID); released.Status != invocations.StatusQueued || released.Error == "" {
+		t.Errorf("stale invocation = %+v, want queued again", released)
+	}
+
+	// Running attempts keep their lease
+	if _, err := repo.Claim(inv.ID, time.Now()); err != nil {
+		t.Fatalf("claiming invocation: %v", err)
+	}
+	invocations.ReleaseStale(service)
+	if running := getInvocation(t, service, inv.ID); running.Status != invocations.StatusRunning {
+		t.Errorf("running invocation = %+v, want still running", running)
+	}
+}
+
+func TestStaleLastAttemptFails(t *testing.T) {
+	service, repo, inv := newInvocation(t, &scriptedTunnels{})
+
+	for i := 0; i < invocations.MaxAttempts; i++ {
+		if _, err := repo.Claim(inv.ID, time.Now().Add(-invocations.LeaseDuration-time.Second)); err != nil {
+			t.Fatalf("claiming invocation: %v", err)
+		}
+		invocations.ReleaseStale(service)
+	}
+	if failed := getInvocation(t, service, inv.ID); failed.Status != invocations.StatusFailed || failed.Attempts != invocations.MaxAttempts {
+		t.Errorf("invocation after %d stale attempts = %+v, want failed", invocations.MaxAttempts, failed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, invocations.BaseBackoff},
+		{2, 2 * invocations.BaseBackoff},
+		{3, 4 * invocations.BaseBackoff},
+		{10, invocations.MaxBackoff},
+		{100, invocations.MaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := invocations.Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// BundleFormat format identifier of pipeline bundles
+const BundleFormat = "wyrm.pipeline/v1"
+
+// Bundle a portable pipeline definition (see Service.Export). Device ids
+// are project specific, so nodes refer to devices by reference instead:
+// device-invoke configs hold "device": "<ref>" instead of "device_id" (as do
+// event trigger configs), and Devices lists the references used, e.g.
+//
+//	format: wyrm.pipeline/v1
+//	display_name: Cooling
+//	devices:
+//	  - ref: thermostat
+//	    display_name: Thermostat
+//	graph:
+//	  nodes:
+//	    - id: start
+//	      type: trigger
+//	    - id: fan
+//	      type: device-invoke
+//	      config: {device: thermostat, pattern: fan}
+//	  edges:
+//	    - {from: start, to: fan}
+type Bundle struct {
+	Format      string         `json:"format"`
+	DisplayName string         `json:"display_name"`
+	Description string         `json:"description,omitempty"`
+	Devices     []BundleDevice `json:"devices"`
+	Graph       Graph          `json:"graph"`
+}
+
+// BundleDevice a device referenced by a bundle
+type BundleDevice struct {
+	Ref string `json:"ref"`
+	// DisplayName of the exported device, devices of the target project
+	// with the same display name are picked by default on import
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// bundleDeviceField replaces "device_id" in the node configs of bundles
+const bundleDeviceField = "device"
+
+// AssignDeviceRefs gives the devices unique references derived from
+// their display names (e.g. "Living Room Lamp" gets "living-room-lamp")
+func AssignDeviceRefs(ds []devices.Device) map[int64]BundleDevice {
+	refs := make(map[int64]BundleDevice)
+	taken := make(map[string]bool)
+	for _, d := range ds {
+		base := slug(d.DisplayName)
+		if base == "" {
+			base = "device"
+		}
+		ref := base
+		for i := 2; taken[ref]; i++ {
+			ref = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken[ref] = true
+		refs[d.ID] = BundleDevice{Ref: ref, DisplayName: d.DisplayName}
+	}
+
+	return refs
+}
+
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() != 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// NewBundle builds the bundle of the pipeline, refs gives the references of
+// the devices (every device of the pipeline needs one, see AssignDeviceRefs)
+func NewBundle(p Pipeline, refs map[int64]BundleDevice) (*Bundle, error) {
+	g, err := ParseGraph(p.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pipeline data (%v)", err)
+	}
+
+	b := Bundle{
+		Format:      BundleFormat,
+		DisplayName: p.DisplayName,
+		Description: p.Description,
+		Devices:     []BundleDevice{},
+		Graph:       Graph{Nodes: make([]Node, len(g.Nodes)), Edges: g.Edges},
+	}
+	used := make(map[string]bool)
+	for i, node := range g.Nodes {
+		node.Config, err = mapDeviceField(node, "device_id", bundleDeviceField, func(value interface{}) (interface{}, error) {
+			deviceID, err := toDeviceID(value)
+			if err != nil {
+				return nil, err
+			}
+			device, ok := refs[deviceID]
+			if !ok {
+				return nil, fmt.Errorf("Device %d has no reference", deviceID)
+			}
+			if !used[device.Ref] {
+				used[device.Ref] = true
+				b.Devices = append(b.Devices, device)
+			}
+			return device.Ref, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Node %q: %v", node.ID, err)
+		}
+		b.Graph.Nodes[i] = node
+	}
+	if b.Graph.Edges == nil {
+		b.Graph.Edges = []Edge{}
+	}
+
+	return &b, nil
+}
+
+// bundleData replaces the device references of the bundle graph by the
+// device ids of deviceIDs (by reference), returning the pipeline data
+func bundleData(b Bundle, deviceIDs map[string]int64) (string, []GraphIssue) {
+	issues := []GraphIssue{}
+	g := Graph{Nodes: make([]Node, len(b.Graph.Nodes)), Edges: b.Graph.Edges}
+	for i, node := range b.Graph.Nodes {
+		location := fmt.Sprintf("/graph/nodes/%d/config", i)
+		config, err := mapDeviceField(node, bundleDeviceField, "device_id", func(value interface{}) (interface{}, error) {
+			ref, _ := value.(string)
+			deviceID, ok := deviceIDs[ref]
+			if !ok {
+				return nil, fmt.Errorf("Unknown device reference %q", ref)
+			}
+			return deviceID, nil
+		})
+		if err != nil {
+			issues = append(issues, GraphIssue{Location: location, Message: err.Error()})
+			continue
+		}
+		node.Config = config
+		g.Nodes[i] = node
+	}
+	if len(issues) != 0 {
+		return "", issues
+	}
+	if g.Edges == nil {
+		g.Edges = []Edge{}
+	}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		return "", []GraphIssue{{Location: "/graph", Message: err.Error()}}
+	}
+
+	return string(data), nil
+}
+
+// mapDeviceField renames the device field of device-invoke and trigger
+// (event) node configs from "from" to "to", replacing its value with
+// replace. Other node configs are returned as is.
+func mapDeviceField(node Node, from string, to string, replace func(interface{}) (interface{}, error)) (json.RawMessage, error) {
+	if node.Type != DeviceInvokeNode && node.Type != TriggerNode {
+		return node.Config, nil
+	}
+	if len(node.Config) == 0 || string(node.Config) == "null" {
+		return node.Config, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(node.Config))
+	decoder.UseNumber()
+	var config map[string]interface{}
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("Invalid config (%v)", err)
+	}
+
+	fields := config
+	if node.Type == TriggerNode {
+		event, ok := config["event"].(map[string]interface{})
+		if !ok {
+			return node.Config, nil
+		}
+		fields = event
+	}
+	if _, ok := fields[to]; ok {
+		return nil, fmt.Errorf("Unexpected %q (devices are given by %q)", to, from)
+	}
+	value, ok := fields[from]
+	if !ok {
+		return nil, fmt.Errorf("Missing %q", from)
+	}
+
+	replaced, err := replace(value)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, from)
+	fields[to] = replaced
+
+	return json.Marshal(config)
+}
+
+func toDeviceID(value interface{}) (int64, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("Invalid device_id %v", value)
+	}
+	deviceID, err := number.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("Invalid device_id %v", value)
+	}
+	return deviceID, nil
+}
+
+func (s *service) Export(ctx context.Context, pipelineID int64) (*Bundle, error) {
+	pipeline, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	g, err := ParseGraph(pipeline.Data)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidPipelineCode,
+			Message: fmt.Sprintf("Invalid pipeline data (%v)", err),
+		}
+	}
+
+	// Devices deleted since the pipeline was saved are exported without
+	// a display name (they have to be mapped explicitly on import)
+	var pipelineDevices []devices.Device
+	seen := make(map[int64]bool)
+	for _, deviceID := range graphDeviceIDs(g) {
+		if seen[deviceID] {
+			continue
+		}
+		seen[deviceID] = true
+		device, err := s.deviceService.GetByID(deviceID)
+		if err != nil {
+			device = &devices.Device{ID: deviceID}
+		}
+		pipelineDevices = append(pipelineDevices, *device)
+	}
+
+	bundle, err := NewBundle(*pipeline, AssignDeviceRefs(pipelineDevices))
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidPipelineCode,
+			Message: err.Error(),
+		}
+	}
+
+	return bundle, nil
+}
+
+// graphDeviceIDs returns the devices referenced by the graph nodes
+// (in node order, ignoring invalid configs)
+func graphDeviceIDs(g *Graph) []int64 {
+	var deviceIDs []int64
+	for _, node := range g.Nodes {
+		switch node.Type {
+		case DeviceInvokeNode:
+			var config DeviceInvokeConfig
+			if node.DecodeConfig(&config) == nil {
+				deviceIDs = append(deviceIDs, config.DeviceID)
+			}
+		case TriggerNode:
+			var config TriggerConfig
+			if node.DecodeConfig(&config) == nil && config.Event != nil {
+				deviceIDs = append(deviceIDs, config.Event.DeviceID)
+			}
+		}
+	}
+	return deviceIDs
+}
+
+// Import creates a pipeline in the project from the bundle. deviceIDs maps
+// device references of the bundle to devices of the project, unmapped
+// references are resolved to the project device with the same display name.
+func (s *service) Import(ctx context.Context, projectID int64, createdBy int64, b Bundle, deviceIDs map[string]int64) (*Pipeline, error) {
+	if b.Format != BundleFormat {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Unsupported bundle format %q (expected %q)", b.Format, BundleFormat),
+		}
+	}
+
+	projectDevices, err := s.deviceService.GetByProjectID(projectID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	issues := []GraphIssue{}
+	refs := make(map[string]bool)
+	resolved := make(map[string]int64)
+	for i, device := range b.Devices {
+		location := fmt.Sprintf("/devices/%d", i)
+		if device.Ref == "" || refs[device.Ref] {
+			issues = append(issues, GraphIssue{Location: location + "/ref", Message: "Device references should be unique and not empty"})
+			continue
+		}
+		refs[device.Ref] = true
+
+		if deviceID, ok := deviceIDs[device.Ref]; ok {
+			// Checked against the project by the graph validation
+			resolved[device.Ref] = deviceID
+			continue
+		}
+
+		var matches []int64
+		for _, d := range projectDevices {
+			if device.DisplayName != "" && d.DisplayName == device.DisplayName {
+				matches = append(matches, d.ID)
+			}
+		}
+		switch len(matches) {
+		case 1:
+			resolved[device.Ref] = matches[0]
+		case 0:
+			issues = append(issues, GraphIssue{Location: location, Message: fmt.Sprintf("No device named %q in the project, map %q to a device", device.DisplayName, device.Ref)})
+		default:
+			issues = append(issues, GraphIssue{Location: location, Message: fmt.Sprintf("%d devices named %q in the project, map %q to one of them", len(matches), device.DisplayName, device.Ref)})
+		}
+	}
+	for ref := range deviceIDs {
+		if !refs[ref] {
+			issues = append(issues, GraphIssue{Location: "/devices", Message: fmt.Sprintf("Unknown device reference %q", ref)})
+		}
+	}
+	if len(issues) != 0 {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Unresolved device references",
+			Details: issues,
+		}
+	}
+
+	data, issues := bundleData(b, resolved)
+	if len(issues) != 0 {
+		return nil, invalidPipelineErr(issues)
+	}
+
+	return s.Create(ctx, Pipeline{
+		DisplayName: b.DisplayName,
+		Description: b.Description,
+		Data:        data,
+		ProjectID:   projectID,
+		CreatedBy:   createdBy,
+	})
+}
@@ -0,0 +1,163 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// evaluate compares the input value at config.Path with config.Value
+// (references in config.Value are resolved against the input too)
+func (config ConditionConfig) evaluate(input interface{}) (bool, error) {
+	segments, err := parseRef(config.Path)
+	if err != nil {
+		return false, err
+	}
+	actual, found := lookup(input, segments)
+
+	if config.Operator == ExistsOperator {
+		return found && actual != nil, nil
+	}
+	if !found {
+		return false, fmt.Errorf("Reference %q not found in the node input", config.Path)
+	}
+
+	expected, err := resolveRefs(config.Value, input)
+	if err != nil {
+		return false, err
+	}
+
+	switch config.Operator {
+	case EqualOperator:
+		return equal(actual, expected), nil
+	case NotEqualOperator:
+		return !equal(actual, expected), nil
+	case GreaterOperator, GreaterOrEqualOperator, LessOperator, LessOrEqualOperator:
+		c, err := compare(actual, expected)
+		if err != nil {
+			return false, err
+		}
+		switch config.Operator {
+		case GreaterOperator:
+			return c > 0, nil
+		case GreaterOrEqualOperator:
+			return c >= 0, nil
+		case LessOperator:
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case ContainsOperator:
+		return contains(actual, expected)
+	default:
+		return false, fmt.Errorf("Unknown operator %q", config.Operator)
+	}
+}
+
+// toNumber converts decoded JSON numbers (json.Number or float64)
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// normalize converts the numbers of the value to float64
+// so that equal values compare equal however they were decoded
+func normalize(v interface{}) interface{} {
+	if n, ok := toNumber(v); ok {
+		return n
+	}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			normalized[k] = normalize(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, item := range value {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	}
+	return v
+}
+
+func equal(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// compare orders two numbers or two strings
+func compare(a interface{}, b interface{}) (int, error) {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("Can not compare %s with %s", typeName(a), typeName(b))
+}
+
+// contains checks substrings of strings, elements of arrays and keys of objects
+func contains(container interface{}, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("Strings can only contain strings (got %s)", typeName(item))
+		}
+		return strings.Contains(c, s), nil
+	case []interface{}:
+		for _, element := range c {
+			if equal(element, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("Object keys are strings (got %s)", typeName(item))
+		}
+		_, found := c[key]
+		return found, nil
+	}
+	return false, fmt.Errorf("Can not look for values in %s", typeName(container))
+}
+
+// typeName JSON type of a decoded value
+func typeName(v interface{}) string {
+	if _, ok := toNumber(v); ok {
+		return "number"
+	}
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,49 @@
+package pipelines
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	input := decodeValue(`{"temp": 31, "name": "fan-1", "tags": ["a", "b"], "limits": {"max": 30}, "none": null}`)
+
+	tests := []struct {
+		name    string
+		config  ConditionConfig
+		want    bool
+		wantErr bool
+	}{
+		{"equal", ConditionConfig{"$.temp", EqualOperator, 31.0}, true, false},
+		{"equal decoded number", ConditionConfig{"$.temp", EqualOperator, decodeValue("31")}, true, false},
+		{"not equal", ConditionConfig{"$.temp", NotEqualOperator, 30.0}, true, false},
+		{"equal array", ConditionConfig{"$.tags", EqualOperator, []interface{}{"a", "b"}}, true, false},
+		{"greater", ConditionConfig{"$.temp", GreaterOperator, 30.0}, true, false},
+		{"not greater", ConditionConfig{"$.temp", GreaterOperator, 31.0}, false, false},
+		{"greater or equal", ConditionConfig{"$.temp", GreaterOrEqualOperator, 31.0}, true, false},
+		{"less", ConditionConfig{"$.temp", LessOperator, 31.0}, false, false},
+		{"less or equal", ConditionConfig{"$.temp", LessOrEqualOperator, 31.0}, true, false},
+		{"reference value", ConditionConfig{"$.temp", GreaterOperator, "$.limits.max"}, true, false},
+		{"strings", ConditionConfig{"$.name", LessOperator, "fan-2"}, true, false},
+		{"substring", ConditionConfig{"$.name", ContainsOperator, "fan"}, true, false},
+		{"array element", ConditionConfig{"$.tags", ContainsOperator, "b"}, true, false},
+		{"missing array element", ConditionConfig{"$.tags", ContainsOperator, "c"}, false, false},
+		{"object key", ConditionConfig{"$.limits", ContainsOperator, "max"}, true, false},
+		{"exists", ConditionConfig{"$.temp", ExistsOperator, nil}, true, false},
+		{"missing", ConditionConfig{"$.humidity", ExistsOperator, nil}, false, false},
+		{"null", ConditionConfig{"$.none", ExistsOperator, nil}, false, false},
+		{"missing path", ConditionConfig{"$.humidity", GreaterOperator, 30.0}, false, true},
+		{"number and string", ConditionConfig{"$.temp", GreaterOperator, "30"}, false, true},
+		{"number in string", ConditionConfig{"$.name", ContainsOperator, 1.0}, false, true},
+		{"contains in number", ConditionConfig{"$.temp", ContainsOperator, 1.0}, false, true},
+		{"unknown operator", ConditionConfig{"$.temp", Operator("between"), 30.0}, false, true},
+		{"invalid path", ConditionConfig{"temp", EqualOperator, 31.0}, false, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.config.evaluate(input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("evaluate(%s) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("evaluate(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,16 @@ import "github.com/tnynlabs/wyrm/pkg/utils"
 const (
 	WorkerConnectionErrorCode = utils.ServiceErrCode("WORKER_CONN_ERROR")
 	PipelineNotFoundCode      = utils.ServiceErrCode("PIPELINE_NOT_FOUND")
+	RunNotFoundCode           = utils.ServiceErrCode("RUN_NOT_FOUND")
+	ScheduleNotFoundCode      = utils.ServiceErrCode("SCHEDULE_NOT_FOUND")
+	VersionNotFoundCode       = utils.ServiceErrCode("VERSION_NOT_FOUND")
 	ProjectNotFoundCode       = utils.ServiceErrCode("PROJECT_NOT_FOUND")
 	InvalidInputCode          = utils.ServiceErrCode("INVALID_INPUT")
+	InvalidPipelineCode       = utils.ServiceErrCode("INVALID_PIPELINE")
+	TimeoutCode               = utils.ServiceErrCode("TIMEOUT")
+	CanceledCode              = utils.ServiceErrCode("CANCELED")
+	WebhookDisabledCode       = utils.ServiceErrCode("WEBHOOK_DISABLED")
+	InvalidSignatureCode      = utils.ServiceErrCode("INVALID_SIGNATURE")
+	ForbiddenIPCode           = utils.ServiceErrCode("FORBIDDEN_IP")
+	DeliveryInProgressCode    = utils.ServiceErrCode("DELIVERY_IN_PROGRESS")
 )
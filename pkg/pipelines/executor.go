@@ -0,0 +1,350 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxHTTPResponseSize max size of response bodies read by http-request nodes
+const maxHTTPResponseSize = 1 << 20
+
+// invokeFunc sends data to a device endpoint and returns the device response
+type invokeFunc func(ctx context.Context, deviceID int64, pattern string, data string) (string, error)
+
+// executor interprets pipeline graphs
+type executor struct {
+	invoke     invokeFunc
+	httpClient *http.Client
+	// mocks (by node id) replace nodes with side effects in simulations
+	// (nil for real runs, see Service.Simulate)
+	mocks map[string]Mock
+}
+
+// execute runs the graph with the payload. Nodes run one at a time in
+// topological order, a node runs if one of the edges leading to it was
+// taken and its input is the output of the latest such node (nodes that
+// can not be reached are skipped). send is called with the progress of
+// every node and finally with the run completion. Failed nodes fail the
+// run (reported through send), errors are only returned if the context
+// is done or send fails.
+func (e *executor) execute(ctx context.Context, g *Graph, payload string, send func(*protobuf.RunProgress) error) error {
+	outputs := make(map[string]interface{})
+	branches := make(map[string]string)
+	// order in which nodes ran (to find the latest input of joins)
+	ran := make(map[string]int)
+
+	var output interface{}
+	for _, n := range g.order() {
+		var (
+			input interface{}
+			taken bool
+		)
+		if n.Type == TriggerNode {
+			input, taken = decodeValue(payload), true
+		}
+		latest := -1
+		for _, edge := range g.Edges {
+			seq, ok := ran[edge.From]
+			if edge.To != n.ID || !ok || seq < latest {
+				continue
+			}
+			if branch, ok := branches[edge.From]; ok && branch != edge.Branch {
+				continue
+			}
+			input, taken, latest = outputs[edge.From], true, seq
+		}
+
+		if !taken {
+			err := sendNode(send, &protobuf.NodeProgress{
+				NodeId: n.ID,
+				Status: protobuf.NodeStatus_NODE_SKIPPED,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		startedAt := timestamppb.Now()
+		err := sendNode(send, &protobuf.NodeProgress{
+			NodeId:    n.ID,
+			Status:    protobuf.NodeStatus_NODE_RUNNING,
+			Input:     encodeValue(input),
+			StartedAt: startedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		nodeOutput, branch, nodeErr := e.runNode(ctx, n, input)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if nodeErr != nil {
+			err = sendNode(send, &protobuf.NodeProgress{
+				NodeId:     n.ID,
+				Status:     protobuf.NodeStatus_NODE_FAILED,
+				Input:      encodeValue(input),
+				Error:      nodeErr.Error(),
+				StartedAt:  startedAt,
+				FinishedAt: timestamppb.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			return send(&protobuf.RunProgress{
+				Progress: &protobuf.RunProgress_Completed{Completed: &protobuf.RunCompleted{
+					Failed: true,
+					Error:  fmt.Sprintf("Node %q failed (%v)", n.ID, nodeErr),
+				}},
+			})
+		}
+
+		outputs[n.ID] = nodeOutput
+		if n.Type == ConditionNode {
+			branches[n.ID] = branch
+		}
+		ran[n.ID] = len(ran)
+		output = nodeOutput
+
+		err = sendNode(send, &protobuf.NodeProgress{
+			NodeId:     n.ID,
+			Status:     protobuf.NodeStatus_NODE_SUCCEEDED,
+			Input:      encodeValue(input),
+			Output:     encodeValue(nodeOutput),
+			Branch:     branch,
+			StartedAt:  startedAt,
+			FinishedAt: timestamppb.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return send(&protobuf.RunProgress{
+		Progress: &protobuf.RunProgress_Completed{Completed: &protobuf.RunCompleted{
+			Output: encodeValue(output),
+		}},
+	})
+}
+
+func sendNode(send func(*protobuf.RunProgress) error, node *protobuf.NodeProgress) error {
+	return send(&protobuf.RunProgress{
+		Progress: &protobuf.RunProgress_Node{Node: node},
+	})
+}
+
+// runNode returns the output of the node (and the branch taken by condition nodes)
+func (e *executor) runNode(ctx context.Context, n Node, input interface{}) (interface{}, string, error) {
+	if e.mocks != nil {
+		if output, mocked, err := e.mockNode(n, input); mocked {
+			return output, "", err
+		}
+	}
+
+	switch n.Type {
+	case TriggerNode:
+		return input, "", nil
+	case DeviceInvokeNode:
+		var config DeviceInvokeConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, "", err
+		}
+		output, err := e.invokeDevice(ctx, config, input)
+		return output, "", err
+	case TransformNode:
+		var config TransformConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, "", err
+		}
+		output, err := resolveRefs(config.Output, input)
+		return output, "", err
+	case ConditionNode:
+		var config ConditionConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, "", err
+		}
+		ok, err := config.evaluate(input)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			return input, TrueBranch, nil
+		}
+		return input, FalseBranch, nil
+	case HTTPRequestNode:
+		var config HTTPRequestConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, "", err
+		}
+		output, err := e.sendHTTPRequest(ctx, config, input)
+		return output, "", err
+	case DelayNode:
+		var config DelayConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, "", err
+		}
+		timer := time.NewTimer(time.Duration(config.DurationMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return input, "", nil
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	default:
+		return nil, "", fmt.Errorf("Unknown node type %q", n.Type)
+	}
+}
+
+func (e *executor) invokeDevice(ctx context.Context, config DeviceInvokeConfig, input interface{}) (interface{}, error) {
+	payload := input
+	if config.Payload != nil {
+		var err error
+		payload, err = resolveRefs(config.Payload, input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Devices receive strings as is and other values JSON encoded
+	data, ok := payload.(string)
+	if !ok {
+		data = encodeValue(payload)
+	}
+
+	resp, err := e.invoke(ctx, config.DeviceID, config.Pattern, data)
+	if err != nil {
+		var serviceErr *utils.ServiceErr
+		if errors.As(err, &serviceErr) {
+			return nil, errors.New(serviceErr.Message)
+		}
+		return nil, err
+	}
+
+	return decodeValue(resp), nil
+}
+
+func (e *executor) sendHTTPRequest(ctx context.Context, config HTTPRequestConfig, input interface{}) (interface{}, error) {
+	var body io.Reader
+	if config.Body != nil || (config.Method != http.MethodGet && config.Method != http.MethodDelete) {
+		value := input
+		if config.Body != nil {
+			var err error
+			value, err = resolveRefs(config.Body, input)
+			if err != nil {
+				return nil, err
+			}
+		}
+		body = bytes.NewReader([]byte(encodeValue(value)))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, config.Method, config.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range config.Headers {
+		resolved, err := resolveRefs(value, input)
+		if err != nil {
+			return nil, err
+		}
+		headerValue, ok := resolved.(string)
+		if !ok {
+			headerValue = encodeValue(resolved)
+		}
+		req.Header.Set(name, headerValue)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("Request failed with status %d", resp.StatusCode)
+	}
+
+	return decodeValue(string(respBody)), nil
+}
+
+// order returns the nodes in topological order (nodes are otherwise
+// kept in the order they are declared in)
+func (g *Graph) order() []Node {
+	incoming := make(map[string]int)
+	for _, e := range g.Edges {
+		incoming[e.To]++
+	}
+
+	ordered := make([]Node, 0, len(g.Nodes))
+	done := make(map[string]bool)
+	for len(ordered) < len(g.Nodes) {
+		progressed := false
+		for _, n := range g.Nodes {
+			if done[n.ID] || incoming[n.ID] != 0 {
+				continue
+			}
+			done[n.ID] = true
+			ordered = append(ordered, n)
+			for _, e := range g.Outgoing(n.ID) {
+				incoming[e.To]--
+			}
+			progressed = true
+			break
+		}
+		// Cycles are rejected on save, stop rather than loop forever
+		if !progressed {
+			break
+		}
+	}
+
+	return ordered
+}
+
+// decodeValue decodes JSON data (data that is not valid JSON is
+// kept as a string, empty data is null)
+func decodeValue(data string) interface{} {
+	if data == "" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return data
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return data
+	}
+
+	return value
+}
+
+// encodeValue JSON encodes a decoded value
+func encodeValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(value))
+	}
+	return string(data)
+}
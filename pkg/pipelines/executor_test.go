@@ -0,0 +1,165 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines/protobuf"
+)
+
+// coolingGraph turns the fan on when it is hot, both branches join in
+// "report" (nodes are not declared in topological order)
+const coolingGraph = `{
+	"nodes": [
+		{"id": "report", "type": "transform", "config": {"output": {"result": "$"}}},
+		{"id": "fan", "type": "device-invoke", "config": {"device_id": 1, "pattern": "fan", "payload": {"speed": "$.temp"}}},
+		{"id": "start", "type": "trigger"},
+		{"id": "hot", "type": "condition", "config": {"path": "$.temp", "operator": "gt", "value": 30}},
+		{"id": "idle", "type": "transform", "config": {"output": "idle"}}
+	],
+	"edges": [
+		{"from": "start", "to": "hot"},
+		{"from": "hot", "to": "fan", "branch": "true"},
+		{"from": "hot", "to": "idle", "branch": "false"},
+		{"from": "fan", "to": "report"},
+		{"from": "idle", "to": "report"}
+	]
+}`
+
+// trace progress of a run sent by the executor
+type trace struct {
+	// node id and status of every finished (or skipped) node, in order
+	nodes     []string
+	branches  map[string]string
+	completed *protobuf.RunCompleted
+}
+
+func executeGraph(t *testing.T, e *executor, data string, payload string) *trace {
+	t.Helper()
+	g, err := ParseGraph(data)
+	if err != nil {
+		t.Fatalf("ParseGraph: %v", err)
+	}
+
+	tr := &trace{branches: map[string]string{}}
+	err = e.execute(context.Background(), g, payload, func(progress *protobuf.RunProgress) error {
+		if node := progress.GetNode(); node != nil && node.Status != protobuf.NodeStatus_NODE_RUNNING {
+			tr.nodes = append(tr.nodes, node.NodeId+" "+node.Status.String())
+			if node.Branch != "" {
+				tr.branches[node.NodeId] = node.Branch
+			}
+		}
+		if completed := progress.GetCompleted(); completed != nil {
+			tr.completed = completed
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if tr.completed == nil {
+		t.Fatal("execute did not complete the run")
+	}
+	return tr
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		invokeErr error
+		nodes     []string
+		branch    string
+		invoked   []string
+		failed    bool
+		output    string
+	}{
+		{
+			name:    "true branch",
+			payload: `{"temp": 31}`,
+			nodes:   []string{"start NODE_SUCCEEDED", "hot NODE_SUCCEEDED", "fan NODE_SUCCEEDED", "idle NODE_SKIPPED", "report NODE_SUCCEEDED"},
+			branch:  TrueBranch,
+			invoked: []string{`{"speed":31}`},
+			output:  `{"result":{"on":true}}`,
+		},
+		{
+			name:    "false branch",
+			payload: `{"temp": 20}`,
+			nodes:   []string{"start NODE_SUCCEEDED", "hot NODE_SUCCEEDED", "fan NODE_SKIPPED", "idle NODE_SUCCEEDED", "report NODE_SUCCEEDED"},
+			branch:  FalseBranch,
+			output:  `{"result":"idle"}`,
+		},
+		{
+			name:      "failed node",
+			payload:   `{"temp": 31}`,
+			invokeErr: errors.New("device offline"),
+			nodes:     []string{"start NODE_SUCCEEDED", "hot NODE_SUCCEEDED", "fan NODE_FAILED"},
+			branch:    TrueBranch,
+			invoked:   []string{`{"speed":31}`},
+			failed:    true,
+		},
+		{
+			name:    "failed condition",
+			payload: `{"humidity": 80}`,
+			nodes:   []string{"start NODE_SUCCEEDED", "hot NODE_FAILED"},
+			failed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invoked []string
+			e := &executor{
+				invoke: func(ctx context.Context, deviceID int64, pattern string, data string) (string, error) {
+					if deviceID != 1 || pattern != "fan" {
+						t.Errorf("invoked device %d endpoint %q, want device 1 endpoint fan", deviceID, pattern)
+					}
+					invoked = append(invoked, data)
+					return `{"on": true}`, tt.invokeErr
+				},
+			}
+
+			tr := executeGraph(t, e, coolingGraph, tt.payload)
+			if !reflect.DeepEqual(tr.nodes, tt.nodes) {
+				t.Errorf("nodes %v, want %v", tr.nodes, tt.nodes)
+			}
+			if tr.branches["hot"] != tt.branch {
+				t.Errorf("branch %q, want %q", tr.branches["hot"], tt.branch)
+			}
+			if !reflect.DeepEqual(invoked, tt.invoked) {
+				t.Errorf("invoked with %v, want %v", invoked, tt.invoked)
+			}
+			if tr.completed.Failed != tt.failed || (!tt.failed && tr.completed.Output != tt.output) {
+				t.Errorf("completed %+v, want failed %v with output %s", tr.completed, tt.failed, tt.output)
+			}
+		})
+	}
+}
+
+func TestExecuteJoinTakesLatestInput(t *testing.T) {
+	data := `{
+		"nodes": [
+			{"id": "start", "type": "trigger"},
+			{"id": "a", "type": "transform", "config": {"output": "a"}},
+			{"id": "b", "type": "transform", "config": {"output": "b"}},
+			{"id": "join", "type": "transform", "config": {"output": "$"}}
+		],
+		"edges": [
+			{"from": "start", "to": "b"},
+			{"from": "start", "to": "a"},
+			{"from": "b", "to": "join"},
+			{"from": "a", "to": "join"}
+		]
+	}`
+
+	tr := executeGraph(t, &executor{}, data, "")
+	want := []string{"start NODE_SUCCEEDED", "a NODE_SUCCEEDED", "b NODE_SUCCEEDED", "join NODE_SUCCEEDED"}
+	if !reflect.DeepEqual(tr.nodes, want) {
+		t.Errorf("nodes %v, want %v", tr.nodes, want)
+	}
+	// b ran after a (nodes are ordered by declaration, not by edges)
+	if tr.completed.Failed || tr.completed.Output != `"b"` {
+		t.Errorf("completed %+v, want the output of b", tr.completed)
+	}
+}
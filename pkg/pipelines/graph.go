@@ -0,0 +1,211 @@
+package pipelines
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+)
+
+// Pipeline.Data holds the JSON encoded pipeline graph, e.g.
+//
+//	{
+//	    "nodes": [
+//	        {"id": "start", "type": "trigger"},
+//	        {"id": "hot", "type": "condition", "config": {"path": "$.temp", "operator": "gt", "value": 30}},
+//	        {"id": "fan", "type": "device-invoke", "config": {"device_id": 1, "pattern": "fan", "payload": {"speed": "$.temp"}}}
+//	    ],
+//	    "edges": [
+//	        {"from": "start", "to": "hot"},
+//	        {"from": "hot", "to": "fan", "branch": "true"}
+//	    ]
+//	}
+//
+// Each node receives the output of the node before it (the trigger outputs
+// the run payload). Strings starting with "$" in node configs refer to
+// values of the node input ("$" is the whole input, "$.a.b[0]" a nested value).
+
+type NodeType string
+
+const (
+	// TriggerNode entry point of the pipeline, outputs the run payload
+	// (optionally started by device events, see TriggerConfig)
+	TriggerNode = NodeType("trigger")
+	// DeviceInvokeNode invokes a device endpoint, outputs the device response
+	DeviceInvokeNode = NodeType("device-invoke")
+	// TransformNode outputs a new value built from its input
+	TransformNode = NodeType("transform")
+	// ConditionNode continues through its "true" or "false" edges
+	ConditionNode = NodeType("condition")
+	// HTTPRequestNode sends an HTTP request, outputs the response body
+	HTTPRequestNode = NodeType("http-request")
+	// DelayNode waits before passing its input through
+	DelayNode = NodeType("delay")
+)
+
+// Branches of condition nodes
+const (
+	TrueBranch  = "true"
+	FalseBranch = "false"
+)
+
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+type Node struct {
+	ID   string   `json:"id"`
+	Type NodeType `json:"type"`
+	// Name optional human readable name
+	Name   string          `json:"name,omitempty"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Branch of the condition node the edge starts from (TrueBranch or FalseBranch)
+	Branch string `json:"branch,omitempty"`
+}
+
+// Node configurations (Node.Config) by node type
+
+type TriggerConfig struct {
+	// Event (optional) starts the pipeline on device events too (besides
+	// webhooks, manual and scheduled runs), the event is the run payload
+	Event *EventTrigger `json:"event,omitempty"`
+}
+
+type EventTrigger struct {
+	Type     events.Type `json:"type"`
+	DeviceID int64       `json:"device_id"`
+	// Pattern of the device messages (required by "device.message" events)
+	Pattern string `json:"pattern,omitempty"`
+	// Metric (optional) of the datapoints ("telemetry" events only)
+	Metric string `json:"metric,omitempty"`
+	// Condition (optional) the event has to match, e.g.
+	// {"path": "$.data.value", "operator": "gt", "value": 30}
+	Condition *ConditionConfig `json:"condition,omitempty"`
+}
+
+type DeviceInvokeConfig struct {
+	DeviceID int64  `json:"device_id"`
+	Pattern  string `json:"pattern"`
+	// Payload sent to the device (the node input if omitted)
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+type TransformConfig struct {
+	// Output value of the node (references are replaced by input values)
+	Output interface{} `json:"output"`
+}
+
+type ConditionConfig struct {
+	// Path reference to the input value to compare (e.g. "$.temp")
+	Path     string      `json:"path"`
+	Operator Operator    `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+type HTTPRequestConfig struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body sent as JSON (the node input if omitted)
+	Body interface{} `json:"body,omitempty"`
+}
+
+type DelayConfig struct {
+	DurationMs int64 `json:"duration_ms"`
+}
+
+type Operator string
+
+const (
+	EqualOperator          = Operator("eq")
+	NotEqualOperator       = Operator("ne")
+	GreaterOperator        = Operator("gt")
+	GreaterOrEqualOperator = Operator("gte")
+	LessOperator           = Operator("lt")
+	LessOrEqualOperator    = Operator("lte")
+	ContainsOperator       = Operator("contains")
+	ExistsOperator         = Operator("exists")
+)
+
+// ParseGraph decodes pipeline data (see Graph)
+func ParseGraph(data string) (*Graph, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.DisallowUnknownFields()
+
+	var g Graph
+	err := decoder.Decode(&g)
+	if err != nil {
+		return nil, err
+	}
+
+	return &g, nil
+}
+
+// DecodeConfig decodes the node configuration into v
+// (one of the node configuration types)
+func (n Node) DecodeConfig(v interface{}) error {
+	config := n.Config
+	if len(config) == 0 || string(config) == "null" {
+		config = json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+// Node returns the node with the given ID (nil if not found)
+func (g *Graph) Node(nodeID string) *Node {
+	for i := range g.Nodes {
+		if g.Nodes[i].ID == nodeID {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Trigger returns the trigger node (nil if not found)
+func (g *Graph) Trigger() *Node {
+	for i := range g.Nodes {
+		if g.Nodes[i].Type == TriggerNode {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Outgoing returns the edges starting from the node
+func (g *Graph) Outgoing(nodeID string) []Edge {
+	edges := []Edge{}
+	for _, e := range g.Edges {
+		if e.From == nodeID {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// TotalDelay returns the sum of the durations of the delay nodes
+// (the longest a run can spend waiting, as nodes run one at a time)
+func (g *Graph) TotalDelay() time.Duration {
+	var total time.Duration
+	for _, n := range g.Nodes {
+		if n.Type != DelayNode {
+			continue
+		}
+		var config DelayConfig
+		if err := n.DecodeConfig(&config); err == nil && config.DurationMs > 0 {
+			total += time.Duration(config.DurationMs) * time.Millisecond
+		}
+	}
+	return total
+}
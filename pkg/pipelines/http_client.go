@@ -0,0 +1,93 @@
+package pipelines
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTPRequestTimeout max duration of a request of an http-request node
+// (requests are canceled earlier if the run deadline is reached)
+const HTTPRequestTimeout = 30 * time.Second
+
+// blockedNetworks http-request nodes can not connect to (loopback, private,
+// shared and link-local addresses, e.g. internal services and cloud metadata)
+var blockedNetworks = mustParseNetworks("0.0.0.0/8,127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16," +
+	"100.64.0.0/10,169.254.0.0/16,::/128,::1/128,fc00::/7,fe80::/10")
+
+// ParseNetworks parses comma separated CIDRs (e.g. "10.1.0.0/16,192.168.1.10/32")
+func ParseNetworks(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(s, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func mustParseNetworks(s string) []*net.IPNet {
+	networks, err := ParseNetworks(s)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// newHTTPClient client of http-request nodes. Connections to blocked
+// networks are refused unless the address is in one of the allowed
+// networks. Addresses are checked when connecting (after name resolution
+// and for every redirect), so DNS names can not be used to get around it.
+func newHTTPClient(allowed []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			return checkAddress(address, allowed)
+		},
+	}
+
+	// No proxy, requests would not be checked against the target address
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &http.Client{Transport: transport, Timeout: HTTPRequestTimeout}
+}
+
+func checkAddress(address string, allowed []*net.IPNet) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("Invalid address %q", host)
+	}
+
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("Requests to %s are not allowed", ip)
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("Requests to internal address %s are not allowed", ip)
+		}
+	}
+
+	return nil
+}
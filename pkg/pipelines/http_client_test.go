@@ -0,0 +1,42 @@
+package pipelines
+
+import "testing"
+
+func TestCheckAddress(t *testing.T) {
+	allowed := mustParseNetworks("10.1.0.0/16")
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"100.64.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"0.0.0.0:80", false},
+		{"224.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"[::]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		// IPv4-mapped IPv6 addresses are checked as IPv4 addresses
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		{"[::ffff:93.184.216.34]:80", true},
+		// Allowed networks take precedence over blocked ones
+		{"10.1.2.3:80", true},
+		{"[::ffff:10.1.2.3]:80", true},
+		{"localhost:80", false},
+		{"127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		err := checkAddress(tt.address, allowed)
+		if got := err == nil; got != tt.allowed {
+			t.Errorf("checkAddress(%q) error = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+)
+
+// runEvent data of run.started and run.completed events (the payload
+// and node results are left out, see nodeEvent and Service.GetRun)
+type runEvent struct {
+	ID              int64      `json:"id"`
+	PipelineID      int64      `json:"pipeline_id"`
+	PipelineVersion int        `json:"pipeline_version,omitempty"`
+	Trigger         Trigger    `json:"trigger"`
+	Status          RunStatus  `json:"status"`
+	Output          string     `json:"output,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// nodeEvent data of run.node events
+type nodeEvent struct {
+	RunID      int64      `json:"run_id"`
+	PipelineID int64      `json:"pipeline_id"`
+	Node       NodeResult `json:"node"`
+}
+
+// publishPipeline publishes a change of the pipeline (with its id only)
+func (s *service) publishPipeline(t events.Type, p *Pipeline) {
+	s.bus.Publish(events.Event{
+		Type:      t,
+		ProjectID: p.ProjectID,
+		Data:      events.ResourceData(p.ID),
+	})
+}
+
+func (s *service) publishRun(t events.Type, projectID int64, run *Run) {
+	e := runEvent{
+		ID:              run.ID,
+		PipelineID:      run.PipelineID,
+		PipelineVersion: run.PipelineVersion,
+		Trigger:         run.Trigger,
+		Status:          run.Status,
+		Output:          run.Output,
+		Error:           run.Error,
+		StartedAt:       run.StartedAt,
+	}
+	if !run.FinishedAt.IsZero() {
+		e.FinishedAt = &run.FinishedAt
+	}
+
+	data, _ := json.Marshal(e)
+	s.bus.Publish(events.Event{
+		Type:      t,
+		ProjectID: projectID,
+		Data:      data,
+	})
+}
+
+func (s *service) publishNode(projectID int64, run *Run, result NodeResult) {
+	data, _ := json.Marshal(nodeEvent{
+		RunID:      run.ID,
+		PipelineID: run.PipelineID,
+		Node:       result,
+	})
+	s.bus.Publish(events.Event{
+		Type:      events.RunNode,
+		ProjectID: projectID,
+		Data:      data,
+	})
+}
@@ -0,0 +1,163 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// References are strings starting with "$" that refer to values of
+// the node input: "$" is the whole input, "$.a.b" a field of a nested
+// object and "$.a[0]" an array element. Strings starting with "$$" are
+// not references (the first "$" is dropped).
+
+var errInvalidRef = errors.New(`References should look like "$", "$.field" or "$.list[0]"`)
+
+// isRef reports whether the string is a reference
+func isRef(s string) bool {
+	return strings.HasPrefix(s, "$") && !strings.HasPrefix(s, "$$")
+}
+
+// parseRef splits a reference into its segments, strings for object
+// fields and ints for array indexes
+func parseRef(ref string) ([]interface{}, error) {
+	if !isRef(ref) {
+		return nil, errInvalidRef
+	}
+
+	segments := []interface{}{}
+	rest := ref[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return nil, errInvalidRef
+			}
+			segments = append(segments, field)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errInvalidRef
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, errInvalidRef
+			}
+			segments = append(segments, index)
+			rest = rest[end+1:]
+		default:
+			return nil, errInvalidRef
+		}
+	}
+
+	return segments, nil
+}
+
+// checkRefs validates the syntax of every reference in the value,
+// report is called with the JSON pointer of each invalid reference
+func checkRefs(v interface{}, location string, report func(location string, err error)) {
+	switch value := v.(type) {
+	case string:
+		if isRef(value) {
+			if _, err := parseRef(value); err != nil {
+				report(location, err)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			checkRefs(value[k], location+"/"+escapePointer(k), report)
+		}
+	case []interface{}:
+		for i, item := range value {
+			checkRefs(item, location+"/"+strconv.Itoa(i), report)
+		}
+	}
+}
+
+// escapePointer escapes a JSON pointer token (RFC 6901)
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// lookup returns the value the reference points to in the input
+// (found is false if a field or index is missing)
+func lookup(input interface{}, segments []interface{}) (value interface{}, found bool) {
+	value = input
+	for _, segment := range segments {
+		switch s := segment.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[s]; !ok {
+				return nil, false
+			}
+		case int:
+			list, ok := value.([]interface{})
+			if !ok || s >= len(list) {
+				return nil, false
+			}
+			value = list[s]
+		}
+	}
+	return value, true
+}
+
+// resolveRefs returns a copy of the value where every reference is
+// replaced by the input value it points to (and "$$" escapes are dropped)
+func resolveRefs(v interface{}, input interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		if strings.HasPrefix(value, "$$") {
+			return value[1:], nil
+		}
+		if !isRef(value) {
+			return value, nil
+		}
+		segments, err := parseRef(value)
+		if err != nil {
+			return nil, err
+		}
+		resolved, found := lookup(input, segments)
+		if !found {
+			return nil, fmt.Errorf("Reference %q not found in the node input", value)
+		}
+		return resolved, nil
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			r, err := resolveRefs(item, input)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(value))
+		for i, item := range value {
+			r, err := resolveRefs(item, input)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,102 @@
+package pipelines
+
+import "time"
+
+const (
+	// DefaultRunsLimit and MaxRunsLimit page sizes of run histories
+	DefaultRunsLimit = 50
+	MaxRunsLimit     = 500
+)
+
+// Trigger what started a pipeline run
+type Trigger string
+
+const (
+	TriggerWebhook  = Trigger("webhook")
+	TriggerManual   = Trigger("manual")
+	TriggerSchedule = Trigger("schedule")
+	TriggerEvent    = Trigger("event")
+	// TriggerSimulation simulated runs (never recorded, see Service.Simulate)
+	TriggerSimulation = Trigger("simulation")
+)
+
+// RunStatus progress of a pipeline run
+type RunStatus string
+
+const (
+	RunRunning   = RunStatus("running")
+	RunSucceeded = RunStatus("succeeded")
+	RunFailed    = RunStatus("failed")
+)
+
+// NodeStatus progress of a node of a pipeline run
+type NodeStatus string
+
+const (
+	NodeRunning   = NodeStatus("running")
+	NodeSucceeded = NodeStatus("succeeded")
+	NodeFailed    = NodeStatus("failed")
+	NodeSkipped   = NodeStatus("skipped")
+)
+
+// Run a single execution of a pipeline
+type Run struct {
+	ID         int64
+	PipelineID int64
+	// PipelineVersion version of the pipeline that ran
+	// (zero for runs recorded before pipelines were versioned)
+	PipelineVersion int
+	Trigger         Trigger
+	// Payload the pipeline was triggered with
+	Payload string
+
+	Status RunStatus
+	// Output JSON encoded output of the last executed node
+	Output string
+	Error  string
+	// Nodes results of the executed nodes (in execution order)
+	Nodes []NodeResult
+
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// NodeResult execution of a single node of a run
+type NodeResult struct {
+	NodeID string     `json:"node_id"`
+	Status NodeStatus `json:"status"`
+	// Input and Output JSON encoded values
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Branch taken by condition nodes
+	Branch string `json:"branch,omitempty"`
+
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+type RunRepository interface {
+	Create(run Run) (*Run, error)
+	GetByID(runID int64) (*Run, error)
+	// GetByPipelineID returns the runs of the pipeline (latest first)
+	GetByPipelineID(pipelineID int64, limit int, offset int) ([]Run, error)
+	// Update stores the progress of the run (status, output, error,
+	// nodes and finished at)
+	Update(run Run) error
+}
+
+// setNode records the progress of a node (replacing
+// the previous progress of the same node)
+func (run *Run) setNode(result NodeResult) {
+	for i := range run.Nodes {
+		if run.Nodes[i].NodeID == result.NodeID {
+			if result.StartedAt.IsZero() {
+				result.StartedAt = run.Nodes[i].StartedAt
+			}
+			run.Nodes[i] = result
+			return
+		}
+	}
+	run.Nodes = append(run.Nodes, result)
+}
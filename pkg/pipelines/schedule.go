@@ -0,0 +1,241 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// MissedRunPolicy what happens to runs that were due while no
+// scheduler was running (e.g. during deployments)
+type MissedRunPolicy string
+
+const (
+	// SkipMissedRuns missed runs are dropped
+	SkipMissedRuns = MissedRunPolicy("skip")
+	// CatchUpMissedRuns missed runs are started late (at most MaxCatchUpRuns)
+	CatchUpMissedRuns = MissedRunPolicy("catch-up")
+)
+
+// Schedule runs a pipeline periodically
+type Schedule struct {
+	ID         int64
+	PipelineID int64
+	// Cron standard cron expression ("minute hour day month weekday")
+	// or descriptor (e.g. "@hourly", "@every 15m")
+	Cron string
+	// Timezone the cron expression is evaluated in (IANA name, e.g. "Europe/Berlin")
+	Timezone   string
+	MissedRuns MissedRunPolicy
+	// Payload passed to the runs (see scheduleEvent)
+	Payload string
+	Enabled bool
+
+	LastRunAt time.Time
+	NextRunAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ErrScheduleMoved the schedule is not due anymore (fired by another
+// replica or updated in the meantime)
+var ErrScheduleMoved = errors.New("Schedule moved")
+
+type ScheduleRepository interface {
+	Create(sch Schedule) (*Schedule, error)
+	GetByID(scheduleID int64) (*Schedule, error)
+	GetByPipelineID(pipelineID int64) ([]Schedule, error)
+	// Update stores the cron expression, timezone, missed runs policy,
+	// payload, enabled and next run at of the schedule
+	Update(sch Schedule) (*Schedule, error)
+	Delete(scheduleID int64) error
+	// GetDue returns (at most limit) enabled schedules with a next run before now
+	GetDue(now time.Time, limit int) ([]Schedule, error)
+	// Advance moves the schedule to its next run if its next run is still
+	// dueAt, ErrScheduleMoved is returned otherwise
+	Advance(scheduleID int64, dueAt time.Time, lastRunAt time.Time, nextRunAt time.Time) error
+}
+
+// scheduleEvent payload of scheduled runs
+type scheduleEvent struct {
+	ScheduleID  int64     `json:"schedule_id"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	// Data the schedule payload (decoded if it is JSON)
+	Data interface{} `json:"data"`
+}
+
+// parseCron parses the cron expression of a schedule
+// and loads the timezone it is evaluated in
+func parseCron(expr string, timezone string) (cron.Schedule, *time.Location, error) {
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, nil, errors.New("Set the timezone instead of prefixing the cron expression")
+	}
+
+	cronSchedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid cron expression (%v)", err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unknown timezone %q", timezone)
+	}
+
+	return cronSchedule, loc, nil
+}
+
+// nextRun first run of the schedule after t
+func nextRun(cronSchedule cron.Schedule, loc *time.Location, t time.Time) time.Time {
+	return cronSchedule.Next(t.In(loc))
+}
+
+// validateSchedule checks the schedule (defaulting its timezone to UTC and
+// its missed runs policy to skip) and computes its next run after now
+func validateSchedule(sch *Schedule, now time.Time) error {
+	if sch.Timezone == "" {
+		sch.Timezone = "UTC"
+	}
+	if sch.MissedRuns == "" {
+		sch.MissedRuns = SkipMissedRuns
+	}
+	if sch.MissedRuns != SkipMissedRuns && sch.MissedRuns != CatchUpMissedRuns {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Missed runs policy should be %q or %q", SkipMissedRuns, CatchUpMissedRuns),
+		}
+	}
+
+	cronSchedule, loc, err := parseCron(sch.Cron, sch.Timezone)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: err.Error(),
+		}
+	}
+	sch.NextRunAt = nextRun(cronSchedule, loc, now)
+	if sch.NextRunAt.IsZero() {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Cron expression never matches",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) CreateSchedule(ctx context.Context, pipelineID int64, sch Schedule) (*Schedule, error) {
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	sch.PipelineID = pipelineID
+	if err := validateSchedule(&sch, time.Now()); err != nil {
+		return nil, err
+	}
+
+	newSchedule, err := s.scheduleRepo.Create(sch)
+	if err != nil {
+		log.Printf("Failed creating pipeline schedule (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating pipeline schedule",
+		}
+	}
+
+	return newSchedule, nil
+}
+
+func (s *service) GetSchedules(ctx context.Context, pipelineID int64) ([]Schedule, error) {
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	schedules, err := s.scheduleRepo.GetByPipelineID(pipelineID)
+	if err != nil {
+		log.Printf("Failed getting pipeline schedules (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting pipeline schedules",
+		}
+	}
+
+	return schedules, nil
+}
+
+func (s *service) GetSchedule(ctx context.Context, scheduleID int64) (*Schedule, error) {
+	sch, err := s.scheduleRepo.GetByID(scheduleID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ScheduleNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return sch, nil
+}
+
+// UpdateSchedule replaces the schedule settings, the next run is computed
+// from now (runs missed before the update are dropped)
+func (s *service) UpdateSchedule(ctx context.Context, scheduleID int64, sch Schedule) (*Schedule, error) {
+	current, err := s.scheduleRepo.GetByID(scheduleID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ScheduleNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	sch.ID = current.ID
+	sch.PipelineID = current.PipelineID
+	if err := validateSchedule(&sch, time.Now()); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.scheduleRepo.Update(sch)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ScheduleNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return updated, nil
+}
+
+func (s *service) DeleteSchedule(ctx context.Context, scheduleID int64) error {
+	err := s.scheduleRepo.Delete(scheduleID)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    ScheduleNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+	return nil
+}
+
+// setNextRun fills the next run of the pipeline (earliest next run of
+// its enabled schedules)
+func (s *service) setNextRun(p *Pipeline) {
+	schedules, err := s.scheduleRepo.GetByPipelineID(p.ID)
+	if err != nil {
+		log.Printf("Failed getting schedules of pipeline %d (error: %v)", p.ID, err)
+		return
+	}
+
+	for _, sch := range schedules {
+		if sch.Enabled && (p.NextRunAt.IsZero() || sch.NextRunAt.Before(p.NextRunAt)) {
+			p.NextRunAt = sch.NextRunAt
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package pipelines
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", value, err)
+	}
+	return parsed
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		cron     string
+		timezone string
+		valid    bool
+	}{
+		{"*/5 * * * *", "UTC", true},
+		{"0 9 * * MON-FRI", "Europe/Berlin", true},
+		{"@hourly", "UTC", true},
+		{"@every 15m", "UTC", true},
+		{"0 9 * * *", "Mars/Olympus", false},
+		{"TZ=Europe/Berlin 0 9 * * *", "UTC", false},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", "UTC", false},
+		{"* * * * * *", "UTC", false},
+		{"61 * * * *", "UTC", false},
+		{"", "UTC", false},
+	}
+	for _, tt := range tests {
+		_, _, err := parseCron(tt.cron, tt.timezone)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("parseCron(%q, %q) error = %v, want valid %v", tt.cron, tt.timezone, err, tt.valid)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		cron     string
+		timezone string
+		after    string
+		want     string
+	}{
+		{"every 5 minutes", "*/5 * * * *", "UTC", "2021-06-01T12:03:10Z", "2021-06-01T12:05:00Z"},
+		{"exactly due", "*/5 * * * *", "UTC", "2021-06-01T12:05:00Z", "2021-06-01T12:10:00Z"},
+		{"summer time", "0 9 * * *", "America/New_York", "2021-06-01T12:00:00Z", "2021-06-01T13:00:00Z"},
+		{"winter time", "0 9 * * *", "America/New_York", "2021-01-15T12:00:00Z", "2021-01-15T14:00:00Z"},
+		{"across the end of summer time", "0 9 * * *", "America/New_York", "2021-11-06T14:00:00Z", "2021-11-07T14:00:00Z"},
+		{"weekdays", "0 9 * * MON-FRI", "Europe/Berlin", "2021-06-04T08:00:00Z", "2021-06-07T07:00:00Z"},
+		{"positive offset", "30 0 * * *", "Asia/Kolkata", "2021-06-01T00:00:00Z", "2021-06-01T19:00:00Z"},
+		{"every", "@every 15m", "UTC", "2021-06-01T12:03:00Z", "2021-06-01T12:18:00Z"},
+	}
+	for _, tt := range tests {
+		cronSchedule, loc, err := parseCron(tt.cron, tt.timezone)
+		if err != nil {
+			t.Fatalf("parseCron(%q, %q): %v", tt.cron, tt.timezone, err)
+		}
+		got := nextRun(cronSchedule, loc, mustTime(t, tt.after))
+		if want := mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: nextRun = %v, want %v", tt.name, got.UTC(), want)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := mustTime(t, "2021-06-01T12:03:00Z")
+
+	sch := Schedule{Cron: "*/5 * * * *"}
+	if err := validateSchedule(&sch, now); err != nil {
+		t.Fatalf("validateSchedule: %v", err)
+	}
+	if sch.Timezone != "UTC" || sch.MissedRuns != SkipMissedRuns {
+		t.Errorf("defaults = %q, %q, want UTC, %q", sch.Timezone, sch.MissedRuns, SkipMissedRuns)
+	}
+	if want := mustTime(t, "2021-06-01T12:05:00Z"); !sch.NextRunAt.Equal(want) {
+		t.Errorf("NextRunAt = %v, want %v", sch.NextRunAt, want)
+	}
+
+	invalid := []Schedule{
+		{Cron: "*/5 * * * *", MissedRuns: "run-twice"},
+		{Cron: "not cron"},
+		{Cron: "0 0 30 2 *"}, // February 30th never happens
+	}
+	for _, sch := range invalid {
+		if err := validateSchedule(&sch, now); err == nil {
+			t.Errorf("validateSchedule(%q, %q) did not fail", sch.Cron, sch.MissedRuns)
+		}
+	}
+}
+
+// advanceRecorder records the last advance of a schedule
+type advanceRecorder struct {
+	ScheduleRepository
+	lastRunAt time.Time
+	nextRunAt time.Time
+}
+
+func (r *advanceRecorder) Advance(scheduleID int64, dueAt time.Time, lastRunAt time.Time, nextRunAt time.Time) error {
+	r.lastRunAt = lastRunAt
+	r.nextRunAt = nextRunAt
+	return nil
+}
+
+// runRecorder records the payloads of the started runs
+type runRecorder struct {
+	Service
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	payloads []string
+}
+
+func (r *runRecorder) RunPipeline(ctx context.Context, pipelineID int64, trigger Trigger, payload string) (*Run, error) {
+	defer r.wg.Done()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads = append(r.payloads, payload)
+	return &Run{}, nil
+}
+
+// fireSchedule fires the schedule at now, waits for the expected number of
+// runs and returns their (sorted) scheduled times
+func fireSchedule(t *testing.T, sch Schedule, now time.Time, runs int) ([]time.Time, *advanceRecorder) {
+	t.Helper()
+	advances := &advanceRecorder{}
+	runner := &runRecorder{}
+	runner.wg.Add(runs)
+	CreateScheduler(runner, advances, nil, 0).fire(sch, now)
+	runner.wg.Wait()
+
+	scheduled := []time.Time{}
+	for _, payload := range runner.payloads {
+		event, ok := decodeValue(payload).(map[string]interface{})
+		if !ok {
+			t.Fatalf("invalid schedule payload %q", payload)
+		}
+		scheduledAt, _ := event["scheduled_at"].(string)
+		scheduled = append(scheduled, mustTime(t, scheduledAt))
+	}
+	sort.Slice(scheduled, func(i, j int) bool { return scheduled[i].Before(scheduled[j]) })
+	return scheduled, advances
+}
+
+func TestSchedulerFire(t *testing.T) {
+	now := mustTime(t, "2021-06-01T12:00:30Z")
+
+	tests := []struct {
+		name       string
+		nextRunAt  string
+		missedRuns MissedRunPolicy
+		want       []string
+	}{
+		{"on time", "2021-06-01T12:00:00Z", SkipMissedRuns, []string{"2021-06-01T12:00:00Z"}},
+		{"skip missed runs", "2021-06-01T11:00:00Z", SkipMissedRuns, []string{"2021-06-01T12:00:00Z"}},
+		{"catch up missed runs", "2021-06-01T11:55:00Z", CatchUpMissedRuns, []string{"2021-06-01T11:55:00Z", "2021-06-01T11:58:00Z", "2021-06-01T11:59:00Z", "2021-06-01T12:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every minute, except 11:56 and 11:57
+			sch := Schedule{
+				ID:         1,
+				Cron:       "0-55,58-59 * * * *",
+				Timezone:   "UTC",
+				MissedRuns: tt.missedRuns,
+				NextRunAt:  mustTime(t, tt.nextRunAt),
+			}
+
+			got, advances := fireSchedule(t, sch, now, len(tt.want))
+			if len(got) != len(tt.want) {
+				t.Fatalf("started %d runs (%v), want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if want := mustTime(t, tt.want[i]); !got[i].Equal(want) {
+					t.Errorf("run %d scheduled at %v, want %v", i, got[i], want)
+				}
+			}
+			if want := mustTime(t, "2021-06-01T12:01:00Z"); !advances.nextRunAt.Equal(want) {
+				t.Errorf("advanced to %v, want %v", advances.nextRunAt, want)
+			}
+		})
+	}
+}
+
+func TestSchedulerCatchUpLimit(t *testing.T) {
+	now := mustTime(t, "2021-06-01T12:00:30Z")
+	sch := Schedule{
+		ID:         1,
+		Cron:       "* * * * *",
+		Timezone:   "UTC",
+		MissedRuns: CatchUpMissedRuns,
+		NextRunAt:  mustTime(t, "2021-06-01T10:00:00Z"),
+	}
+
+	got, advances := fireSchedule(t, sch, now, MaxCatchUpRuns)
+	if len(got) != MaxCatchUpRuns {
+		t.Fatalf("started %d runs, want %d", len(got), MaxCatchUpRuns)
+	}
+	// The latest runs are started
+	if first := mustTime(t, "2021-06-01T11:51:00Z"); !got[0].Equal(first) {
+		t.Errorf("first run scheduled at %v, want %v", got[0], first)
+	}
+	if last := mustTime(t, "2021-06-01T12:00:00Z"); !got[len(got)-1].Equal(last) || !advances.lastRunAt.Equal(last) {
+		t.Errorf("last run scheduled at %v (last run at %v), want %v", got[len(got)-1], advances.lastRunAt, last)
+	}
+}
@@ -0,0 +1,153 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultSchedulerInterval how often the scheduler looks for due schedules
+	DefaultSchedulerInterval = 10 * time.Second
+	// MissedRunTolerance how late a run may start before it counts as missed
+	MissedRunTolerance = time.Minute
+	// MaxCatchUpRuns max number of missed runs (the latest ones) started
+	// for a schedule when catching up
+	MaxCatchUpRuns = 10
+
+	// scheduleBatch max number of due schedules fired per tick
+	scheduleBatch = 100
+)
+
+// Leader elects the single API replica running the scheduler
+type Leader interface {
+	// IsLeader reports whether this replica leads, trying to
+	// take the lead if no other replica holds it
+	IsLeader(ctx context.Context) (bool, error)
+}
+
+// Scheduler starts the runs of pipeline schedules (on the leader replica only)
+type Scheduler struct {
+	pipelineService Service
+	scheduleRepo    ScheduleRepository
+	leader          Leader
+	interval        time.Duration
+}
+
+// CreateScheduler pipelineService starts the runs, interval is how often
+// due schedules are looked for (DefaultSchedulerInterval if zero)
+func CreateScheduler(pipelineService Service, scheduleRepo ScheduleRepository, leader Leader, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+
+	return &Scheduler{
+		pipelineService: pipelineService,
+		scheduleRepo:    scheduleRepo,
+		leader:          leader,
+		interval:        interval,
+	}
+}
+
+// Run fires due schedules until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	wasLeader := false
+	for {
+		isLeader, err := s.leader.IsLeader(ctx)
+		if err != nil {
+			log.Printf("Failed electing pipeline scheduler leader (error: %v)", err)
+		}
+		if isLeader != wasLeader {
+			log.Printf("Pipeline scheduler leader: %v", isLeader)
+			wasLeader = isLeader
+		}
+		if isLeader {
+			s.tick(time.Now())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) tick(now time.Time) {
+	due, err := s.scheduleRepo.GetDue(now, scheduleBatch)
+	if err != nil {
+		log.Printf("Failed getting due pipeline schedules (error: %v)", err)
+		return
+	}
+
+	for _, sch := range due {
+		s.fire(sch, now)
+	}
+}
+
+// fire starts the runs of the schedule that are due and moves it to its
+// next run. Runs late by more than MissedRunTolerance are missed, they are
+// dropped or started according to the schedule policy.
+func (s *Scheduler) fire(sch Schedule, now time.Time) {
+	cronSchedule, loc, err := parseCron(sch.Cron, sch.Timezone)
+	if err != nil {
+		log.Printf("Invalid pipeline schedule %d (error: %v)", sch.ID, err)
+		return
+	}
+
+	first := sch.NextRunAt
+	missed := false
+	if sch.MissedRuns != CatchUpMissedRuns && now.Sub(first) > MissedRunTolerance {
+		// Jump over the missed runs (rather than walking through them)
+		first = nextRun(cronSchedule, loc, now.Add(-MissedRunTolerance-time.Second))
+		missed = true
+	}
+
+	runs := []time.Time{}
+	for t := first; !t.IsZero() && !t.After(now); t = nextRun(cronSchedule, loc, t) {
+		runs = append(runs, t)
+		if len(runs) > MaxCatchUpRuns {
+			runs = runs[1:]
+			missed = true
+		}
+	}
+	if missed {
+		log.Printf("Pipeline schedule %d missed runs (policy: %s)", sch.ID, sch.MissedRuns)
+	}
+
+	lastRunAt := sch.LastRunAt
+	if len(runs) != 0 {
+		lastRunAt = runs[len(runs)-1]
+	}
+	// Claim the runs before starting them so that no other
+	// replica (e.g. a former leader) starts them too
+	err = s.scheduleRepo.Advance(sch.ID, sch.NextRunAt, lastRunAt, nextRun(cronSchedule, loc, now))
+	if errors.Is(err, ErrScheduleMoved) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed advancing pipeline schedule %d (error: %v)", sch.ID, err)
+		return
+	}
+
+	for _, scheduledAt := range runs {
+		go s.run(sch, scheduledAt)
+	}
+}
+
+func (s *Scheduler) run(sch Schedule, scheduledAt time.Time) {
+	payload := encodeValue(scheduleEvent{
+		ScheduleID:  sch.ID,
+		ScheduledAt: scheduledAt,
+		Data:        decodeValue(sch.Payload),
+	})
+
+	_, err := s.pipelineService.RunPipeline(context.Background(), sch.PipelineID, TriggerSchedule, payload)
+	if err != nil {
+		log.Printf("Failed running pipeline %d of schedule %d (error: %v)", sch.PipelineID, sch.ID, err)
+	}
+}
@@ -2,50 +2,134 @@ package pipelines
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"time"
 
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/events"
 	"github.com/tnynlabs/wyrm/pkg/pipelines/protobuf"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Pipeline struct {
 	ID          int64
 	DisplayName string
+	// Data JSON encoded pipeline graph (see Graph)
 	Data        string
 	Description string
 	ProjectID   int64
 	CreatedBy   int64
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// Version number of the current version (see Version)
+	Version int
+	// UpdatedBy author of an update, recorded on the
+	// version it creates (not stored on the pipeline)
+	UpdatedBy int64
+	// NextRunAt earliest next run of the pipeline schedules
+	// (zero if it has no enabled schedule, not stored)
+	NextRunAt time.Time
 }
 
 type Repository interface {
 	GetByID(pipelineID int64) (*Pipeline, error)
 	GetByProjectID(projectID int64) ([]Pipeline, error)
+	// GetAll returns the pipelines of every project
+	GetAll() ([]Pipeline, error)
+	// Create stores the pipeline and its first version
 	Create(p Pipeline) (*Pipeline, error)
+	// Update stores the changes of the pipeline as its next version
 	Update(pipelineID int64, pipeline Pipeline) (*Pipeline, error)
+	// Replace stores the display name, description and data of the pipeline
+	// as they are (empty values included) as its next version
+	Replace(pipelineID int64, pipeline Pipeline) (*Pipeline, error)
 	Delete(pipelineID int64) error
 }
 
+// DefaultRunTimeout max time to wait for the worker to run a pipeline,
+// in addition to the delays of its graph (used when the caller does not
+// set a deadline, e.g. scheduled and event triggered runs)
+const DefaultRunTimeout = 30 * time.Second
+
+// runTimeout default timeout of runs of the graph
+func runTimeout(g *Graph) time.Duration {
+	return DefaultRunTimeout + g.TotalDelay()
+}
+
 type Service interface {
-	GetByID(pipelineID int64) (*Pipeline, error)
-	GetByProjectID(projectID int64) ([]Pipeline, error)
-	Create(p Pipeline) (*Pipeline, error)
-	Update(pipelineID int64, pipeline Pipeline) (*Pipeline, error)
-	Delete(pipelineID int64) error
-	RunPipeline(pipelineID int64, payload string) error
+	GetByID(ctx context.Context, pipelineID int64) (*Pipeline, error)
+	GetByProjectID(ctx context.Context, projectID int64) ([]Pipeline, error)
+	Create(ctx context.Context, p Pipeline) (*Pipeline, error)
+	Update(ctx context.Context, pipelineID int64, pipeline Pipeline) (*Pipeline, error)
+	Delete(ctx context.Context, pipelineID int64) error
+	// RunPipeline runs the pipeline and records the run (and the progress
+	// of every node). Runs failed by the pipeline itself are not errors,
+	// their status is RunFailed.
+	RunPipeline(ctx context.Context, pipelineID int64, trigger Trigger, payload string) (*Run, error)
+	GetRuns(ctx context.Context, pipelineID int64, limit int, offset int) ([]Run, error)
+	GetRun(ctx context.Context, runID int64) (*Run, error)
+	// Simulate runs the pipeline (or the draft graph data) with mocked
+	// device-invoke and http-request nodes and returns the trace
+	Simulate(ctx context.Context, pipelineID int64, data string, payload string, mocks map[string]Mock) (*Run, error)
+
+	GetVersions(ctx context.Context, pipelineID int64, limit int, offset int) ([]Version, error)
+	GetVersion(ctx context.Context, pipelineID int64, version int) (*Version, error)
+	DiffVersions(ctx context.Context, pipelineID int64, from int, to int) (*VersionDiff, error)
+	Rollback(ctx context.Context, pipelineID int64, version int, userID int64) (*Pipeline, error)
+
+	// Export returns the pipeline as a portable bundle (see Bundle)
+	Export(ctx context.Context, pipelineID int64) (*Bundle, error)
+	// Import creates a pipeline in the project from a bundle
+	// (deviceIDs maps the bundle device references to project devices)
+	Import(ctx context.Context, projectID int64, createdBy int64, b Bundle, deviceIDs map[string]int64) (*Pipeline, error)
+
+	CreateSchedule(ctx context.Context, pipelineID int64, sch Schedule) (*Schedule, error)
+	GetSchedules(ctx context.Context, pipelineID int64) ([]Schedule, error)
+	GetSchedule(ctx context.Context, scheduleID int64) (*Schedule, error)
+	UpdateSchedule(ctx context.Context, scheduleID int64, sch Schedule) (*Schedule, error)
+	DeleteSchedule(ctx context.Context, scheduleID int64) error
+
+	GetWebhook(ctx context.Context, pipelineID int64) (*Webhook, error)
+	// UpdateWebhook replaces the IP allowlist of the webhook
+	UpdateWebhook(ctx context.Context, pipelineID int64, allowedIPs []string) (*Webhook, error)
+	RotateWebhookSecret(ctx context.Context, pipelineID int64, gracePeriod time.Duration) (*Webhook, error)
+	// HandleWebhook authenticates the webhook request and starts a run of the pipeline
+	HandleWebhook(ctx context.Context, pipelineID int64, req WebhookRequest) (run *Run, replayed bool, err error)
+
+	// HandleEvent starts (in the background) the pipelines triggered by the device event
+	HandleEvent(e events.Event)
+	// LoadTriggers rebuilds the event triggers from the stored pipelines
+	LoadTriggers() error
 }
 
 type service struct {
-	pipelineRepo Repository
-	client       protobuf.PipelineWorkerClient
+	pipelineRepo    Repository
+	runRepo         RunRepository
+	versionRepo     VersionRepository
+	scheduleRepo    ScheduleRepository
+	webhookRepo     WebhookRepository
+	client          protobuf.PipelineWorkerClient
+	deviceService   devices.Service
+	endpointService endpoints.Service
+	bus             events.Bus
+
+	triggers *triggerRegistry
+	// eventRuns limits the event triggered runs in progress
+	eventRuns chan struct{}
 }
 
-func CreateService(repo Repository, workerAddr string) (Service, error) {
+// CreateService deviceService and endpointService are used to check
+// the devices and endpoints referenced by pipelines, pipeline changes
+// and run progress are published to bus
+func CreateService(repo Repository, runRepo RunRepository, versionRepo VersionRepository, scheduleRepo ScheduleRepository, webhookRepo WebhookRepository, deviceService devices.Service, endpointService endpoints.Service, bus events.Bus, workerAddr string) (Service, error) {
 	log.Println((workerAddr))
 	conn, err := grpc.Dial(workerAddr, grpc.WithInsecure())
 	if err != nil {
@@ -53,14 +137,28 @@ func CreateService(repo Repository, workerAddr string) (Service, error) {
 		return nil, err
 	}
 	workerClient := protobuf.NewPipelineWorkerClient(conn)
-	svc := service{
-		pipelineRepo: repo,
-		client:       workerClient,
+	return CreateServiceWithWorker(repo, runRepo, versionRepo, scheduleRepo, webhookRepo, deviceService, endpointService, bus, workerClient), nil
+}
+
+// CreateServiceWithWorker runs pipelines with the given worker
+// (e.g. the embedded worker, see CreateEmbeddedWorker)
+func CreateServiceWithWorker(repo Repository, runRepo RunRepository, versionRepo VersionRepository, scheduleRepo ScheduleRepository, webhookRepo WebhookRepository, deviceService devices.Service, endpointService endpoints.Service, bus events.Bus, worker protobuf.PipelineWorkerClient) Service {
+	return &service{
+		pipelineRepo:    repo,
+		runRepo:         runRepo,
+		versionRepo:     versionRepo,
+		scheduleRepo:    scheduleRepo,
+		webhookRepo:     webhookRepo,
+		client:          worker,
+		deviceService:   deviceService,
+		endpointService: endpointService,
+		bus:             bus,
+		triggers:        newTriggerRegistry(),
+		eventRuns:       make(chan struct{}, MaxConcurrentEventRuns),
 	}
-	return &svc, nil
 }
 
-func (s *service) GetByID(pipelineID int64) (*Pipeline, error) {
+func (s *service) GetByID(ctx context.Context, pipelineID int64) (*Pipeline, error) {
 	pipeline, err := s.pipelineRepo.GetByID(pipelineID)
 	if err != nil {
 		return nil, &utils.ServiceErr{
@@ -68,11 +166,12 @@ func (s *service) GetByID(pipelineID int64) (*Pipeline, error) {
 			Message: "Invalid ID",
 		}
 	}
+	s.setNextRun(pipeline)
 
 	return pipeline, nil
 }
 
-func (s *service) GetByProjectID(projectID int64) ([]Pipeline, error) {
+func (s *service) GetByProjectID(ctx context.Context, projectID int64) ([]Pipeline, error) {
 
 	pipelines, err := s.pipelineRepo.GetByProjectID(projectID)
 	if err != nil {
@@ -81,11 +180,14 @@ func (s *service) GetByProjectID(projectID int64) ([]Pipeline, error) {
 			Message: "Invalid ID",
 		}
 	}
+	for i := range pipelines {
+		s.setNextRun(&pipelines[i])
+	}
 
 	return pipelines, nil
 }
 
-func (s *service) Create(p Pipeline) (*Pipeline, error) {
+func (s *service) Create(ctx context.Context, p Pipeline) (*Pipeline, error) {
 	if p.DisplayName == "" {
 		return nil, &utils.ServiceErr{
 			Code:    InvalidInputCode,
@@ -98,6 +200,9 @@ func (s *service) Create(p Pipeline) (*Pipeline, error) {
 			Message: "Invalid pipeline structure",
 		}
 	}
+	if _, err := s.validateGraph(p.ProjectID, p.Data); err != nil {
+		return nil, err
+	}
 	newPipeline, err := s.pipelineRepo.Create(p)
 	if err != nil {
 		log.Printf("Failed creating new pipeline (error: %v", err)
@@ -106,10 +211,22 @@ func (s *service) Create(p Pipeline) (*Pipeline, error) {
 			Message: "Failed creating new pipeline",
 		}
 	}
+	s.triggers.set(*newPipeline)
+	s.publishPipeline(events.PipelineCreated, newPipeline)
+
+	// New pipelines get an enabled webhook
+	_, err = s.webhookRepo.Save(Webhook{
+		PipelineID: newPipeline.ID,
+		Secret:     newWebhookSecret(),
+		AllowedIPs: []string{},
+	})
+	if err != nil {
+		log.Printf("Failed creating webhook of pipeline %d (error: %v)", newPipeline.ID, err)
+	}
 	return newPipeline, nil
 }
 
-func (s *service) Update(pipelineID int64, p Pipeline) (*Pipeline, error) {
+func (s *service) Update(ctx context.Context, pipelineID int64, p Pipeline) (*Pipeline, error) {
 	if p.DisplayName == "" {
 		return nil, &utils.ServiceErr{
 			Code:    InvalidInputCode,
@@ -122,10 +239,21 @@ func (s *service) Update(pipelineID int64, p Pipeline) (*Pipeline, error) {
 			Message: "Invalid pipeline data",
 		}
 	}
+	current, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+	if _, err := s.validateGraph(current.ProjectID, p.Data); err != nil {
+		return nil, err
+	}
 	updatedData := Pipeline{
 		DisplayName: p.DisplayName,
 		Description: p.Description,
 		Data:        p.Data,
+		UpdatedBy:   p.UpdatedBy,
 	}
 	pipeline, err := s.pipelineRepo.Update(pipelineID, updatedData)
 	if err != nil {
@@ -134,35 +262,246 @@ func (s *service) Update(pipelineID int64, p Pipeline) (*Pipeline, error) {
 			Message: "Invalid ID",
 		}
 	}
+	s.triggers.set(*pipeline)
+	s.setNextRun(pipeline)
+	s.publishPipeline(events.PipelineUpdated, pipeline)
 
 	return pipeline, nil
 }
 
-func (s *service) Delete(pipelineID int64) error {
-	err := s.pipelineRepo.Delete(pipelineID)
+func (s *service) Delete(ctx context.Context, pipelineID int64) error {
+	pipeline, err := s.pipelineRepo.GetByID(pipelineID)
+	if err == nil {
+		err = s.pipelineRepo.Delete(pipelineID)
+	}
 	if err != nil {
 		return &utils.ServiceErr{
 			Code:    PipelineNotFoundCode,
 			Message: "Invalid ID",
 		}
 	}
+	s.triggers.remove(pipelineID)
+	s.publishPipeline(events.PipelineDeleted, pipeline)
 	return nil
 }
 
-func (s *service) RunPipeline(pipelineID int64, payload string) error {
+func (s *service) RunPipeline(ctx context.Context, pipelineID int64, trigger Trigger, payload string) (*Run, error) {
+	pipeline, run, err := s.startRun(pipelineID, trigger, payload)
+	if err != nil {
+		return nil, err
+	}
+	return s.finishRun(ctx, pipeline, run)
+}
+
+// startRun records a new running run of the pipeline
+func (s *service) startRun(pipelineID int64, trigger Trigger, payload string) (*Pipeline, *Run, error) {
+	pipeline, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil {
+		return nil, nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	run, err := s.runRepo.Create(Run{
+		PipelineID:      pipelineID,
+		PipelineVersion: pipeline.Version,
+		Trigger:         trigger,
+		Payload:         payload,
+		Status:          RunRunning,
+		StartedAt:       time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed recording pipeline run (error: %v)", err)
+		return nil, nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed recording pipeline run",
+		}
+	}
+	s.publishRun(events.RunStarted, pipeline.ProjectID, run)
+	return pipeline, run, nil
+}
+
+// finishRun executes a started run until it completes
+// (or ctx is done) and records its outcome
+func (s *service) finishRun(ctx context.Context, pipeline *Pipeline, run *Run) (*Run, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		timeout := DefaultRunTimeout
+		// Invalid data is rejected by the worker
+		if g, err := ParseGraph(pipeline.Data); err == nil {
+			timeout = runTimeout(g)
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	pipelineRequest := protobuf.PipelineRequest{
-		PipelineId: pipelineID,
-		Payload:    payload,
+		PipelineId: pipeline.ID,
+		Payload:    run.Payload,
+		RunId:      run.ID,
+		Data:       pipeline.Data,
 	}
 
-	_, err := s.client.RunPipeline(context.Background(), &pipelineRequest)
+	err := s.execute(ctx, pipeline.ProjectID, run, &pipelineRequest)
 	if err != nil {
-		errMsg := fmt.Sprintf("Pipeline run failed (%v)", err)
+		serviceErr := runErr(ctx, err)
+		run.Status = RunFailed
+		run.Error = serviceErr.Message
+		run.FinishedAt = time.Now()
+		s.saveRun(run)
+		s.publishRun(events.RunCompleted, pipeline.ProjectID, run)
+		return nil, serviceErr
+	}
+
+	return run, nil
+}
+
+// execute streams the progress of the run from the worker, records it
+// and publishes it to the project of the pipeline
+func (s *service) execute(ctx context.Context, projectID int64, run *Run, pipelineRequest *protobuf.PipelineRequest) error {
+	stream, err := s.client.ExecutePipeline(ctx, pipelineRequest)
+	if err != nil {
+		return err
+	}
+
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("Worker closed the stream before completing the run")
+		}
+		if err != nil {
+			return err
+		}
+
+		if node := progress.GetNode(); node != nil {
+			result := fromNodeProgress(node)
+			run.setNode(result)
+			s.saveRun(run)
+			s.publishNode(projectID, run, result)
+			continue
+		}
+
+		if completed := progress.GetCompleted(); completed != nil {
+			run.Status = RunSucceeded
+			if completed.Failed {
+				run.Status = RunFailed
+			}
+			run.Error = completed.Error
+			run.Output = completed.Output
+			run.FinishedAt = time.Now()
+			s.saveRun(run)
+			s.publishRun(events.RunCompleted, projectID, run)
+			return nil
+		}
+	}
+}
+
+// saveRun stores the run progress (failures are only logged,
+// they must not fail the run itself)
+func (s *service) saveRun(run *Run) {
+	err := s.runRepo.Update(*run)
+	if err != nil {
+		log.Printf("Failed updating pipeline run %d (error: %v)", run.ID, err)
+	}
+}
+
+func runErr(ctx context.Context, err error) *utils.ServiceErr {
+	if ctx.Err() == context.DeadlineExceeded || status.Code(err) == codes.DeadlineExceeded {
+		return &utils.ServiceErr{
+			Code:    TimeoutCode,
+			Message: "Pipeline run timed out",
+		}
+	}
+	if ctx.Err() == context.Canceled {
 		return &utils.ServiceErr{
-			Code:    WorkerConnectionErrorCode,
-			Message: errMsg,
+			Code:    CanceledCode,
+			Message: "Pipeline run canceled",
 		}
 	}
 
-	return nil
+	if status.Code(err) == codes.InvalidArgument {
+		return &utils.ServiceErr{
+			Code:    InvalidPipelineCode,
+			Message: status.Convert(err).Message(),
+		}
+	}
+
+	errMsg := fmt.Sprintf("Pipeline run failed (%v)", err)
+	return &utils.ServiceErr{
+		Code:    WorkerConnectionErrorCode,
+		Message: errMsg,
+	}
+}
+
+func fromNodeProgress(node *protobuf.NodeProgress) NodeResult {
+	result := NodeResult{
+		NodeID: node.NodeId,
+		Input:  node.Input,
+		Output: node.Output,
+		Error:  node.Error,
+		Branch: node.Branch,
+	}
+
+	switch node.Status {
+	case protobuf.NodeStatus_NODE_SUCCEEDED:
+		result.Status = NodeSucceeded
+	case protobuf.NodeStatus_NODE_FAILED:
+		result.Status = NodeFailed
+	case protobuf.NodeStatus_NODE_SKIPPED:
+		result.Status = NodeSkipped
+	default:
+		result.Status = NodeRunning
+	}
+
+	if node.StartedAt != nil {
+		result.StartedAt = node.StartedAt.AsTime()
+	}
+	if node.FinishedAt != nil {
+		result.FinishedAt = node.FinishedAt.AsTime()
+	}
+
+	return result
+}
+
+func (s *service) GetRuns(ctx context.Context, pipelineID int64, limit int, offset int) ([]Run, error) {
+	if limit <= 0 {
+		limit = DefaultRunsLimit
+	}
+	if limit > MaxRunsLimit || offset < 0 {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Limit should be at most %d and offset positive", MaxRunsLimit),
+		}
+	}
+
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	runs, err := s.runRepo.GetByPipelineID(pipelineID, limit, offset)
+	if err != nil {
+		log.Printf("Failed getting pipeline runs (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting pipeline runs",
+		}
+	}
+
+	return runs, nil
+}
+
+func (s *service) GetRun(ctx context.Context, runID int64) (*Run, error) {
+	run, err := s.runRepo.GetByID(runID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    RunNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return run, nil
 }
@@ -0,0 +1,160 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// Mock canned result of a device-invoke or http-request node in simulations
+type Mock struct {
+	// Response JSON encoded output of the node (not JSON values are strings)
+	Response string
+	// Error fails the node instead (e.g. to simulate an offline device)
+	Error string
+}
+
+// Simulate runs the pipeline without side effects: devices are not invoked
+// and HTTP requests are not sent, device-invoke and http-request nodes output
+// the mocks given by node id instead (nodes without a mock fail), and delays
+// are skipped. data is the graph to simulate (the saved graph if empty, e.g.
+// to try changes before saving them). The run is executed in process and not
+// recorded, its ID is zero.
+func (s *service) Simulate(ctx context.Context, pipelineID int64, data string, payload string, mocks map[string]Mock) (*Run, error) {
+	pipeline, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	var g *Graph
+	run := &Run{
+		PipelineID: pipelineID,
+		Trigger:    TriggerSimulation,
+		Payload:    payload,
+		Status:     RunRunning,
+		Nodes:      []NodeResult{},
+	}
+	if data == "" {
+		g, err = ParseGraph(pipeline.Data)
+		if err != nil {
+			return nil, &utils.ServiceErr{
+				Code:    InvalidPipelineCode,
+				Message: fmt.Sprintf("Invalid pipeline data (%v)", err),
+			}
+		}
+		run.PipelineVersion = pipeline.Version
+	} else {
+		g, err = s.validateGraph(pipeline.ProjectID, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkMocks(g, mocks); err != nil {
+		return nil, err
+	}
+	if mocks == nil {
+		mocks = make(map[string]Mock)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRunTimeout)
+		defer cancel()
+	}
+
+	// Nodes with side effects are all mocked, the executor
+	// needs neither a device invoker nor an HTTP client
+	e := &executor{mocks: mocks}
+	run.StartedAt = time.Now()
+	err = e.execute(ctx, g, payload, func(progress *protobuf.RunProgress) error {
+		if node := progress.GetNode(); node != nil {
+			run.setNode(fromNodeProgress(node))
+		}
+		if completed := progress.GetCompleted(); completed != nil {
+			run.Status = RunSucceeded
+			if completed.Failed {
+				run.Status = RunFailed
+			}
+			run.Error = completed.Error
+			run.Output = completed.Output
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, runErr(ctx, err)
+	}
+	run.FinishedAt = time.Now()
+
+	return run, nil
+}
+
+// checkMocks rejects mocks of nodes that are not device-invoke or http-request nodes
+func checkMocks(g *Graph, mocks map[string]Mock) error {
+	var unknown []string
+	for nodeID := range mocks {
+		n := g.Node(nodeID)
+		if n == nil || (n.Type != DeviceInvokeNode && n.Type != HTTPRequestNode) {
+			unknown = append(unknown, nodeID)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Mocks are given by id of device-invoke and http-request nodes (invalid mocks: %q)", unknown),
+		}
+	}
+
+	return nil
+}
+
+// mockNode runs the node of a simulation if it has side effects (mocked is
+// false for other nodes). Payloads are still resolved so that invalid
+// references fail as they would in real runs.
+func (e *executor) mockNode(n Node, input interface{}) (output interface{}, mocked bool, err error) {
+	switch n.Type {
+	case DeviceInvokeNode:
+		var config DeviceInvokeConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, true, err
+		}
+		if config.Payload != nil {
+			if _, err := resolveRefs(config.Payload, input); err != nil {
+				return nil, true, err
+			}
+		}
+	case HTTPRequestNode:
+		var config HTTPRequestConfig
+		if err := n.DecodeConfig(&config); err != nil {
+			return nil, true, err
+		}
+		if config.Body != nil {
+			if _, err := resolveRefs(config.Body, input); err != nil {
+				return nil, true, err
+			}
+		}
+	case DelayNode:
+		return input, true, nil
+	default:
+		return nil, false, nil
+	}
+
+	mock, ok := e.mocks[n.ID]
+	if !ok {
+		return nil, true, errors.New("No mocked response (simulation)")
+	}
+	if mock.Error != "" {
+		return nil, true, errors.New(mock.Error)
+	}
+
+	return decodeValue(mock.Response), true, nil
+}
@@ -0,0 +1,264 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+)
+
+const (
+	// DefaultTriggerReloadInterval how often event triggers are reloaded from
+	// the storage (picking up pipelines changed through other API replicas)
+	DefaultTriggerReloadInterval = 30 * time.Second
+	// MaxConcurrentEventRuns max number of event triggered runs in progress
+	// (per API replica), further events wait for a run to finish
+	MaxConcurrentEventRuns = 32
+)
+
+// triggerKey events are matched by device, event type and (for
+// device messages) endpoint pattern
+type triggerKey struct {
+	deviceID  int64
+	eventType events.Type
+	pattern   string
+}
+
+type eventTrigger struct {
+	EventTrigger
+	pipelineID int64
+	projectID  int64
+}
+
+// triggerRegistry event triggers of the pipelines
+type triggerRegistry struct {
+	mu       sync.RWMutex
+	triggers map[triggerKey][]eventTrigger
+	// pipeline id -> key of its trigger
+	keys map[int64]triggerKey
+}
+
+func newTriggerRegistry() *triggerRegistry {
+	return &triggerRegistry{
+		triggers: make(map[triggerKey][]eventTrigger),
+		keys:     make(map[int64]triggerKey),
+	}
+}
+
+// eventTriggerOf returns the event trigger of the pipeline (nil if it has none)
+func eventTriggerOf(p Pipeline) *EventTrigger {
+	g, err := ParseGraph(p.Data)
+	if err != nil {
+		return nil
+	}
+	trigger := g.Trigger()
+	if trigger == nil {
+		return nil
+	}
+
+	var config TriggerConfig
+	if err := trigger.DecodeConfig(&config); err != nil {
+		return nil
+	}
+
+	return config.Event
+}
+
+// set registers the event trigger of the pipeline, replacing its previous
+// one (pipelines without event trigger are removed)
+func (r *triggerRegistry) set(p Pipeline) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(p.ID)
+	if trigger := eventTriggerOf(p); trigger != nil {
+		r.addLocked(p, *trigger)
+	}
+}
+
+func (r *triggerRegistry) remove(pipelineID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeLocked(pipelineID)
+}
+
+// replace rebuilds the registry from all the pipelines
+func (r *triggerRegistry) replace(all []Pipeline) {
+	triggers := make(map[int64]*EventTrigger)
+	for _, p := range all {
+		triggers[p.ID] = eventTriggerOf(p)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.triggers = make(map[triggerKey][]eventTrigger)
+	r.keys = make(map[int64]triggerKey)
+	for _, p := range all {
+		if trigger := triggers[p.ID]; trigger != nil {
+			r.addLocked(p, *trigger)
+		}
+	}
+}
+
+func (r *triggerRegistry) addLocked(p Pipeline, trigger EventTrigger) {
+	key := triggerKey{
+		deviceID:  trigger.DeviceID,
+		eventType: trigger.Type,
+		pattern:   trigger.Pattern,
+	}
+	r.triggers[key] = append(r.triggers[key], eventTrigger{
+		EventTrigger: trigger,
+		pipelineID:   p.ID,
+		projectID:    p.ProjectID,
+	})
+	r.keys[p.ID] = key
+}
+
+func (r *triggerRegistry) removeLocked(pipelineID int64) {
+	key, ok := r.keys[pipelineID]
+	if !ok {
+		return
+	}
+
+	remaining := []eventTrigger{}
+	for _, trigger := range r.triggers[key] {
+		if trigger.pipelineID != pipelineID {
+			remaining = append(remaining, trigger)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(r.triggers, key)
+	} else {
+		r.triggers[key] = remaining
+	}
+	delete(r.keys, pipelineID)
+}
+
+// match returns the pipelines triggered by the event
+// (payload is the decoded event)
+func (r *triggerRegistry) match(e events.Event, payload interface{}) []int64 {
+	key := triggerKey{
+		deviceID:  e.DeviceID,
+		eventType: e.Type,
+		pattern:   e.Pattern,
+	}
+
+	r.mu.RLock()
+	candidates := r.triggers[key]
+	r.mu.RUnlock()
+
+	matched := []int64{}
+	for _, trigger := range candidates {
+		if trigger.projectID != e.ProjectID {
+			continue
+		}
+		if trigger.Metric != "" {
+			metric, _ := lookup(payload, []interface{}{"data", "metric"})
+			if metric != trigger.Metric {
+				continue
+			}
+		}
+		if trigger.Condition != nil {
+			ok, err := trigger.Condition.evaluate(payload)
+			if err != nil {
+				log.Printf("Failed evaluating the event condition of pipeline %d (error: %v)", trigger.pipelineID, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, trigger.pipelineID)
+	}
+
+	return matched
+}
+
+// HandleEvent starts (in the background) the pipelines triggered by
+// the event, the event is the run payload
+func (s *service) HandleEvent(e events.Event) {
+	// Only device events trigger pipelines (e.g. not the run events
+	// of the pipelines themselves)
+	if !e.Type.IsDeviceEvent() {
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed encoding %s event (error: %v)", e.Type, err)
+		return
+	}
+	payload := string(data)
+
+	for _, pipelineID := range s.triggers.match(e, decodeValue(payload)) {
+		s.eventRuns <- struct{}{}
+		go func(pipelineID int64) {
+			defer func() { <-s.eventRuns }()
+
+			_, err := s.RunPipeline(context.Background(), pipelineID, TriggerEvent, payload)
+			if err != nil {
+				log.Printf("Failed running pipeline %d on %s event (error: %v)", pipelineID, e.Type, err)
+			}
+		}(pipelineID)
+	}
+}
+
+func (s *service) LoadTriggers() error {
+	all, err := s.pipelineRepo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	s.triggers.replace(all)
+	return nil
+}
+
+// EventDispatcher starts the pipelines triggered by the events of the bus
+type EventDispatcher struct {
+	pipelineService Service
+	bus             events.Bus
+	reloadInterval  time.Duration
+}
+
+// CreateEventDispatcher reloadInterval is how often event triggers are
+// reloaded from the storage (DefaultTriggerReloadInterval if zero)
+func CreateEventDispatcher(pipelineService Service, bus events.Bus, reloadInterval time.Duration) *EventDispatcher {
+	if reloadInterval <= 0 {
+		reloadInterval = DefaultTriggerReloadInterval
+	}
+
+	return &EventDispatcher{
+		pipelineService: pipelineService,
+		bus:             bus,
+		reloadInterval:  reloadInterval,
+	}
+}
+
+// Run dispatches events until ctx is done
+func (d *EventDispatcher) Run(ctx context.Context) {
+	if err := d.pipelineService.LoadTriggers(); err != nil {
+		log.Printf("Failed loading pipeline event triggers (error: %v)", err)
+	}
+
+	unsubscribe := d.bus.Subscribe(d.pipelineService.HandleEvent)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(d.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.pipelineService.LoadTriggers(); err != nil {
+				log.Printf("Failed reloading pipeline event triggers (error: %v)", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
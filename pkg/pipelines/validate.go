@@ -0,0 +1,377 @@
+package pipelines
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// MaxDelay max duration of delay nodes
+const MaxDelay = time.Hour
+
+// GraphIssue a problem found in a pipeline graph
+type GraphIssue struct {
+	// Location JSON pointer to the invalid value of the pipeline data
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+var httpMethods = map[string]bool{
+	"GET":    true,
+	"POST":   true,
+	"PUT":    true,
+	"PATCH":  true,
+	"DELETE": true,
+}
+
+var operators = map[Operator]bool{
+	EqualOperator:          true,
+	NotEqualOperator:       true,
+	GreaterOperator:        true,
+	GreaterOrEqualOperator: true,
+	LessOperator:           true,
+	LessOrEqualOperator:    true,
+	ContainsOperator:       true,
+	ExistsOperator:         true,
+}
+
+type validator struct {
+	g         *Graph
+	projectID int64
+	issues    []GraphIssue
+
+	deviceService   devices.Service
+	endpointService endpoints.Service
+}
+
+// validateGraph parses the pipeline data and checks the graph structure and
+// the configuration of every node (devices and endpoints referenced by nodes
+// must belong to the project).
+func (s *service) validateGraph(projectID int64, data string) (*Graph, error) {
+	g, err := ParseGraph(data)
+	if err != nil {
+		return nil, invalidPipelineErr([]GraphIssue{{
+			Location: "",
+			Message:  fmt.Sprintf("Invalid pipeline data (%v)", err),
+		}})
+	}
+
+	v := validator{
+		g:               g,
+		projectID:       projectID,
+		deviceService:   s.deviceService,
+		endpointService: s.endpointService,
+	}
+	v.validate()
+	if len(v.issues) != 0 {
+		return nil, invalidPipelineErr(v.issues)
+	}
+
+	return g, nil
+}
+
+func invalidPipelineErr(issues []GraphIssue) error {
+	return &utils.ServiceErr{
+		Code:    InvalidPipelineCode,
+		Message: "Invalid pipeline definition",
+		Details: issues,
+	}
+}
+
+func (v *validator) report(location string, format string, args ...interface{}) {
+	v.issues = append(v.issues, GraphIssue{
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate() {
+	if len(v.g.Nodes) == 0 {
+		v.report("/nodes", "Pipeline should have at least one node")
+		return
+	}
+
+	nodes := make(map[string]*Node)
+	triggers := 0
+	for i := range v.g.Nodes {
+		n := &v.g.Nodes[i]
+		location := fmt.Sprintf("/nodes/%d", i)
+
+		if n.ID == "" {
+			v.report(location+"/id", "Node ID is required")
+		} else if nodes[n.ID] != nil {
+			v.report(location+"/id", "Duplicate node ID %q", n.ID)
+		} else {
+			nodes[n.ID] = n
+		}
+
+		if n.Type == TriggerNode {
+			triggers++
+		}
+		v.validateNode(*n, location)
+	}
+	if triggers != 1 {
+		v.report("/nodes", "Pipeline should have exactly one trigger node (found %d)", triggers)
+	}
+
+	edgesValid := true
+	for j, e := range v.g.Edges {
+		location := fmt.Sprintf("/edges/%d", j)
+
+		from, to := nodes[e.From], nodes[e.To]
+		if from == nil {
+			v.report(location+"/from", "Unknown node %q", e.From)
+		}
+		if to == nil {
+			v.report(location+"/to", "Unknown node %q", e.To)
+		}
+		if from == nil || to == nil {
+			edgesValid = false
+			continue
+		}
+
+		if e.From == e.To {
+			v.report(location, "Node %q can not lead to itself", e.From)
+			edgesValid = false
+		}
+		if to.Type == TriggerNode {
+			v.report(location+"/to", "Edges can not lead to the trigger node")
+			edgesValid = false
+		}
+
+		if from.Type == ConditionNode {
+			if e.Branch != TrueBranch && e.Branch != FalseBranch {
+				v.report(location+"/branch", "Edges of condition nodes should have a %q or %q branch", TrueBranch, FalseBranch)
+			}
+		} else if e.Branch != "" {
+			v.report(location+"/branch", "Only edges of condition nodes have a branch")
+		}
+	}
+
+	// Cycles and reachability are only meaningful for well formed edges
+	if edgesValid {
+		v.validateCycles()
+		v.validateReachability()
+	}
+}
+
+// validateCycles reports edges closing a cycle (found using depth first search)
+func (v *validator) validateCycles() {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+	path := []string{}
+
+	var visit func(nodeID string)
+	visit = func(nodeID string) {
+		state[nodeID] = visiting
+		path = append(path, nodeID)
+
+		for j, e := range v.g.Edges {
+			if e.From != nodeID {
+				continue
+			}
+			switch state[e.To] {
+			case unvisited:
+				visit(e.To)
+			case visiting:
+				cycle := []string{}
+				for i := len(path) - 1; i >= 0; i-- {
+					cycle = append([]string{path[i]}, cycle...)
+					if path[i] == e.To {
+						break
+					}
+				}
+				cycle = append(cycle, e.To)
+				v.report(fmt.Sprintf("/edges/%d", j), "Edge closes a cycle (%s)", strings.Join(cycle, " -> "))
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[nodeID] = visited
+	}
+
+	for _, n := range v.g.Nodes {
+		if state[n.ID] == unvisited {
+			visit(n.ID)
+		}
+	}
+}
+
+// validateReachability reports nodes that can never run
+func (v *validator) validateReachability() {
+	trigger := v.g.Trigger()
+	if trigger == nil {
+		return
+	}
+
+	reached := map[string]bool{trigger.ID: true}
+	queue := []string{trigger.ID}
+	for len(queue) != 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+		for _, e := range v.g.Outgoing(nodeID) {
+			if !reached[e.To] {
+				reached[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+
+	for i, n := range v.g.Nodes {
+		if n.ID != "" && !reached[n.ID] {
+			v.report(fmt.Sprintf("/nodes/%d", i), "Node %q is not reachable from the trigger", n.ID)
+		}
+	}
+}
+
+func (v *validator) validateNode(n Node, location string) {
+	configLocation := location + "/config"
+
+	var err error
+	switch n.Type {
+	case TriggerNode:
+		var config TriggerConfig
+		if err = n.DecodeConfig(&config); err == nil && config.Event != nil {
+			v.validateEventTrigger(*config.Event, configLocation+"/event")
+		}
+	case DeviceInvokeNode:
+		var config DeviceInvokeConfig
+		if err = n.DecodeConfig(&config); err == nil {
+			v.validateDeviceInvoke(config, configLocation)
+		}
+	case TransformNode:
+		var config TransformConfig
+		if err = n.DecodeConfig(&config); err == nil {
+			if config.Output == nil {
+				v.report(configLocation+"/output", "Output is required")
+			}
+			v.checkRefs(config.Output, configLocation+"/output")
+		}
+	case ConditionNode:
+		var config ConditionConfig
+		if err = n.DecodeConfig(&config); err == nil {
+			v.validateCondition(config, configLocation)
+		}
+	case HTTPRequestNode:
+		var config HTTPRequestConfig
+		if err = n.DecodeConfig(&config); err == nil {
+			v.validateHTTPRequest(config, configLocation)
+		}
+	case DelayNode:
+		var config DelayConfig
+		if err = n.DecodeConfig(&config); err == nil {
+			if config.DurationMs <= 0 || config.DurationMs > int64(MaxDelay/time.Millisecond) {
+				v.report(configLocation+"/duration_ms", "Duration should be between 1ms and %v", MaxDelay)
+			}
+		}
+	case "":
+		v.report(location+"/type", "Node type is required")
+	default:
+		v.report(location+"/type", "Unknown node type %q", n.Type)
+	}
+
+	if err != nil {
+		v.report(configLocation, "Invalid %s config (%v)", n.Type, err)
+	}
+}
+
+func (v *validator) validateEventTrigger(trigger EventTrigger, location string) {
+	if !trigger.Type.IsDeviceEvent() {
+		v.report(location+"/type", "Event type should be one of %q, %q, %q or %q",
+			events.DeviceConnected, events.DeviceDisconnected, events.DeviceMessage, events.TelemetryReceived)
+	}
+	if trigger.Type == events.DeviceMessage && trigger.Pattern == "" {
+		v.report(location+"/pattern", "Pattern is required by %q events", events.DeviceMessage)
+	}
+	if trigger.Type != events.DeviceMessage && trigger.Pattern != "" {
+		v.report(location+"/pattern", "Only %q events have a pattern", events.DeviceMessage)
+	}
+	if trigger.Type != events.TelemetryReceived && trigger.Metric != "" {
+		v.report(location+"/metric", "Only %q events have a metric", events.TelemetryReceived)
+	}
+	if trigger.Condition != nil {
+		v.validateCondition(*trigger.Condition, location+"/condition")
+	}
+
+	device, err := v.deviceService.GetByID(trigger.DeviceID)
+	if trigger.DeviceID == 0 || err != nil || device.ProjectID != v.projectID {
+		v.report(location+"/device_id", "Device %d not found in the project", trigger.DeviceID)
+	}
+}
+
+func (v *validator) validateDeviceInvoke(config DeviceInvokeConfig, location string) {
+	v.checkRefs(config.Payload, location+"/payload")
+
+	if config.Pattern == "" {
+		v.report(location+"/pattern", "Pattern is required")
+	}
+	if config.DeviceID == 0 {
+		v.report(location+"/device_id", "Device ID is required")
+		return
+	}
+
+	device, err := v.deviceService.GetByID(config.DeviceID)
+	if err != nil || device.ProjectID != v.projectID {
+		v.report(location+"/device_id", "Device %d not found in the project", config.DeviceID)
+		return
+	}
+
+	if config.Pattern != "" {
+		_, err := v.endpointService.GetByPattern(config.DeviceID, config.Pattern)
+		if err != nil {
+			v.report(location+"/pattern", "Device %d has no endpoint registered with pattern %q", config.DeviceID, config.Pattern)
+		}
+	}
+}
+
+func (v *validator) validateCondition(config ConditionConfig, location string) {
+	if _, err := parseRef(config.Path); err != nil {
+		v.report(location+"/path", "%v", err)
+	}
+
+	if !operators[config.Operator] {
+		v.report(location+"/operator", "Unknown operator %q", config.Operator)
+	} else if config.Operator != ExistsOperator && config.Value == nil {
+		v.report(location+"/value", "Value is required by the %q operator", config.Operator)
+	}
+	v.checkRefs(config.Value, location+"/value")
+}
+
+func (v *validator) validateHTTPRequest(config HTTPRequestConfig, location string) {
+	if !httpMethods[config.Method] {
+		v.report(location+"/method", "Method should be one of GET, POST, PUT, PATCH or DELETE")
+	}
+
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.report(location+"/url", "URL should be an absolute http(s) URL")
+	}
+
+	names := make([]string, 0, len(config.Headers))
+	for name := range config.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.checkRefs(config.Headers[name], location+"/headers/"+escapePointer(name))
+	}
+	v.checkRefs(config.Body, location+"/body")
+}
+
+func (v *validator) checkRefs(value interface{}, location string) {
+	checkRefs(value, location, func(location string, err error) {
+		v.report(location, "%v", err)
+	})
+}
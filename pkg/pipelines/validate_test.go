@@ -0,0 +1,258 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+)
+
+// projectDevices device 1 belongs to project 1, device 2 to project 2
+type projectDevices struct {
+	devices.Service
+}
+
+// projectEndpoints device 1 has a "fan" endpoint
+type projectEndpoints struct {
+	endpoints.Service
+}
+
+func (projectDevices) GetByID(deviceID int64) (*devices.Device, error) {
+	if deviceID != 1 && deviceID != 2 {
+		return nil, errors.New("not found")
+	}
+	return &devices.Device{ID: deviceID, ProjectID: deviceID}, nil
+}
+
+func (projectEndpoints) GetByPattern(deviceID int64, pattern string) (*endpoints.Endpoint, error) {
+	if deviceID != 1 || pattern != "fan" {
+		return nil, errors.New("not found")
+	}
+	return &endpoints.Endpoint{DeviceID: deviceID, Pattern: pattern}, nil
+}
+
+// issueLocations validates the graph in project 1 and returns the sorted locations of the issues
+func issueLocations(t *testing.T, data string) []string {
+	t.Helper()
+	g, err := ParseGraph(data)
+	if err != nil {
+		t.Fatalf("ParseGraph(%s): %v", data, err)
+	}
+
+	v := validator{g: g, projectID: 1, deviceService: projectDevices{}, endpointService: projectEndpoints{}}
+	v.validate()
+
+	locations := []string{}
+	for _, issue := range v.issues {
+		locations = append(locations, issue.Location)
+	}
+	sort.Strings(locations)
+	return locations
+}
+
+func TestValidateGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "valid",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "hot", "type": "condition", "config": {"path": "$.temp", "operator": "gt", "value": 30}},
+				{"id": "fan", "type": "device-invoke", "config": {"device_id": 1, "pattern": "fan", "payload": {"speed": "$.temp"}}},
+				{"id": "wait", "type": "delay", "config": {"duration_ms": 1000}},
+				{"id": "notify", "type": "http-request", "config": {"method": "POST", "url": "https://example.com/hook", "headers": {"X-Temp": "$.temp"}}}
+			], "edges": [
+				{"from": "start", "to": "hot"},
+				{"from": "hot", "to": "fan", "branch": "true"},
+				{"from": "hot", "to": "wait", "branch": "false"},
+				{"from": "wait", "to": "notify"}
+			]}`,
+			want: []string{},
+		},
+		{
+			name: "no nodes",
+			data: `{"nodes": [], "edges": []}`,
+			want: []string{"/nodes"},
+		},
+		{
+			name: "no trigger",
+			data: `{"nodes": [{"id": "a", "type": "transform", "config": {"output": 1}}], "edges": []}`,
+			want: []string{"/nodes"},
+		},
+		{
+			name: "two triggers",
+			data: `{"nodes": [{"id": "a", "type": "trigger"}, {"id": "b", "type": "trigger"}], "edges": []}`,
+			want: []string{"/nodes", "/nodes/1"},
+		},
+		{
+			name: "duplicate and missing IDs",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "start", "type": "transform", "config": {"output": 1}},
+				{"type": "transform", "config": {"output": 1}}
+			], "edges": []}`,
+			want: []string{"/nodes/1/id", "/nodes/2/id"},
+		},
+		{
+			name: "unknown and missing types",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "a", "type": "sms"},
+				{"id": "b"}
+			], "edges": [{"from": "start", "to": "a"}, {"from": "start", "to": "b"}]}`,
+			want: []string{"/nodes/1/type", "/nodes/2/type"},
+		},
+		{
+			name: "dangling edges",
+			data: `{"nodes": [{"id": "start", "type": "trigger"}], "edges": [{"from": "start", "to": "ghost"}, {"from": "ghost", "to": "start"}]}`,
+			want: []string{"/edges/0/to", "/edges/1/from"},
+		},
+		{
+			name: "cycle",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "a", "type": "transform", "config": {"output": "$"}},
+				{"id": "b", "type": "transform", "config": {"output": "$"}}
+			], "edges": [
+				{"from": "start", "to": "a"},
+				{"from": "a", "to": "b"},
+				{"from": "b", "to": "a"}
+			]}`,
+			want: []string{"/edges/2"},
+		},
+		{
+			name: "self loop and edge to the trigger",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "a", "type": "transform", "config": {"output": "$"}}
+			], "edges": [
+				{"from": "start", "to": "a"},
+				{"from": "a", "to": "a"},
+				{"from": "a", "to": "start"}
+			]}`,
+			want: []string{"/edges/1", "/edges/2/to"},
+		},
+		{
+			name: "unreachable node",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "orphan", "type": "transform", "config": {"output": 1}}
+			], "edges": []}`,
+			want: []string{"/nodes/1"},
+		},
+		{
+			name: "branches",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "hot", "type": "condition", "config": {"path": "$.temp", "operator": "exists"}},
+				{"id": "a", "type": "transform", "config": {"output": 1}}
+			], "edges": [
+				{"from": "start", "to": "hot", "branch": "true"},
+				{"from": "hot", "to": "a", "branch": "maybe"}
+			]}`,
+			want: []string{"/edges/0/branch", "/edges/1/branch"},
+		},
+		{
+			name: "delay bounds",
+			data: fmt.Sprintf(`{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "none", "type": "delay", "config": {"duration_ms": 0}},
+				{"id": "long", "type": "delay", "config": {"duration_ms": %d}},
+				{"id": "max", "type": "delay", "config": {"duration_ms": %d}}
+			], "edges": [
+				{"from": "start", "to": "none"},
+				{"from": "none", "to": "long"},
+				{"from": "long", "to": "max"}
+			]}`, MaxDelay.Milliseconds()+1, MaxDelay.Milliseconds()),
+			want: []string{"/nodes/1/config/duration_ms", "/nodes/2/config/duration_ms"},
+		},
+		{
+			name: "node configs",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger"},
+				{"id": "cond", "type": "condition", "config": {"path": "temp", "operator": "between", "value": 1}},
+				{"id": "http", "type": "http-request", "config": {"method": "TRACE", "url": "ftp://example.com"}},
+				{"id": "out", "type": "transform", "config": {}},
+				{"id": "extra", "type": "delay", "config": {"duration_ms": 10, "jitter": true}}
+			], "edges": [
+				{"from": "start", "to": "cond"},
+				{"from": "cond", "to": "http", "branch": "true"},
+				{"from": "http", "to": "out"},
+				{"from": "out", "to": "extra"}
+			]}`,
+			want: []string{
+				"/nodes/1/config/operator",
+				"/nodes/1/config/path",
+				"/nodes/2/config/method",
+				"/nodes/2/config/url",
+				"/nodes/3/config/output",
+				"/nodes/4/config",
+			},
+		},
+		{
+			name: "devices of other projects",
+			data: `{"nodes": [
+				{"id": "start", "type": "trigger", "config": {"event": {"type": "device.connected", "device_id": 2}}},
+				{"id": "other", "type": "device-invoke", "config": {"device_id": 2, "pattern": "fan"}},
+				{"id": "unknown", "type": "device-invoke", "config": {"device_id": 1, "pattern": "led"}},
+				{"id": "missing", "type": "device-invoke", "config": {}}
+			], "edges": [
+				{"from": "start", "to": "other"},
+				{"from": "other", "to": "unknown"},
+				{"from": "unknown", "to": "missing"}
+			]}`,
+			want: []string{
+				"/nodes/0/config/event/device_id",
+				"/nodes/1/config/device_id",
+				"/nodes/2/config/pattern",
+				"/nodes/3/config/device_id",
+				"/nodes/3/config/pattern",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issueLocations(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGraphRejectsUnknownFields(t *testing.T) {
+	if _, err := ParseGraph(`{"nodes": [], "edges": [], "version": 2}`); err == nil {
+		t.Error("ParseGraph accepted an unknown field")
+	}
+	if _, err := ParseGraph(`{"nodes": [{"id": "a", "kind": "trigger"}]}`); err == nil {
+		t.Error("ParseGraph accepted an unknown node field")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	g, err := ParseGraph(`{"nodes": [
+		{"id": "start", "type": "trigger"},
+		{"id": "a", "type": "delay", "config": {"duration_ms": 20000}},
+		{"id": "b", "type": "delay", "config": {"duration_ms": 15000}}
+	], "edges": [{"from": "start", "to": "a"}, {"from": "a", "to": "b"}]}`)
+	if err != nil {
+		t.Fatalf("ParseGraph: %v", err)
+	}
+
+	if got := g.TotalDelay(); got != 35*time.Second {
+		t.Errorf("TotalDelay = %v, want 35s", got)
+	}
+	if got := runTimeout(g); got != DefaultRunTimeout+35*time.Second {
+		t.Errorf("runTimeout = %v, want %v", got, DefaultRunTimeout+35*time.Second)
+	}
+	if got := runTimeout(&Graph{}); got != DefaultRunTimeout {
+		t.Errorf("runTimeout without delays = %v, want %v", got, DefaultRunTimeout)
+	}
+}
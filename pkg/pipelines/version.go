@@ -0,0 +1,280 @@
+package pipelines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// DefaultVersionsLimit and MaxVersionsLimit page sizes of version histories
+	DefaultVersionsLimit = 50
+	MaxVersionsLimit     = 500
+)
+
+// Version an immutable revision of a pipeline, every create and update
+// of the pipeline records one (numbered from 1)
+type Version struct {
+	PipelineID  int64
+	Version     int
+	DisplayName string
+	Description string
+	Data        string
+	// CreatedBy author of the revision (zero value if the user was deleted)
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+type VersionRepository interface {
+	// GetByPipelineID returns the versions of the pipeline (latest first)
+	GetByPipelineID(pipelineID int64, limit int, offset int) ([]Version, error)
+	GetByVersion(pipelineID int64, version int) (*Version, error)
+}
+
+// VersionDiff structural changes between two versions of a pipeline
+type VersionDiff struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// DisplayName and Description are set if they changed
+	DisplayName *FieldChange `json:"display_name,omitempty"`
+	Description *FieldChange `json:"description,omitempty"`
+
+	AddedNodes   []Node       `json:"added_nodes"`
+	RemovedNodes []Node       `json:"removed_nodes"`
+	ChangedNodes []NodeChange `json:"changed_nodes"`
+	AddedEdges   []Edge       `json:"added_edges"`
+	RemovedEdges []Edge       `json:"removed_edges"`
+}
+
+type FieldChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// NodeChange a node (same id) whose type, name or config changed
+type NodeChange struct {
+	ID     string `json:"id"`
+	Before Node   `json:"before"`
+	After  Node   `json:"after"`
+}
+
+// diffVersions compares the graphs of two versions (nodes by id, edges by
+// their ends and branch)
+func diffVersions(from *Version, to *Version) (*VersionDiff, error) {
+	fromGraph, err := ParseGraph(from.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Version %d has an invalid graph (%v)", from.Version, err)
+	}
+	toGraph, err := ParseGraph(to.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Version %d has an invalid graph (%v)", to.Version, err)
+	}
+
+	diff := VersionDiff{
+		From:         from.Version,
+		To:           to.Version,
+		AddedNodes:   []Node{},
+		RemovedNodes: []Node{},
+		ChangedNodes: []NodeChange{},
+		AddedEdges:   []Edge{},
+		RemovedEdges: []Edge{},
+	}
+	if from.DisplayName != to.DisplayName {
+		diff.DisplayName = &FieldChange{Before: from.DisplayName, After: to.DisplayName}
+	}
+	if from.Description != to.Description {
+		diff.Description = &FieldChange{Before: from.Description, After: to.Description}
+	}
+
+	fromNodes := make(map[string]Node)
+	for _, node := range fromGraph.Nodes {
+		fromNodes[node.ID] = node
+	}
+	toNodes := make(map[string]Node)
+	for _, node := range toGraph.Nodes {
+		toNodes[node.ID] = node
+		before, ok := fromNodes[node.ID]
+		if !ok {
+			diff.AddedNodes = append(diff.AddedNodes, node)
+			continue
+		}
+		if !sameNode(before, node) {
+			diff.ChangedNodes = append(diff.ChangedNodes, NodeChange{ID: node.ID, Before: before, After: node})
+		}
+	}
+	for _, node := range fromGraph.Nodes {
+		if _, ok := toNodes[node.ID]; !ok {
+			diff.RemovedNodes = append(diff.RemovedNodes, node)
+		}
+	}
+
+	fromEdges := make(map[Edge]bool)
+	for _, edge := range fromGraph.Edges {
+		fromEdges[edge] = true
+	}
+	toEdges := make(map[Edge]bool)
+	for _, edge := range toGraph.Edges {
+		toEdges[edge] = true
+		if !fromEdges[edge] {
+			diff.AddedEdges = append(diff.AddedEdges, edge)
+		}
+	}
+	for _, edge := range fromGraph.Edges {
+		if !toEdges[edge] {
+			diff.RemovedEdges = append(diff.RemovedEdges, edge)
+		}
+	}
+
+	return &diff, nil
+}
+
+// sameNode compares configs by value (ignoring formatting and key order)
+func sameNode(a Node, b Node) bool {
+	if a.Type != b.Type || a.Name != b.Name {
+		return false
+	}
+
+	var aConfig, bConfig interface{}
+	if len(a.Config) != 0 {
+		if err := json.Unmarshal(a.Config, &aConfig); err != nil {
+			return false
+		}
+	}
+	if len(b.Config) != 0 {
+		if err := json.Unmarshal(b.Config, &bConfig); err != nil {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(aConfig, bConfig)
+}
+
+func (s *service) GetVersions(ctx context.Context, pipelineID int64, limit int, offset int) ([]Version, error) {
+	if limit <= 0 {
+		limit = DefaultVersionsLimit
+	}
+	if limit > MaxVersionsLimit || offset < 0 {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Limit should be at most %d and offset positive", MaxVersionsLimit),
+		}
+	}
+
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	versions, err := s.versionRepo.GetByPipelineID(pipelineID, limit, offset)
+	if err != nil {
+		log.Printf("Failed getting pipeline versions (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting pipeline versions",
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *service) GetVersion(ctx context.Context, pipelineID int64, version int) (*Version, error) {
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	v, err := s.versionRepo.GetByVersion(pipelineID, version)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    VersionNotFoundCode,
+			Message: fmt.Sprintf("Version %d not found", version),
+		}
+	}
+
+	return v, nil
+}
+
+// DiffVersions compares version from with version to (the current
+// version of the pipeline if to is zero)
+func (s *service) DiffVersions(ctx context.Context, pipelineID int64, from int, to int) (*VersionDiff, error) {
+	if to == 0 {
+		pipeline, err := s.pipelineRepo.GetByID(pipelineID)
+		if err != nil {
+			return nil, &utils.ServiceErr{
+				Code:    PipelineNotFoundCode,
+				Message: "Invalid ID",
+			}
+		}
+		to = pipeline.Version
+	}
+
+	fromVersion, err := s.GetVersion(ctx, pipelineID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.GetVersion(ctx, pipelineID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := diffVersions(fromVersion, toVersion)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidPipelineCode,
+			Message: err.Error(),
+		}
+	}
+
+	return diff, nil
+}
+
+// Rollback restores the definition of a previous version, recorded
+// as a new version (authored by userID)
+func (s *service) Rollback(ctx context.Context, pipelineID int64, version int, userID int64) (*Pipeline, error) {
+	v, err := s.GetVersion(ctx, pipelineID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.pipelineRepo.GetByID(pipelineID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+	// The restored graph is validated again (e.g. its devices may be gone)
+	if _, err := s.validateGraph(current.ProjectID, v.Data); err != nil {
+		return nil, err
+	}
+
+	// Replaced rather than updated, updates keep the current
+	// values of empty fields (e.g. an empty description)
+	pipeline, err := s.pipelineRepo.Replace(pipelineID, Pipeline{
+		DisplayName: v.DisplayName,
+		Description: v.Description,
+		Data:        v.Data,
+		UpdatedBy:   userID,
+	})
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+	s.triggers.set(*pipeline)
+	s.setNextRun(pipeline)
+	s.publishPipeline(events.PipelineUpdated, pipeline)
+
+	return pipeline, nil
+}
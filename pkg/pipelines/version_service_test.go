@@ -0,0 +1,104 @@
+package pipelines_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+const delayGraph = `{
+	"nodes": [
+		{"id": "start", "type": "trigger"},
+		{"id": "wait", "type": "delay", "config": {"duration_ms": 10}}
+	],
+	"edges": [{"from": "start", "to": "wait"}]
+}`
+
+// newVersionedPipeline creates a pipeline (version 1) through the service
+func newVersionedPipeline(t *testing.T) (pipelines.Service, *pipelines.Pipeline) {
+	t.Helper()
+	db := memory.CreateDB()
+	worker, err := pipelines.CreateEmbeddedWorker(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("creating worker: %v", err)
+	}
+	service := pipelines.CreateServiceWithWorker(
+		memory.CreatePipelineRepository(db),
+		memory.CreatePipelineRunRepository(db),
+		memory.CreatePipelineVersionRepository(db),
+		memory.CreatePipelineScheduleRepository(db),
+		memory.CreatePipelineWebhookRepository(db),
+		nil,
+		nil,
+		events.CreateBus(),
+		worker,
+	)
+
+	user, err := memory.CreateUserRepository(db).Create(users.User{Name: "bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	project, err := memory.CreateProjectRepository(db).Create(projects.Project{DisplayName: "project", CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	p, err := service.Create(context.Background(), pipelines.Pipeline{ProjectID: project.ID, DisplayName: "versioned", Data: echoGraph, CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating pipeline: %v", err)
+	}
+
+	return service, p
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	service, p := newVersionedPipeline(t)
+
+	updated, err := service.Update(ctx, p.ID, pipelines.Pipeline{
+		DisplayName: "renamed",
+		Description: "waits a bit",
+		Data:        delayGraph,
+		UpdatedBy:   p.CreatedBy,
+	})
+	if err != nil {
+		t.Fatalf("updating pipeline: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("updated version = %d, want 2", updated.Version)
+	}
+
+	if _, err := service.Rollback(ctx, p.ID, 5, p.CreatedBy); memtest.ErrCode(err) != pipelines.VersionNotFoundCode {
+		t.Errorf("rolling back to a missing version: got error %v, want %s", err, pipelines.VersionNotFoundCode)
+	}
+
+	restored, err := service.Rollback(ctx, p.ID, 1, p.CreatedBy)
+	if err != nil {
+		t.Fatalf("rolling back: %v", err)
+	}
+	// Every field is restored, including the empty description
+	if restored.Version != 3 || restored.DisplayName != p.DisplayName || restored.Description != "" || restored.Data != p.Data {
+		t.Errorf("restored pipeline = %+v, want version 3 with the fields of version 1", restored)
+	}
+
+	versions, err := service.GetVersions(ctx, p.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("getting versions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 || versions[0].Description != "" {
+		t.Errorf("versions = %+v, want 3 versions (latest first)", versions)
+	}
+
+	diff, err := service.DiffVersions(ctx, p.ID, 1, 0)
+	if err != nil {
+		t.Fatalf("diffing versions: %v", err)
+	}
+	if diff.To != 3 || diff.DisplayName != nil || diff.Description != nil || len(diff.AddedNodes) != 0 || len(diff.RemovedNodes) != 0 || len(diff.ChangedNodes) != 0 {
+		t.Errorf("diff of version 1 with the restored version = %+v, want no changes", diff)
+	}
+}
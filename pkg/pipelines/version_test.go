@@ -0,0 +1,118 @@
+package pipelines
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffVersions(t *testing.T) {
+	from := &Version{
+		Version:     1,
+		DisplayName: "cooling",
+		Description: "turns the fan on",
+		Data: `{"nodes": [
+			{"id": "start", "type": "trigger"},
+			{"id": "hot", "type": "condition", "config": {"path": "$.temp", "operator": "gt", "value": 30}},
+			{"id": "fan", "type": "device-invoke", "config": {"device_id": 1, "pattern": "fan"}},
+			{"id": "log", "type": "http-request", "config": {"method": "POST", "url": "https://example.com"}}
+		], "edges": [
+			{"from": "start", "to": "hot"},
+			{"from": "hot", "to": "fan", "branch": "true"},
+			{"from": "hot", "to": "log", "branch": "false"}
+		]}`,
+	}
+	to := &Version{
+		Version:     2,
+		DisplayName: "cooling",
+		Data: `{"nodes": [
+			{"id": "start", "type": "trigger"},
+			{"id": "hot", "type": "condition", "config": {"value": 35, "operator": "gt", "path": "$.temp"}},
+			{"id": "fan", "type": "device-invoke", "config": {"pattern": "fan", "device_id": 1}},
+			{"id": "wait", "type": "delay", "config": {"duration_ms": 1000}}
+		], "edges": [
+			{"from": "start", "to": "hot"},
+			{"from": "hot", "to": "fan", "branch": "false"},
+			{"from": "hot", "to": "wait", "branch": "true"}
+		]}`,
+	}
+
+	diff, err := diffVersions(from, to)
+	if err != nil {
+		t.Fatalf("diffVersions: %v", err)
+	}
+
+	if diff.From != 1 || diff.To != 2 {
+		t.Errorf("diff from %d to %d, want from 1 to 2", diff.From, diff.To)
+	}
+	if diff.DisplayName != nil {
+		t.Errorf("unchanged display name in the diff: %+v", diff.DisplayName)
+	}
+	if want := (&FieldChange{Before: "turns the fan on", After: ""}); !reflect.DeepEqual(diff.Description, want) {
+		t.Errorf("description change = %+v, want %+v", diff.Description, want)
+	}
+
+	if ids := nodeIDs(diff.AddedNodes); !reflect.DeepEqual(ids, []string{"wait"}) {
+		t.Errorf("added nodes %v, want [wait]", ids)
+	}
+	if ids := nodeIDs(diff.RemovedNodes); !reflect.DeepEqual(ids, []string{"log"}) {
+		t.Errorf("removed nodes %v, want [log]", ids)
+	}
+	// The fan config only changed its key order
+	if len(diff.ChangedNodes) != 1 || diff.ChangedNodes[0].ID != "hot" {
+		t.Errorf("changed nodes %+v, want [hot]", diff.ChangedNodes)
+	}
+
+	wantAdded := []Edge{{From: "hot", To: "fan", Branch: FalseBranch}, {From: "hot", To: "wait", Branch: TrueBranch}}
+	if !reflect.DeepEqual(diff.AddedEdges, wantAdded) {
+		t.Errorf("added edges %+v, want %+v", diff.AddedEdges, wantAdded)
+	}
+	wantRemoved := []Edge{{From: "hot", To: "fan", Branch: TrueBranch}, {From: "hot", To: "log", Branch: FalseBranch}}
+	if !reflect.DeepEqual(diff.RemovedEdges, wantRemoved) {
+		t.Errorf("removed edges %+v, want %+v", diff.RemovedEdges, wantRemoved)
+	}
+}
+
+func TestDiffSameVersion(t *testing.T) {
+	v := &Version{Version: 1, DisplayName: "a", Data: `{"nodes": [{"id": "start", "type": "trigger"}], "edges": []}`}
+
+	diff, err := diffVersions(v, v)
+	if err != nil {
+		t.Fatalf("diffVersions: %v", err)
+	}
+	if diff.DisplayName != nil || diff.Description != nil || len(diff.AddedNodes) != 0 || len(diff.RemovedNodes) != 0 ||
+		len(diff.ChangedNodes) != 0 || len(diff.AddedEdges) != 0 || len(diff.RemovedEdges) != 0 {
+		t.Errorf("diff of a version with itself = %+v, want no changes", diff)
+	}
+
+	if _, err := diffVersions(v, &Version{Version: 2, Data: "not json"}); err == nil {
+		t.Error("diffVersions with an invalid graph did not fail")
+	}
+}
+
+func TestSameNode(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Node
+		b    Node
+		want bool
+	}{
+		{"formatting", Node{ID: "a", Type: DelayNode, Config: []byte(`{"duration_ms":10}`)}, Node{ID: "a", Type: DelayNode, Config: []byte(`{ "duration_ms": 10 }`)}, true},
+		{"no config", Node{ID: "a", Type: TriggerNode}, Node{ID: "a", Type: TriggerNode}, true},
+		{"config value", Node{ID: "a", Type: DelayNode, Config: []byte(`{"duration_ms":10}`)}, Node{ID: "a", Type: DelayNode, Config: []byte(`{"duration_ms":20}`)}, false},
+		{"type", Node{ID: "a", Type: TriggerNode}, Node{ID: "a", Type: DelayNode}, false},
+		{"name", Node{ID: "a", Type: TriggerNode, Name: "start"}, Node{ID: "a", Type: TriggerNode}, false},
+	}
+	for _, tt := range tests {
+		if got := sameNode(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameNode(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func nodeIDs(nodes []Node) []string {
+	ids := []string{}
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
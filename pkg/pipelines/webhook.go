@@ -0,0 +1,366 @@
+package pipelines
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// WebhookSignatureHeader signature of webhook requests, formatted as
+	// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">"
+	// (several v1 values may be given, e.g. while rotating the secret)
+	WebhookSignatureHeader = "X-Wyrm-Signature"
+	// WebhookIdempotencyHeader optional key deduplicating webhook retries
+	WebhookIdempotencyHeader = "Idempotency-Key"
+
+	// WebhookTolerance max age (and clock skew) of a signature timestamp
+	WebhookTolerance = 5 * time.Minute
+	// MaxSecretGracePeriod longest time an old webhook secret can keep
+	// working after a rotation
+	MaxSecretGracePeriod = time.Hour * 24 * 7
+	// IdempotencyKeyTTL how long idempotency keys are remembered
+	IdempotencyKeyTTL = 24 * time.Hour
+	// MaxIdempotencyKeyLength max length of idempotency keys
+	MaxIdempotencyKeyLength = 255
+)
+
+// Webhook settings of the webhook of a pipeline
+type Webhook struct {
+	PipelineID int64
+	// Secret signs the webhook requests (empty if the webhook is disabled)
+	Secret string
+	// PreviousSecret keeps working until PreviousSecretExpiresAt
+	PreviousSecret          string
+	PreviousSecretExpiresAt time.Time
+	// AllowedIPs addresses and CIDR ranges allowed to call
+	// the webhook (any address if empty)
+	AllowedIPs []string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDelivery a webhook request made with an idempotency key
+type WebhookDelivery struct {
+	PipelineID     int64
+	IdempotencyKey string
+	// RunID run started by the delivery (zero while it is in progress)
+	RunID     int64
+	CreatedAt time.Time
+}
+
+type WebhookRepository interface {
+	// Get returns the webhook settings of the pipeline
+	Get(pipelineID int64) (*Webhook, error)
+	// Save creates or replaces the webhook settings of the pipeline
+	Save(wh Webhook) (*Webhook, error)
+	// ClaimDelivery records the delivery unless its key was used since
+	// expiredBefore, in which case the recorded delivery is returned
+	// (claimed is false)
+	ClaimDelivery(d WebhookDelivery, expiredBefore time.Time) (delivery *WebhookDelivery, claimed bool, err error)
+	// CompleteDelivery records the run started by the delivery
+	CompleteDelivery(pipelineID int64, idempotencyKey string, runID int64) error
+	// ReleaseDelivery drops a delivery that did not start a run
+	// (so that it can be retried)
+	ReleaseDelivery(pipelineID int64, idempotencyKey string) error
+}
+
+// WebhookRequest a call of the webhook of a pipeline
+type WebhookRequest struct {
+	// Body the run payload
+	Body           []byte
+	Signature      string
+	IdempotencyKey string
+	RemoteIP       string
+}
+
+// SignWebhook returns the signature header value of a webhook request
+// with the given body sent at t
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, webhookMAC(secret, timestamp, body))
+}
+
+func webhookMAC(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks the signature header of a webhook request
+// against the current (and unexpired previous) secret
+func (wh *Webhook) verifySignature(signature string, body []byte, now time.Time) error {
+	var timestamp string
+	signatures := []string{}
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("Missing or malformed %s header", WebhookSignatureHeader)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid signature timestamp %q", timestamp)
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("Signature timestamp is outside the tolerance (%v)", WebhookTolerance)
+	}
+
+	secrets := []string{wh.Secret}
+	if wh.PreviousSecret != "" && now.Before(wh.PreviousSecretExpiresAt) {
+		secrets = append(secrets, wh.PreviousSecret)
+	}
+	for _, secret := range secrets {
+		expected := []byte(webhookMAC(secret, timestamp, body))
+		for _, sig := range signatures {
+			if hmac.Equal(expected, []byte(sig)) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Signature does not match")
+}
+
+// allows reports whether ip may call the webhook
+func (wh *Webhook) allows(ip string) bool {
+	if len(wh.AllowedIPs) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range wh.AllowedIPs {
+		_, ipNet, err := net.ParseCIDR(allowed)
+		if err == nil && ipNet.Contains(addr) {
+			return true
+		}
+		if err != nil && addr.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeAllowedIPs checks the addresses and CIDR ranges of an allowlist
+func normalizeAllowedIPs(allowedIPs []string) ([]string, error) {
+	normalized := []string{}
+	for _, allowed := range allowedIPs {
+		allowed = strings.TrimSpace(allowed)
+		if _, ipNet, err := net.ParseCIDR(allowed); err == nil {
+			normalized = append(normalized, ipNet.String())
+			continue
+		}
+		if ip := net.ParseIP(allowed); ip != nil {
+			normalized = append(normalized, ip.String())
+			continue
+		}
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Invalid IP address or CIDR range %q", allowed),
+		}
+	}
+
+	return normalized, nil
+}
+
+// newWebhookSecret generates a webhook secret
+func newWebhookSecret() string {
+	return utils.GenString(32)
+}
+
+// GetWebhook returns the webhook settings of the pipeline (a pipeline without
+// settings has a disabled webhook until its secret is rotated)
+func (s *service) GetWebhook(ctx context.Context, pipelineID int64) (*Webhook, error) {
+	if _, err := s.pipelineRepo.GetByID(pipelineID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    PipelineNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	wh, err := s.webhookRepo.Get(pipelineID)
+	if err != nil {
+		return &Webhook{PipelineID: pipelineID, AllowedIPs: []string{}}, nil
+	}
+
+	return wh, nil
+}
+
+func (s *service) UpdateWebhook(ctx context.Context, pipelineID int64, allowedIPs []string) (*Webhook, error) {
+	wh, err := s.GetWebhook(ctx, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.AllowedIPs, err = normalizeAllowedIPs(allowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveWebhook(*wh)
+}
+
+// RotateWebhookSecret generates a new webhook secret (enabling the webhook),
+// the old secret stops working right away unless a grace period is given
+func (s *service) RotateWebhookSecret(ctx context.Context, pipelineID int64, gracePeriod time.Duration) (*Webhook, error) {
+	if gracePeriod < 0 || gracePeriod > MaxSecretGracePeriod {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Grace period should be between 0 and 7 days",
+		}
+	}
+
+	wh, err := s.GetWebhook(ctx, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.PreviousSecret = ""
+	wh.PreviousSecretExpiresAt = time.Time{}
+	if gracePeriod > 0 && wh.Secret != "" {
+		wh.PreviousSecret = wh.Secret
+		wh.PreviousSecretExpiresAt = time.Now().Add(gracePeriod)
+	}
+	wh.Secret = newWebhookSecret()
+
+	return s.saveWebhook(*wh)
+}
+
+func (s *service) saveWebhook(wh Webhook) (*Webhook, error) {
+	saved, err := s.webhookRepo.Save(wh)
+	if err != nil {
+		log.Printf("Failed saving webhook of pipeline %d (error: %v)", wh.PipelineID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed saving pipeline webhook",
+		}
+	}
+
+	return saved, nil
+}
+
+// HandleWebhook authenticates the webhook request and starts a run of the
+// pipeline with the request body as payload (the run continues in the
+// background). A retry of a delivery (same idempotency key) returns the run
+// of the delivery instead (replayed is true).
+func (s *service) HandleWebhook(ctx context.Context, pipelineID int64, req WebhookRequest) (run *Run, replayed bool, err error) {
+	wh, err := s.GetWebhook(ctx, pipelineID)
+	if err != nil {
+		return nil, false, err
+	}
+	if wh.Secret == "" {
+		return nil, false, &utils.ServiceErr{
+			Code:    WebhookDisabledCode,
+			Message: "Webhook is disabled (rotate its secret to enable it)",
+		}
+	}
+	if !wh.allows(req.RemoteIP) {
+		return nil, false, &utils.ServiceErr{
+			Code:    ForbiddenIPCode,
+			Message: fmt.Sprintf("%s is not allowed to call the webhook", req.RemoteIP),
+		}
+	}
+
+	now := time.Now()
+	if err := wh.verifySignature(req.Signature, req.Body, now); err != nil {
+		return nil, false, &utils.ServiceErr{
+			Code:    InvalidSignatureCode,
+			Message: err.Error(),
+		}
+	}
+
+	if req.IdempotencyKey == "" {
+		run, err := s.startWebhookRun(pipelineID, req.Body)
+		return run, false, err
+	}
+	if len(req.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return nil, false, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("%s should be at most %d characters", WebhookIdempotencyHeader, MaxIdempotencyKeyLength),
+		}
+	}
+
+	delivery, claimed, err := s.webhookRepo.ClaimDelivery(WebhookDelivery{
+		PipelineID:     pipelineID,
+		IdempotencyKey: req.IdempotencyKey,
+		CreatedAt:      now,
+	}, now.Add(-IdempotencyKeyTTL))
+	if err != nil {
+		log.Printf("Failed claiming webhook delivery of pipeline %d (error: %v)", pipelineID, err)
+		return nil, false, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed recording webhook delivery",
+		}
+	}
+	if !claimed {
+		if delivery.RunID == 0 {
+			return nil, false, &utils.ServiceErr{
+				Code:    DeliveryInProgressCode,
+				Message: "A delivery with the same idempotency key is in progress",
+			}
+		}
+		run, err := s.GetRun(ctx, delivery.RunID)
+		return run, true, err
+	}
+
+	run, err = s.startWebhookRun(pipelineID, req.Body)
+	if err != nil {
+		// The delivery did not start the pipeline, let the sender retry it
+		if releaseErr := s.webhookRepo.ReleaseDelivery(pipelineID, req.IdempotencyKey); releaseErr != nil {
+			log.Printf("Failed releasing webhook delivery of pipeline %d (error: %v)", pipelineID, releaseErr)
+		}
+		return nil, false, err
+	}
+
+	err = s.webhookRepo.CompleteDelivery(pipelineID, req.IdempotencyKey, run.ID)
+	if err != nil {
+		log.Printf("Failed completing webhook delivery of pipeline %d (error: %v)", pipelineID, err)
+	}
+
+	return run, false, nil
+}
+
+// startWebhookRun starts a run of the pipeline that keeps running after
+// the webhook request returns (senders usually give up after a few seconds)
+// and returns the run as it was started
+func (s *service) startWebhookRun(pipelineID int64, body []byte) (*Run, error) {
+	pipeline, run, err := s.startRun(pipelineID, TriggerWebhook, string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	started := *run
+	go func() {
+		if _, err := s.finishRun(context.Background(), pipeline, run); err != nil {
+			log.Printf("Webhook run %d of pipeline %d failed (error: %v)", run.ID, pipelineID, err)
+		}
+	}()
+	return &started, nil
+}
@@ -0,0 +1,170 @@
+package pipelines_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+// echoGraph outputs the run payload, nothing is invoked
+const echoGraph = `{
+	"nodes": [
+		{"id": "start", "type": "trigger"},
+		{"id": "echo", "type": "transform", "config": {"output": {"temp": "$.temp"}}}
+	],
+	"edges": [{"from": "start", "to": "echo"}]
+}`
+
+func newWebhookPipeline(t *testing.T) (pipelines.Service, *pipelines.Pipeline) {
+	t.Helper()
+	db := memory.CreateDB()
+	worker, err := pipelines.CreateEmbeddedWorker(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("creating worker: %v", err)
+	}
+	pipelineRepo := memory.CreatePipelineRepository(db)
+	service := pipelines.CreateServiceWithWorker(
+		pipelineRepo,
+		memory.CreatePipelineRunRepository(db),
+		memory.CreatePipelineVersionRepository(db),
+		memory.CreatePipelineScheduleRepository(db),
+		memory.CreatePipelineWebhookRepository(db),
+		nil,
+		nil,
+		events.CreateBus(),
+		worker,
+	)
+
+	user, err := memory.CreateUserRepository(db).Create(users.User{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	project, err := memory.CreateProjectRepository(db).Create(projects.Project{DisplayName: "project", CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	p, err := pipelineRepo.Create(pipelines.Pipeline{ProjectID: project.ID, DisplayName: "webhook", Data: echoGraph, CreatedBy: user.ID})
+	if err != nil {
+		t.Fatalf("creating pipeline: %v", err)
+	}
+
+	return service, p
+}
+
+// waitForRun polls the run until it is no longer running
+func waitForRun(t *testing.T, service pipelines.Service, runID int64) *pipelines.Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		run, err := service.GetRun(context.Background(), runID)
+		if err != nil {
+			t.Fatalf("getting run %d: %v", runID, err)
+		}
+		if run.Status != pipelines.RunRunning {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run %d is still running", runID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	service, p := newWebhookPipeline(t)
+	body := []byte(`{"temp":31}`)
+
+	req := pipelines.WebhookRequest{Body: body, RemoteIP: "10.0.0.1"}
+	if _, _, err := service.HandleWebhook(ctx, p.ID, req); memtest.ErrCode(err) != pipelines.WebhookDisabledCode {
+		t.Fatalf("calling a disabled webhook: got error %v, want %s", err, pipelines.WebhookDisabledCode)
+	}
+
+	wh, err := service.RotateWebhookSecret(ctx, p.ID, 0)
+	if err != nil {
+		t.Fatalf("rotating secret: %v", err)
+	}
+	req.Signature = pipelines.SignWebhook(wh.Secret, time.Now(), body)
+
+	if _, _, err := service.HandleWebhook(ctx, p.ID, pipelines.WebhookRequest{Body: body, Signature: pipelines.SignWebhook("wrong", time.Now(), body)}); memtest.ErrCode(err) != pipelines.InvalidSignatureCode {
+		t.Errorf("calling with a wrong signature: got error %v, want %s", err, pipelines.InvalidSignatureCode)
+	}
+
+	if _, err := service.UpdateWebhook(ctx, p.ID, []string{"192.168.0.0/16"}); err != nil {
+		t.Fatalf("updating webhook: %v", err)
+	}
+	if _, _, err := service.HandleWebhook(ctx, p.ID, req); memtest.ErrCode(err) != pipelines.ForbiddenIPCode {
+		t.Errorf("calling from a forbidden address: got error %v, want %s", err, pipelines.ForbiddenIPCode)
+	}
+	if _, err := service.UpdateWebhook(ctx, p.ID, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatalf("updating webhook: %v", err)
+	}
+
+	run, replayed, err := service.HandleWebhook(ctx, p.ID, req)
+	if err != nil {
+		t.Fatalf("calling webhook: %v", err)
+	}
+	if replayed || run.Trigger != pipelines.TriggerWebhook || run.Status != pipelines.RunRunning || run.Payload != string(body) {
+		t.Errorf("webhook run = %+v (replayed %v), want a new running webhook run", run, replayed)
+	}
+	// The run completes after the webhook returns
+	if finished := waitForRun(t, service, run.ID); finished.Status != pipelines.RunSucceeded {
+		t.Errorf("finished webhook run = %+v, want a succeeded run", finished)
+	}
+
+	// Retries with the same idempotency key return the first run
+	req.IdempotencyKey = "delivery-1"
+	first, replayed, err := service.HandleWebhook(ctx, p.ID, req)
+	if err != nil || replayed {
+		t.Fatalf("first delivery: replayed %v (error: %v)", replayed, err)
+	}
+	retry, replayed, err := service.HandleWebhook(ctx, p.ID, req)
+	if err != nil || !replayed || retry.ID != first.ID {
+		t.Errorf("retried delivery: run %d, replayed %v (error: %v), want run %d replayed", retry.ID, replayed, err, first.ID)
+	}
+
+	req.IdempotencyKey = "delivery-2"
+	other, replayed, err := service.HandleWebhook(ctx, p.ID, req)
+	if err != nil || replayed || other.ID == first.ID {
+		t.Errorf("other delivery: replayed %v (error: %v), want a new run", replayed, err)
+	}
+}
+
+func TestRotateWebhookSecretGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	service, p := newWebhookPipeline(t)
+	body := []byte(`{"temp":31}`)
+
+	old, err := service.RotateWebhookSecret(ctx, p.ID, 0)
+	if err != nil {
+		t.Fatalf("rotating secret: %v", err)
+	}
+	oldSecret := old.Secret
+
+	if _, err := service.RotateWebhookSecret(ctx, p.ID, pipelines.MaxSecretGracePeriod+time.Second); memtest.ErrCode(err) != pipelines.InvalidInputCode {
+		t.Errorf("rotating with a too long grace period: got error %v, want %s", err, pipelines.InvalidInputCode)
+	}
+
+	if _, err := service.RotateWebhookSecret(ctx, p.ID, time.Hour); err != nil {
+		t.Fatalf("rotating secret: %v", err)
+	}
+	req := pipelines.WebhookRequest{Body: body, Signature: pipelines.SignWebhook(oldSecret, time.Now(), body)}
+	if _, _, err := service.HandleWebhook(ctx, p.ID, req); err != nil {
+		t.Errorf("calling with the previous secret during the grace period: %v", err)
+	}
+
+	// Rotating without grace period revokes every older secret
+	if _, err := service.RotateWebhookSecret(ctx, p.ID, 0); err != nil {
+		t.Fatalf("rotating secret: %v", err)
+	}
+	if _, _, err := service.HandleWebhook(ctx, p.ID, req); memtest.ErrCode(err) != pipelines.InvalidSignatureCode {
+		t.Errorf("calling with a revoked secret: got error %v, want %s", err, pipelines.InvalidSignatureCode)
+	}
+}
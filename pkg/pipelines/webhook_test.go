@@ -0,0 +1,105 @@
+package pipelines
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"temp":31}`)
+	wh := Webhook{
+		Secret:                  "current",
+		PreviousSecret:          "previous",
+		PreviousSecretExpiresAt: now.Add(time.Hour),
+	}
+	expired := Webhook{
+		Secret:                  "current",
+		PreviousSecret:          "previous",
+		PreviousSecretExpiresAt: now.Add(-time.Second),
+	}
+	timestamp := fmt.Sprint(now.Unix())
+
+	tests := []struct {
+		name      string
+		wh        Webhook
+		signature string
+		body      []byte
+		valid     bool
+	}{
+		{"current secret", wh, SignWebhook("current", now, body), body, true},
+		{"previous secret", wh, SignWebhook("previous", now, body), body, true},
+		{"expired previous secret", expired, SignWebhook("previous", now, body), body, false},
+		{"wrong secret", wh, SignWebhook("other", now, body), body, false},
+		{"tampered body", wh, SignWebhook("current", now, body), []byte(`{"temp":99}`), false},
+		{"within tolerance", wh, SignWebhook("current", now.Add(-WebhookTolerance+time.Second), body), body, true},
+		{"too old", wh, SignWebhook("current", now.Add(-WebhookTolerance-time.Second), body), body, false},
+		{"in the future", wh, SignWebhook("current", now.Add(WebhookTolerance+time.Second), body), body, false},
+		{
+			name:      "several signatures",
+			wh:        wh,
+			signature: fmt.Sprintf("t=%s, v1=%s, v1=%s", timestamp, webhookMAC("other", timestamp, body), webhookMAC("current", timestamp, body)),
+			body:      body,
+			valid:     true,
+		},
+		{
+			// The timestamp is signed, it can not be moved to replay an old request
+			name:      "replaced timestamp",
+			wh:        wh,
+			signature: fmt.Sprintf("t=%s,v1=%s", timestamp, webhookMAC("current", fmt.Sprint(now.Add(-time.Hour).Unix()), body)),
+			body:      body,
+		},
+		{"missing timestamp", wh, "v1=" + webhookMAC("current", timestamp, body), body, false},
+		{"missing signature", wh, "t=" + timestamp, body, false},
+		{"invalid timestamp", wh, "t=now,v1=" + webhookMAC("current", "now", body), body, false},
+		{"empty", wh, "", body, false},
+	}
+	for _, tt := range tests {
+		err := tt.wh.verifySignature(tt.signature, tt.body, now)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("verifySignature(%s) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestWebhookAllows(t *testing.T) {
+	wh := Webhook{AllowedIPs: []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"}}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.10", true},
+		{"192.168.1.11", false},
+		{"2001:db8::1", true},
+		{"::1", false},
+		{"not an ip", false},
+	}
+	for _, tt := range tests {
+		if got := wh.allows(tt.ip); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if !(&Webhook{}).allows("203.0.113.1") {
+		t.Error("webhook without an allowlist rejected an address")
+	}
+}
+
+func TestNormalizeAllowedIPs(t *testing.T) {
+	got, err := normalizeAllowedIPs([]string{" 10.1.2.3/8", "192.168.1.10 ", "2001:DB8::1"})
+	if err != nil {
+		t.Fatalf("normalizeAllowedIPs: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("normalizeAllowedIPs = %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "example.com", ""} {
+		if _, err := normalizeAllowedIPs([]string{invalid}); err == nil {
+			t.Errorf("normalizeAllowedIPs(%q) did not fail", invalid)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package pipelines
+
+import (
+	"context"
+	"log"
+	"net"
+
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/pipelines/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/tunnels"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// embeddedWorkerBufSize buffer size of the in memory connection to the embedded worker
+const embeddedWorkerBufSize = 1 << 20
+
+type workerServer struct {
+	protobuf.UnimplementedPipelineWorkerServer
+	executor *executor
+}
+
+// CreateWorkerServer PipelineWorker implementation interpreting pipeline
+// graphs in Go. Devices are invoked through tunnelService, as REST
+// invocations are (checked against the endpoint contract and limited
+// to the endpoint timeout). http-request nodes can not reach internal
+// addresses except the allowed networks (see newHTTPClient).
+func CreateWorkerServer(tunnelService tunnels.Service, endpointService endpoints.Service, allowedNetworks []*net.IPNet) protobuf.PipelineWorkerServer {
+	invoke := func(ctx context.Context, deviceID int64, pattern string, data string) (string, error) {
+		endpoint, err := endpointService.GetByPattern(deviceID, pattern)
+		if err != nil {
+			return "", err
+		}
+		// Pipelines invoke endpoints with the method they accept
+		// (only the payload is checked)
+		err = endpointService.ValidateRequest(*endpoint, endpoint.Method, data)
+		if err != nil {
+			return "", err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, endpoint.InvokeTimeout())
+		defer cancel()
+
+		resp, err := tunnelService.InvokeDevice(ctx, deviceID, pattern, data)
+		if err != nil {
+			return "", err
+		}
+		err = endpointService.ValidateResponse(*endpoint, resp.Data)
+		if err != nil {
+			return "", err
+		}
+		return resp.Data, nil
+	}
+
+	return &workerServer{
+		executor: &executor{
+			invoke:     invoke,
+			httpClient: newHTTPClient(allowedNetworks),
+		},
+	}
+}
+
+// CreateEmbeddedWorker serves the in process worker (see CreateWorkerServer)
+// over an in memory connection and returns a client to it, so pipelines can
+// run without the external worker
+func CreateEmbeddedWorker(tunnelService tunnels.Service, endpointService endpoints.Service, allowedNetworks []*net.IPNet) (protobuf.PipelineWorkerClient, error) {
+	lis := bufconn.Listen(embeddedWorkerBufSize)
+	server := grpc.NewServer()
+	protobuf.RegisterPipelineWorkerServer(server, CreateWorkerServer(tunnelService, endpointService, allowedNetworks))
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Printf("Embedded pipeline worker stopped (error: %v)", err)
+		}
+	}()
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.Dial("embedded", grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+
+	return protobuf.NewPipelineWorkerClient(conn), nil
+}
+
+// RunPipeline runs the pipeline without reporting progress
+// (deprecated, see ExecutePipeline)
+func (w *workerServer) RunPipeline(ctx context.Context, req *protobuf.PipelineRequest) (*protobuf.PipelineResponse, error) {
+	g, err := ParseGraph(req.Data)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid pipeline data (%v)", err)
+	}
+
+	var completed *protobuf.RunCompleted
+	err = w.executor.execute(ctx, g, req.Payload, func(progress *protobuf.RunProgress) error {
+		completed = progress.GetCompleted()
+		return nil
+	})
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if completed != nil && completed.Failed {
+		return nil, status.Error(codes.Aborted, completed.Error)
+	}
+
+	return &protobuf.PipelineResponse{}, nil
+}
+
+func (w *workerServer) ExecutePipeline(req *protobuf.PipelineRequest, stream protobuf.PipelineWorker_ExecutePipelineServer) error {
+	g, err := ParseGraph(req.Data)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid pipeline data (%v)", err)
+	}
+
+	err = w.executor.execute(stream.Context(), g, req.Payload, stream.Send)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+
+	return nil
+}
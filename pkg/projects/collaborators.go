@@ -0,0 +1,390 @@
+package projects
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// InvitationTTL how long an invitation can be accepted
+const InvitationTTL = time.Hour * 24 * 7
+
+// Collaborator a user taking part in a project
+type Collaborator struct {
+	ProjectID int64
+	UserID    int64
+	Role      Role
+	CreatedAt time.Time
+
+	// Filled from the user
+	Name        string
+	DisplayName string
+	Email       string
+}
+
+// InvitationStatus state of an invitation
+type InvitationStatus string
+
+const (
+	InvitationPending  = InvitationStatus("pending")
+	InvitationAccepted = InvitationStatus("accepted")
+	InvitationDeclined = InvitationStatus("declined")
+	InvitationRevoked  = InvitationStatus("revoked")
+)
+
+// Invitation a pending request for someone (registered or not) to join a project
+// Note: only the hash of the token is stored
+type Invitation struct {
+	ID          int64
+	ProjectID   int64
+	Email       string
+	Role        Role
+	TokenHash   string
+	InvitedBy   int64
+	Status      InvitationStatus
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	RespondedAt time.Time
+
+	// Token is the plain invitation token, only set right after creation
+	Token string
+}
+
+// InvitationRepository defines the invitation storage operations
+type InvitationRepository interface {
+	GetByID(invitationID int64) (*Invitation, error)
+	GetByTokenHash(tokenHash string) (*Invitation, error)
+	// GetPending returns pending non expired invitations of a project
+	GetPending(projectID int64, now time.Time) ([]Invitation, error)
+	// GetPendingByEmail returns pending non expired invitations sent to an email
+	GetPendingByEmail(email string, now time.Time) ([]Invitation, error)
+	Create(inv Invitation) (*Invitation, error)
+	// SetStatus changes the status of a pending invitation
+	SetStatus(invitationID int64, status InvitationStatus, respondedAt time.Time) error
+}
+
+func (s *service) GetCollaborators(projectID int64) ([]Collaborator, error) {
+	collaborators, err := s.projectRepo.GetCollaborators(projectID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return collaborators, nil
+}
+
+func (s *service) UpdateCollaboratorRole(actorID int64, actorRole Role, projectID int64, userID int64, role Role) error {
+	if !role.IsValid() || role == RoleOwner {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid role (use ownership transfer to change the owner)",
+		}
+	}
+
+	err := s.checkCanManage(actorID, actorRole, projectID, userID, role)
+	if err != nil {
+		return err
+	}
+
+	err = s.projectRepo.UpdateCollaboratorRole(userID, projectID, role)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    CollaboratorNotFoundCode,
+			Message: "Invalid user ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) RemoveCollaborator(actorID int64, actorRole Role, projectID int64, userID int64) error {
+	if actorID == userID {
+		role, err := s.projectRepo.GetRole(userID, projectID)
+		if err != nil {
+			return &utils.ServiceErr{
+				Code:    CollaboratorNotFoundCode,
+				Message: "Invalid user ID",
+			}
+		}
+		if role == RoleOwner {
+			return &utils.ServiceErr{
+				Code:    InvalidInputCode,
+				Message: "The owner can not leave the project (transfer ownership first)",
+			}
+		}
+	} else {
+		err := s.checkCanManage(actorID, actorRole, projectID, userID, RoleViewer)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.projectRepo.RemoveCollaborator(userID, projectID)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    CollaboratorNotFoundCode,
+			Message: "Invalid user ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) TransferOwnership(actorID int64, projectID int64, toUserID int64) error {
+	actorRole, err := s.projectRepo.GetRole(actorID, projectID)
+	if err != nil || actorRole != RoleOwner {
+		return &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Only the owner can transfer ownership",
+		}
+	}
+
+	if _, err = s.projectRepo.GetRole(toUserID, projectID); err != nil || actorID == toUserID {
+		return &utils.ServiceErr{
+			Code:    CollaboratorNotFoundCode,
+			Message: "New owner should be another collaborator of the project",
+		}
+	}
+
+	err = s.projectRepo.TransferOwnership(projectID, actorID, toUserID)
+	if err != nil {
+		log.Printf("Failed transferring ownership (error: %v)", err)
+		return &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed transferring ownership",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) Invite(actorID int64, actorRole Role, projectID int64, email string, role Role) (*Invitation, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid email",
+		}
+	}
+	if !role.IsValid() || role == RoleOwner {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid role",
+		}
+	}
+
+	actorRole, err := s.actingRole(actorID, actorRole, projectID)
+	if err != nil || !actorRole.Includes(RoleAdmin) || !canGrant(actorRole, role) {
+		return nil, &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Not allowed to invite with this role",
+		}
+	}
+
+	collaborators, err := s.projectRepo.GetCollaborators(projectID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+	for _, c := range collaborators {
+		if strings.EqualFold(c.Email, email) {
+			return nil, &utils.ServiceErr{
+				Code:    DuplicateCollaboratorCode,
+				Message: "User is already a collaborator",
+			}
+		}
+	}
+
+	now := time.Now()
+	token := utils.GenString(32)
+	inv, err := s.invitationRepo.Create(Invitation{
+		ProjectID: projectID,
+		Email:     email,
+		Role:      role,
+		TokenHash: hashInvitationToken(token),
+		InvitedBy: actorID,
+		Status:    InvitationPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(InvitationTTL),
+	})
+	if err != nil {
+		log.Printf("Failed creating invitation (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating invitation",
+		}
+	}
+	inv.Token = token
+
+	return inv, nil
+}
+
+func (s *service) GetPendingInvitations(projectID int64) ([]Invitation, error) {
+	invitations, err := s.invitationRepo.GetPending(projectID, time.Now())
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return invitations, nil
+}
+
+func (s *service) GetPendingInvitationsByEmail(email string) ([]Invitation, error) {
+	invitations, err := s.invitationRepo.GetPendingByEmail(email, time.Now())
+	if err != nil {
+		log.Printf("Failed listing invitations (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed listing invitations",
+		}
+	}
+
+	return invitations, nil
+}
+
+func (s *service) RevokeInvitation(projectID int64, invitationID int64) error {
+	inv, err := s.invitationRepo.GetByID(invitationID)
+	if err != nil || inv.ProjectID != projectID || inv.Status != InvitationPending {
+		return &utils.ServiceErr{
+			Code:    InvitationNotFoundCode,
+			Message: "Invalid invitation ID",
+		}
+	}
+
+	err = s.invitationRepo.SetStatus(invitationID, InvitationRevoked, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    InvitationNotFoundCode,
+			Message: "Invalid invitation ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) AcceptInvitation(userID int64, email string, token string) (*Invitation, error) {
+	inv, err := s.pendingInvitation(email, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.projectRepo.GetRole(userID, inv.ProjectID); err != nil {
+		err = s.projectRepo.AddCollaborator(userID, inv.ProjectID, inv.Role)
+		if err != nil {
+			log.Printf("Failed adding collaborator (error: %v)", err)
+			return nil, &utils.ServiceErr{
+				Code:    utils.UnexpectedCode,
+				Message: "Failed accepting invitation",
+			}
+		}
+	}
+
+	inv.Status = InvitationAccepted
+	inv.RespondedAt = time.Now()
+	err = s.invitationRepo.SetStatus(inv.ID, inv.Status, inv.RespondedAt)
+	if err != nil {
+		log.Printf("Failed updating invitation %d (error: %v)", inv.ID, err)
+	}
+
+	return inv, nil
+}
+
+func (s *service) DeclineInvitation(email string, token string) (*Invitation, error) {
+	inv, err := s.pendingInvitation(email, token)
+	if err != nil {
+		return nil, err
+	}
+
+	inv.Status = InvitationDeclined
+	inv.RespondedAt = time.Now()
+	err = s.invitationRepo.SetStatus(inv.ID, inv.Status, inv.RespondedAt)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvitationNotFoundCode,
+			Message: "Invalid invitation",
+		}
+	}
+
+	return inv, nil
+}
+
+// pendingInvitation finds a pending non expired invitation sent to email
+func (s *service) pendingInvitation(email string, token string) (*Invitation, error) {
+	inv, err := s.invitationRepo.GetByTokenHash(hashInvitationToken(token))
+	if err != nil || inv.Status != InvitationPending ||
+		time.Now().After(inv.ExpiresAt) || !strings.EqualFold(inv.Email, email) {
+		return nil, &utils.ServiceErr{
+			Code:    InvitationNotFoundCode,
+			Message: "Invalid or expired invitation",
+		}
+	}
+
+	return inv, nil
+}
+
+// actingRole the role of the actor in the project, limited to actorRole
+// (e.g. owners using an API key with the admin scope act as admins)
+func (s *service) actingRole(actorID int64, actorRole Role, projectID int64) (Role, error) {
+	role, err := s.projectRepo.GetRole(actorID, projectID)
+	if err != nil {
+		return "", err
+	}
+	if role.Includes(actorRole) {
+		return actorRole, nil
+	}
+
+	return role, nil
+}
+
+// checkCanManage checks that the actor can manage the collaborator userID
+// and grant them the given role. Owners manage everyone, admins manage
+// collaborators below them and can only grant roles below their own.
+func (s *service) checkCanManage(actorID int64, actorRole Role, projectID int64, userID int64, role Role) error {
+	actorRole, err := s.actingRole(actorID, actorRole, projectID)
+	if err != nil || !actorRole.Includes(RoleAdmin) || !canGrant(actorRole, role) {
+		return &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Not allowed to manage collaborators",
+		}
+	}
+
+	targetRole, err := s.projectRepo.GetRole(userID, projectID)
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    CollaboratorNotFoundCode,
+			Message: "Invalid user ID",
+		}
+	}
+	if targetRole == RoleOwner || (actorRole != RoleOwner && targetRole.Includes(actorRole)) {
+		return &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Not allowed to manage this collaborator",
+		}
+	}
+
+	return nil
+}
+
+// canGrant reports if actorRole can give the role to someone (admins
+// only grant roles they could manage afterwards, see checkCanManage)
+func canGrant(actorRole Role, role Role) bool {
+	if actorRole == RoleOwner {
+		return true
+	}
+	return actorRole.Includes(role) && !role.Includes(actorRole)
+}
+
+func hashInvitationToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
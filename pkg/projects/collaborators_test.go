@@ -0,0 +1,122 @@
+package projects_test
+
+import (
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// newProject creates a project with a collaborator per role and returns
+// the service, the project ID and the user ID of each role
+func newProject(t *testing.T) (projects.Service, int64, map[projects.Role]int64) {
+	t.Helper()
+	db := memory.CreateDB()
+	service := projects.CreateService(memory.CreateProjectRepository(db), memory.CreateInvitationRepository(db))
+	projectID, userIDs := memtest.CreateProject(t, db, service)
+	return service, projectID, userIDs
+}
+
+func collaboratorRole(t *testing.T, service projects.Service, projectID int64, userID int64) projects.Role {
+	t.Helper()
+	collaborators, err := service.GetCollaborators(projectID)
+	if err != nil {
+		t.Fatalf("getting collaborators: %v", err)
+	}
+	for _, c := range collaborators {
+		if c.UserID == userID {
+			return c.Role
+		}
+	}
+	return ""
+}
+
+func TestUpdateCollaboratorRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     projects.Role
+		actorRole projects.Role // role the actor acts with (e.g. capped by an API key)
+		target    projects.Role
+		role      projects.Role
+		code      utils.ServiceErrCode
+	}{
+		{"owner promotes to admin", projects.RoleOwner, projects.RoleOwner, projects.RoleDeveloper, projects.RoleAdmin, ""},
+		{"owner demotes admin", projects.RoleOwner, projects.RoleOwner, projects.RoleAdmin, projects.RoleViewer, ""},
+		{"admin demotes developer", projects.RoleAdmin, projects.RoleAdmin, projects.RoleDeveloper, projects.RoleViewer, ""},
+		{"admin promotes to admin", projects.RoleAdmin, projects.RoleAdmin, projects.RoleDeveloper, projects.RoleAdmin, projects.ForbiddenCode},
+		{"developer demotes viewer", projects.RoleDeveloper, projects.RoleDeveloper, projects.RoleViewer, projects.RoleViewer, projects.ForbiddenCode},
+		{"owner key demotes admin", projects.RoleOwner, projects.RoleAdmin, projects.RoleAdmin, projects.RoleViewer, projects.ForbiddenCode},
+		{"owner key promotes to admin", projects.RoleOwner, projects.RoleAdmin, projects.RoleDeveloper, projects.RoleAdmin, projects.ForbiddenCode},
+		{"owner key demotes developer", projects.RoleOwner, projects.RoleAdmin, projects.RoleDeveloper, projects.RoleViewer, ""},
+		{"admin acting as owner", projects.RoleAdmin, projects.RoleOwner, projects.RoleAdmin, projects.RoleViewer, projects.ForbiddenCode},
+		{"make owner", projects.RoleOwner, projects.RoleOwner, projects.RoleAdmin, projects.RoleOwner, projects.InvalidInputCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, projectID, userIDs := newProject(t)
+			err := service.UpdateCollaboratorRole(userIDs[tt.actor], tt.actorRole, projectID, userIDs[tt.target], tt.role)
+			if code := memtest.ErrCode(err); code != tt.code {
+				t.Fatalf("got error code %q, want %q (error: %v)", code, tt.code, err)
+			}
+
+			want := tt.target
+			if tt.code == "" {
+				want = tt.role
+			}
+			if got := collaboratorRole(t, service, projectID, userIDs[tt.target]); got != want {
+				t.Errorf("collaborator role = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoveCollaborator(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     projects.Role
+		actorRole projects.Role
+		target    projects.Role
+		code      utils.ServiceErrCode
+	}{
+		{"owner removes admin", projects.RoleOwner, projects.RoleOwner, projects.RoleAdmin, ""},
+		{"admin removes developer", projects.RoleAdmin, projects.RoleAdmin, projects.RoleDeveloper, ""},
+		{"admin removes owner", projects.RoleAdmin, projects.RoleAdmin, projects.RoleOwner, projects.ForbiddenCode},
+		{"owner key removes admin", projects.RoleOwner, projects.RoleAdmin, projects.RoleAdmin, projects.ForbiddenCode},
+		{"viewer leaves", projects.RoleViewer, projects.RoleViewer, projects.RoleViewer, ""},
+		{"owner leaves", projects.RoleOwner, projects.RoleOwner, projects.RoleOwner, projects.InvalidInputCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, projectID, userIDs := newProject(t)
+			err := service.RemoveCollaborator(userIDs[tt.actor], tt.actorRole, projectID, userIDs[tt.target])
+			if code := memtest.ErrCode(err); code != tt.code {
+				t.Errorf("got error code %q, want %q (error: %v)", code, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestInviteRoles(t *testing.T) {
+	tests := []struct {
+		actor     projects.Role
+		actorRole projects.Role
+		role      projects.Role
+		code      utils.ServiceErrCode
+	}{
+		{projects.RoleOwner, projects.RoleOwner, projects.RoleAdmin, ""},
+		{projects.RoleAdmin, projects.RoleAdmin, projects.RoleDeveloper, ""},
+		{projects.RoleAdmin, projects.RoleAdmin, projects.RoleAdmin, projects.ForbiddenCode},
+		{projects.RoleOwner, projects.RoleAdmin, projects.RoleAdmin, projects.ForbiddenCode},
+		{projects.RoleDeveloper, projects.RoleDeveloper, projects.RoleViewer, projects.ForbiddenCode},
+		{projects.RoleOwner, projects.RoleOwner, projects.RoleOwner, projects.InvalidInputCode},
+	}
+	for _, tt := range tests {
+		service, projectID, userIDs := newProject(t)
+		_, err := service.Invite(userIDs[tt.actor], tt.actorRole, projectID, "new@example.com", tt.role)
+		if code := memtest.ErrCode(err); code != tt.code {
+			t.Errorf("%s acting as %s inviting a %s: got error code %q, want %q (error: %v)", tt.actor, tt.actorRole, tt.role, code, tt.code, err)
+		}
+	}
+}
@@ -3,7 +3,13 @@ package projects
 import "github.com/tnynlabs/wyrm/pkg/utils"
 
 const (
-	InvalidInputCode   = utils.ServiceErrCode("INVALID_INPUT")
-	ProjectNotFoundCode   = utils.ServiceErrCode("PROJECT_NOT_FOUND")
-	UserNotFoundCode = utils.ServiceErrCode("USER_NOT_FOUND")
+	InvalidInputCode     = utils.ServiceErrCode("INVALID_INPUT")
+	ProjectNotFoundCode  = utils.ServiceErrCode("PROJECT_NOT_FOUND")
+	UserNotFoundCode     = utils.ServiceErrCode("USER_NOT_FOUND")
+	ResourceNotFoundCode = utils.ServiceErrCode("RESOURCE_NOT_FOUND")
+	ForbiddenCode        = utils.ServiceErrCode("FORBIDDEN")
+
+	CollaboratorNotFoundCode  = utils.ServiceErrCode("COLLABORATOR_NOT_FOUND")
+	DuplicateCollaboratorCode = utils.ServiceErrCode("DUPLICATE_COLLABORATOR")
+	InvitationNotFoundCode    = utils.ServiceErrCode("INVITATION_NOT_FOUND")
 )
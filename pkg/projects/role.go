@@ -0,0 +1,59 @@
+package projects
+
+// Role of a collaborator in a project
+type Role string
+
+const (
+	// RoleOwner full control including deleting the project
+	RoleOwner = Role("owner")
+	// RoleAdmin manages the project and its collaborators
+	RoleAdmin = Role("admin")
+	// RoleDeveloper manages devices, endpoints and pipelines
+	RoleDeveloper = Role("developer")
+	// RoleViewer read only access
+	RoleViewer = Role("viewer")
+)
+
+// roleRanks higher rank includes the permissions of lower ranks
+var roleRanks = map[Role]int{
+	RoleViewer:    1,
+	RoleDeveloper: 2,
+	RoleAdmin:     3,
+	RoleOwner:     4,
+}
+
+// IsValid checks that the role is one of the known roles
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports if r grants at least the permissions of required
+func (r Role) Includes(required Role) bool {
+	return r.IsValid() && roleRanks[r] >= roleRanks[required]
+}
+
+// ResourceType kinds of resources that belong to a project
+type ResourceType string
+
+const (
+	ProjectResource    = ResourceType("project")
+	DeviceResource     = ResourceType("device")
+	EndpointResource   = ResourceType("endpoint")
+	PipelineResource   = ResourceType("pipeline")
+	InvocationResource = ResourceType("invocation")
+	RunResource        = ResourceType("run")
+	ScheduleResource   = ResourceType("schedule")
+)
+
+// Resource identifies a single project resource (e.g. device 5)
+type Resource struct {
+	Type ResourceType
+	ID   int64
+}
+
+// Authorization result of a successful authorization check
+type Authorization struct {
+	ProjectID int64
+	Role      Role
+}
@@ -22,7 +22,16 @@ type Repository interface {
 	Create(p Project) (*Project, error)
 	Update(projectID int64, p Project) (*Project, error)
 	Delete(projectID int64) error
-	AddCollaborator(userID int64, projectID int64) error
+	AddCollaborator(userID int64, projectID int64, role Role) error
+	GetCollaborators(projectID int64) ([]Collaborator, error)
+	UpdateCollaboratorRole(userID int64, projectID int64, role Role) error
+	RemoveCollaborator(userID int64, projectID int64) error
+	// TransferOwnership atomically makes toUserID the owner and fromUserID an admin
+	TransferOwnership(projectID int64, fromUserID int64, toUserID int64) error
+	// GetRole returns the role of the user in the project
+	GetRole(userID int64, projectID int64) (Role, error)
+	// GetResourceProjectID resolves a resource to the id of the project owning it
+	GetResourceProjectID(resource Resource) (int64, error)
 }
 
 type Service interface {
@@ -31,15 +40,39 @@ type Service interface {
 	Create(p Project) (*Project, error)
 	Update(projectID int64, p Project) (*Project, error)
 	Delete(projectID int64) error
-	AddCollaborator(userID int64, projectID int64) error
+	// AddCollaborator directly attaches a user to the project (see Invite for the invitation flow)
+	AddCollaborator(userID int64, projectID int64, role Role) error
+
+	// Collaborator management (actorID is the user performing the action, actorRole
+	// the role they act with, e.g. limited by the scope of their API key, see Authorization)
+	GetCollaborators(projectID int64) ([]Collaborator, error)
+	UpdateCollaboratorRole(actorID int64, actorRole Role, projectID int64, userID int64, role Role) error
+	// RemoveCollaborator removes a collaborator (users can always remove themselves unless they are the owner)
+	RemoveCollaborator(actorID int64, actorRole Role, projectID int64, userID int64) error
+	TransferOwnership(actorID int64, projectID int64, toUserID int64) error
+
+	// Invitations
+	// Invite creates a pending invitation (the returned invitation has Token set)
+	Invite(actorID int64, actorRole Role, projectID int64, email string, role Role) (*Invitation, error)
+	GetPendingInvitations(projectID int64) ([]Invitation, error)
+	GetPendingInvitationsByEmail(email string) ([]Invitation, error)
+	RevokeInvitation(projectID int64, invitationID int64) error
+	// AcceptInvitation adds the user (whose email must match the invitation) as a collaborator
+	AcceptInvitation(userID int64, email string, token string) (*Invitation, error)
+	DeclineInvitation(email string, token string) (*Invitation, error)
+
+	// Authorize checks that the user has at least the required role
+	// in the project owning the resource
+	Authorize(userID int64, resource Resource, required Role) (*Authorization, error)
 }
 
 type service struct {
-	projectRepo Repository
+	projectRepo    Repository
+	invitationRepo InvitationRepository
 }
 
-func CreateService(repo Repository) Service {
-	return &service{repo}
+func CreateService(repo Repository, invitationRepo InvitationRepository) Service {
+	return &service{repo, invitationRepo}
 }
 
 func (s *service) GetByID(projectID int64) (*Project, error) {
@@ -85,8 +118,22 @@ func (s *service) Create(p Project) (*Project, error) {
 
 	return newProject, nil
 }
-func (s *service) AddCollaborator(userID int64, projectID int64) error{
-	err := s.projectRepo.AddCollaborator(userID, projectID)
+func (s *service) AddCollaborator(userID int64, projectID int64, role Role) error {
+	if !role.IsValid() || role == RoleOwner {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid role",
+		}
+	}
+
+	if _, err := s.projectRepo.GetRole(userID, projectID); err == nil {
+		return &utils.ServiceErr{
+			Code:    DuplicateCollaboratorCode,
+			Message: "User is already a collaborator",
+		}
+	}
+
+	err := s.projectRepo.AddCollaborator(userID, projectID, role)
 	if err != nil {
 		log.Printf("Failed adding collaborator (error: %v)", err)
 		return &utils.ServiceErr{
@@ -131,3 +178,23 @@ func (s *service) Delete(projectID int64) error {
 	
 	return nil
 }
+
+func (s *service) Authorize(userID int64, resource Resource, required Role) (*Authorization, error) {
+	projectID, err := s.projectRepo.GetResourceProjectID(resource)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ResourceNotFoundCode,
+			Message: "Invalid " + string(resource.Type) + " ID",
+		}
+	}
+
+	role, err := s.projectRepo.GetRole(userID, projectID)
+	if err != nil || !role.Includes(required) {
+		return nil, &utils.ServiceErr{
+			Code:    ForbiddenCode,
+			Message: "Not allowed to access this " + string(resource.Type),
+		}
+	}
+
+	return &Authorization{ProjectID: projectID, Role: role}, nil
+}
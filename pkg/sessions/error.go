@@ -0,0 +1,16 @@
+package sessions
+
+import (
+	"errors"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	InvalidSessionCode  = utils.ServiceErrCode("INVALID_SESSION")
+	SessionNotFoundCode = utils.ServiceErrCode("SESSION_NOT_FOUND")
+)
+
+// ErrTokenRotated should be returned by repositories when rotating a session
+// whose token is no longer the given one (e.g. rotated by a concurrent request)
+var ErrTokenRotated = errors.New("session token was rotated")
@@ -0,0 +1,263 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// DefaultTTL how long a session stays valid without being used
+	DefaultTTL = time.Hour * 24 * 30
+	// DefaultRotationInterval how often a session token gets replaced while in use
+	DefaultRotationInterval = time.Hour * 24
+	// RotationGracePeriod how long the previous token of a rotated session
+	// stays valid (requests sent before the client got the new token)
+	RotationGracePeriod = time.Minute
+
+	// lastUsedResolution avoids a write on every authenticated request
+	lastUsedResolution = time.Minute
+)
+
+// Session a single login of a user
+// Note: only the hash of the token is stored
+type Session struct {
+	ID         int64
+	UserID     int64
+	TokenHash  string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	RotatedAt  time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	// PreviousTokenHash hash of the token replaced by the latest rotation,
+	// valid until PreviousTokenExpiresAt
+	PreviousTokenHash      string
+	PreviousTokenExpiresAt time.Time
+
+	// Token is the plain session token, only set when a token was just
+	// minted (on creation or rotation) so it can be sent to the client
+	Token string
+}
+
+// IsActive reports if the session was not revoked and did not expire
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
+
+// Repository defines the sessions.Repository operations
+type Repository interface {
+	GetByID(sessionID int64) (*Session, error)
+	// GetByTokenHash returns the session with the token (or previous token) hash
+	GetByTokenHash(tokenHash string) (*Session, error)
+	// GetActiveByUserID returns sessions of the user that are neither revoked nor expired
+	GetActiveByUserID(userID int64, now time.Time) ([]Session, error)
+	Create(s Session) (*Session, error)
+	// Update updates last used and expiry times (zero values are not updated)
+	Update(sessionID int64, s Session) (*Session, error)
+	// Rotate replaces the token hash of the session if it is still oldTokenHash
+	// (kept as the previous token hash), and updates the previous token expiry,
+	// last used, rotated and expiry times. ErrTokenRotated is returned if the
+	// token hash is no longer oldTokenHash.
+	Rotate(sessionID int64, oldTokenHash string, s Session) (*Session, error)
+	Revoke(sessionID int64, revokedAt time.Time) error
+	RevokeByUserID(userID int64, revokedAt time.Time) error
+}
+
+// Service defines the sessions.Service operations
+type Service interface {
+	// Create mints a new session for the user (the returned session has Token set)
+	Create(userID int64, userAgent, ip string) (*Session, error)
+	// Authenticate validates a token and marks the session as used.
+	// If the token is due for rotation a new one is minted and returned in Session.Token
+	Authenticate(token string) (*Session, error)
+	GetActiveByUserID(userID int64) ([]Session, error)
+	// Revoke revokes a session of the given user
+	Revoke(userID int64, sessionID int64) error
+	RevokeByToken(token string) error
+	RevokeAll(userID int64) error
+}
+
+type service struct {
+	sessionRepo      Repository
+	ttl              time.Duration
+	rotationInterval time.Duration
+}
+
+// CreateService Create new instance of Session Service
+// Sessions expire after ttl without use and their tokens are
+// replaced every rotationInterval while in use
+func CreateService(repo Repository, ttl, rotationInterval time.Duration) Service {
+	return &service{repo, ttl, rotationInterval}
+}
+
+func (s *service) Create(userID int64, userAgent, ip string) (*Session, error) {
+	now := time.Now()
+	token := utils.GenString(48)
+
+	sess, err := s.sessionRepo.Create(Session{
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		RotatedAt:  now,
+		ExpiresAt:  now.Add(s.ttl),
+	})
+	if err != nil {
+		log.Printf("Failed creating new session (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating new session",
+		}
+	}
+	sess.Token = token
+
+	return sess, nil
+}
+
+func (s *service) Authenticate(token string) (*Session, error) {
+	if token == "" {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidSessionCode,
+			Message: "Missing session token",
+		}
+	}
+
+	now := time.Now()
+	tokenHash := hashToken(token)
+	sess, err := s.sessionRepo.GetByTokenHash(tokenHash)
+	if err != nil || !sess.IsActive(now) {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidSessionCode,
+			Message: "Invalid or expired session",
+		}
+	}
+
+	if sess.TokenHash != tokenHash {
+		// Previous token of a rotated session (the new token
+		// was sent to the client by the rotating request)
+		if !now.Before(sess.PreviousTokenExpiresAt) {
+			return nil, &utils.ServiceErr{
+				Code:    InvalidSessionCode,
+				Message: "Invalid or expired session",
+			}
+		}
+		return sess, nil
+	}
+
+	if now.Sub(sess.LastUsedAt) < lastUsedResolution {
+		return sess, nil
+	}
+
+	if now.Sub(sess.RotatedAt) >= s.rotationInterval {
+		return s.rotate(sess, now)
+	}
+
+	updated, err := s.sessionRepo.Update(sess.ID, Session{
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	})
+	if err != nil {
+		// The session itself is still valid
+		log.Printf("Failed updating session %d (error: %v)", sess.ID, err)
+		return sess, nil
+	}
+
+	return updated, nil
+}
+
+// rotate replaces the token of the session, the current token stays valid
+// for RotationGracePeriod. Only one of concurrent requests rotating the
+// session mints a new token (the others go on with the current one).
+func (s *service) rotate(sess *Session, now time.Time) (*Session, error) {
+	newToken := utils.GenString(48)
+	rotated, err := s.sessionRepo.Rotate(sess.ID, sess.TokenHash, Session{
+		TokenHash:              hashToken(newToken),
+		PreviousTokenExpiresAt: now.Add(RotationGracePeriod),
+		LastUsedAt:             now,
+		RotatedAt:              now,
+		ExpiresAt:              now.Add(s.ttl),
+	})
+	if err == ErrTokenRotated {
+		return sess, nil
+	}
+	if err != nil {
+		// The session itself is still valid, rotation will be retried next time
+		log.Printf("Failed rotating session %d (error: %v)", sess.ID, err)
+		return sess, nil
+	}
+	rotated.Token = newToken
+
+	return rotated, nil
+}
+
+func (s *service) GetActiveByUserID(userID int64) ([]Session, error) {
+	userSessions, err := s.sessionRepo.GetActiveByUserID(userID, time.Now())
+	if err != nil {
+		log.Printf("Failed listing sessions (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed listing sessions",
+		}
+	}
+
+	return userSessions, nil
+}
+
+func (s *service) Revoke(userID int64, sessionID int64) error {
+	sess, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil || sess.UserID != userID {
+		return &utils.ServiceErr{
+			Code:    SessionNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	err = s.sessionRepo.Revoke(sessionID, time.Now())
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    SessionNotFoundCode,
+			Message: "Invalid ID",
+		}
+	}
+
+	return nil
+}
+
+func (s *service) RevokeByToken(token string) error {
+	sess, err := s.sessionRepo.GetByTokenHash(hashToken(token))
+	if err != nil {
+		return &utils.ServiceErr{
+			Code:    InvalidSessionCode,
+			Message: "Invalid session",
+		}
+	}
+
+	return s.Revoke(sess.UserID, sess.ID)
+}
+
+func (s *service) RevokeAll(userID int64) error {
+	err := s.sessionRepo.RevokeByUserID(userID, time.Now())
+	if err != nil {
+		log.Printf("Failed revoking sessions (error: %v)", err)
+		return &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed revoking sessions",
+		}
+	}
+
+	return nil
+}
+
+// hashToken tokens are random (high entropy) so a fast hash is enough
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
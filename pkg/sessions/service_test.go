@@ -0,0 +1,185 @@
+package sessions_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+// newSession returns a service that rotates tokens on every use (unless used
+// within the last minute) and a session of a new user
+func newSession(t *testing.T) (sessions.Service, sessions.Repository, *sessions.Session) {
+	t.Helper()
+	db := memory.CreateDB()
+	repo := memory.CreateSessionRepository(db)
+	service := sessions.CreateService(repo, time.Hour, 0)
+
+	user, err := memory.CreateUserRepository(db).Create(users.User{Name: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	sess, err := service.Create(user.ID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+	return service, repo, sess
+}
+
+// ageSession marks the session as last used long enough ago to be rotated
+func ageSession(t *testing.T, repo sessions.Repository, sessionID int64) {
+	t.Helper()
+	if _, err := repo.Update(sessionID, sessions.Session{LastUsedAt: time.Now().Add(-2 * time.Minute)}); err != nil {
+		t.Fatalf("updating session: %v", err)
+	}
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestRotateWithinGracePeriod(t *testing.T) {
+	service, repo, sess := newSession(t)
+	oldToken := sess.Token
+
+	ageSession(t, repo, sess.ID)
+	rotated, err := service.Authenticate(oldToken)
+	if err != nil {
+		t.Fatalf("authenticating: %v", err)
+	}
+	if rotated.Token == "" || rotated.Token == oldToken {
+		t.Fatalf("token was not rotated (got %q)", rotated.Token)
+	}
+
+	// Requests sent before the client got the new token
+	again, err := service.Authenticate(oldToken)
+	if err != nil {
+		t.Fatalf("authenticating with the previous token during the grace period: %v", err)
+	}
+	if again.ID != sess.ID || again.Token != "" {
+		t.Errorf("previous token authenticated session %d with token %q, want session %d without a new token", again.ID, again.Token, sess.ID)
+	}
+	if _, err := service.Authenticate(rotated.Token); err != nil {
+		t.Errorf("authenticating with the new token: %v", err)
+	}
+}
+
+func TestRotateAfterGracePeriod(t *testing.T) {
+	service, repo, sess := newSession(t)
+	now := time.Now()
+
+	// A rotation whose grace period is over
+	_, err := repo.Rotate(sess.ID, hashToken(sess.Token), sessions.Session{
+		TokenHash:              hashToken("new-token"),
+		PreviousTokenExpiresAt: now.Add(-time.Second),
+		LastUsedAt:             now,
+		RotatedAt:              now,
+		ExpiresAt:              now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("rotating session: %v", err)
+	}
+
+	if _, err := service.Authenticate(sess.Token); memtest.ErrCode(err) != sessions.InvalidSessionCode {
+		t.Errorf("authenticating with an expired previous token: got error %v, want %s", err, sessions.InvalidSessionCode)
+	}
+	if _, err := service.Authenticate("new-token"); err != nil {
+		t.Errorf("authenticating with the new token: %v", err)
+	}
+
+	// Only the latest token can be rotated
+	if _, err := repo.Rotate(sess.ID, hashToken(sess.Token), sessions.Session{TokenHash: hashToken("other-token")}); err != sessions.ErrTokenRotated {
+		t.Errorf("rotating a replaced token: got error %v, want %v", err, sessions.ErrTokenRotated)
+	}
+}
+
+// raceRepo makes concurrent requests read the session before any of them goes on
+type raceRepo struct {
+	sessions.Repository
+	read *sync.WaitGroup
+}
+
+func (r raceRepo) GetByTokenHash(tokenHash string) (*sessions.Session, error) {
+	sess, err := r.Repository.GetByTokenHash(tokenHash)
+	r.read.Done()
+	r.read.Wait()
+	return sess, err
+}
+
+func TestConcurrentRotations(t *testing.T) {
+	_, repo, sess := newSession(t)
+	ageSession(t, repo, sess.ID)
+
+	const requests = 10
+	read := &sync.WaitGroup{}
+	read.Add(requests)
+	service := sessions.CreateService(raceRepo{repo, read}, time.Hour, 0)
+
+	var wg sync.WaitGroup
+	tokens := make(chan string, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authenticated, err := service.Authenticate(sess.Token)
+			if err != nil {
+				t.Errorf("authenticating: %v", err)
+				return
+			}
+			if authenticated.Token != "" {
+				tokens <- authenticated.Token
+			}
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+
+	minted := []string{}
+	for token := range tokens {
+		minted = append(minted, token)
+	}
+	if len(minted) != 1 {
+		t.Fatalf("concurrent requests minted %d tokens, want 1", len(minted))
+	}
+	stored, err := repo.GetByID(sess.ID)
+	if err != nil {
+		t.Fatalf("getting session: %v", err)
+	}
+	if stored.TokenHash != hashToken(minted[0]) {
+		t.Errorf("stored token hash %q, want the hash of the minted token", stored.TokenHash)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	service, repo, sess := newSession(t)
+	ageSession(t, repo, sess.ID)
+	rotated, err := service.Authenticate(sess.Token)
+	if err != nil {
+		t.Fatalf("authenticating: %v", err)
+	}
+
+	if err := service.Revoke(sess.UserID+1, sess.ID); memtest.ErrCode(err) != sessions.SessionNotFoundCode {
+		t.Errorf("revoking the session of another user: got error %v, want %s", err, sessions.SessionNotFoundCode)
+	}
+	if err := service.RevokeByToken(rotated.Token); err != nil {
+		t.Fatalf("revoking session: %v", err)
+	}
+
+	// Neither the current nor the previous token are valid anymore
+	for _, token := range []string{rotated.Token, sess.Token} {
+		if _, err := service.Authenticate(token); memtest.ErrCode(err) != sessions.InvalidSessionCode {
+			t.Errorf("authenticating with a revoked session: got error %v, want %s", err, sessions.InvalidSessionCode)
+		}
+	}
+	active, err := service.GetActiveByUserID(sess.UserID)
+	if err != nil || len(active) != 0 {
+		t.Errorf("active sessions = %d (error: %v), want none", len(active), err)
+	}
+}
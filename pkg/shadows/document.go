@@ -0,0 +1,68 @@
+package shadows
+
+import "reflect"
+
+// Document a JSON object (as decoded by encoding/json)
+type Document map[string]interface{}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to a copy of doc:
+// null values remove keys and nested objects are merged recursively
+func mergePatch(doc Document, patch Document) Document {
+	result := copyDocument(doc)
+	for key, value := range patch {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+
+		patchObj, isObj := value.(map[string]interface{})
+		if !isObj {
+			result[key] = value
+			continue
+		}
+		targetObj, _ := result[key].(map[string]interface{})
+		result[key] = map[string]interface{}(mergePatch(targetObj, patchObj))
+	}
+
+	return result
+}
+
+// delta returns the parts of desired that differ from reported
+// (nested objects are compared key by key)
+func delta(desired Document, reported Document) Document {
+	result := Document{}
+	for key, desiredValue := range desired {
+		reportedValue, ok := reported[key]
+		if !ok {
+			result[key] = desiredValue
+			continue
+		}
+
+		desiredObj, desiredIsObj := desiredValue.(map[string]interface{})
+		reportedObj, reportedIsObj := reportedValue.(map[string]interface{})
+		if desiredIsObj && reportedIsObj {
+			if nested := delta(desiredObj, reportedObj); len(nested) != 0 {
+				result[key] = map[string]interface{}(nested)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(desiredValue, reportedValue) {
+			result[key] = desiredValue
+		}
+	}
+
+	return result
+}
+
+func copyDocument(doc Document) Document {
+	result := make(Document, len(doc))
+	for key, value := range doc {
+		if obj, ok := value.(map[string]interface{}); ok {
+			value = map[string]interface{}(copyDocument(obj))
+		}
+		result[key] = value
+	}
+
+	return result
+}
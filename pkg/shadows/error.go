@@ -0,0 +1,17 @@
+package shadows
+
+import (
+	"errors"
+
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	InvalidInputCode    = utils.ServiceErrCode("INVALID_INPUT")
+	DeviceNotFoundCode  = utils.ServiceErrCode("DEVICE_NOT_FOUND")
+	VersionConflictCode = utils.ServiceErrCode("VERSION_CONFLICT")
+)
+
+// ErrVersionConflict should be returned by repositories when the
+// stored shadow version is not the expected one
+var ErrVersionConflict = errors.New("shadow version conflict")
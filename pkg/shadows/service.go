@@ -0,0 +1,208 @@
+package shadows
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// maxReportRetries how many times a reported state update is retried on version conflicts
+const maxReportRetries = 3
+
+// Shadow the desired (set by users) and reported (set by the device) state of a device.
+// Version is incremented on every change and is used for optimistic concurrency.
+type Shadow struct {
+	DeviceID          int64
+	Desired           Document
+	Reported          Document
+	Version           int64
+	DesiredUpdatedAt  time.Time
+	ReportedUpdatedAt time.Time
+}
+
+// Delta returns the desired state the device did not report yet
+func (s *Shadow) Delta() Document {
+	return delta(s.Desired, s.Reported)
+}
+
+// Repository defines the shadows.Repository operations
+type Repository interface {
+	// Get returns the shadow of the device (an error if it was never saved)
+	Get(deviceID int64) (*Shadow, error)
+	// Save stores the shadow with version expectedVersion+1 only if the stored
+	// version is expectedVersion (0 if it was never saved), ErrVersionConflict otherwise
+	Save(s Shadow, expectedVersion int64) (*Shadow, error)
+}
+
+// Service defines the shadows.Service operations
+type Service interface {
+	// Get returns the shadow of the device (an empty one with version 0 if it was never set)
+	Get(deviceID int64) (*Shadow, error)
+	// UpdateDesired merges patch into the desired state if version is the current one
+	// then delivers the delta to the device if it is online
+	UpdateDesired(deviceID int64, patch Document, version int64) (*Shadow, error)
+	// UpdateReported merges patch (sent by the device) into the reported state
+	UpdateReported(deviceID int64, patch Document) (*Shadow, error)
+	// SyncDevice delivers the current delta to the device (e.g. when it reconnects)
+	SyncDevice(deviceID int64)
+}
+
+// DeltaSender delivers shadow deltas to devices
+type DeltaSender interface {
+	SendShadowDelta(ctx context.Context, deviceID int64, version int64, delta string) error
+}
+
+type service struct {
+	shadowRepo    Repository
+	deviceService devices.Service
+	deltaSender   DeltaSender
+}
+
+// CreateService Create new instance of Shadow Service
+func CreateService(repo Repository, deviceService devices.Service, deltaSender DeltaSender) Service {
+	return &service{repo, deviceService, deltaSender}
+}
+
+func (s *service) Get(deviceID int64) (*Shadow, error) {
+	_, err := s.deviceService.GetByID(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	return s.get(deviceID), nil
+}
+
+func (s *service) UpdateDesired(deviceID int64, patch Document, version int64) (*Shadow, error) {
+	if patch == nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Desired state should be a JSON object",
+		}
+	}
+
+	device, err := s.deviceService.GetByID(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	shadow := s.get(deviceID)
+	if shadow.Version != version {
+		return nil, &utils.ServiceErr{
+			Code:    VersionConflictCode,
+			Message: "Shadow was modified (get the latest version and retry)",
+		}
+	}
+
+	shadow.Desired = mergePatch(shadow.Desired, patch)
+	shadow.DesiredUpdatedAt = time.Now()
+	saved, err := s.shadowRepo.Save(*shadow, version)
+	if err == ErrVersionConflict {
+		return nil, &utils.ServiceErr{
+			Code:    VersionConflictCode,
+			Message: "Shadow was modified (get the latest version and retry)",
+		}
+	}
+	if err != nil {
+		log.Printf("Failed saving shadow of device %d (error: %v)", deviceID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed saving shadow",
+		}
+	}
+
+	// Offline devices get the delta when they reconnect
+	if device.Status == devices.StatusOnline {
+		s.sendDelta(saved)
+	}
+
+	return saved, nil
+}
+
+func (s *service) UpdateReported(deviceID int64, patch Document) (*Shadow, error) {
+	if patch == nil {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Reported state should be a JSON object",
+		}
+	}
+
+	_, err := s.deviceService.GetByID(deviceID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	// Devices can not resolve conflicts themselves so the merge is retried
+	for i := 0; i < maxReportRetries; i++ {
+		shadow := s.get(deviceID)
+		version := shadow.Version
+		shadow.Reported = mergePatch(shadow.Reported, patch)
+		shadow.ReportedUpdatedAt = time.Now()
+
+		saved, err := s.shadowRepo.Save(*shadow, version)
+		if err == ErrVersionConflict {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed saving shadow of device %d (error: %v)", deviceID, err)
+			break
+		}
+
+		return saved, nil
+	}
+
+	return nil, &utils.ServiceErr{
+		Code:    utils.UnexpectedCode,
+		Message: "Failed saving shadow",
+	}
+}
+
+func (s *service) SyncDevice(deviceID int64) {
+	s.sendDelta(s.get(deviceID))
+}
+
+// get returns the stored shadow or an empty one
+func (s *service) get(deviceID int64) *Shadow {
+	shadow, err := s.shadowRepo.Get(deviceID)
+	if err != nil {
+		return &Shadow{
+			DeviceID: deviceID,
+			Desired:  Document{},
+			Reported: Document{},
+		}
+	}
+
+	return shadow
+}
+
+// sendDelta the shadow is already saved so delivery failures are only logged
+// (the delta is sent again when the device reconnects)
+func (s *service) sendDelta(shadow *Shadow) {
+	d := shadow.Delta()
+	if len(d) == 0 {
+		return
+	}
+
+	deltaJSON, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("Failed encoding shadow delta of device %d (error: %v)", shadow.DeviceID, err)
+		return
+	}
+
+	err = s.deltaSender.SendShadowDelta(context.Background(), shadow.DeviceID, shadow.Version, string(deltaJSON))
+	if err != nil {
+		log.Printf("Failed delivering shadow delta to device %d (error: %v)", shadow.DeviceID, err)
+	}
+}
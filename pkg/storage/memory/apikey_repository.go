@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+)
+
+// APIKeyRepository apikeys.Repository in memory implementation
+type APIKeyRepository struct {
+	db *DB
+}
+
+// CreateAPIKeyRepository Create new instance of memory.APIKeyRepository
+func CreateAPIKeyRepository(db *DB) apikeys.Repository {
+	return &APIKeyRepository{db}
+}
+
+func (kR *APIKeyRepository) GetByID(keyID int64) (*apikeys.Key, error) {
+	kR.db.mu.RLock()
+	defer kR.db.mu.RUnlock()
+
+	k, ok := kR.db.apiKeys[keyID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &k, nil
+}
+
+func (kR *APIKeyRepository) GetByHash(keyHash string) (*apikeys.Key, error) {
+	kR.db.mu.RLock()
+	defer kR.db.mu.RUnlock()
+
+	for _, k := range kR.db.apiKeys {
+		if k.KeyHash == keyHash {
+			return &k, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (kR *APIKeyRepository) GetByUserID(userID int64) ([]apikeys.Key, error) {
+	kR.db.mu.RLock()
+	defer kR.db.mu.RUnlock()
+
+	keys := []apikeys.Key{}
+	for _, k := range kR.db.apiKeys {
+		if k.UserID == userID && k.RevokedAt.IsZero() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (kR *APIKeyRepository) Create(k apikeys.Key) (*apikeys.Key, error) {
+	kR.db.mu.Lock()
+	defer kR.db.mu.Unlock()
+
+	if _, ok := kR.db.users[k.UserID]; !ok {
+		return nil, errForeignKey
+	}
+	if _, ok := kR.db.projects[k.ProjectID]; !ok {
+		return nil, errForeignKey
+	}
+	for _, existing := range kR.db.apiKeys {
+		if existing.KeyHash == k.KeyHash {
+			return nil, errDuplicate
+		}
+	}
+
+	k.ID = kR.db.nextID("external_keys")
+	kR.db.apiKeys[k.ID] = k
+
+	return &k, nil
+}
+
+func (kR *APIKeyRepository) UpdateLastUsed(keyID int64, lastUsedAt time.Time) error {
+	kR.db.mu.Lock()
+	defer kR.db.mu.Unlock()
+
+	k, ok := kR.db.apiKeys[keyID]
+	if !ok {
+		return errInvalidID
+	}
+	k.LastUsedAt = lastUsedAt
+	kR.db.apiKeys[keyID] = k
+
+	return nil
+}
+
+func (kR *APIKeyRepository) Revoke(keyID int64, revokedAt time.Time) error {
+	kR.db.mu.Lock()
+	defer kR.db.mu.Unlock()
+
+	k, ok := kR.db.apiKeys[keyID]
+	if !ok || !k.RevokedAt.IsZero() {
+		return errInvalidID
+	}
+	k.RevokedAt = revokedAt
+	kR.db.apiKeys[keyID] = k
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+	"github.com/tnynlabs/wyrm/pkg/telemetry"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+var (
+	errInvalidID  = errors.New("Invalid ID")
+	errDuplicate  = errors.New("Duplicate key")
+	errForeignKey = errors.New("Referenced row does not exist")
+	errReferenced = errors.New("Row is still referenced")
+)
+
+// DB In memory storage shared by all memory repositories
+// Note: a single lock guards all tables so that cascading deletes
+// and cross table checks stay consistent. Deletes follow the foreign keys
+// of the postgres schema (cascading or rejected when still referenced).
+type DB struct {
+	mu sync.RWMutex
+
+	users     map[int64]users.User
+	projects  map[int64]projects.Project
+	devices   map[int64]devices.Device
+	endpoints map[int64]endpoints.Endpoint
+	pipelines map[int64]pipelines.Pipeline
+	sessions  map[int64]sessions.Session
+
+	invitations map[int64]projects.Invitation
+	apiKeys     map[int64]apikeys.Key
+
+	deviceKeyEvents map[int64]devices.KeyEvent
+	// device id -> shadow
+	deviceShadows map[int64]shadows.Shadow
+	// device id -> datapoints
+	telemetry map[int64][]telemetry.Datapoint
+
+	invocations map[int64]invocations.Invocation
+
+	pipelineRuns map[int64]pipelines.Run
+	// pipeline id -> versions (oldest first)
+	pipelineVersions  map[int64][]pipelines.Version
+	pipelineSchedules map[int64]pipelines.Schedule
+	// pipeline id -> webhook settings
+	pipelineWebhooks  map[int64]pipelines.Webhook
+	webhookDeliveries map[webhookDeliveryKey]pipelines.WebhookDelivery
+
+	// project id -> collaborator user id -> collaborator
+	collaborators map[int64]map[int64]projects.Collaborator
+
+	// table name -> last used id (mimics postgres bigserial)
+	sequences map[string]int64
+}
+
+// CreateDB Create new empty instance of memory.DB
+func CreateDB() *DB {
+	return &DB{
+		users:             make(map[int64]users.User),
+		projects:          make(map[int64]projects.Project),
+		devices:           make(map[int64]devices.Device),
+		endpoints:         make(map[int64]endpoints.Endpoint),
+		pipelines:         make(map[int64]pipelines.Pipeline),
+		sessions:          make(map[int64]sessions.Session),
+		invitations:       make(map[int64]projects.Invitation),
+		apiKeys:           make(map[int64]apikeys.Key),
+		deviceKeyEvents:   make(map[int64]devices.KeyEvent),
+		deviceShadows:     make(map[int64]shadows.Shadow),
+		telemetry:         make(map[int64][]telemetry.Datapoint),
+		invocations:       make(map[int64]invocations.Invocation),
+		pipelineRuns:      make(map[int64]pipelines.Run),
+		pipelineVersions:  make(map[int64][]pipelines.Version),
+		pipelineSchedules: make(map[int64]pipelines.Schedule),
+		pipelineWebhooks:  make(map[int64]pipelines.Webhook),
+		webhookDeliveries: make(map[webhookDeliveryKey]pipelines.WebhookDelivery),
+		collaborators:     make(map[int64]map[int64]projects.Collaborator),
+		sequences:         make(map[string]int64),
+	}
+}
+
+// nextID returns a new unique id for the given table
+// (caller must hold the write lock)
+func (db *DB) nextID(table string) int64 {
+	db.sequences[table]++
+	return db.sequences[table]
+}
+
+// deleteProject removes a project with its collaborators, invitations and
+// api keys. Like postgres, a project that still has devices or pipelines
+// is not deleted. (caller must hold the write lock)
+func (db *DB) deleteProject(projectID int64) error {
+	for _, d := range db.devices {
+		if d.ProjectID == projectID {
+			return errReferenced
+		}
+	}
+	for _, p := range db.pipelines {
+		if p.ProjectID == projectID {
+			return errReferenced
+		}
+	}
+
+	for id, inv := range db.invitations {
+		if inv.ProjectID == projectID {
+			delete(db.invitations, id)
+		}
+	}
+	for id, k := range db.apiKeys {
+		if k.ProjectID == projectID {
+			delete(db.apiKeys, id)
+		}
+	}
+	delete(db.collaborators, projectID)
+	delete(db.projects, projectID)
+	return nil
+}
+
+// deleteDevice removes a device with its key events, shadow, telemetry and
+// invocations. Like postgres, a device that still has endpoints is not
+// deleted. (caller must hold the write lock)
+func (db *DB) deleteDevice(deviceID int64) error {
+	for _, ep := range db.endpoints {
+		if ep.DeviceID == deviceID {
+			return errReferenced
+		}
+	}
+
+	for id, e := range db.deviceKeyEvents {
+		if e.DeviceID == deviceID {
+			delete(db.deviceKeyEvents, id)
+		}
+	}
+	delete(db.deviceShadows, deviceID)
+	delete(db.telemetry, deviceID)
+	for id, inv := range db.invocations {
+		if inv.DeviceID == deviceID {
+			delete(db.invocations, id)
+		}
+	}
+	delete(db.devices, deviceID)
+	return nil
+}
+
+// deletePipeline removes a pipeline and everything that belongs to it
+// (caller must hold the write lock)
+func (db *DB) deletePipeline(pipelineID int64) {
+	for id, run := range db.pipelineRuns {
+		if run.PipelineID == pipelineID {
+			delete(db.pipelineRuns, id)
+		}
+	}
+	for id, sch := range db.pipelineSchedules {
+		if sch.PipelineID == pipelineID {
+			delete(db.pipelineSchedules, id)
+		}
+	}
+	for key := range db.webhookDeliveries {
+		if key.pipelineID == pipelineID {
+			delete(db.webhookDeliveries, key)
+		}
+	}
+	delete(db.pipelineWebhooks, pipelineID)
+	delete(db.pipelineVersions, pipelineID)
+	delete(db.pipelines, pipelineID)
+}
@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+)
+
+// DeviceRepository devices.Repository in memory implementation
+type DeviceRepository struct {
+	db *DB
+}
+
+// CreateDeviceRepository Create new instance of memory.DeviceRepository
+func CreateDeviceRepository(db *DB) devices.Repository {
+	return &DeviceRepository{db}
+}
+
+func (dR *DeviceRepository) GetByID(deviceID int64) (*devices.Device, error) {
+	dR.db.mu.RLock()
+	defer dR.db.mu.RUnlock()
+
+	d, ok := dR.db.devices[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &d, nil
+}
+
+func (dR *DeviceRepository) GetByKey(authKey string, now time.Time) (*devices.Device, error) {
+	dR.db.mu.RLock()
+	defer dR.db.mu.RUnlock()
+
+	if authKey == "" {
+		return nil, errInvalidID
+	}
+	for _, d := range dR.db.devices {
+		if !d.KeyRevokedAt.IsZero() {
+			continue
+		}
+		if d.AuthKey == authKey ||
+			(d.PreviousAuthKey == authKey && now.Before(d.PreviousKeyExpiresAt)) {
+			return &d, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (dR *DeviceRepository) Create(d devices.Device) (*devices.Device, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	if _, ok := dR.db.projects[d.ProjectID]; !ok {
+		return nil, errForeignKey
+	}
+	for _, existing := range dR.db.devices {
+		if existing.AuthKey == d.AuthKey {
+			return nil, errDuplicate
+		}
+	}
+
+	d.ID = dR.db.nextID("devices")
+	d.CreatedAt = time.Now()
+	d.Status = devices.StatusOffline
+	dR.db.devices[d.ID] = d
+
+	return &d, nil
+}
+
+func (dR *DeviceRepository) Update(deviceID int64, d devices.Device) (*devices.Device, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	if d.ProjectID != 0 {
+		if _, ok := dR.db.projects[d.ProjectID]; !ok {
+			return nil, errForeignKey
+		}
+		device.ProjectID = d.ProjectID
+	}
+	if d.DisplayName != "" {
+		device.DisplayName = d.DisplayName
+	}
+	if d.Description != "" {
+		device.Description = d.Description
+	}
+	device.UpdatedAt = time.Now()
+	dR.db.devices[deviceID] = device
+
+	return &device, nil
+}
+
+func (dR *DeviceRepository) Delete(deviceID int64) error {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	if _, ok := dR.db.devices[deviceID]; !ok {
+		return errInvalidID
+	}
+	return dR.db.deleteDevice(deviceID)
+}
+
+func (dR *DeviceRepository) GetByProjectID(projectID int64) ([]devices.Device, error) {
+	dR.db.mu.RLock()
+	defer dR.db.mu.RUnlock()
+
+	projectDevices := []devices.Device{}
+	for _, d := range dR.db.devices {
+		if d.ProjectID == projectID {
+			projectDevices = append(projectDevices, d)
+		}
+	}
+	sort.Slice(projectDevices, func(i, j int) bool {
+		return projectDevices[i].ID < projectDevices[j].ID
+	})
+
+	return projectDevices, nil
+}
+
+func (dR *DeviceRepository) RotateKey(deviceID int64, newKey string, previousKeyExpiresAt time.Time) (*devices.Device, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	// A revoked key never becomes the previous key
+	if device.KeyRevokedAt.IsZero() && !previousKeyExpiresAt.IsZero() {
+		device.PreviousAuthKey = device.AuthKey
+		device.PreviousKeyExpiresAt = previousKeyExpiresAt
+	} else {
+		device.PreviousAuthKey = ""
+		device.PreviousKeyExpiresAt = time.Time{}
+	}
+	device.AuthKey = newKey
+	device.KeyRevokedAt = time.Time{}
+	dR.db.devices[deviceID] = device
+
+	return &device, nil
+}
+
+func (dR *DeviceRepository) RevokeKey(deviceID int64, revokedAt time.Time) (*devices.Device, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	device.KeyRevokedAt = revokedAt
+	device.PreviousAuthKey = ""
+	device.PreviousKeyExpiresAt = time.Time{}
+	dR.db.devices[deviceID] = device
+
+	return &device, nil
+}
+
+func (dR *DeviceRepository) CreateKeyEvent(e devices.KeyEvent) (*devices.KeyEvent, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	if _, ok := dR.db.devices[e.DeviceID]; !ok {
+		return nil, errForeignKey
+	}
+
+	e.ID = dR.db.nextID("device_key_events")
+	dR.db.deviceKeyEvents[e.ID] = e
+
+	return &e, nil
+}
+
+func (dR *DeviceRepository) GetKeyEvents(deviceID int64) ([]devices.KeyEvent, error) {
+	dR.db.mu.RLock()
+	defer dR.db.mu.RUnlock()
+
+	events := []devices.KeyEvent{}
+	for _, e := range dR.db.deviceKeyEvents {
+		if e.DeviceID == deviceID {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID > events[j].ID
+	})
+
+	return events, nil
+}
+
+func (dR *DeviceRepository) SetOnline(deviceID int64, conn devices.Connection, now time.Time) (*devices.Device, error) {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	device.Status = devices.StatusOnline
+	device.LastSeenAt = now
+	device.ConnectedAt = now
+	device.Connection = conn
+	dR.db.devices[deviceID] = device
+
+	return &device, nil
+}
+
+func (dR *DeviceRepository) SetOffline(deviceID int64, connectionID string, now time.Time) error {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return errInvalidID
+	}
+
+	// Stale events (of an older connection) are ignored
+	if device.Status == devices.StatusOnline && device.Connection.ID == connectionID {
+		device.Status = devices.StatusOffline
+		device.LastSeenAt = now
+		dR.db.devices[deviceID] = device
+	}
+
+	return nil
+}
+
+func (dR *DeviceRepository) Heartbeat(deviceID int64, connectionID string, now time.Time) error {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	device, ok := dR.db.devices[deviceID]
+	if !ok {
+		return errInvalidID
+	}
+
+	if device.Status == devices.StatusOnline && device.Connection.ID == connectionID {
+		device.LastSeenAt = now
+		dR.db.devices[deviceID] = device
+	}
+
+	return nil
+}
+
+func (dR *DeviceRepository) SetManagerOffline(managerID string, now time.Time) error {
+	dR.db.mu.Lock()
+	defer dR.db.mu.Unlock()
+
+	for id, device := range dR.db.devices {
+		if device.Status == devices.StatusOnline && device.Connection.ManagerID == managerID {
+			device.Status = devices.StatusOffline
+			device.LastSeenAt = now
+			dR.db.devices[id] = device
+		}
+	}
+
+	return nil
+}
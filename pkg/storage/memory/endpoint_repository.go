@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+)
+
+// EndpointRepository endpoints.Repository in memory implementation
+type EndpointRepository struct {
+	db *DB
+}
+
+// CreateEndpointRepository Create new instance of memory.EndpointRepository
+func CreateEndpointRepository(db *DB) endpoints.Repository {
+	return &EndpointRepository{db}
+}
+
+func (epR *EndpointRepository) GetByID(endpointID int64) (*endpoints.Endpoint, error) {
+	epR.db.mu.RLock()
+	defer epR.db.mu.RUnlock()
+
+	ep, ok := epR.db.endpoints[endpointID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &ep, nil
+}
+
+func (epR *EndpointRepository) Create(ep endpoints.Endpoint) (*endpoints.Endpoint, error) {
+	epR.db.mu.Lock()
+	defer epR.db.mu.Unlock()
+
+	if _, ok := epR.db.devices[ep.DeviceID]; !ok {
+		return nil, errForeignKey
+	}
+
+	ep.ID = epR.db.nextID("endpoints")
+	ep.CreatedAt = time.Now()
+	epR.db.endpoints[ep.ID] = ep
+
+	return &ep, nil
+}
+
+func (epR *EndpointRepository) Update(endpointID int64, ep endpoints.Endpoint) (*endpoints.Endpoint, error) {
+	epR.db.mu.Lock()
+	defer epR.db.mu.Unlock()
+
+	endpoint, ok := epR.db.endpoints[endpointID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	if ep.DeviceID != 0 {
+		if _, ok := epR.db.devices[ep.DeviceID]; !ok {
+			return nil, errForeignKey
+		}
+		endpoint.DeviceID = ep.DeviceID
+	}
+	if ep.DisplayName != "" {
+		endpoint.DisplayName = ep.DisplayName
+	}
+	if ep.Description != "" {
+		endpoint.Description = ep.Description
+	}
+	if ep.Pattern != "" {
+		endpoint.Pattern = ep.Pattern
+	}
+	if ep.Timeout != 0 {
+		endpoint.Timeout = ep.Timeout
+	}
+	if ep.Method != "" {
+		endpoint.Method = ep.Method
+	}
+	if ep.RequestSchema != "" {
+		endpoint.RequestSchema = ep.RequestSchema
+	}
+	if ep.ResponseSchema != "" {
+		endpoint.ResponseSchema = ep.ResponseSchema
+	}
+	endpoint.UpdatedAt = time.Now()
+	epR.db.endpoints[endpointID] = endpoint
+
+	return &endpoint, nil
+}
+
+func (epR *EndpointRepository) Delete(endpointID int64) error {
+	epR.db.mu.Lock()
+	defer epR.db.mu.Unlock()
+
+	if _, ok := epR.db.endpoints[endpointID]; !ok {
+		return errInvalidID
+	}
+	delete(epR.db.endpoints, endpointID)
+
+	return nil
+}
+
+func (epR *EndpointRepository) GetbyDeviceID(deviceID int64) ([]endpoints.Endpoint, error) {
+	epR.db.mu.RLock()
+	defer epR.db.mu.RUnlock()
+
+	deviceEndpoints := []endpoints.Endpoint{}
+	for _, ep := range epR.db.endpoints {
+		if ep.DeviceID == deviceID {
+			deviceEndpoints = append(deviceEndpoints, ep)
+		}
+	}
+	sort.Slice(deviceEndpoints, func(i, j int) bool {
+		return deviceEndpoints[i].ID < deviceEndpoints[j].ID
+	})
+
+	return deviceEndpoints, nil
+}
+
+func (epR *EndpointRepository) GetByPattern(deviceID int64, pattern string) (*endpoints.Endpoint, error) {
+	deviceEndpoints, _ := epR.GetbyDeviceID(deviceID)
+	for _, ep := range deviceEndpoints {
+		if ep.Pattern == pattern {
+			return &ep, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
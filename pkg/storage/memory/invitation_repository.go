@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/projects"
+)
+
+// InvitationRepository projects.InvitationRepository in memory implementation
+type InvitationRepository struct {
+	db *DB
+}
+
+// CreateInvitationRepository Create new instance of memory.InvitationRepository
+func CreateInvitationRepository(db *DB) projects.InvitationRepository {
+	return &InvitationRepository{db}
+}
+
+func (iR *InvitationRepository) GetByID(invitationID int64) (*projects.Invitation, error) {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	inv, ok := iR.db.invitations[invitationID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &inv, nil
+}
+
+func (iR *InvitationRepository) GetByTokenHash(tokenHash string) (*projects.Invitation, error) {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	for _, inv := range iR.db.invitations {
+		if inv.TokenHash == tokenHash {
+			return &inv, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (iR *InvitationRepository) GetPending(projectID int64, now time.Time) ([]projects.Invitation, error) {
+	return iR.filter(func(inv projects.Invitation) bool {
+		return inv.ProjectID == projectID && isPending(inv, now)
+	}), nil
+}
+
+func (iR *InvitationRepository) GetPendingByEmail(email string, now time.Time) ([]projects.Invitation, error) {
+	return iR.filter(func(inv projects.Invitation) bool {
+		return strings.EqualFold(inv.Email, email) && isPending(inv, now)
+	}), nil
+}
+
+func (iR *InvitationRepository) Create(inv projects.Invitation) (*projects.Invitation, error) {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	if _, ok := iR.db.projects[inv.ProjectID]; !ok {
+		return nil, errForeignKey
+	}
+	if _, ok := iR.db.users[inv.InvitedBy]; !ok {
+		return nil, errForeignKey
+	}
+
+	inv.ID = iR.db.nextID("invitations")
+	iR.db.invitations[inv.ID] = inv
+
+	return &inv, nil
+}
+
+func (iR *InvitationRepository) SetStatus(invitationID int64, status projects.InvitationStatus, respondedAt time.Time) error {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	inv, ok := iR.db.invitations[invitationID]
+	if !ok || inv.Status != projects.InvitationPending {
+		return errInvalidID
+	}
+	inv.Status = status
+	inv.RespondedAt = respondedAt
+	iR.db.invitations[invitationID] = inv
+
+	return nil
+}
+
+func (iR *InvitationRepository) filter(match func(inv projects.Invitation) bool) []projects.Invitation {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	invitations := []projects.Invitation{}
+	for _, inv := range iR.db.invitations {
+		if match(inv) {
+			invitations = append(invitations, inv)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID < invitations[j].ID })
+
+	return invitations
+}
+
+func isPending(inv projects.Invitation, now time.Time) bool {
+	return inv.Status == projects.InvitationPending && now.Before(inv.ExpiresAt)
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/invocations"
+)
+
+// InvocationRepository invocations.Repository in memory implementation
+type InvocationRepository struct {
+	db *DB
+}
+
+// CreateInvocationRepository Create new instance of memory.InvocationRepository
+func CreateInvocationRepository(db *DB) invocations.Repository {
+	return &InvocationRepository{db}
+}
+
+func (iR *InvocationRepository) Create(inv invocations.Invocation) (*invocations.Invocation, error) {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	if _, ok := iR.db.devices[inv.DeviceID]; !ok {
+		return nil, errForeignKey
+	}
+
+	inv.ID = iR.db.nextID("invocations")
+	iR.db.invocations[inv.ID] = inv
+
+	return &inv, nil
+}
+
+func (iR *InvocationRepository) GetByID(invocationID int64) (*invocations.Invocation, error) {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	inv, ok := iR.db.invocations[invocationID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &inv, nil
+}
+
+func (iR *InvocationRepository) GetByDeviceID(deviceID int64, limit int, offset int) ([]invocations.Invocation, error) {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	deviceInvocations := []invocations.Invocation{}
+	for _, inv := range iR.db.invocations {
+		if inv.DeviceID == deviceID {
+			deviceInvocations = append(deviceInvocations, inv)
+		}
+	}
+	// ids increase with creation time
+	sort.Slice(deviceInvocations, func(i, j int) bool {
+		return deviceInvocations[i].ID > deviceInvocations[j].ID
+	})
+	start, end := pageBounds(len(deviceInvocations), limit, offset)
+
+	return deviceInvocations[start:end], nil
+}
+
+func (iR *InvocationRepository) GetDue(now time.Time, limit int) ([]invocations.Invocation, error) {
+	iR.db.mu.RLock()
+	defer iR.db.mu.RUnlock()
+
+	due := []invocations.Invocation{}
+	for _, inv := range iR.db.invocations {
+		if inv.Status == invocations.StatusQueued && !inv.NextAttemptAt.After(now) {
+			due = append(due, inv)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	return due, nil
+}
+
+func (iR *InvocationRepository) Claim(invocationID int64, now time.Time) (*invocations.Invocation, error) {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	inv, ok := iR.db.invocations[invocationID]
+	if !ok {
+		return nil, errInvalidID
+	}
+	if inv.Status != invocations.StatusQueued {
+		return nil, invocations.ErrNotQueued
+	}
+
+	inv.Status = invocations.StatusRunning
+	inv.Attempts++
+	inv.ClaimedAt = now
+	if inv.StartedAt.IsZero() {
+		inv.StartedAt = now
+	}
+	iR.db.invocations[invocationID] = inv
+
+	return &inv, nil
+}
+
+func (iR *InvocationRepository) Update(inv invocations.Invocation) error {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	stored, ok := iR.db.invocations[inv.ID]
+	if !ok {
+		return errInvalidID
+	}
+	if stored.Status != invocations.StatusRunning || stored.Attempts != inv.Attempts {
+		return invocations.ErrAttemptExpired
+	}
+
+	stored.Status = inv.Status
+	stored.Response = inv.Response
+	stored.Error = inv.Error
+	stored.FinishedAt = inv.FinishedAt
+	stored.NextAttemptAt = inv.NextAttemptAt
+	iR.db.invocations[inv.ID] = stored
+
+	return nil
+}
+
+func (iR *InvocationRepository) ReleaseStale(claimedBefore time.Time, now time.Time, maxAttempts int, reason string) ([]invocations.Invocation, error) {
+	iR.db.mu.Lock()
+	defer iR.db.mu.Unlock()
+
+	released := []invocations.Invocation{}
+	for id, inv := range iR.db.invocations {
+		if inv.Status != invocations.StatusRunning || !inv.ClaimedAt.Before(claimedBefore) {
+			continue
+		}
+
+		inv.Error = reason
+		if inv.Attempts < maxAttempts {
+			inv.Status = invocations.StatusQueued
+			inv.NextAttemptAt = now
+		} else {
+			inv.Status = invocations.StatusFailed
+			inv.FinishedAt = now
+		}
+		iR.db.invocations[id] = inv
+		released = append(released, inv)
+	}
+
+	return released, nil
+}
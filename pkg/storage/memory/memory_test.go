@@ -0,0 +1,255 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory/memtest"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const testPwd = "Password123!"
+
+// fixture services backed by a single memory.DB
+type fixture struct {
+	users     users.Service
+	projects  projects.Service
+	devices   devices.Service
+	endpoints endpoints.Service
+	pipelines pipelines.Repository
+}
+
+func newFixture() *fixture {
+	db := memory.CreateDB()
+	bus := events.CreateBus()
+	deviceService := devices.CreateDeviceService(memory.CreateDeviceRepository(db), nil, bus)
+
+	return &fixture{
+		// Cheap hashing, the hasher is not under test
+		users:     users.CreateServiceWithHasher(memory.CreateUserRepository(db), &users.BcryptHasher{Cost: 4}),
+		projects:  projects.CreateService(memory.CreateProjectRepository(db), memory.CreateInvitationRepository(db)),
+		devices:   deviceService,
+		endpoints: endpoints.CreateEndpointService(memory.CreateEndpointRepository(db), deviceService, bus),
+		pipelines: memory.CreatePipelineRepository(db),
+	}
+}
+
+func (f *fixture) createUser(t *testing.T, name string) *users.User {
+	t.Helper()
+	u, err := f.users.CreateWithPwd(users.User{
+		Name:        name,
+		DisplayName: name,
+		Email:       name + "@example.com",
+	}, testPwd)
+	if err != nil {
+		t.Fatalf("creating user %s: %v", name, err)
+	}
+	return u
+}
+
+func (f *fixture) createProject(t *testing.T, owner *users.User) *projects.Project {
+	t.Helper()
+	p, err := f.projects.Create(projects.Project{DisplayName: "project", CreatedBy: owner.ID})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	return p
+}
+
+func projectIDs(ps []projects.Project) []int64 {
+	ids := []int64{}
+	for _, p := range ps {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCreateUserDuplicates(t *testing.T) {
+	f := newFixture()
+	f.createUser(t, "alice")
+
+	tests := []struct {
+		name string
+		user users.User
+		code utils.ServiceErrCode
+	}{
+		{
+			name: "duplicate email",
+			user: users.User{Name: "alice2", DisplayName: "A", Email: "alice@example.com"},
+			code: users.DuplicateEmailCode,
+		},
+		{
+			name: "duplicate name",
+			user: users.User{Name: "alice", DisplayName: "A", Email: "other@example.com"},
+			code: users.DuplicateNameCode,
+		},
+		{
+			name: "unique",
+			user: users.User{Name: "bob", DisplayName: "B", Email: "bob@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.users.CreateWithPwd(tt.user, testPwd)
+			if code := memtest.ErrCode(err); code != tt.code {
+				t.Errorf("got error code %q, want %q (error: %v)", code, tt.code, err)
+			}
+		})
+	}
+}
+
+func TestGetAllowed(t *testing.T) {
+	f := newFixture()
+	alice := f.createUser(t, "alice")
+	bob := f.createUser(t, "bob")
+	carol := f.createUser(t, "carol")
+
+	aliceProject := f.createProject(t, alice)
+	bobProject := f.createProject(t, bob)
+
+	if err := f.projects.AddCollaborator(bob.ID, aliceProject.ID, projects.RoleDeveloper); err != nil {
+		t.Fatalf("adding collaborator: %v", err)
+	}
+	err := f.projects.AddCollaborator(bob.ID, aliceProject.ID, projects.RoleViewer)
+	if code := memtest.ErrCode(err); code != projects.DuplicateCollaboratorCode {
+		t.Errorf("adding collaborator twice: got error code %q, want %q", code, projects.DuplicateCollaboratorCode)
+	}
+
+	tests := []struct {
+		user *users.User
+		want []int64
+	}{
+		{alice, []int64{aliceProject.ID}},
+		{bob, []int64{aliceProject.ID, bobProject.ID}},
+		{carol, []int64{}},
+	}
+	for _, tt := range tests {
+		allowed, err := f.projects.GetAllowed(tt.user.ID)
+		if err != nil {
+			t.Fatalf("GetAllowed(%s): %v", tt.user.Name, err)
+		}
+		if got := projectIDs(allowed); !equalIDs(got, tt.want) {
+			t.Errorf("GetAllowed(%s) = %v, want %v", tt.user.Name, got, tt.want)
+		}
+	}
+}
+
+// Deletes follow the postgres foreign keys: a project is only deleted once
+// its devices and pipelines are, and takes its collaborators with it
+func TestDeleteReferencedProject(t *testing.T) {
+	f := newFixture()
+	alice := f.createUser(t, "alice")
+	bob := f.createUser(t, "bob")
+	project := f.createProject(t, alice)
+	if err := f.projects.AddCollaborator(bob.ID, project.ID, projects.RoleViewer); err != nil {
+		t.Fatalf("adding collaborator: %v", err)
+	}
+
+	device, err := f.devices.Create(devices.Device{ProjectID: project.ID, DisplayName: "device"})
+	if err != nil {
+		t.Fatalf("creating device: %v", err)
+	}
+	endpoint, err := f.endpoints.Create(endpoints.Endpoint{DeviceID: device.ID, DisplayName: "led", Pattern: "led"})
+	if err != nil {
+		t.Fatalf("creating endpoint: %v", err)
+	}
+	pipeline, err := f.pipelines.Create(pipelines.Pipeline{ProjectID: project.ID, DisplayName: "pipeline", Data: `{"nodes":[]}`, CreatedBy: alice.ID})
+	if err != nil {
+		t.Fatalf("creating pipeline: %v", err)
+	}
+
+	if err := f.projects.Delete(project.ID); err == nil {
+		t.Fatal("deleted a project that still has devices and pipelines")
+	}
+	if err := f.devices.Delete(device.ID); err == nil {
+		t.Fatal("deleted a device that still has endpoints")
+	}
+	if _, err := f.devices.GetByID(device.ID); err != nil {
+		t.Errorf("device was deleted by a rejected delete: %v", err)
+	}
+
+	if err := f.endpoints.Delete(endpoint.ID); err != nil {
+		t.Fatalf("deleting endpoint: %v", err)
+	}
+	if err := f.devices.Delete(device.ID); err != nil {
+		t.Fatalf("deleting device: %v", err)
+	}
+	if err := f.projects.Delete(project.ID); err == nil {
+		t.Fatal("deleted a project that still has pipelines")
+	}
+	if err := f.pipelines.Delete(pipeline.ID); err != nil {
+		t.Fatalf("deleting pipeline: %v", err)
+	}
+	if err := f.projects.Delete(project.ID); err != nil {
+		t.Fatalf("deleting project: %v", err)
+	}
+
+	if _, err := f.projects.GetByID(project.ID); err == nil {
+		t.Error("project still exists")
+	}
+	allowed, err := f.projects.GetAllowed(bob.ID)
+	if err != nil || len(allowed) != 0 {
+		t.Errorf("GetAllowed of a former collaborator = %v (error: %v), want none", projectIDs(allowed), err)
+	}
+	if err := f.projects.Delete(project.ID); memtest.ErrCode(err) != projects.ProjectNotFoundCode {
+		t.Errorf("deleting a deleted project: got error %v, want %s", err, projects.ProjectNotFoundCode)
+	}
+}
+
+// A user is only deleted once the projects they created and
+// the projects they collaborate on are deleted
+func TestDeleteReferencedUser(t *testing.T) {
+	f := newFixture()
+	alice := f.createUser(t, "alice")
+	bob := f.createUser(t, "bob")
+	aliceProject := f.createProject(t, alice)
+	bobProject := f.createProject(t, bob)
+	if err := f.projects.AddCollaborator(alice.ID, bobProject.ID, projects.RoleAdmin); err != nil {
+		t.Fatalf("adding collaborator: %v", err)
+	}
+
+	if err := f.users.Delete(alice.ID); err == nil {
+		t.Fatal("deleted a user that still has projects")
+	}
+	if _, err := f.projects.GetByID(aliceProject.ID); err != nil {
+		t.Errorf("project was deleted by a rejected delete: %v", err)
+	}
+
+	if err := f.projects.Delete(aliceProject.ID); err != nil {
+		t.Fatalf("deleting project: %v", err)
+	}
+	if err := f.users.Delete(alice.ID); err == nil {
+		t.Fatal("deleted a user that still collaborates on a project")
+	}
+
+	if err := f.projects.Delete(bobProject.ID); err != nil {
+		t.Fatalf("deleting project: %v", err)
+	}
+	if err := f.users.Delete(alice.ID); err != nil {
+		t.Fatalf("deleting user: %v", err)
+	}
+	if _, err := f.users.GetByID(alice.ID); err == nil {
+		t.Error("user still exists")
+	}
+
+	// The email and name are free again
+	f.createUser(t, "alice")
+}
@@ -0,0 +1,50 @@
+// Package memtest helpers for tests of services backed by the memory storage
+package memtest
+
+import (
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/users"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// roles every project role (highest first)
+var roles = []projects.Role{projects.RoleOwner, projects.RoleAdmin, projects.RoleDeveloper, projects.RoleViewer}
+
+// ErrCode returns the service error code of err (empty for nil errors)
+func ErrCode(err error) utils.ServiceErrCode {
+	if err == nil {
+		return ""
+	}
+	return utils.ToServiceErr(err).Code
+}
+
+// CreateProject creates a project with a collaborator per role (the owner
+// created it) and returns the project ID and the user ID of each role
+func CreateProject(t *testing.T, db *memory.DB, service projects.Service) (int64, map[projects.Role]int64) {
+	t.Helper()
+	userRepo := memory.CreateUserRepository(db)
+
+	userIDs := map[projects.Role]int64{}
+	for _, role := range roles {
+		u, err := userRepo.Create(users.User{Name: string(role), Email: string(role) + "@example.com"})
+		if err != nil {
+			t.Fatalf("creating user: %v", err)
+		}
+		userIDs[role] = u.ID
+	}
+
+	p, err := service.Create(projects.Project{DisplayName: "project", CreatedBy: userIDs[projects.RoleOwner]})
+	if err != nil {
+		t.Fatalf("creating project: %v", err)
+	}
+	for _, role := range roles[1:] {
+		if err := service.AddCollaborator(userIDs[role], p.ID, role); err != nil {
+			t.Fatalf("adding collaborator: %v", err)
+		}
+	}
+
+	return p.ID, userIDs
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineRepository pipelines.Repository in memory implementation
+type PipelineRepository struct {
+	db *DB
+}
+
+// CreatePipelineRepository Create new instance of memory.PipelineRepository
+func CreatePipelineRepository(db *DB) pipelines.Repository {
+	return &PipelineRepository{db}
+}
+
+func (pR *PipelineRepository) GetByID(pipelineID int64) (*pipelines.Pipeline, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	p, ok := pR.db.pipelines[pipelineID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &p, nil
+}
+
+func (pR *PipelineRepository) GetByProjectID(projectID int64) ([]pipelines.Pipeline, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	projectPipelines := []pipelines.Pipeline{}
+	for _, p := range pR.db.pipelines {
+		if p.ProjectID == projectID {
+			projectPipelines = append(projectPipelines, p)
+		}
+	}
+	sort.Slice(projectPipelines, func(i, j int) bool {
+		return projectPipelines[i].ID < projectPipelines[j].ID
+	})
+
+	return projectPipelines, nil
+}
+
+func (pR *PipelineRepository) GetAll() ([]pipelines.Pipeline, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	all := make([]pipelines.Pipeline, 0, len(pR.db.pipelines))
+	for _, p := range pR.db.pipelines {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+
+	return all, nil
+}
+
+func (pR *PipelineRepository) Create(p pipelines.Pipeline) (*pipelines.Pipeline, error) {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.projects[p.ProjectID]; !ok {
+		return nil, errForeignKey
+	}
+	if _, ok := pR.db.users[p.CreatedBy]; !ok {
+		return nil, errForeignKey
+	}
+
+	p.ID = pR.db.nextID("pipelines")
+	p.CreatedAt = time.Now()
+	p.Version = 1
+	p.UpdatedBy = 0
+	pR.db.pipelines[p.ID] = p
+	pR.db.addPipelineVersion(p, p.CreatedBy, p.CreatedAt)
+
+	return &p, nil
+}
+
+func (pR *PipelineRepository) Update(pipelineID int64, p pipelines.Pipeline) (*pipelines.Pipeline, error) {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	pipeline, ok := pR.db.pipelines[pipelineID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	if p.DisplayName != "" {
+		pipeline.DisplayName = p.DisplayName
+	}
+	if p.Description != "" {
+		pipeline.Description = p.Description
+	}
+	if p.Data != "" && p.Data != "{}" {
+		pipeline.Data = p.Data
+	}
+	pipeline.UpdatedAt = time.Now()
+	pipeline.Version++
+	pR.db.pipelines[pipelineID] = pipeline
+	pR.db.addPipelineVersion(pipeline, p.UpdatedBy, pipeline.UpdatedAt)
+
+	return &pipeline, nil
+}
+
+func (pR *PipelineRepository) Replace(pipelineID int64, p pipelines.Pipeline) (*pipelines.Pipeline, error) {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	pipeline, ok := pR.db.pipelines[pipelineID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	pipeline.DisplayName = p.DisplayName
+	pipeline.Description = p.Description
+	pipeline.Data = p.Data
+	pipeline.UpdatedAt = time.Now()
+	pipeline.Version++
+	pR.db.pipelines[pipelineID] = pipeline
+	pR.db.addPipelineVersion(pipeline, p.UpdatedBy, pipeline.UpdatedAt)
+
+	return &pipeline, nil
+}
+
+func (pR *PipelineRepository) Delete(pipelineID int64) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.pipelines[pipelineID]; !ok {
+		return errInvalidID
+	}
+	pR.db.deletePipeline(pipelineID)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineRunRepository pipelines.RunRepository in memory implementation
+type PipelineRunRepository struct {
+	db *DB
+}
+
+// CreatePipelineRunRepository Create new instance of memory.PipelineRunRepository
+func CreatePipelineRunRepository(db *DB) pipelines.RunRepository {
+	return &PipelineRunRepository{db}
+}
+
+func (rR *PipelineRunRepository) Create(run pipelines.Run) (*pipelines.Run, error) {
+	rR.db.mu.Lock()
+	defer rR.db.mu.Unlock()
+
+	if _, ok := rR.db.pipelines[run.PipelineID]; !ok {
+		return nil, errForeignKey
+	}
+
+	run.ID = rR.db.nextID("pipeline_runs")
+	run.Nodes = copyNodeResults(run.Nodes)
+	rR.db.pipelineRuns[run.ID] = run
+
+	return &run, nil
+}
+
+func (rR *PipelineRunRepository) GetByID(runID int64) (*pipelines.Run, error) {
+	rR.db.mu.RLock()
+	defer rR.db.mu.RUnlock()
+
+	run, ok := rR.db.pipelineRuns[runID]
+	if !ok {
+		return nil, errInvalidID
+	}
+	run.Nodes = copyNodeResults(run.Nodes)
+
+	return &run, nil
+}
+
+func (rR *PipelineRunRepository) GetByPipelineID(pipelineID int64, limit int, offset int) ([]pipelines.Run, error) {
+	rR.db.mu.RLock()
+	defer rR.db.mu.RUnlock()
+
+	runs := []pipelines.Run{}
+	for _, run := range rR.db.pipelineRuns {
+		if run.PipelineID == pipelineID {
+			run.Nodes = copyNodeResults(run.Nodes)
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].ID > runs[j].ID
+		}
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	start, end := pageBounds(len(runs), limit, offset)
+	return runs[start:end], nil
+}
+
+func (rR *PipelineRunRepository) Update(run pipelines.Run) error {
+	rR.db.mu.Lock()
+	defer rR.db.mu.Unlock()
+
+	current, ok := rR.db.pipelineRuns[run.ID]
+	if !ok {
+		return errInvalidID
+	}
+
+	current.Status = run.Status
+	current.Output = run.Output
+	current.Error = run.Error
+	current.Nodes = copyNodeResults(run.Nodes)
+	current.FinishedAt = run.FinishedAt
+	rR.db.pipelineRuns[run.ID] = current
+
+	return nil
+}
+
+// copyNodeResults keeps stored runs from sharing their
+// node results with callers (which keep updating them)
+func copyNodeResults(nodes []pipelines.NodeResult) []pipelines.NodeResult {
+	return append([]pipelines.NodeResult{}, nodes...)
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineScheduleRepository pipelines.ScheduleRepository in memory implementation
+type PipelineScheduleRepository struct {
+	db *DB
+}
+
+// CreatePipelineScheduleRepository Create new instance of memory.PipelineScheduleRepository
+func CreatePipelineScheduleRepository(db *DB) pipelines.ScheduleRepository {
+	return &PipelineScheduleRepository{db}
+}
+
+func (sR *PipelineScheduleRepository) Create(sch pipelines.Schedule) (*pipelines.Schedule, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	if _, ok := sR.db.pipelines[sch.PipelineID]; !ok {
+		return nil, errForeignKey
+	}
+
+	sch.ID = sR.db.nextID("pipeline_schedules")
+	sch.CreatedAt = time.Now()
+	sch.UpdatedAt = time.Time{}
+	sR.db.pipelineSchedules[sch.ID] = sch
+
+	return &sch, nil
+}
+
+func (sR *PipelineScheduleRepository) GetByID(scheduleID int64) (*pipelines.Schedule, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	sch, ok := sR.db.pipelineSchedules[scheduleID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &sch, nil
+}
+
+func (sR *PipelineScheduleRepository) GetByPipelineID(pipelineID int64) ([]pipelines.Schedule, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	schedules := []pipelines.Schedule{}
+	for _, sch := range sR.db.pipelineSchedules {
+		if sch.PipelineID == pipelineID {
+			schedules = append(schedules, sch)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules, nil
+}
+
+func (sR *PipelineScheduleRepository) Update(sch pipelines.Schedule) (*pipelines.Schedule, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	current, ok := sR.db.pipelineSchedules[sch.ID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	current.Cron = sch.Cron
+	current.Timezone = sch.Timezone
+	current.MissedRuns = sch.MissedRuns
+	current.Payload = sch.Payload
+	current.Enabled = sch.Enabled
+	current.NextRunAt = sch.NextRunAt
+	current.UpdatedAt = time.Now()
+	sR.db.pipelineSchedules[sch.ID] = current
+
+	return &current, nil
+}
+
+func (sR *PipelineScheduleRepository) Delete(scheduleID int64) error {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	if _, ok := sR.db.pipelineSchedules[scheduleID]; !ok {
+		return errInvalidID
+	}
+	delete(sR.db.pipelineSchedules, scheduleID)
+
+	return nil
+}
+
+func (sR *PipelineScheduleRepository) GetDue(now time.Time, limit int) ([]pipelines.Schedule, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	due := []pipelines.Schedule{}
+	for _, sch := range sR.db.pipelineSchedules {
+		if sch.Enabled && !sch.NextRunAt.After(now) {
+			due = append(due, sch)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	return due, nil
+}
+
+func (sR *PipelineScheduleRepository) Advance(scheduleID int64, dueAt time.Time, lastRunAt time.Time, nextRunAt time.Time) error {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	sch, ok := sR.db.pipelineSchedules[scheduleID]
+	if !ok || !sch.NextRunAt.Equal(dueAt) {
+		return pipelines.ErrScheduleMoved
+	}
+
+	sch.LastRunAt = lastRunAt
+	sch.NextRunAt = nextRunAt
+	sR.db.pipelineSchedules[scheduleID] = sch
+
+	return nil
+}
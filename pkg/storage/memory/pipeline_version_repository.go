@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineVersionRepository pipelines.VersionRepository in memory implementation
+type PipelineVersionRepository struct {
+	db *DB
+}
+
+// CreatePipelineVersionRepository Create new instance of memory.PipelineVersionRepository
+func CreatePipelineVersionRepository(db *DB) pipelines.VersionRepository {
+	return &PipelineVersionRepository{db}
+}
+
+func (vR *PipelineVersionRepository) GetByPipelineID(pipelineID int64, limit int, offset int) ([]pipelines.Version, error) {
+	vR.db.mu.RLock()
+	defer vR.db.mu.RUnlock()
+
+	versions := vR.db.pipelineVersions[pipelineID]
+	page := []pipelines.Version{}
+	for i := len(versions) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, versions[i])
+	}
+
+	return page, nil
+}
+
+func (vR *PipelineVersionRepository) GetByVersion(pipelineID int64, version int) (*pipelines.Version, error) {
+	vR.db.mu.RLock()
+	defer vR.db.mu.RUnlock()
+
+	versions := vR.db.pipelineVersions[pipelineID]
+	if version < 1 || version > len(versions) {
+		return nil, errInvalidID
+	}
+	v := versions[version-1]
+
+	return &v, nil
+}
+
+// addPipelineVersion records the current state of the pipeline as its
+// latest version (caller must hold the write lock)
+func (db *DB) addPipelineVersion(p pipelines.Pipeline, createdBy int64, createdAt time.Time) {
+	db.pipelineVersions[p.ID] = append(db.pipelineVersions[p.ID], pipelines.Version{
+		PipelineID:  p.ID,
+		Version:     p.Version,
+		DisplayName: p.DisplayName,
+		Description: p.Description,
+		Data:        p.Data,
+		CreatedBy:   createdBy,
+		CreatedAt:   createdAt,
+	})
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// webhookDeliveryKey deliveries are unique per pipeline and idempotency key
+type webhookDeliveryKey struct {
+	pipelineID     int64
+	idempotencyKey string
+}
+
+// PipelineWebhookRepository pipelines.WebhookRepository in memory implementation
+type PipelineWebhookRepository struct {
+	db *DB
+}
+
+// CreatePipelineWebhookRepository Create new instance of memory.PipelineWebhookRepository
+func CreatePipelineWebhookRepository(db *DB) pipelines.WebhookRepository {
+	return &PipelineWebhookRepository{db}
+}
+
+func (wR *PipelineWebhookRepository) Get(pipelineID int64) (*pipelines.Webhook, error) {
+	wR.db.mu.RLock()
+	defer wR.db.mu.RUnlock()
+
+	wh, ok := wR.db.pipelineWebhooks[pipelineID]
+	if !ok {
+		return nil, errInvalidID
+	}
+	wh.AllowedIPs = append([]string{}, wh.AllowedIPs...)
+
+	return &wh, nil
+}
+
+func (wR *PipelineWebhookRepository) Save(wh pipelines.Webhook) (*pipelines.Webhook, error) {
+	wR.db.mu.Lock()
+	defer wR.db.mu.Unlock()
+
+	if _, ok := wR.db.pipelines[wh.PipelineID]; !ok {
+		return nil, errForeignKey
+	}
+
+	if current, ok := wR.db.pipelineWebhooks[wh.PipelineID]; ok {
+		wh.CreatedAt = current.CreatedAt
+		wh.UpdatedAt = time.Now()
+	} else {
+		wh.CreatedAt = time.Now()
+		wh.UpdatedAt = time.Time{}
+	}
+	wh.AllowedIPs = append([]string{}, wh.AllowedIPs...)
+	wR.db.pipelineWebhooks[wh.PipelineID] = wh
+
+	return &wh, nil
+}
+
+func (wR *PipelineWebhookRepository) ClaimDelivery(d pipelines.WebhookDelivery, expiredBefore time.Time) (*pipelines.WebhookDelivery, bool, error) {
+	wR.db.mu.Lock()
+	defer wR.db.mu.Unlock()
+
+	if _, ok := wR.db.pipelines[d.PipelineID]; !ok {
+		return nil, false, errForeignKey
+	}
+
+	// Forget the expired deliveries of the pipeline
+	for key, current := range wR.db.webhookDeliveries {
+		if key.pipelineID == d.PipelineID && current.CreatedAt.Before(expiredBefore) {
+			delete(wR.db.webhookDeliveries, key)
+		}
+	}
+
+	key := webhookDeliveryKey{d.PipelineID, d.IdempotencyKey}
+	if current, ok := wR.db.webhookDeliveries[key]; ok {
+		return &current, false, nil
+	}
+
+	d.RunID = 0
+	wR.db.webhookDeliveries[key] = d
+
+	return &d, true, nil
+}
+
+func (wR *PipelineWebhookRepository) CompleteDelivery(pipelineID int64, idempotencyKey string, runID int64) error {
+	wR.db.mu.Lock()
+	defer wR.db.mu.Unlock()
+
+	key := webhookDeliveryKey{pipelineID, idempotencyKey}
+	d, ok := wR.db.webhookDeliveries[key]
+	if !ok {
+		return errInvalidID
+	}
+	d.RunID = runID
+	wR.db.webhookDeliveries[key] = d
+
+	return nil
+}
+
+func (wR *PipelineWebhookRepository) ReleaseDelivery(pipelineID int64, idempotencyKey string) error {
+	wR.db.mu.Lock()
+	defer wR.db.mu.Unlock()
+
+	key := webhookDeliveryKey{pipelineID, idempotencyKey}
+	if _, ok := wR.db.webhookDeliveries[key]; !ok {
+		return errInvalidID
+	}
+	delete(wR.db.webhookDeliveries, key)
+
+	return nil
+}
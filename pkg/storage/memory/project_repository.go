@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/projects"
+)
+
+// ProjectRepository projects.Repository in memory implementation
+type ProjectRepository struct {
+	db *DB
+}
+
+// CreateProjectRepository Create new instance of memory.ProjectRepository
+func CreateProjectRepository(db *DB) projects.Repository {
+	return &ProjectRepository{db}
+}
+
+func (pR *ProjectRepository) GetByID(projectID int64) (*projects.Project, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	p, ok := pR.db.projects[projectID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &p, nil
+}
+
+func (pR *ProjectRepository) GetAllowed(userID int64) ([]projects.Project, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	allowed := []projects.Project{}
+	for projectID, collaborators := range pR.db.collaborators {
+		if _, ok := collaborators[userID]; ok {
+			allowed = append(allowed, pR.db.projects[projectID])
+		}
+	}
+	sort.Slice(allowed, func(i, j int) bool { return allowed[i].ID < allowed[j].ID })
+
+	return allowed, nil
+}
+
+func (pR *ProjectRepository) Create(p projects.Project) (*projects.Project, error) {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.users[p.CreatedBy]; !ok {
+		return nil, errForeignKey
+	}
+
+	p.ID = pR.db.nextID("projects")
+	p.CreatedAt = time.Now()
+	pR.db.projects[p.ID] = p
+	pR.db.collaborators[p.ID] = map[int64]projects.Collaborator{
+		p.CreatedBy: {ProjectID: p.ID, UserID: p.CreatedBy, Role: projects.RoleOwner, CreatedAt: p.CreatedAt},
+	}
+
+	return &p, nil
+}
+
+func (pR *ProjectRepository) Update(projectID int64, p projects.Project) (*projects.Project, error) {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	project, ok := pR.db.projects[projectID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	if p.DisplayName != "" {
+		project.DisplayName = p.DisplayName
+	}
+	if p.Description != "" {
+		project.Description = p.Description
+	}
+	project.UpdatedAt = time.Now()
+	pR.db.projects[projectID] = project
+
+	return &project, nil
+}
+
+func (pR *ProjectRepository) Delete(projectID int64) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.projects[projectID]; !ok {
+		return errInvalidID
+	}
+	return pR.db.deleteProject(projectID)
+}
+
+func (pR *ProjectRepository) AddCollaborator(userID int64, projectID int64, role projects.Role) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.users[userID]; !ok {
+		return errForeignKey
+	}
+	collaborators, ok := pR.db.collaborators[projectID]
+	if !ok {
+		return errForeignKey
+	}
+	if _, ok := collaborators[userID]; ok {
+		return errDuplicate
+	}
+	collaborators[userID] = projects.Collaborator{
+		ProjectID: projectID,
+		UserID:    userID,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (pR *ProjectRepository) GetCollaborators(projectID int64) ([]projects.Collaborator, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	collaborators := []projects.Collaborator{}
+	for userID, c := range pR.db.collaborators[projectID] {
+		u := pR.db.users[userID]
+		c.Name = u.Name
+		c.DisplayName = u.DisplayName
+		c.Email = u.Email
+		collaborators = append(collaborators, c)
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		return collaborators[i].CreatedAt.Before(collaborators[j].CreatedAt)
+	})
+
+	return collaborators, nil
+}
+
+func (pR *ProjectRepository) UpdateCollaboratorRole(userID int64, projectID int64, role projects.Role) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	c, ok := pR.db.collaborators[projectID][userID]
+	if !ok {
+		return errInvalidID
+	}
+	c.Role = role
+	pR.db.collaborators[projectID][userID] = c
+
+	return nil
+}
+
+func (pR *ProjectRepository) RemoveCollaborator(userID int64, projectID int64) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	if _, ok := pR.db.collaborators[projectID][userID]; !ok {
+		return errInvalidID
+	}
+	delete(pR.db.collaborators[projectID], userID)
+
+	return nil
+}
+
+func (pR *ProjectRepository) TransferOwnership(projectID int64, fromUserID int64, toUserID int64) error {
+	pR.db.mu.Lock()
+	defer pR.db.mu.Unlock()
+
+	from, ok := pR.db.collaborators[projectID][fromUserID]
+	if !ok {
+		return errInvalidID
+	}
+	to, ok := pR.db.collaborators[projectID][toUserID]
+	if !ok {
+		return errInvalidID
+	}
+
+	from.Role = projects.RoleAdmin
+	to.Role = projects.RoleOwner
+	pR.db.collaborators[projectID][fromUserID] = from
+	pR.db.collaborators[projectID][toUserID] = to
+
+	return nil
+}
+
+func (pR *ProjectRepository) GetRole(userID int64, projectID int64) (projects.Role, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	c, ok := pR.db.collaborators[projectID][userID]
+	if !ok {
+		return "", errInvalidID
+	}
+
+	return c.Role, nil
+}
+
+func (pR *ProjectRepository) GetResourceProjectID(resource projects.Resource) (int64, error) {
+	pR.db.mu.RLock()
+	defer pR.db.mu.RUnlock()
+
+	switch resource.Type {
+	case projects.ProjectResource:
+		if _, ok := pR.db.projects[resource.ID]; ok {
+			return resource.ID, nil
+		}
+	case projects.DeviceResource:
+		if d, ok := pR.db.devices[resource.ID]; ok {
+			return d.ProjectID, nil
+		}
+	case projects.EndpointResource:
+		if ep, ok := pR.db.endpoints[resource.ID]; ok {
+			return pR.db.devices[ep.DeviceID].ProjectID, nil
+		}
+	case projects.PipelineResource:
+		if p, ok := pR.db.pipelines[resource.ID]; ok {
+			return p.ProjectID, nil
+		}
+	case projects.InvocationResource:
+		if inv, ok := pR.db.invocations[resource.ID]; ok {
+			return pR.db.devices[inv.DeviceID].ProjectID, nil
+		}
+	case projects.RunResource:
+		if run, ok := pR.db.pipelineRuns[resource.ID]; ok {
+			return pR.db.pipelines[run.PipelineID].ProjectID, nil
+		}
+	case projects.ScheduleResource:
+		if sch, ok := pR.db.pipelineSchedules[resource.ID]; ok {
+			return pR.db.pipelines[sch.PipelineID].ProjectID, nil
+		}
+	default:
+		return 0, fmt.Errorf("unknown resource type (%s)", resource.Type)
+	}
+
+	return 0, errInvalidID
+}
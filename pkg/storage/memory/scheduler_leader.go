@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// SchedulerLeader pipelines.Leader in memory implementation
+// (in memory storage is not shared between replicas, the only one leads)
+type SchedulerLeader struct{}
+
+// CreateSchedulerLeader Create new instance of memory.SchedulerLeader
+func CreateSchedulerLeader() pipelines.Leader {
+	return &SchedulerLeader{}
+}
+
+func (l *SchedulerLeader) IsLeader(ctx context.Context) (bool, error) {
+	return true, nil
+}
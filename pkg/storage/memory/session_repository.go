@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+)
+
+// SessionRepository sessions.Repository in memory implementation
+type SessionRepository struct {
+	db *DB
+}
+
+// CreateSessionRepository Create new instance of memory.SessionRepository
+func CreateSessionRepository(db *DB) sessions.Repository {
+	return &SessionRepository{db}
+}
+
+func (sR *SessionRepository) GetByID(sessionID int64) (*sessions.Session, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	s, ok := sR.db.sessions[sessionID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &s, nil
+}
+
+func (sR *SessionRepository) GetByTokenHash(tokenHash string) (*sessions.Session, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	for _, s := range sR.db.sessions {
+		if s.TokenHash == tokenHash || s.PreviousTokenHash == tokenHash {
+			return &s, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (sR *SessionRepository) GetActiveByUserID(userID int64, now time.Time) ([]sessions.Session, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	userSessions := []sessions.Session{}
+	for _, s := range sR.db.sessions {
+		if s.UserID == userID && s.IsActive(now) {
+			userSessions = append(userSessions, s)
+		}
+	}
+	sort.Slice(userSessions, func(i, j int) bool {
+		return userSessions[i].LastUsedAt.After(userSessions[j].LastUsedAt)
+	})
+
+	return userSessions, nil
+}
+
+func (sR *SessionRepository) Create(s sessions.Session) (*sessions.Session, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	if _, ok := sR.db.users[s.UserID]; !ok {
+		return nil, errForeignKey
+	}
+	for _, existing := range sR.db.sessions {
+		if existing.TokenHash == s.TokenHash {
+			return nil, errDuplicate
+		}
+	}
+
+	s.ID = sR.db.nextID("sessions")
+	sR.db.sessions[s.ID] = s
+
+	return &s, nil
+}
+
+func (sR *SessionRepository) Update(sessionID int64, s sessions.Session) (*sessions.Session, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	sess, ok := sR.db.sessions[sessionID]
+	if !ok || !sess.RevokedAt.IsZero() {
+		return nil, errInvalidID
+	}
+
+	if !s.LastUsedAt.IsZero() {
+		sess.LastUsedAt = s.LastUsedAt
+	}
+	if !s.ExpiresAt.IsZero() {
+		sess.ExpiresAt = s.ExpiresAt
+	}
+	sR.db.sessions[sessionID] = sess
+
+	return &sess, nil
+}
+
+func (sR *SessionRepository) Rotate(sessionID int64, oldTokenHash string, s sessions.Session) (*sessions.Session, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	sess, ok := sR.db.sessions[sessionID]
+	if !ok || !sess.RevokedAt.IsZero() {
+		return nil, errInvalidID
+	}
+	if sess.TokenHash != oldTokenHash {
+		return nil, sessions.ErrTokenRotated
+	}
+
+	sess.TokenHash = s.TokenHash
+	sess.PreviousTokenHash = oldTokenHash
+	sess.PreviousTokenExpiresAt = s.PreviousTokenExpiresAt
+	sess.LastUsedAt = s.LastUsedAt
+	sess.RotatedAt = s.RotatedAt
+	sess.ExpiresAt = s.ExpiresAt
+	sR.db.sessions[sessionID] = sess
+
+	return &sess, nil
+}
+
+func (sR *SessionRepository) Revoke(sessionID int64, revokedAt time.Time) error {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	sess, ok := sR.db.sessions[sessionID]
+	if !ok || !sess.RevokedAt.IsZero() {
+		return errInvalidID
+	}
+	sess.RevokedAt = revokedAt
+	sR.db.sessions[sessionID] = sess
+
+	return nil
+}
+
+func (sR *SessionRepository) RevokeByUserID(userID int64, revokedAt time.Time) error {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	for id, sess := range sR.db.sessions {
+		if sess.UserID == userID && sess.RevokedAt.IsZero() {
+			sess.RevokedAt = revokedAt
+			sR.db.sessions[id] = sess
+		}
+	}
+
+	return nil
+}
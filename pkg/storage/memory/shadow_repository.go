@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+)
+
+// ShadowRepository shadows.Repository in memory implementation
+type ShadowRepository struct {
+	db *DB
+}
+
+// CreateShadowRepository Create new instance of memory.ShadowRepository
+func CreateShadowRepository(db *DB) shadows.Repository {
+	return &ShadowRepository{db}
+}
+
+func (sR *ShadowRepository) Get(deviceID int64) (*shadows.Shadow, error) {
+	sR.db.mu.RLock()
+	defer sR.db.mu.RUnlock()
+
+	s, ok := sR.db.deviceShadows[deviceID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return copyShadow(s)
+}
+
+func (sR *ShadowRepository) Save(s shadows.Shadow, expectedVersion int64) (*shadows.Shadow, error) {
+	sR.db.mu.Lock()
+	defer sR.db.mu.Unlock()
+
+	if _, ok := sR.db.devices[s.DeviceID]; !ok {
+		return nil, errForeignKey
+	}
+	if sR.db.deviceShadows[s.DeviceID].Version != expectedVersion {
+		return nil, shadows.ErrVersionConflict
+	}
+
+	s.Version = expectedVersion + 1
+	stored, err := copyShadow(s)
+	if err != nil {
+		return nil, err
+	}
+	sR.db.deviceShadows[s.DeviceID] = *stored
+
+	return &s, nil
+}
+
+// copyShadow deep copies the documents so callers never share them with the storage
+// (documents go through JSON like they would in postgres)
+func copyShadow(s shadows.Shadow) (*shadows.Shadow, error) {
+	for _, doc := range []*shadows.Document{&s.Desired, &s.Reported} {
+		encoded, err := json.Marshal(*doc)
+		if err != nil {
+			return nil, err
+		}
+		*doc = shadows.Document{}
+		if err = json.Unmarshal(encoded, doc); err != nil {
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
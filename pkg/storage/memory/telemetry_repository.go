@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/telemetry"
+)
+
+// TelemetryRepository telemetry.Repository in memory implementation
+type TelemetryRepository struct {
+	db *DB
+}
+
+// CreateTelemetryRepository Create new instance of memory.TelemetryRepository
+func CreateTelemetryRepository(db *DB) telemetry.Repository {
+	return &TelemetryRepository{db}
+}
+
+func (tR *TelemetryRepository) CreateBatch(points []telemetry.Datapoint) error {
+	tR.db.mu.Lock()
+	defer tR.db.mu.Unlock()
+
+	for _, p := range points {
+		if _, ok := tR.db.devices[p.DeviceID]; !ok {
+			return errForeignKey
+		}
+	}
+	for _, p := range points {
+		tR.db.telemetry[p.DeviceID] = append(tR.db.telemetry[p.DeviceID], p)
+	}
+
+	return nil
+}
+
+func (tR *TelemetryRepository) Get(q telemetry.Query) ([]telemetry.Datapoint, error) {
+	points := tR.selectPoints(q)
+	start, end := pageBounds(len(points), q.Limit, q.Offset)
+
+	return points[start:end], nil
+}
+
+func (tR *TelemetryRepository) GetAggregates(q telemetry.Query) ([]telemetry.Aggregate, error) {
+	points := tR.selectPoints(q)
+
+	type bucketKey struct {
+		metric string
+		start  int64
+	}
+	buckets := map[bucketKey]*telemetry.Aggregate{}
+	aggregates := []*telemetry.Aggregate{}
+	sum := map[bucketKey]float64{}
+	bucketSeconds := int64(q.Bucket / time.Second)
+	for _, p := range points {
+		// floor (not truncation toward zero) to match postgres for times before the epoch
+		start := p.Timestamp.Unix() / bucketSeconds * bucketSeconds
+		if p.Timestamp.Unix() < 0 && p.Timestamp.Unix()%bucketSeconds != 0 {
+			start -= bucketSeconds
+		}
+		key := bucketKey{p.Metric, start}
+
+		a, ok := buckets[key]
+		if !ok {
+			a = &telemetry.Aggregate{
+				Metric:      p.Metric,
+				BucketStart: time.Unix(start, 0),
+				Min:         p.Value,
+				Max:         p.Value,
+			}
+			buckets[key] = a
+			aggregates = append(aggregates, a)
+		}
+		if p.Value < a.Min {
+			a.Min = p.Value
+		}
+		if p.Value > a.Max {
+			a.Max = p.Value
+		}
+		a.Count++
+		sum[key] += p.Value
+		a.Avg = sum[key] / float64(a.Count)
+	}
+
+	sort.SliceStable(aggregates, func(i, j int) bool {
+		if !aggregates[i].BucketStart.Equal(aggregates[j].BucketStart) {
+			return aggregates[i].BucketStart.Before(aggregates[j].BucketStart)
+		}
+		return aggregates[i].Metric < aggregates[j].Metric
+	})
+
+	result := make([]telemetry.Aggregate, len(aggregates))
+	for i, a := range aggregates {
+		result[i] = *a
+	}
+
+	start, end := pageBounds(len(result), q.Limit, q.Offset)
+
+	return result[start:end], nil
+}
+
+// selectPoints returns the datapoints matching q (ignoring pagination) ordered by timestamp
+func (tR *TelemetryRepository) selectPoints(q telemetry.Query) []telemetry.Datapoint {
+	tR.db.mu.RLock()
+	defer tR.db.mu.RUnlock()
+
+	points := []telemetry.Datapoint{}
+	for _, p := range tR.db.telemetry[q.DeviceID] {
+		if p.Timestamp.Before(q.From) || !p.Timestamp.Before(q.To) {
+			continue
+		}
+		if q.Metric != "" && p.Metric != q.Metric {
+			continue
+		}
+		points = append(points, p)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		if !points[i].Timestamp.Equal(points[j].Timestamp) {
+			return points[i].Timestamp.Before(points[j].Timestamp)
+		}
+		return points[i].Metric < points[j].Metric
+	})
+
+	return points
+}
+
+// pageBounds returns the slice bounds of a limit/offset page of n rows
+func pageBounds(n int, limit int, offset int) (int, int) {
+	start := offset
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if end > n {
+		end = n
+	}
+
+	return start, end
+}
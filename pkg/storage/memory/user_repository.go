@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+// UserRepository users.Repository in memory implementation
+type UserRepository struct {
+	db *DB
+}
+
+// CreateUserRepository Create new instance of memory.UserRepository
+func CreateUserRepository(db *DB) users.Repository {
+	return &UserRepository{db}
+}
+
+func (uR *UserRepository) GetByID(userID int64) (*users.User, error) {
+	uR.db.mu.RLock()
+	defer uR.db.mu.RUnlock()
+
+	u, ok := uR.db.users[userID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	return &u, nil
+}
+
+func (uR *UserRepository) GetByEmail(email string) (*users.User, error) {
+	uR.db.mu.RLock()
+	defer uR.db.mu.RUnlock()
+
+	for _, u := range uR.db.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (uR *UserRepository) GetByKey(key string) (*users.User, error) {
+	uR.db.mu.RLock()
+	defer uR.db.mu.RUnlock()
+
+	for _, u := range uR.db.users {
+		if key != "" && u.AuthKey == key {
+			return &u, nil
+		}
+	}
+
+	return nil, errInvalidID
+}
+
+func (uR *UserRepository) Create(u users.User) (*users.User, error) {
+	uR.db.mu.Lock()
+	defer uR.db.mu.Unlock()
+
+	for _, existing := range uR.db.users {
+		if existing.Email == u.Email || existing.Name == u.Name {
+			return nil, errDuplicate
+		}
+	}
+
+	u.ID = uR.db.nextID("users")
+	u.CreatedAt = time.Now()
+	uR.db.users[u.ID] = u
+
+	return &u, nil
+}
+
+func (uR *UserRepository) Update(userID int64, u users.User) (*users.User, error) {
+	uR.db.mu.Lock()
+	defer uR.db.mu.Unlock()
+
+	user, ok := uR.db.users[userID]
+	if !ok {
+		return nil, errInvalidID
+	}
+
+	// Mimic postgres COALESCE (zero values are not updated)
+	if u.Email != "" {
+		user.Email = u.Email
+	}
+	if u.DisplayName != "" {
+		user.DisplayName = u.DisplayName
+	}
+	if u.AuthKey != "" {
+		user.AuthKey = u.AuthKey
+	}
+	if u.PwdHash != "" {
+		user.PwdHash = u.PwdHash
+	}
+	if u.PwdSalt != "" {
+		user.PwdSalt = u.PwdSalt
+	}
+	user.UpdatedAt = time.Now()
+	uR.db.users[userID] = user
+
+	return &user, nil
+}
+
+func (uR *UserRepository) Delete(userID int64) error {
+	uR.db.mu.Lock()
+	defer uR.db.mu.Unlock()
+
+	if _, ok := uR.db.users[userID]; !ok {
+		return errInvalidID
+	}
+
+	// Like postgres, a user who still created projects or pipelines
+	// or collaborates on a project is not deleted
+	for _, p := range uR.db.projects {
+		if p.CreatedBy == userID {
+			return errReferenced
+		}
+	}
+	for _, p := range uR.db.pipelines {
+		if p.CreatedBy == userID {
+			return errReferenced
+		}
+	}
+	for _, collaborators := range uR.db.collaborators {
+		if _, ok := collaborators[userID]; ok {
+			return errReferenced
+		}
+	}
+
+	for id, inv := range uR.db.invitations {
+		if inv.InvitedBy == userID {
+			delete(uR.db.invitations, id)
+		}
+	}
+	// Key audit trail entries and invocations outlive their actor
+	for id, e := range uR.db.deviceKeyEvents {
+		if e.ActorID == userID {
+			e.ActorID = 0
+			uR.db.deviceKeyEvents[id] = e
+		}
+	}
+	for id, inv := range uR.db.invocations {
+		if inv.CreatedBy == userID {
+			inv.CreatedBy = 0
+			uR.db.invocations[id] = inv
+		}
+	}
+	for _, versions := range uR.db.pipelineVersions {
+		for i := range versions {
+			if versions[i].CreatedBy == userID {
+				versions[i].CreatedBy = 0
+			}
+		}
+	}
+	for id, k := range uR.db.apiKeys {
+		if k.UserID == userID {
+			delete(uR.db.apiKeys, id)
+		}
+	}
+	for id, sess := range uR.db.sessions {
+		if sess.UserID == userID {
+			delete(uR.db.sessions, id)
+		}
+	}
+	delete(uR.db.users, userID)
+
+	return nil
+}
+
+func (uR *UserRepository) IsDuplicateEmail(email string) (bool, error) {
+	uR.db.mu.RLock()
+	defer uR.db.mu.RUnlock()
+
+	for _, u := range uR.db.users {
+		if u.Email == email {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (uR *UserRepository) IsDuplicateName(name string) (bool, error) {
+	uR.db.mu.RLock()
+	defer uR.db.mu.RUnlock()
+
+	for _, u := range uR.db.users {
+		if u.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/apikeys"
+)
+
+// APIKeyRepository apikeys.Repository Postgres implementation
+type APIKeyRepository struct {
+	db *sqlx.DB
+}
+
+// CreateAPIKeyRepository Create new instance of postgres.APIKeyRepository
+func CreateAPIKeyRepository(db *sqlx.DB) apikeys.Repository {
+	return &APIKeyRepository{db}
+}
+
+func (kR *APIKeyRepository) GetByID(keyID int64) (*apikeys.Key, error) {
+	const getByIDStmt = `
+		SELECT
+			id, created_by, project_id, name, key_prefix, key_hash, scope,
+			created_at, expires_at, last_used_at, revoked_at
+		FROM external_keys
+		WHERE id = $1`
+
+	var keyData apiKeySQL
+	err := kR.db.Get(&keyData, getByIDStmt, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAPIKey(keyData), nil
+}
+
+func (kR *APIKeyRepository) GetByHash(keyHash string) (*apikeys.Key, error) {
+	const getByHashStmt = `
+		SELECT
+			id, created_by, project_id, name, key_prefix, key_hash, scope,
+			created_at, expires_at, last_used_at, revoked_at
+		FROM external_keys
+		WHERE key_hash = $1`
+
+	var keyData apiKeySQL
+	err := kR.db.Get(&keyData, getByHashStmt, keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAPIKey(keyData), nil
+}
+
+func (kR *APIKeyRepository) GetByUserID(userID int64) ([]apikeys.Key, error) {
+	const getByUserIDStmt = `
+		SELECT
+			id, created_by, project_id, name, key_prefix, key_hash, scope,
+			created_at, expires_at, last_used_at, revoked_at
+		FROM external_keys
+		WHERE created_by = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`
+
+	keysSQL := []apiKeySQL{}
+	err := kR.db.Select(&keysSQL, getByUserIDStmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]apikeys.Key, len(keysSQL))
+	for i := 0; i < len(keysSQL); i++ {
+		keys[i] = *toAPIKey(keysSQL[i])
+	}
+
+	return keys, nil
+}
+
+func (kR *APIKeyRepository) Create(k apikeys.Key) (*apikeys.Key, error) {
+	keyData := fromAPIKey(k)
+
+	const insertKeyStmt = `
+		INSERT INTO external_keys (
+			created_by, project_id, name, key_prefix, key_hash, scope,
+			created_at, expires_at
+		) VALUES (
+			:created_by, :project_id, :name, :key_prefix, :key_hash, :scope,
+			:created_at, :expires_at
+		) RETURNING id`
+
+	query, args, err := sqlx.Named(insertKeyStmt, keyData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	err = kR.db.Get(&k.ID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+func (kR *APIKeyRepository) UpdateLastUsed(keyID int64, lastUsedAt time.Time) error {
+	const updateLastUsedStmt = `
+		UPDATE external_keys
+		SET last_used_at = $2
+		WHERE id = $1`
+
+	_, err := kR.db.Exec(updateLastUsedStmt, keyID, lastUsedAt)
+	return err
+}
+
+func (kR *APIKeyRepository) Revoke(keyID int64, revokedAt time.Time) error {
+	const revokeStmt = `
+		UPDATE external_keys
+		SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := kR.db.Exec(revokeStmt, keyID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+type apiKeySQL struct {
+	ID         int64        `db:"id"`
+	CreatedBy  int64        `db:"created_by"`
+	ProjectID  int64        `db:"project_id"`
+	Name       string       `db:"name"`
+	KeyPrefix  string       `db:"key_prefix"`
+	KeyHash    string       `db:"key_hash"`
+	Scope      string       `db:"scope"`
+	CreatedAt  time.Time    `db:"created_at"`
+	ExpiresAt  sql.NullTime `db:"expires_at"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+}
+
+func toAPIKey(kSQL apiKeySQL) *apikeys.Key {
+	return &apikeys.Key{
+		ID:         kSQL.ID,
+		UserID:     kSQL.CreatedBy,
+		ProjectID:  kSQL.ProjectID,
+		Name:       kSQL.Name,
+		Prefix:     kSQL.KeyPrefix,
+		KeyHash:    kSQL.KeyHash,
+		Scope:      apikeys.Scope(kSQL.Scope),
+		CreatedAt:  kSQL.CreatedAt,
+		ExpiresAt:  kSQL.ExpiresAt.Time,
+		LastUsedAt: kSQL.LastUsedAt.Time,
+		RevokedAt:  kSQL.RevokedAt.Time,
+	}
+}
+
+func fromAPIKey(k apikeys.Key) *apiKeySQL {
+	return &apiKeySQL{
+		ID:         k.ID,
+		CreatedBy:  k.UserID,
+		ProjectID:  k.ProjectID,
+		Name:       k.Name,
+		KeyPrefix:  k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scope:      string(k.Scope),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  sql.NullTime{Time: k.ExpiresAt, Valid: !k.ExpiresAt.IsZero()},
+		LastUsedAt: sql.NullTime{Time: k.LastUsedAt, Valid: !k.LastUsedAt.IsZero()},
+		RevokedAt:  sql.NullTime{Time: k.RevokedAt, Valid: !k.RevokedAt.IsZero()},
+	}
+}
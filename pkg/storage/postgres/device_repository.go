@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -15,7 +16,9 @@ type DeviceRepository struct {
 
 func (dR *DeviceRepository) GetByID(deviceID int64) (*devices.Device, error) {
 	const sqlStmt = `
-	SELECT id, project_id, display_name, auth_key, description, created_at
+	SELECT id, project_id, display_name, auth_key, description, created_at,
+		previous_auth_key, previous_key_expires_at, key_revoked_at,
+		status, last_seen_at, connected_at, connection_id, manager_id, remote_addr, connection_metadata
 	FROM Devices
 	WHERE id = $1 `
 	var deviceData deviceSQL
@@ -27,13 +30,18 @@ func (dR *DeviceRepository) GetByID(deviceID int64) (*devices.Device, error) {
 	return toDevice(deviceData), nil
 }
 
-func (dR *DeviceRepository) GetByKey(authKey string) (*devices.Device, error) {
+func (dR *DeviceRepository) GetByKey(authKey string, now time.Time) (*devices.Device, error) {
 	const sqlStmt = `
-	Select id, project_id, display_name, auth_key, description, created_at
+	Select id, project_id, display_name, auth_key, description, created_at,
+		previous_auth_key, previous_key_expires_at, key_revoked_at,
+		status, last_seen_at, connected_at, connection_id, manager_id, remote_addr, connection_metadata
 	FROM Devices
-	WHERE auth_key = $1 `
+	WHERE key_revoked_at IS NULL AND (
+		auth_key = $1 OR
+		(previous_auth_key = $1 AND previous_key_expires_at > $2)
+	)`
 	var deviceData deviceSQL
-	err := dR.db.Get(&deviceData, sqlStmt, authKey)
+	err := dR.db.Get(&deviceData, sqlStmt, authKey, now)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +131,9 @@ func (dR *DeviceRepository) Delete(deviceID int64) error {
 func (dR *DeviceRepository) GetByProjectID(projectID int64) ([]devices.Device, error) {
 	devicesSQL := []deviceSQL{}
 	const sqlStmt = `
-		SELECT id, project_id, display_name, auth_key, description, created_at 
+		SELECT id, project_id, display_name, auth_key, description, created_at,
+			previous_auth_key, previous_key_expires_at, key_revoked_at,
+		status, last_seen_at, connected_at, connection_id, manager_id, remote_addr, connection_metadata
 		FROM devices
 		WHERE project_id = $1
 	`
@@ -140,6 +150,188 @@ func (dR *DeviceRepository) GetByProjectID(projectID int64) ([]devices.Device, e
 	return devices, nil
 }
 
+func (dR *DeviceRepository) RotateKey(deviceID int64, newKey string, previousKeyExpiresAt time.Time) (*devices.Device, error) {
+	// A revoked key never becomes the previous key
+	const sqlStmt = `
+		UPDATE devices
+		SET
+			previous_auth_key = CASE WHEN key_revoked_at IS NULL AND $3::timestamptz IS NOT NULL
+				THEN auth_key ELSE NULL END,
+			previous_key_expires_at = CASE WHEN key_revoked_at IS NULL THEN $3::timestamptz ELSE NULL END,
+			auth_key = $2,
+			key_revoked_at = NULL
+		WHERE id = $1
+	`
+	expiresAt := sql.NullTime{Time: previousKeyExpiresAt, Valid: !previousKeyExpiresAt.IsZero()}
+	result, err := dR.db.Exec(sqlStmt, deviceID, newKey, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return nil, errors.New("Invalid ID")
+	}
+
+	return dR.GetByID(deviceID)
+}
+
+func (dR *DeviceRepository) RevokeKey(deviceID int64, revokedAt time.Time) (*devices.Device, error) {
+	const sqlStmt = `
+		UPDATE devices
+		SET
+			key_revoked_at = $2,
+			previous_auth_key = NULL,
+			previous_key_expires_at = NULL
+		WHERE id = $1
+	`
+	result, err := dR.db.Exec(sqlStmt, deviceID, revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return nil, errors.New("Invalid ID")
+	}
+
+	return dR.GetByID(deviceID)
+}
+
+func (dR *DeviceRepository) CreateKeyEvent(e devices.KeyEvent) (*devices.KeyEvent, error) {
+	const sqlStmt = `
+	INSERT INTO device_key_events (
+		device_id, action, actor_id, grace_period_seconds, created_at
+	) VALUES (
+		$1, $2, $3, $4, $5
+	) RETURNING id`
+
+	actorID := sql.NullInt64{Int64: e.ActorID, Valid: e.ActorID != 0}
+	gracePeriodSeconds := int64(e.GracePeriod / time.Second)
+	err := dR.db.Get(&e.ID, sqlStmt, e.DeviceID, e.Action, actorID, gracePeriodSeconds, e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (dR *DeviceRepository) GetKeyEvents(deviceID int64) ([]devices.KeyEvent, error) {
+	eventsSQL := []keyEventSQL{}
+	const sqlStmt = `
+		SELECT id, device_id, action, actor_id, grace_period_seconds, created_at
+		FROM device_key_events
+		WHERE device_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	err := dR.db.Select(&eventsSQL, sqlStmt, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]devices.KeyEvent, len(eventsSQL))
+	for i := 0; i < len(eventsSQL); i++ {
+		events[i] = devices.KeyEvent{
+			ID:          eventsSQL[i].ID,
+			DeviceID:    eventsSQL[i].DeviceID,
+			Action:      devices.KeyAction(eventsSQL[i].Action),
+			ActorID:     eventsSQL[i].ActorID.Int64,
+			GracePeriod: time.Duration(eventsSQL[i].GracePeriodSeconds) * time.Second,
+			CreatedAt:   eventsSQL[i].CreatedAt,
+		}
+	}
+
+	return events, nil
+}
+
+func (dR *DeviceRepository) SetOnline(deviceID int64, conn devices.Connection, now time.Time) (*devices.Device, error) {
+	metadata := sql.NullString{}
+	if len(conn.Metadata) != 0 {
+		metadataJSON, err := json.Marshal(conn.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = sql.NullString{String: string(metadataJSON), Valid: true}
+	}
+
+	const sqlStmt = `
+		UPDATE devices
+		SET
+			status = 'online',
+			last_seen_at = $2,
+			connected_at = $2,
+			connection_id = $3,
+			manager_id = NULLIF($4, ''),
+			remote_addr = NULLIF($5, ''),
+			connection_metadata = $6
+		WHERE id = $1
+	`
+	result, err := dR.db.Exec(sqlStmt, deviceID, now, conn.ID, conn.ManagerID, conn.RemoteAddr, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return nil, errors.New("Invalid ID")
+	}
+
+	return dR.GetByID(deviceID)
+}
+
+func (dR *DeviceRepository) SetOffline(deviceID int64, connectionID string, now time.Time) error {
+	// Stale events (of an older connection) are ignored
+	const sqlStmt = `
+		UPDATE devices
+		SET status = 'offline', last_seen_at = $3
+		WHERE id = $1 AND connection_id = $2 AND status = 'online'
+	`
+	_, err := dR.db.Exec(sqlStmt, deviceID, connectionID, now)
+	if err != nil {
+		return err
+	}
+
+	return dR.exists(deviceID)
+}
+
+func (dR *DeviceRepository) Heartbeat(deviceID int64, connectionID string, now time.Time) error {
+	const sqlStmt = `
+		UPDATE devices
+		SET last_seen_at = $3
+		WHERE id = $1 AND connection_id = $2 AND status = 'online'
+	`
+	_, err := dR.db.Exec(sqlStmt, deviceID, connectionID, now)
+	if err != nil {
+		return err
+	}
+
+	return dR.exists(deviceID)
+}
+
+func (dR *DeviceRepository) SetManagerOffline(managerID string, now time.Time) error {
+	const sqlStmt = `
+		UPDATE devices
+		SET status = 'offline', last_seen_at = $2
+		WHERE manager_id = $1 AND status = 'online'
+	`
+	_, err := dR.db.Exec(sqlStmt, managerID, now)
+	return err
+}
+
+// exists returns an error if the device does not exist
+func (dR *DeviceRepository) exists(deviceID int64) error {
+	var found bool
+	err := dR.db.Get(&found, `SELECT EXISTS (SELECT 1 FROM devices WHERE id = $1)`, deviceID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
 func CreateDeviceRepository(db *sqlx.DB) devices.Repository {
 	return &DeviceRepository{db}
 }
@@ -153,6 +345,27 @@ type deviceSQL struct {
 	Description sql.NullString `db:"description"`
 	CreatedAt   time.Time      `db:"created_at"`
 	UpdatedAt   sql.NullTime   `db:"updated_at"`
+
+	PreviousAuthKey      sql.NullString `db:"previous_auth_key"`
+	PreviousKeyExpiresAt sql.NullTime   `db:"previous_key_expires_at"`
+	KeyRevokedAt         sql.NullTime   `db:"key_revoked_at"`
+
+	Status             string         `db:"status"`
+	LastSeenAt         sql.NullTime   `db:"last_seen_at"`
+	ConnectedAt        sql.NullTime   `db:"connected_at"`
+	ConnectionID       sql.NullString `db:"connection_id"`
+	ManagerID          sql.NullString `db:"manager_id"`
+	RemoteAddr         sql.NullString `db:"remote_addr"`
+	ConnectionMetadata sql.NullString `db:"connection_metadata"`
+}
+
+type keyEventSQL struct {
+	ID                 int64         `db:"id"`
+	DeviceID           int64         `db:"device_id"`
+	Action             string        `db:"action"`
+	ActorID            sql.NullInt64 `db:"actor_id"`
+	GracePeriodSeconds int64         `db:"grace_period_seconds"`
+	CreatedAt          time.Time     `db:"created_at"`
 }
 
 //Changing from postgress device implementation to device service implementation
@@ -167,9 +380,37 @@ func toDevice(dSQL deviceSQL) *devices.Device {
 		ProjectID:   dSQL.ProjectID.Int64,
 
 		AuthKey: dSQL.AuthKey.String,
+
+		PreviousAuthKey:      dSQL.PreviousAuthKey.String,
+		PreviousKeyExpiresAt: dSQL.PreviousKeyExpiresAt.Time,
+		KeyRevokedAt:         dSQL.KeyRevokedAt.Time,
+
+		Status:      devices.Status(dSQL.Status),
+		LastSeenAt:  dSQL.LastSeenAt.Time,
+		ConnectedAt: dSQL.ConnectedAt.Time,
+		Connection: devices.Connection{
+			ID:         dSQL.ConnectionID.String,
+			ManagerID:  dSQL.ManagerID.String,
+			RemoteAddr: dSQL.RemoteAddr.String,
+			Metadata:   toConnectionMetadata(dSQL.ConnectionMetadata),
+		},
 	}
 }
 
+func toConnectionMetadata(metadataSQL sql.NullString) map[string]string {
+	if !metadataSQL.Valid {
+		return nil
+	}
+
+	metadata := map[string]string{}
+	err := json.Unmarshal([]byte(metadataSQL.String), &metadata)
+	if err != nil {
+		return nil
+	}
+
+	return metadata
+}
+
 //Changing from device service implementation to postgress device implementation
 func fromDevice(d devices.Device) *deviceSQL {
 	var deviceData deviceSQL
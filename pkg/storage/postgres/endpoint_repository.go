@@ -19,7 +19,8 @@ func CreateEndpointRepository(db *sqlx.DB) endpoints.Repository {
 
 func (epR *EndpointRepository) GetByID(endpointID int64) (*endpoints.Endpoint, error) {
 	const sqlStmt = `
-	Select id, device_id, display_name, description, pattern
+	Select id, device_id, display_name, description, pattern, timeout_ms,
+		method, request_schema, response_schema
 	From endpoints
 	where id = $1 `
 	var endpointData endpointSQL
@@ -37,9 +38,11 @@ func (epR *EndpointRepository) Create(ep endpoints.Endpoint) (*endpoints.Endpoin
 	endpointData := fromEndpoint(ep)
 	const sqlStmt = `
 	INSERT INTO endpoints (
-		device_id, display_name, description, pattern, created_at
+		device_id, display_name, description, pattern, timeout_ms,
+		method, request_schema, response_schema, created_at
 	) VALUES (
-		:device_id, :display_name, :description, :pattern, :created_at
+		:device_id, :display_name, :description, :pattern, :timeout_ms,
+		:method, :request_schema, :response_schema, :created_at
 	) RETURNING id`
 	query, args, err := sqlx.Named(sqlStmt, endpointData)
 	if err != nil {
@@ -71,6 +74,10 @@ func (epR *EndpointRepository) Update(endpointID int64, ep endpoints.Endpoint) (
 			display_name = COALESCE(:display_name, display_name),
 			description = COALESCE(:description, description),
 			pattern = COALESCE(:pattern, pattern),
+			timeout_ms = COALESCE(:timeout_ms, timeout_ms),
+			method = COALESCE(:method, method),
+			request_schema = COALESCE(:request_schema, request_schema),
+			response_schema = COALESCE(:response_schema, response_schema),
 			updated_at = COALESCE(:updated_at, updated_at)
 		WHERE id = :id`
 
@@ -110,10 +117,28 @@ func (epR *EndpointRepository) Delete(endpointID int64) error {
 	return nil
 }
 
+func (epR *EndpointRepository) GetByPattern(deviceID int64, pattern string) (*endpoints.Endpoint, error) {
+	const sqlStmt = `
+	SELECT id, device_id, display_name, description, pattern, timeout_ms,
+		method, request_schema, response_schema
+	FROM endpoints
+	WHERE device_id = $1 AND pattern = $2
+	ORDER BY id
+	LIMIT 1`
+	var endpointData endpointSQL
+	err := epR.db.Get(&endpointData, sqlStmt, deviceID, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return toEndpoint(endpointData), nil
+}
+
 func (epR *EndpointRepository) GetbyDeviceID(deviceID int64) ([]endpoints.Endpoint, error) {
 	endpointsSQL := []endpointSQL{}
 	const sqlStmt = `
-	SELECT id, device_id, display_name, description, pattern
+	SELECT id, device_id, display_name, description, pattern, timeout_ms,
+		method, request_schema, response_schema
 	FROM endpoints
 	WHERE device_id = $1
 	`
@@ -132,13 +157,17 @@ func (epR *EndpointRepository) GetbyDeviceID(deviceID int64) ([]endpoints.Endpoi
 
 //stucrt to match the postgres database construction
 type endpointSQL struct {
-	ID          int64          `db:"id"`
-	DeviceID    sql.NullInt64  `db:"device_id"`
-	DisplayName sql.NullString `db:"display_name"`
-	Description sql.NullString `db:"description"`
-	Pattern     sql.NullString `db:"pattern"`
-	CreatedAt   time.Time      `db:"created_at"`
-	UpdatedAt   sql.NullTime   `db:"updated_at"`
+	ID             int64          `db:"id"`
+	DeviceID       sql.NullInt64  `db:"device_id"`
+	DisplayName    sql.NullString `db:"display_name"`
+	Description    sql.NullString `db:"description"`
+	Pattern        sql.NullString `db:"pattern"`
+	TimeoutMs      sql.NullInt64  `db:"timeout_ms"`
+	Method         sql.NullString `db:"method"`
+	RequestSchema  sql.NullString `db:"request_schema"`
+	ResponseSchema sql.NullString `db:"response_schema"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      sql.NullTime   `db:"updated_at"`
 }
 
 func toEndpoint(epSQL endpointSQL) *endpoints.Endpoint {
@@ -151,6 +180,11 @@ func toEndpoint(epSQL endpointSQL) *endpoints.Endpoint {
 		DisplayName: epSQL.DisplayName.String,
 		DeviceID:    epSQL.DeviceID.Int64,
 		Pattern:     epSQL.Pattern.String,
+		Timeout:     time.Duration(epSQL.TimeoutMs.Int64) * time.Millisecond,
+
+		Method:         epSQL.Method.String,
+		RequestSchema:  epSQL.RequestSchema.String,
+		ResponseSchema: epSQL.ResponseSchema.String,
 	}
 }
 
@@ -178,6 +212,22 @@ func fromEndpoint(ep endpoints.Endpoint) *endpointSQL {
 		String: ep.Pattern,
 		Valid:  ep.Pattern != "",
 	}
+	endpointData.TimeoutMs = sql.NullInt64{
+		Int64: int64(ep.Timeout / time.Millisecond),
+		Valid: ep.Timeout != 0,
+	}
+	endpointData.Method = sql.NullString{
+		String: ep.Method,
+		Valid:  ep.Method != "",
+	}
+	endpointData.RequestSchema = sql.NullString{
+		String: ep.RequestSchema,
+		Valid:  ep.RequestSchema != "",
+	}
+	endpointData.ResponseSchema = sql.NullString{
+		String: ep.ResponseSchema,
+		Valid:  ep.ResponseSchema != "",
+	}
 
 	return &endpointData
 }
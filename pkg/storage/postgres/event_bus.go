@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/tnynlabs/wyrm/pkg/events"
+)
+
+// eventChannel notification channel events are fanned out on
+const eventChannel = "wyrm_events"
+
+// maxNotifyPayload notification payloads are limited to 8000 bytes
+// (events with larger data are sent without their data)
+const maxNotifyPayload = 7900
+
+// notifyBuffer number of events waiting to be sent to the other replicas
+// before new events are only published to this replica
+const notifyBuffer = 4096
+
+// EventBus events.Bus Postgres implementation. Events are published to the
+// subscribers of this replica and sent to the other API replicas with
+// NOTIFY, which publish them to their SubscribeAll subscribers only.
+type EventBus struct {
+	db       *sqlx.DB
+	listener *pq.Listener
+	// replicaID origin of the notifications sent by this replica
+	replicaID string
+
+	// local events published by this replica
+	local events.Bus
+	// all events published by any replica
+	all     events.Bus
+	pending chan notification
+}
+
+// notification NOTIFY payload
+type notification struct {
+	Origin string       `json:"origin"`
+	Event  events.Event `json:"event"`
+}
+
+// CreateEventBus Create new instance of postgres.EventBus, connInfo is used
+// for the connection listening to the other replicas (see ConnInfoFromEnv)
+func CreateEventBus(db *sqlx.DB, connInfo string) (events.Bus, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	b := &EventBus{
+		db:        db,
+		replicaID: hex.EncodeToString(id),
+		local:     events.CreateBus(),
+		all:       events.CreateBus(),
+		pending:   make(chan notification, notifyBuffer),
+	}
+	b.listener = pq.NewListener(connInfo, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event listener connection failed (error: %v)", err)
+		}
+	})
+	if err := b.listener.Listen(eventChannel); err != nil {
+		b.listener.Close()
+		return nil, err
+	}
+
+	go b.notify()
+	go b.listen()
+
+	return b, nil
+}
+
+func (b *EventBus) Publish(e events.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.local.Publish(e)
+	b.all.Publish(e)
+
+	select {
+	case b.pending <- notification{Origin: b.replicaID, Event: e}:
+	default:
+		log.Printf("Dropped %s event of project %d for other replicas (too many pending events)", e.Type, e.ProjectID)
+	}
+}
+
+func (b *EventBus) Subscribe(handler func(events.Event)) func() {
+	return b.local.Subscribe(handler)
+}
+
+func (b *EventBus) SubscribeAll(handler func(events.Event)) func() {
+	return b.all.Subscribe(handler)
+}
+
+// notify sends the published events to the other replicas
+func (b *EventBus) notify() {
+	for n := range b.pending {
+		payload, err := json.Marshal(n)
+		if err == nil && len(payload) > maxNotifyPayload {
+			n.Event.Data = nil
+			n.Event.Truncated = true
+			payload, err = json.Marshal(n)
+		}
+		if err != nil {
+			log.Printf("Failed encoding %s event (error: %v)", n.Event.Type, err)
+			continue
+		}
+
+		_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, eventChannel, string(payload))
+		if err != nil {
+			log.Printf("Failed sending %s event to other replicas (error: %v)", n.Event.Type, err)
+		}
+	}
+}
+
+// listen publishes the events of the other replicas
+func (b *EventBus) listen() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case pqNotification := <-b.listener.Notify:
+			if pqNotification == nil {
+				// Notifications sent while reconnecting are lost
+				log.Println("Event listener reconnected")
+				continue
+			}
+
+			var n notification
+			if err := json.Unmarshal([]byte(pqNotification.Extra), &n); err != nil {
+				log.Printf("Failed decoding event notification (error: %v)", err)
+				continue
+			}
+			if n.Origin == b.replicaID {
+				continue
+			}
+			b.all.Publish(n.Event)
+		case <-ticker.C:
+			// Detects dropped connections while no events are sent
+			go b.listener.Ping()
+		}
+	}
+}
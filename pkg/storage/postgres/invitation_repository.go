@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+)
+
+// InvitationRepository projects.InvitationRepository Postgres implementation
+type InvitationRepository struct {
+	db *sqlx.DB
+}
+
+// CreateInvitationRepository Create new instance of postgres.InvitationRepository
+func CreateInvitationRepository(db *sqlx.DB) projects.InvitationRepository {
+	return &InvitationRepository{db}
+}
+
+func (iR *InvitationRepository) GetByID(invitationID int64) (*projects.Invitation, error) {
+	const getByIDStmt = `
+	SELECT
+		id, project_id, email, role, token_hash, invited_by,
+		status, created_at, expires_at, responded_at
+	FROM invitations
+	WHERE id = $1`
+
+	var invitationData invitationSQL
+	err := iR.db.Get(&invitationData, getByIDStmt, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toInvitation(invitationData), nil
+}
+
+func (iR *InvitationRepository) GetByTokenHash(tokenHash string) (*projects.Invitation, error) {
+	const getByTokenHashStmt = `
+	SELECT
+		id, project_id, email, role, token_hash, invited_by,
+		status, created_at, expires_at, responded_at
+	FROM invitations
+	WHERE token_hash = $1`
+
+	var invitationData invitationSQL
+	err := iR.db.Get(&invitationData, getByTokenHashStmt, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toInvitation(invitationData), nil
+}
+
+func (iR *InvitationRepository) GetPending(projectID int64, now time.Time) ([]projects.Invitation, error) {
+	const getPendingStmt = `
+	SELECT
+		id, project_id, email, role, token_hash, invited_by,
+		status, created_at, expires_at, responded_at
+	FROM invitations
+	WHERE project_id = $1 AND status = $2 AND expires_at > $3
+	ORDER BY created_at`
+
+	return iR.selectInvitations(getPendingStmt, projectID, projects.InvitationPending, now)
+}
+
+func (iR *InvitationRepository) GetPendingByEmail(email string, now time.Time) ([]projects.Invitation, error) {
+	const getPendingByEmailStmt = `
+	SELECT
+		id, project_id, email, role, token_hash, invited_by,
+		status, created_at, expires_at, responded_at
+	FROM invitations
+	WHERE lower(email) = lower($1) AND status = $2 AND expires_at > $3
+	ORDER BY created_at`
+
+	return iR.selectInvitations(getPendingByEmailStmt, email, projects.InvitationPending, now)
+}
+
+func (iR *InvitationRepository) Create(inv projects.Invitation) (*projects.Invitation, error) {
+	invitationData := fromInvitation(inv)
+
+	const insertInvitationStmt = `
+	INSERT INTO invitations (
+		project_id, email, role, token_hash, invited_by,
+		status, created_at, expires_at
+	) VALUES (
+		:project_id, :email, :role, :token_hash, :invited_by,
+		:status, :created_at, :expires_at
+	) RETURNING id`
+
+	query, args, err := sqlx.Named(insertInvitationStmt, invitationData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	err = iR.db.Get(&inv.ID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (iR *InvitationRepository) SetStatus(invitationID int64, status projects.InvitationStatus, respondedAt time.Time) error {
+	const setStatusStmt = `
+	UPDATE invitations
+	SET status = $2, responded_at = $3
+	WHERE id = $1 AND status = $4`
+
+	result, err := iR.db.Exec(setStatusStmt, invitationID, status, respondedAt, projects.InvitationPending)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (iR *InvitationRepository) selectInvitations(query string, args ...interface{}) ([]projects.Invitation, error) {
+	invitationsSQL := []invitationSQL{}
+	err := iR.db.Select(&invitationsSQL, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations := make([]projects.Invitation, len(invitationsSQL))
+	for i := 0; i < len(invitationsSQL); i++ {
+		invitations[i] = *toInvitation(invitationsSQL[i])
+	}
+
+	return invitations, nil
+}
+
+type invitationSQL struct {
+	ID          int64        `db:"id"`
+	ProjectID   int64        `db:"project_id"`
+	Email       string       `db:"email"`
+	Role        string       `db:"role"`
+	TokenHash   string       `db:"token_hash"`
+	InvitedBy   int64        `db:"invited_by"`
+	Status      string       `db:"status"`
+	CreatedAt   time.Time    `db:"created_at"`
+	ExpiresAt   time.Time    `db:"expires_at"`
+	RespondedAt sql.NullTime `db:"responded_at"`
+}
+
+func toInvitation(iSQL invitationSQL) *projects.Invitation {
+	return &projects.Invitation{
+		ID:          iSQL.ID,
+		ProjectID:   iSQL.ProjectID,
+		Email:       iSQL.Email,
+		Role:        projects.Role(iSQL.Role),
+		TokenHash:   iSQL.TokenHash,
+		InvitedBy:   iSQL.InvitedBy,
+		Status:      projects.InvitationStatus(iSQL.Status),
+		CreatedAt:   iSQL.CreatedAt,
+		ExpiresAt:   iSQL.ExpiresAt,
+		RespondedAt: iSQL.RespondedAt.Time,
+	}
+}
+
+func fromInvitation(inv projects.Invitation) *invitationSQL {
+	return &invitationSQL{
+		ID:          inv.ID,
+		ProjectID:   inv.ProjectID,
+		Email:       inv.Email,
+		Role:        string(inv.Role),
+		TokenHash:   inv.TokenHash,
+		InvitedBy:   inv.InvitedBy,
+		Status:      string(inv.Status),
+		CreatedAt:   inv.CreatedAt,
+		ExpiresAt:   inv.ExpiresAt,
+		RespondedAt: sql.NullTime{Time: inv.RespondedAt, Valid: !inv.RespondedAt.IsZero()},
+	}
+}
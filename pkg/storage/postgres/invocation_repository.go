@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/invocations"
+)
+
+// InvocationRepository invocations.Repository Postgres implementation
+type InvocationRepository struct {
+	db *sqlx.DB
+}
+
+// CreateInvocationRepository Create new instance of postgres.InvocationRepository
+func CreateInvocationRepository(db *sqlx.DB) invocations.Repository {
+	return &InvocationRepository{db}
+}
+
+const invocationColumns = `
+	id, device_id, created_by, pattern, request, timeout_ms, status, attempts,
+	response, error, created_at, started_at, claimed_at, finished_at, next_attempt_at`
+
+func (iR *InvocationRepository) Create(inv invocations.Invocation) (*invocations.Invocation, error) {
+	invocationData := fromInvocation(inv)
+
+	const insertInvocationStmt = `
+		INSERT INTO invocations (
+			device_id, created_by, pattern, request, timeout_ms,
+			status, created_at, next_attempt_at
+		) VALUES (
+			:device_id, :created_by, :pattern, :request, :timeout_ms,
+			:status, :created_at, :next_attempt_at
+		) RETURNING id`
+
+	query, args, err := sqlx.Named(insertInvocationStmt, invocationData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	err = iR.db.Get(&inv.ID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (iR *InvocationRepository) GetByID(invocationID int64) (*invocations.Invocation, error) {
+	getByIDStmt := `SELECT` + invocationColumns + `
+		FROM invocations
+		WHERE id = $1`
+
+	var invocationData invocationSQL
+	err := iR.db.Get(&invocationData, getByIDStmt, invocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toInvocation(invocationData), nil
+}
+
+func (iR *InvocationRepository) GetByDeviceID(deviceID int64, limit int, offset int) ([]invocations.Invocation, error) {
+	getByDeviceIDStmt := `SELECT` + invocationColumns + `
+		FROM invocations
+		WHERE device_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	return iR.selectInvocations(getByDeviceIDStmt, deviceID, limit, offset)
+}
+
+func (iR *InvocationRepository) GetDue(now time.Time, limit int) ([]invocations.Invocation, error) {
+	getDueStmt := `SELECT` + invocationColumns + `
+		FROM invocations
+		WHERE status = 'queued' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $2`
+
+	return iR.selectInvocations(getDueStmt, now, limit)
+}
+
+func (iR *InvocationRepository) Claim(invocationID int64, now time.Time) (*invocations.Invocation, error) {
+	claimStmt := `
+		UPDATE invocations
+		SET
+			status = 'running',
+			attempts = attempts + 1,
+			started_at = COALESCE(started_at, $2),
+			claimed_at = $2
+		WHERE id = $1 AND status = 'queued'
+		RETURNING` + invocationColumns
+
+	var invocationData invocationSQL
+	err := iR.db.Get(&invocationData, claimStmt, invocationID, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, invocations.ErrNotQueued
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toInvocation(invocationData), nil
+}
+
+func (iR *InvocationRepository) Update(inv invocations.Invocation) error {
+	invocationData := fromInvocation(inv)
+
+	const updateInvocationStmt = `
+		UPDATE invocations
+		SET
+			status = :status,
+			response = :response,
+			error = :error,
+			finished_at = :finished_at,
+			next_attempt_at = :next_attempt_at
+		WHERE id = :id AND status = 'running' AND attempts = :attempts`
+
+	result, err := iR.db.NamedExec(updateInvocationStmt, invocationData)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != 1 {
+		return invocations.ErrAttemptExpired
+	}
+
+	return nil
+}
+
+func (iR *InvocationRepository) ReleaseStale(claimedBefore time.Time, now time.Time, maxAttempts int, reason string) ([]invocations.Invocation, error) {
+	// Rows are locked by the update, replicas sweeping at
+	// the same time release each invocation once
+	releaseStaleStmt := `
+		UPDATE invocations
+		SET
+			status = CASE WHEN attempts < $3 THEN 'queued' ELSE 'failed' END,
+			error = $4,
+			next_attempt_at = CASE WHEN attempts < $3 THEN $2 ELSE next_attempt_at END,
+			finished_at = CASE WHEN attempts < $3 THEN NULL ELSE $2 END
+		WHERE status = 'running' AND claimed_at < $1
+		RETURNING` + invocationColumns
+
+	return iR.selectInvocations(releaseStaleStmt, claimedBefore, now, maxAttempts, reason)
+}
+
+func (iR *InvocationRepository) selectInvocations(query string, args ...interface{}) ([]invocations.Invocation, error) {
+	invocationsSQL := []invocationSQL{}
+	err := iR.db.Select(&invocationsSQL, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	invs := make([]invocations.Invocation, len(invocationsSQL))
+	for i := 0; i < len(invocationsSQL); i++ {
+		invs[i] = *toInvocation(invocationsSQL[i])
+	}
+
+	return invs, nil
+}
+
+type invocationSQL struct {
+	ID            int64          `db:"id"`
+	DeviceID      int64          `db:"device_id"`
+	CreatedBy     sql.NullInt64  `db:"created_by"`
+	Pattern       string         `db:"pattern"`
+	Request       string         `db:"request"`
+	TimeoutMs     sql.NullInt64  `db:"timeout_ms"`
+	Status        string         `db:"status"`
+	Attempts      int            `db:"attempts"`
+	Response      sql.NullString `db:"response"`
+	Error         sql.NullString `db:"error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	StartedAt     sql.NullTime   `db:"started_at"`
+	ClaimedAt     sql.NullTime   `db:"claimed_at"`
+	FinishedAt    sql.NullTime   `db:"finished_at"`
+	NextAttemptAt sql.NullTime   `db:"next_attempt_at"`
+}
+
+func toInvocation(iSQL invocationSQL) *invocations.Invocation {
+	return &invocations.Invocation{
+		ID:            iSQL.ID,
+		DeviceID:      iSQL.DeviceID,
+		CreatedBy:     iSQL.CreatedBy.Int64,
+		Pattern:       iSQL.Pattern,
+		Request:       iSQL.Request,
+		Timeout:       time.Duration(iSQL.TimeoutMs.Int64) * time.Millisecond,
+		Status:        invocations.Status(iSQL.Status),
+		Attempts:      iSQL.Attempts,
+		Response:      iSQL.Response.String,
+		Error:         iSQL.Error.String,
+		CreatedAt:     iSQL.CreatedAt,
+		StartedAt:     iSQL.StartedAt.Time,
+		ClaimedAt:     iSQL.ClaimedAt.Time,
+		FinishedAt:    iSQL.FinishedAt.Time,
+		NextAttemptAt: iSQL.NextAttemptAt.Time,
+	}
+}
+
+func fromInvocation(inv invocations.Invocation) *invocationSQL {
+	return &invocationSQL{
+		ID:            inv.ID,
+		DeviceID:      inv.DeviceID,
+		CreatedBy:     sql.NullInt64{Int64: inv.CreatedBy, Valid: inv.CreatedBy != 0},
+		Pattern:       inv.Pattern,
+		Request:       inv.Request,
+		TimeoutMs:     sql.NullInt64{Int64: int64(inv.Timeout / time.Millisecond), Valid: inv.Timeout != 0},
+		Status:        string(inv.Status),
+		Attempts:      inv.Attempts,
+		Response:      sql.NullString{String: inv.Response, Valid: inv.Response != ""},
+		Error:         sql.NullString{String: inv.Error, Valid: inv.Error != ""},
+		CreatedAt:     inv.CreatedAt,
+		StartedAt:     sql.NullTime{Time: inv.StartedAt, Valid: !inv.StartedAt.IsZero()},
+		ClaimedAt:     sql.NullTime{Time: inv.ClaimedAt, Valid: !inv.ClaimedAt.IsZero()},
+		FinishedAt:    sql.NullTime{Time: inv.FinishedAt, Valid: !inv.FinishedAt.IsZero()},
+		NextAttemptAt: sql.NullTime{Time: inv.NextAttemptAt, Valid: !inv.NextAttemptAt.IsZero()},
+	}
+}
@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the postgres advisory lock key held while migrating
+// so that multiple API replicas never migrate the same database at once.
+const migrationLockID = 7_123_000_001
+
+var migrationNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration a single versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+// MigrationStatus a migration and whether it was applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations to a postgres database
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+
+	// DryRun prints the pending SQL to Out instead of executing it
+	DryRun bool
+	Out    io.Writer
+}
+
+// CreateMigrator Create new instance of postgres.Migrator
+func CreateMigrator(db *sqlx.DB, out io.Writer) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, Out: out}, nil
+}
+
+// Up applies all pending migrations in order
+func (m *Migrator) Up() error {
+	return m.withLock(func(conn *sqlx.Conn) error {
+		if !m.DryRun {
+			if err := m.createTable(conn); err != nil {
+				return err
+			}
+		}
+
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			fmt.Fprintf(m.Out, "Applying %04d_%s\n", migration.Version, migration.Name)
+			if m.DryRun {
+				fmt.Fprintln(m.Out, migration.UpSQL)
+				continue
+			}
+
+			const insertStmt = `
+				INSERT INTO schema_migrations (version, name, applied_at)
+				VALUES ($1, $2, $3)`
+			err = m.runInTx(conn, migration.UpSQL, insertStmt, migration.Version, migration.Name, time.Now())
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations (latest first)
+func (m *Migrator) Down(n int) error {
+	return m.withLock(func(conn *sqlx.Conn) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			n--
+
+			fmt.Fprintf(m.Out, "Reverting %04d_%s\n", migration.Version, migration.Name)
+			if m.DryRun {
+				fmt.Fprintln(m.Out, migration.DownSQL)
+				continue
+			}
+
+			const deleteStmt = `DELETE FROM schema_migrations WHERE version = $1`
+			err = m.runInTx(conn, migration.DownSQL, deleteStmt, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(func(conn *sqlx.Conn) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, len(m.migrations))
+		for i, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses[i] = MigrationStatus{
+				Migration: migration,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock
+func (m *Migrator) withLock(fn func(conn *sqlx.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	return fn(conn)
+}
+
+// createTable creates the table recording applied migrations
+// (dry runs and status leave the database untouched)
+func (m *Migrator) createTable(conn *sqlx.Conn) error {
+	const createTableStmt = `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+		 version    bigint NOT NULL,
+		 name       text NOT NULL,
+		 applied_at timestamptz NOT NULL,
+		 CONSTRAINT PK_schema_migrations PRIMARY KEY ( version )
+		);`
+	_, err := conn.ExecContext(context.Background(), createTableStmt)
+	return err
+}
+
+// applied returns applied migration versions mapped to when they were
+// applied (none if the schema_migrations table does not exist yet)
+func (m *Migrator) applied(conn *sqlx.Conn) (map[int64]time.Time, error) {
+	ctx := context.Background()
+	var exists bool
+	err := conn.GetContext(ctx, &exists, `SELECT to_regclass('schema_migrations') IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]time.Time{}, nil
+	}
+
+	rows := []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}{}
+	err = conn.SelectContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	return applied, nil
+}
+
+// runInTx executes a migration script and its bookkeeping statement atomically
+func (m *Migrator) runInTx(conn *sqlx.Conn, script, bookkeepingStmt string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, bookkeepingStmt, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// loadMigrations reads the embedded migration files sorted by version
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationNameRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name (%s)", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("conflicting migration names for version %d", version)
+		}
+
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpSQL == "" || migration.DownSQL == "" {
+			return nil, fmt.Errorf("migration %04d_%s is missing its up or down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS collaborators;
+DROP TABLE IF EXISTS pipelines;
+DROP TABLE IF EXISTS external_keys;
+DROP TABLE IF EXISTS endpoints;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS users;
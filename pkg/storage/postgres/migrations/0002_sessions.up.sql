@@ -0,0 +1,28 @@
+/* Sessions Table (previous_token_* keep a rotated token valid for a grace period) */
+CREATE TABLE IF NOT EXISTS sessions
+(
+ "id"                      bigserial NOT NULL,
+ user_id                   bigint NOT NULL,
+ token_hash                text NOT NULL UNIQUE,
+ previous_token_hash       text NULL,
+ previous_token_expires_at timestamptz NULL,
+ user_agent                text NULL,
+ ip                        text NULL,
+ created_at                timestamptz NOT NULL,
+ last_used_at              timestamptz NOT NULL,
+ rotated_at                timestamptz NOT NULL,
+ expires_at                timestamptz NOT NULL,
+ revoked_at                timestamptz NULL,
+ CONSTRAINT PK_sessions PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_sessions_users FOREIGN KEY ( user_id ) REFERENCES users ( "id" ) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_sessions_user ON sessions
+(
+ user_id
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token ON sessions
+(
+ previous_token_hash
+) WHERE previous_token_hash IS NOT NULL;
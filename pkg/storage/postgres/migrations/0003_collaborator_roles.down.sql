@@ -0,0 +1,2 @@
+ALTER TABLE collaborators DROP CONSTRAINT IF EXISTS CK_collaborators_role;
+ALTER TABLE collaborators DROP COLUMN IF EXISTS role;
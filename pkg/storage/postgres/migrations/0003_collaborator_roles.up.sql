@@ -0,0 +1,11 @@
+ALTER TABLE collaborators
+ ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'developer';
+
+ALTER TABLE collaborators
+ ADD CONSTRAINT CK_collaborators_role CHECK ( role IN ('owner', 'admin', 'developer', 'viewer') );
+
+/* Project creators become owners */
+UPDATE collaborators c
+SET role = 'owner'
+FROM projects p
+WHERE c.project_id = p.id AND c.user_id = p.created_by;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS invitations;
+ALTER TABLE collaborators DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,30 @@
+ALTER TABLE collaborators
+ ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+
+/* Invitations Table */
+CREATE TABLE IF NOT EXISTS invitations
+(
+ "id"          bigserial NOT NULL,
+ project_id    bigint NOT NULL,
+ email         text NOT NULL,
+ role          text NOT NULL,
+ token_hash    text NOT NULL UNIQUE,
+ invited_by    bigint NOT NULL,
+ status        text NOT NULL,
+ created_at    timestamptz NOT NULL,
+ expires_at    timestamptz NOT NULL,
+ responded_at  timestamptz NULL,
+ CONSTRAINT PK_invitations PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_invitations_projects FOREIGN KEY ( project_id ) REFERENCES projects ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_invitations_users FOREIGN KEY ( invited_by ) REFERENCES users ( "id" ) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_invitations_project ON invitations
+(
+ project_id
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations
+(
+ lower(email)
+);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS external_keys;
+
+/* External Keys Table */
+CREATE TABLE IF NOT EXISTS external_keys
+(
+ "id"         int NOT NULL,
+ auth_key   text NOT NULL,
+ created_at date NOT NULL,
+ updated_at date NOT NULL,
+ created_by int NOT NULL,
+ CONSTRAINT PK_external_keys PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_61 FOREIGN KEY ( created_by ) REFERENCES users ( "id" )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_62 ON external_keys
+(
+ created_by
+);
@@ -0,0 +1,32 @@
+/* The original external_keys table was never used (it had no scope nor expiry) */
+DROP TABLE IF EXISTS external_keys;
+
+/* External Keys Table (scoped API keys) */
+CREATE TABLE IF NOT EXISTS external_keys
+(
+ "id"          bigserial NOT NULL,
+ created_by    bigint NOT NULL,
+ project_id    bigint NOT NULL,
+ name          text NOT NULL,
+ key_prefix    text NOT NULL,
+ key_hash      text NOT NULL UNIQUE,
+ scope         text NOT NULL,
+ created_at    timestamptz NOT NULL,
+ expires_at    timestamptz NULL,
+ last_used_at  timestamptz NULL,
+ revoked_at    timestamptz NULL,
+ CONSTRAINT PK_external_keys PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_external_keys_users FOREIGN KEY ( created_by ) REFERENCES users ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_external_keys_projects FOREIGN KEY ( project_id ) REFERENCES projects ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT CK_external_keys_scope CHECK ( scope IN ('read', 'invoke', 'admin') )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_external_keys_user ON external_keys
+(
+ created_by
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_external_keys_project ON external_keys
+(
+ project_id
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS device_key_events;
+
+ALTER TABLE devices
+ DROP COLUMN IF EXISTS previous_auth_key,
+ DROP COLUMN IF EXISTS previous_key_expires_at,
+ DROP COLUMN IF EXISTS key_revoked_at;
@@ -0,0 +1,24 @@
+ALTER TABLE devices
+ ADD COLUMN IF NOT EXISTS previous_auth_key text NULL,
+ ADD COLUMN IF NOT EXISTS previous_key_expires_at timestamptz NULL,
+ ADD COLUMN IF NOT EXISTS key_revoked_at timestamptz NULL;
+
+/* Device Key Events Table (key audit trail) */
+CREATE TABLE IF NOT EXISTS device_key_events
+(
+ "id"                 bigserial NOT NULL,
+ device_id            bigint NOT NULL,
+ action               text NOT NULL,
+ actor_id             bigint NULL,
+ grace_period_seconds bigint NOT NULL DEFAULT 0,
+ created_at           timestamptz NOT NULL,
+ CONSTRAINT PK_device_key_events PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_device_key_events_devices FOREIGN KEY ( device_id ) REFERENCES devices ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_device_key_events_users FOREIGN KEY ( actor_id ) REFERENCES users ( "id" ) ON DELETE SET NULL,
+ CONSTRAINT CK_device_key_events_action CHECK ( action IN ('rotated', 'revoked') )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_device_key_events_device ON device_key_events
+(
+ device_id
+);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_devices_manager;
+DROP INDEX IF EXISTS idx_devices_project_status;
+
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS CK_devices_status;
+
+ALTER TABLE devices
+ DROP COLUMN IF EXISTS status,
+ DROP COLUMN IF EXISTS last_seen_at,
+ DROP COLUMN IF EXISTS connected_at,
+ DROP COLUMN IF EXISTS connection_id,
+ DROP COLUMN IF EXISTS manager_id,
+ DROP COLUMN IF EXISTS remote_addr,
+ DROP COLUMN IF EXISTS connection_metadata;
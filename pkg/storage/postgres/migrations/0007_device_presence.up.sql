@@ -0,0 +1,22 @@
+ALTER TABLE devices
+ ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'offline',
+ ADD COLUMN IF NOT EXISTS last_seen_at timestamptz NULL,
+ ADD COLUMN IF NOT EXISTS connected_at timestamptz NULL,
+ ADD COLUMN IF NOT EXISTS connection_id text NULL,
+ ADD COLUMN IF NOT EXISTS manager_id text NULL,
+ ADD COLUMN IF NOT EXISTS remote_addr text NULL,
+ ADD COLUMN IF NOT EXISTS connection_metadata jsonb NULL;
+
+ALTER TABLE devices
+ ADD CONSTRAINT CK_devices_status CHECK ( status IN ('online', 'offline') );
+
+CREATE INDEX IF NOT EXISTS idx_devices_project_status ON devices
+(
+ project_id,
+ status
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_manager ON devices
+(
+ manager_id
+);
@@ -0,0 +1,12 @@
+/* Device Shadows Table (desired vs reported device state) */
+CREATE TABLE IF NOT EXISTS device_shadows
+(
+ device_id           bigint NOT NULL,
+ desired             jsonb NOT NULL DEFAULT '{}',
+ reported            jsonb NOT NULL DEFAULT '{}',
+ version             bigint NOT NULL,
+ desired_updated_at  timestamptz NULL,
+ reported_updated_at timestamptz NULL,
+ CONSTRAINT PK_device_shadows PRIMARY KEY ( device_id ),
+ CONSTRAINT FK_device_shadows_devices FOREIGN KEY ( device_id ) REFERENCES devices ( "id" ) ON DELETE CASCADE
+);
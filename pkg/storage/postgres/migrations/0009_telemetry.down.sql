@@ -0,0 +1,2 @@
+/* Also drops all partitions */
+DROP TABLE IF EXISTS telemetry;
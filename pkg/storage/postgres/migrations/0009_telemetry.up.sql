@@ -0,0 +1,10 @@
+/* Telemetry Table (device datapoints partitioned by month)
+ Partitions (telemetry_yYYYYmMM) are created on demand by the telemetry repository
+ together with their indexes and device foreign keys. */
+CREATE TABLE IF NOT EXISTS telemetry
+(
+ device_id   bigint NOT NULL,
+ metric      varchar(128) NOT NULL,
+ value       double precision NOT NULL,
+ recorded_at timestamptz NOT NULL
+) PARTITION BY RANGE ( recorded_at );
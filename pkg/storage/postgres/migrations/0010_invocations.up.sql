@@ -0,0 +1,38 @@
+/* Invocations Table (asynchronous device invocations) */
+CREATE TABLE IF NOT EXISTS invocations
+(
+ "id"            bigserial NOT NULL,
+ device_id       bigint NOT NULL,
+ created_by      bigint NULL,
+ pattern         text NOT NULL,
+ request         text NOT NULL,
+ status          text NOT NULL,
+ attempts        integer NOT NULL DEFAULT 0,
+ response        text NULL,
+ error           text NULL,
+ created_at      timestamptz NOT NULL,
+ started_at      timestamptz NULL,
+ claimed_at      timestamptz NULL,
+ finished_at     timestamptz NULL,
+ next_attempt_at timestamptz NULL,
+ CONSTRAINT PK_invocations PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_invocations_devices FOREIGN KEY ( device_id ) REFERENCES devices ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_invocations_users FOREIGN KEY ( created_by ) REFERENCES users ( "id" ) ON DELETE SET NULL,
+ CONSTRAINT CK_invocations_status CHECK ( status IN ('queued', 'running', 'succeeded', 'failed') )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_invocations_device ON invocations
+(
+ device_id,
+ created_at DESC
+);
+
+CREATE INDEX IF NOT EXISTS idx_invocations_queued ON invocations
+(
+ next_attempt_at
+) WHERE status = 'queued';
+
+CREATE INDEX IF NOT EXISTS idx_invocations_running ON invocations
+(
+ claimed_at
+) WHERE status = 'running';
@@ -0,0 +1,5 @@
+ALTER TABLE invocations
+ DROP COLUMN IF EXISTS timeout_ms;
+
+ALTER TABLE endpoints
+ DROP COLUMN IF EXISTS timeout_ms;
@@ -0,0 +1,5 @@
+ALTER TABLE endpoints
+ ADD COLUMN IF NOT EXISTS timeout_ms bigint NULL;
+
+ALTER TABLE invocations
+ ADD COLUMN IF NOT EXISTS timeout_ms bigint NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE endpoints
+ DROP COLUMN IF EXISTS response_schema,
+ DROP COLUMN IF EXISTS request_schema,
+ DROP COLUMN IF EXISTS method;
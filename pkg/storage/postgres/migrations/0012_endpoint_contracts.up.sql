@@ -0,0 +1,4 @@
+ALTER TABLE endpoints
+ ADD COLUMN IF NOT EXISTS method varchar(10) NULL,
+ ADD COLUMN IF NOT EXISTS request_schema jsonb NULL,
+ ADD COLUMN IF NOT EXISTS response_schema jsonb NULL;
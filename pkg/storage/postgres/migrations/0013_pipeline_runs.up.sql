@@ -0,0 +1,23 @@
+/* Pipeline Runs Table (history of pipeline executions) */
+CREATE TABLE IF NOT EXISTS pipeline_runs
+(
+ "id"        bigserial NOT NULL,
+ pipeline_id bigint NOT NULL,
+ "trigger"   text NOT NULL,
+ payload     text NOT NULL,
+ status      text NOT NULL,
+ output      text NULL,
+ error       text NULL,
+ nodes       jsonb NOT NULL DEFAULT '[]',
+ started_at  timestamptz NOT NULL,
+ finished_at timestamptz NULL,
+ CONSTRAINT PK_pipeline_runs PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_pipeline_runs_pipelines FOREIGN KEY ( pipeline_id ) REFERENCES pipelines ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT CK_pipeline_runs_status CHECK ( status IN ('running', 'succeeded', 'failed') )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_pipeline_runs_pipeline ON pipeline_runs
+(
+ pipeline_id,
+ started_at DESC
+);
@@ -0,0 +1,29 @@
+/* Pipeline Schedules Table (cron triggers of pipelines) */
+CREATE TABLE IF NOT EXISTS pipeline_schedules
+(
+ "id"        bigserial NOT NULL,
+ pipeline_id bigint NOT NULL,
+ cron        text NOT NULL,
+ timezone    text NOT NULL DEFAULT 'UTC',
+ missed_runs text NOT NULL DEFAULT 'skip',
+ payload     text NOT NULL DEFAULT '',
+ enabled     boolean NOT NULL DEFAULT TRUE,
+ last_run_at timestamptz NULL,
+ next_run_at timestamptz NOT NULL,
+ created_at  timestamptz NOT NULL DEFAULT now(),
+ updated_at  timestamptz NULL,
+ CONSTRAINT PK_pipeline_schedules PRIMARY KEY ( "id" ),
+ CONSTRAINT FK_pipeline_schedules_pipelines FOREIGN KEY ( pipeline_id ) REFERENCES pipelines ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT CK_pipeline_schedules_missed_runs CHECK ( missed_runs IN ('skip', 'catch-up') )
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_pipeline_schedules_pipeline ON pipeline_schedules
+(
+ pipeline_id
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipeline_schedules_due ON pipeline_schedules
+(
+ next_run_at
+)
+WHERE enabled;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pipeline_webhook_deliveries;
+DROP TABLE IF EXISTS pipeline_webhooks;
@@ -0,0 +1,30 @@
+/* Pipeline Webhooks Table (webhook secrets and IP allowlists) */
+CREATE TABLE IF NOT EXISTS pipeline_webhooks
+(
+ pipeline_id                bigint NOT NULL,
+ secret                     text NOT NULL DEFAULT '',
+ previous_secret            text NULL,
+ previous_secret_expires_at timestamptz NULL,
+ allowed_ips                jsonb NOT NULL DEFAULT '[]',
+ created_at                 timestamptz NOT NULL DEFAULT now(),
+ updated_at                 timestamptz NULL,
+ CONSTRAINT PK_pipeline_webhooks PRIMARY KEY ( pipeline_id ),
+ CONSTRAINT FK_pipeline_webhooks_pipelines FOREIGN KEY ( pipeline_id ) REFERENCES pipelines ( "id" ) ON DELETE CASCADE
+);
+
+/* Pipeline Webhook Deliveries Table (idempotency keys of webhook requests) */
+CREATE TABLE IF NOT EXISTS pipeline_webhook_deliveries
+(
+ pipeline_id     bigint NOT NULL,
+ idempotency_key text NOT NULL,
+ run_id          bigint NULL,
+ created_at      timestamptz NOT NULL,
+ CONSTRAINT PK_pipeline_webhook_deliveries PRIMARY KEY ( pipeline_id, idempotency_key ),
+ CONSTRAINT FK_pipeline_webhook_deliveries_pipelines FOREIGN KEY ( pipeline_id ) REFERENCES pipelines ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_pipeline_webhook_deliveries_pipeline_runs FOREIGN KEY ( run_id ) REFERENCES pipeline_runs ( "id" ) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS fkIdx_pipeline_webhook_deliveries_run ON pipeline_webhook_deliveries
+(
+ run_id
+);
@@ -0,0 +1,7 @@
+ALTER TABLE pipeline_runs
+ DROP COLUMN IF EXISTS pipeline_version;
+
+DROP TABLE IF EXISTS pipeline_versions;
+
+ALTER TABLE pipelines
+ DROP COLUMN IF EXISTS version;
@@ -0,0 +1,26 @@
+ALTER TABLE pipelines
+ ADD COLUMN IF NOT EXISTS version int NOT NULL DEFAULT 1;
+
+/* Pipeline Versions Table (immutable revisions of pipelines) */
+CREATE TABLE IF NOT EXISTS pipeline_versions
+(
+ pipeline_id  bigint NOT NULL,
+ version      int NOT NULL,
+ display_name text NOT NULL,
+ description  text NULL,
+ data         text NOT NULL,
+ created_by   bigint NULL,
+ created_at   timestamptz NOT NULL DEFAULT now(),
+ CONSTRAINT PK_pipeline_versions PRIMARY KEY ( pipeline_id, version ),
+ CONSTRAINT FK_pipeline_versions_pipelines FOREIGN KEY ( pipeline_id ) REFERENCES pipelines ( "id" ) ON DELETE CASCADE,
+ CONSTRAINT FK_pipeline_versions_users FOREIGN KEY ( created_by ) REFERENCES users ( "id" ) ON DELETE SET NULL
+);
+
+/* Existing pipelines start at their current definition */
+INSERT INTO pipeline_versions (pipeline_id, version, display_name, description, data, created_by, created_at)
+SELECT "id", version, display_name, description, data, created_by, COALESCE(updated_at, created_at)
+FROM pipelines
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE pipeline_runs
+ ADD COLUMN IF NOT EXISTS pipeline_version int NULL;
@@ -19,7 +19,7 @@ func CreatePipelineRepository(db *sqlx.DB) pipelines.Repository {
 func (pR *PipelineRepository) GetByID(pipelineID int64) (*pipelines.Pipeline, error) {
 	const getByIDStmt = `
 	SELECT
-		id, project_id , display_name, data, description,created_at, updated_at, created_by
+		id, project_id , display_name, data, description,created_at, updated_at, created_by, version
 	FROM pipelines
 	WHERE id = $1`
 	
@@ -35,7 +35,7 @@ func (pR *PipelineRepository) GetByID(pipelineID int64) (*pipelines.Pipeline, er
 func (pR *PipelineRepository) GetByProjectID(projectID int64) ([]pipelines.Pipeline, error) {
 	const getByProjectIDStmt = `
 	SELECT
-		id, project_id , display_name, data, description,created_at, updated_at, created_by
+		id, project_id , display_name, data, description,created_at, updated_at, created_by, version
 	FROM pipelines
 	WHERE project_id = $1`
 	pipelinesSQL := []pipelineSQL{}
@@ -51,15 +51,35 @@ func (pR *PipelineRepository) GetByProjectID(projectID int64) ([]pipelines.Pipel
 	return pipelines, nil
 }
 
+func (pR *PipelineRepository) GetAll() ([]pipelines.Pipeline, error) {
+	const getAllStmt = `
+	SELECT
+		id, project_id, display_name, data, description, created_at, updated_at, created_by, version
+	FROM pipelines
+	ORDER BY id`
+	pipelinesSQL := []pipelineSQL{}
+	err := pR.db.Select(&pipelinesSQL, getAllStmt)
+	if err != nil {
+		return nil, err
+	}
+	pipelines := make([]pipelines.Pipeline, len(pipelinesSQL))
+	for i := 0; i < len(pipelinesSQL); i++ {
+		pipelines[i] = *toPipeline(pipelinesSQL[i])
+	}
+
+	return pipelines, nil
+}
+
 func (pR *PipelineRepository) Create(p pipelines.Pipeline) (*pipelines.Pipeline, error) {
 	p.CreatedAt = time.Now()
+	p.Version = 1
 	pipelineData := fromPipeline(p)
 
 	const createPipelineStmt = `
 	INSERT INTO pipelines (
-		project_id, display_name, data, description, created_at, created_by
+		project_id, display_name, data, description, created_at, created_by, version
 	) VALUES (
-		:project_id, :display_name, :data, :description, :created_at, :created_by
+		:project_id, :display_name, :data, :description, :created_at, :created_by, :version
 	) RETURNING id`
 
 	query, args, err := sqlx.Named(createPipelineStmt, pipelineData)
@@ -67,18 +87,30 @@ func (pR *PipelineRepository) Create(p pipelines.Pipeline) (*pipelines.Pipeline,
 		return nil, err
 	}
 	query = sqlx.Rebind(sqlx.DOLLAR, query)
-	err = pR.db.Get(&p.ID, query, args...)
+
+	tx, err := pR.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Get(&p.ID, query, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = insertPipelineVersion(tx, p, p.CreatedBy, p.CreatedAt)
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
-	return &p, nil
+	return &p, tx.Commit()
 }
 
+// Update bumps the version of the pipeline and records the new version
+// in the same transaction (the row lock orders concurrent updates)
 func (pR *PipelineRepository) Update(pipelineID int64, p pipelines.Pipeline) (*pipelines.Pipeline, error) {
 	p.ID = pipelineID
 	p.UpdatedAt = time.Now()
-	pipelineData := fromPipeline(p)
 
 	const updatePipelineStmt  = `
 	UPDATE pipelines
@@ -86,25 +118,62 @@ func (pR *PipelineRepository) Update(pipelineID int64, p pipelines.Pipeline) (*p
 		display_name 	= COALESCE(:display_name, display_name),
 		description 	= COALESCE(:description, description),
 		data 			= COALESCE(:data, data),
-		updated_at 		= :updated_at
-	WHERE id  = :id;`
+		updated_at 		= :updated_at,
+		version 		= version + 1
+	WHERE id  = :id
+	RETURNING
+		id, project_id, display_name, data, description, created_at, updated_at, created_by, version`
+
+	return pR.storeVersion(updatePipelineStmt, p)
+}
+
+func (pR *PipelineRepository) Replace(pipelineID int64, p pipelines.Pipeline) (*pipelines.Pipeline, error) {
+	p.ID = pipelineID
+	p.UpdatedAt = time.Now()
+
+	const replacePipelineStmt  = `
+	UPDATE pipelines
+	SET
+		display_name 	= :display_name,
+		description 	= :description,
+		data 			= :data,
+		updated_at 		= :updated_at,
+		version 		= version + 1
+	WHERE id  = :id
+	RETURNING
+		id, project_id, display_name, data, description, created_at, updated_at, created_by, version`
+
+	return pR.storeVersion(replacePipelineStmt, p)
+}
 
-	result, err := pR.db.NamedExec(updatePipelineStmt, pipelineData)
+// storeVersion runs the update statement of the pipeline and
+// records the updated pipeline as its next version
+func (pR *PipelineRepository) storeVersion(updateStmt string, p pipelines.Pipeline) (*pipelines.Pipeline, error) {
+	pipelineData := fromPipeline(p)
+	query, args, err := sqlx.Named(updateStmt, pipelineData)
 	if err != nil {
 		return nil, err
 	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected != 1 {
-		return nil, errors.New("Invalid ID")
+	tx, err := pR.db.Beginx()
+	if err != nil {
+		return nil, err
 	}
-
-	pipeline, err := pR.GetByID(pipelineID)
+	var updated pipelineSQL
+	err = tx.Get(&updated, query, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	pipeline := toPipeline(updated)
+	err = insertPipelineVersion(tx, *pipeline, p.UpdatedBy, p.UpdatedAt)
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
-	return pipeline, nil
+	return pipeline, tx.Commit()
 }
 
 func (pR *PipelineRepository) Delete(pipelineID int64) error {
@@ -134,6 +203,7 @@ type pipelineSQL struct {
 	CreatedBy   int64          `db:"created_by"`
 	CreatedAt   time.Time      `db:"created_at"`
 	UpdatedAt   sql.NullTime   `db:"updated_at"`
+	Version     int            `db:"version"`
 }
 
 func toPipeline(pSQL pipelineSQL) *pipelines.Pipeline {
@@ -146,6 +216,7 @@ func toPipeline(pSQL pipelineSQL) *pipelines.Pipeline {
 		CreatedBy:   pSQL.CreatedBy,
 		CreatedAt:   pSQL.CreatedAt,
 		UpdatedAt:   pSQL.UpdatedAt.Time,
+		Version:     pSQL.Version,
 	}
 }
 
@@ -154,6 +225,7 @@ func fromPipeline(p pipelines.Pipeline) *pipelineSQL {
 	pSQL.ID = p.ID
 	pSQL.CreatedBy = p.CreatedBy
 	pSQL.CreatedAt = p.CreatedAt
+	pSQL.Version = p.Version
 	pSQL.UpdatedAt = sql.NullTime{
 		Time:  p.UpdatedAt,
 		Valid: !p.UpdatedAt.IsZero(),
@@ -164,7 +236,7 @@ func fromPipeline(p pipelines.Pipeline) *pipelineSQL {
 	}
 	pSQL.Data = sql.NullString{
 		String: p.Data,
-		Valid:  (p.Data != "" || p.Data != "{}"),
+		Valid:  p.Data != "" && p.Data != "{}",
 	}
 	pSQL.ProjectID = sql.NullInt64{
 		Int64: p.ProjectID,
@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineRunRepository pipelines.RunRepository Postgres implementation
+type PipelineRunRepository struct {
+	db *sqlx.DB
+}
+
+// CreatePipelineRunRepository Create new instance of postgres.PipelineRunRepository
+func CreatePipelineRunRepository(db *sqlx.DB) pipelines.RunRepository {
+	return &PipelineRunRepository{db}
+}
+
+const pipelineRunColumns = `
+	id, pipeline_id, pipeline_version, "trigger", payload, status, output, error,
+	nodes, started_at, finished_at`
+
+func (rR *PipelineRunRepository) Create(run pipelines.Run) (*pipelines.Run, error) {
+	runData, err := fromPipelineRun(run)
+	if err != nil {
+		return nil, err
+	}
+
+	const insertRunStmt = `
+		INSERT INTO pipeline_runs (
+			pipeline_id, pipeline_version, "trigger", payload, status, nodes, started_at
+		) VALUES (
+			:pipeline_id, :pipeline_version, :trigger, :payload, :status, :nodes, :started_at
+		) RETURNING id`
+
+	query, args, err := sqlx.Named(insertRunStmt, runData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	err = rR.db.Get(&run.ID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (rR *PipelineRunRepository) GetByID(runID int64) (*pipelines.Run, error) {
+	getByIDStmt := `SELECT` + pipelineRunColumns + `
+		FROM pipeline_runs
+		WHERE id = $1`
+
+	var runData pipelineRunSQL
+	err := rR.db.Get(&runData, getByIDStmt, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineRun(runData)
+}
+
+func (rR *PipelineRunRepository) GetByPipelineID(pipelineID int64, limit int, offset int) ([]pipelines.Run, error) {
+	getByPipelineIDStmt := `SELECT` + pipelineRunColumns + `
+		FROM pipeline_runs
+		WHERE pipeline_id = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	runsSQL := []pipelineRunSQL{}
+	err := rR.db.Select(&runsSQL, getByPipelineIDStmt, pipelineID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]pipelines.Run, len(runsSQL))
+	for i := 0; i < len(runsSQL); i++ {
+		run, err := toPipelineRun(runsSQL[i])
+		if err != nil {
+			return nil, err
+		}
+		runs[i] = *run
+	}
+
+	return runs, nil
+}
+
+func (rR *PipelineRunRepository) Update(run pipelines.Run) error {
+	runData, err := fromPipelineRun(run)
+	if err != nil {
+		return err
+	}
+
+	const updateRunStmt = `
+		UPDATE pipeline_runs
+		SET
+			status = :status,
+			output = :output,
+			error = :error,
+			nodes = :nodes,
+			finished_at = :finished_at
+		WHERE id = :id`
+
+	result, err := rR.db.NamedExec(updateRunStmt, runData)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+type pipelineRunSQL struct {
+	ID         int64 `db:"id"`
+	PipelineID int64 `db:"pipeline_id"`
+	// PipelineVersion null for runs recorded before pipelines were versioned
+	PipelineVersion sql.NullInt32  `db:"pipeline_version"`
+	Trigger         string         `db:"trigger"`
+	Payload         string         `db:"payload"`
+	Status          string         `db:"status"`
+	Output          sql.NullString `db:"output"`
+	Error           sql.NullString `db:"error"`
+	// Nodes jsonb (written as text, []byte would be sent as bytea)
+	Nodes      string       `db:"nodes"`
+	StartedAt  time.Time    `db:"started_at"`
+	FinishedAt sql.NullTime `db:"finished_at"`
+}
+
+func toPipelineRun(rSQL pipelineRunSQL) (*pipelines.Run, error) {
+	nodes := []pipelines.NodeResult{}
+	if len(rSQL.Nodes) != 0 {
+		err := json.Unmarshal([]byte(rSQL.Nodes), &nodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pipelines.Run{
+		ID:              rSQL.ID,
+		PipelineID:      rSQL.PipelineID,
+		PipelineVersion: int(rSQL.PipelineVersion.Int32),
+		Trigger:         pipelines.Trigger(rSQL.Trigger),
+		Payload:         rSQL.Payload,
+		Status:          pipelines.RunStatus(rSQL.Status),
+		Output:          rSQL.Output.String,
+		Error:           rSQL.Error.String,
+		Nodes:           nodes,
+		StartedAt:       rSQL.StartedAt,
+		FinishedAt:      rSQL.FinishedAt.Time,
+	}, nil
+}
+
+func fromPipelineRun(run pipelines.Run) (*pipelineRunSQL, error) {
+	nodes := run.Nodes
+	if nodes == nil {
+		nodes = []pipelines.NodeResult{}
+	}
+	nodesJSON, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipelineRunSQL{
+		ID:              run.ID,
+		PipelineID:      run.PipelineID,
+		PipelineVersion: sql.NullInt32{Int32: int32(run.PipelineVersion), Valid: run.PipelineVersion != 0},
+		Trigger:         string(run.Trigger),
+		Payload:         run.Payload,
+		Status:          string(run.Status),
+		Output:          sql.NullString{String: run.Output, Valid: run.Output != ""},
+		Error:           sql.NullString{String: run.Error, Valid: run.Error != ""},
+		Nodes:           string(nodesJSON),
+		StartedAt:       run.StartedAt,
+		FinishedAt:      sql.NullTime{Time: run.FinishedAt, Valid: !run.FinishedAt.IsZero()},
+	}, nil
+}
@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineScheduleRepository pipelines.ScheduleRepository Postgres implementation
+type PipelineScheduleRepository struct {
+	db *sqlx.DB
+}
+
+// CreatePipelineScheduleRepository Create new instance of postgres.PipelineScheduleRepository
+func CreatePipelineScheduleRepository(db *sqlx.DB) pipelines.ScheduleRepository {
+	return &PipelineScheduleRepository{db}
+}
+
+const pipelineScheduleColumns = `
+	id, pipeline_id, cron, timezone, missed_runs, payload, enabled,
+	last_run_at, next_run_at, created_at, updated_at`
+
+func (sR *PipelineScheduleRepository) Create(sch pipelines.Schedule) (*pipelines.Schedule, error) {
+	scheduleData := fromPipelineSchedule(sch)
+
+	const insertScheduleStmt = `
+		INSERT INTO pipeline_schedules (
+			pipeline_id, cron, timezone, missed_runs, payload, enabled, next_run_at
+		) VALUES (
+			:pipeline_id, :cron, :timezone, :missed_runs, :payload, :enabled, :next_run_at
+		) RETURNING` + pipelineScheduleColumns
+
+	query, args, err := sqlx.Named(insertScheduleStmt, scheduleData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	var created pipelineScheduleSQL
+	err = sR.db.Get(&created, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineSchedule(created), nil
+}
+
+func (sR *PipelineScheduleRepository) GetByID(scheduleID int64) (*pipelines.Schedule, error) {
+	getByIDStmt := `SELECT` + pipelineScheduleColumns + `
+		FROM pipeline_schedules
+		WHERE id = $1`
+
+	var scheduleData pipelineScheduleSQL
+	err := sR.db.Get(&scheduleData, getByIDStmt, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineSchedule(scheduleData), nil
+}
+
+func (sR *PipelineScheduleRepository) GetByPipelineID(pipelineID int64) ([]pipelines.Schedule, error) {
+	getByPipelineIDStmt := `SELECT` + pipelineScheduleColumns + `
+		FROM pipeline_schedules
+		WHERE pipeline_id = $1
+		ORDER BY id`
+
+	return sR.selectSchedules(getByPipelineIDStmt, pipelineID)
+}
+
+func (sR *PipelineScheduleRepository) Update(sch pipelines.Schedule) (*pipelines.Schedule, error) {
+	scheduleData := fromPipelineSchedule(sch)
+
+	const updateScheduleStmt = `
+		UPDATE pipeline_schedules
+		SET
+			cron = :cron,
+			timezone = :timezone,
+			missed_runs = :missed_runs,
+			payload = :payload,
+			enabled = :enabled,
+			next_run_at = :next_run_at,
+			updated_at = now()
+		WHERE id = :id
+		RETURNING` + pipelineScheduleColumns
+
+	query, args, err := sqlx.Named(updateScheduleStmt, scheduleData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	var updated pipelineScheduleSQL
+	err = sR.db.Get(&updated, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineSchedule(updated), nil
+}
+
+func (sR *PipelineScheduleRepository) Delete(scheduleID int64) error {
+	const deleteScheduleStmt = `DELETE FROM pipeline_schedules WHERE id = $1`
+
+	result, err := sR.db.Exec(deleteScheduleStmt, scheduleID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (sR *PipelineScheduleRepository) GetDue(now time.Time, limit int) ([]pipelines.Schedule, error) {
+	getDueStmt := `SELECT` + pipelineScheduleColumns + `
+		FROM pipeline_schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT $2`
+
+	return sR.selectSchedules(getDueStmt, now, limit)
+}
+
+func (sR *PipelineScheduleRepository) Advance(scheduleID int64, dueAt time.Time, lastRunAt time.Time, nextRunAt time.Time) error {
+	const advanceStmt = `
+		UPDATE pipeline_schedules
+		SET
+			last_run_at = $3,
+			next_run_at = $4
+		WHERE id = $1 AND next_run_at = $2`
+
+	lastRun := sql.NullTime{Time: lastRunAt, Valid: !lastRunAt.IsZero()}
+	result, err := sR.db.Exec(advanceStmt, scheduleID, dueAt, lastRun, nextRunAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != 1 {
+		return pipelines.ErrScheduleMoved
+	}
+
+	return nil
+}
+
+func (sR *PipelineScheduleRepository) selectSchedules(stmt string, args ...interface{}) ([]pipelines.Schedule, error) {
+	schedulesSQL := []pipelineScheduleSQL{}
+	err := sR.db.Select(&schedulesSQL, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make([]pipelines.Schedule, len(schedulesSQL))
+	for i := 0; i < len(schedulesSQL); i++ {
+		schedules[i] = *toPipelineSchedule(schedulesSQL[i])
+	}
+
+	return schedules, nil
+}
+
+type pipelineScheduleSQL struct {
+	ID         int64        `db:"id"`
+	PipelineID int64        `db:"pipeline_id"`
+	Cron       string       `db:"cron"`
+	Timezone   string       `db:"timezone"`
+	MissedRuns string       `db:"missed_runs"`
+	Payload    string       `db:"payload"`
+	Enabled    bool         `db:"enabled"`
+	LastRunAt  sql.NullTime `db:"last_run_at"`
+	NextRunAt  time.Time    `db:"next_run_at"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  sql.NullTime `db:"updated_at"`
+}
+
+func toPipelineSchedule(sSQL pipelineScheduleSQL) *pipelines.Schedule {
+	return &pipelines.Schedule{
+		ID:         sSQL.ID,
+		PipelineID: sSQL.PipelineID,
+		Cron:       sSQL.Cron,
+		Timezone:   sSQL.Timezone,
+		MissedRuns: pipelines.MissedRunPolicy(sSQL.MissedRuns),
+		Payload:    sSQL.Payload,
+		Enabled:    sSQL.Enabled,
+		LastRunAt:  sSQL.LastRunAt.Time,
+		NextRunAt:  sSQL.NextRunAt,
+		CreatedAt:  sSQL.CreatedAt,
+		UpdatedAt:  sSQL.UpdatedAt.Time,
+	}
+}
+
+func fromPipelineSchedule(sch pipelines.Schedule) *pipelineScheduleSQL {
+	return &pipelineScheduleSQL{
+		ID:         sch.ID,
+		PipelineID: sch.PipelineID,
+		Cron:       sch.Cron,
+		Timezone:   sch.Timezone,
+		MissedRuns: string(sch.MissedRuns),
+		Payload:    sch.Payload,
+		Enabled:    sch.Enabled,
+		LastRunAt:  sql.NullTime{Time: sch.LastRunAt, Valid: !sch.LastRunAt.IsZero()},
+		NextRunAt:  sch.NextRunAt,
+		CreatedAt:  sch.CreatedAt,
+		UpdatedAt:  sql.NullTime{Time: sch.UpdatedAt, Valid: !sch.UpdatedAt.IsZero()},
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineVersionRepository pipelines.VersionRepository Postgres implementation
+// (versions are inserted by PipelineRepository)
+type PipelineVersionRepository struct {
+	db *sqlx.DB
+}
+
+// CreatePipelineVersionRepository Create new instance of postgres.PipelineVersionRepository
+func CreatePipelineVersionRepository(db *sqlx.DB) pipelines.VersionRepository {
+	return &PipelineVersionRepository{db}
+}
+
+const pipelineVersionColumns = `
+	pipeline_id, version, display_name, description, data, created_by, created_at`
+
+func (vR *PipelineVersionRepository) GetByPipelineID(pipelineID int64, limit int, offset int) ([]pipelines.Version, error) {
+	getByPipelineIDStmt := `SELECT` + pipelineVersionColumns + `
+		FROM pipeline_versions
+		WHERE pipeline_id = $1
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3`
+
+	versionsSQL := []pipelineVersionSQL{}
+	err := vR.db.Select(&versionsSQL, getByPipelineIDStmt, pipelineID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]pipelines.Version, len(versionsSQL))
+	for i := 0; i < len(versionsSQL); i++ {
+		versions[i] = *toPipelineVersion(versionsSQL[i])
+	}
+
+	return versions, nil
+}
+
+func (vR *PipelineVersionRepository) GetByVersion(pipelineID int64, version int) (*pipelines.Version, error) {
+	getByVersionStmt := `SELECT` + pipelineVersionColumns + `
+		FROM pipeline_versions
+		WHERE pipeline_id = $1 AND version = $2`
+
+	var versionData pipelineVersionSQL
+	err := vR.db.Get(&versionData, getByVersionStmt, pipelineID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineVersion(versionData), nil
+}
+
+// insertPipelineVersion records the current state of the pipeline as its latest version
+func insertPipelineVersion(tx *sqlx.Tx, p pipelines.Pipeline, createdBy int64, createdAt time.Time) error {
+	const insertVersionStmt = `
+		INSERT INTO pipeline_versions (
+			pipeline_id, version, display_name, description, data, created_by, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7
+		)`
+
+	_, err := tx.Exec(insertVersionStmt,
+		p.ID, p.Version, p.DisplayName,
+		sql.NullString{String: p.Description, Valid: p.Description != ""},
+		p.Data,
+		sql.NullInt64{Int64: createdBy, Valid: createdBy != 0},
+		createdAt,
+	)
+	return err
+}
+
+type pipelineVersionSQL struct {
+	PipelineID  int64          `db:"pipeline_id"`
+	Version     int            `db:"version"`
+	DisplayName string         `db:"display_name"`
+	Description sql.NullString `db:"description"`
+	Data        string         `db:"data"`
+	CreatedBy   sql.NullInt64  `db:"created_by"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+func toPipelineVersion(vSQL pipelineVersionSQL) *pipelines.Version {
+	return &pipelines.Version{
+		PipelineID:  vSQL.PipelineID,
+		Version:     vSQL.Version,
+		DisplayName: vSQL.DisplayName,
+		Description: vSQL.Description.String,
+		Data:        vSQL.Data,
+		CreatedBy:   vSQL.CreatedBy.Int64,
+		CreatedAt:   vSQL.CreatedAt,
+	}
+}
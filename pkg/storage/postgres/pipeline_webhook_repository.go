@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// PipelineWebhookRepository pipelines.WebhookRepository Postgres implementation
+type PipelineWebhookRepository struct {
+	db *sqlx.DB
+}
+
+// CreatePipelineWebhookRepository Create new instance of postgres.PipelineWebhookRepository
+func CreatePipelineWebhookRepository(db *sqlx.DB) pipelines.WebhookRepository {
+	return &PipelineWebhookRepository{db}
+}
+
+const pipelineWebhookColumns = `
+	pipeline_id, secret, previous_secret, previous_secret_expires_at,
+	allowed_ips, created_at, updated_at`
+
+func (wR *PipelineWebhookRepository) Get(pipelineID int64) (*pipelines.Webhook, error) {
+	getStmt := `SELECT` + pipelineWebhookColumns + `
+		FROM pipeline_webhooks
+		WHERE pipeline_id = $1`
+
+	var webhookData pipelineWebhookSQL
+	err := wR.db.Get(&webhookData, getStmt, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineWebhook(webhookData)
+}
+
+func (wR *PipelineWebhookRepository) Save(wh pipelines.Webhook) (*pipelines.Webhook, error) {
+	webhookData, err := fromPipelineWebhook(wh)
+	if err != nil {
+		return nil, err
+	}
+
+	const saveWebhookStmt = `
+		INSERT INTO pipeline_webhooks (
+			pipeline_id, secret, previous_secret, previous_secret_expires_at, allowed_ips
+		) VALUES (
+			:pipeline_id, :secret, :previous_secret, :previous_secret_expires_at, :allowed_ips
+		) ON CONFLICT (pipeline_id) DO UPDATE
+		SET
+			secret = excluded.secret,
+			previous_secret = excluded.previous_secret,
+			previous_secret_expires_at = excluded.previous_secret_expires_at,
+			allowed_ips = excluded.allowed_ips,
+			updated_at = now()
+		RETURNING` + pipelineWebhookColumns
+
+	query, args, err := sqlx.Named(saveWebhookStmt, webhookData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	var saved pipelineWebhookSQL
+	err = wR.db.Get(&saved, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPipelineWebhook(saved)
+}
+
+func (wR *PipelineWebhookRepository) ClaimDelivery(d pipelines.WebhookDelivery, expiredBefore time.Time) (*pipelines.WebhookDelivery, bool, error) {
+	// Forget the expired deliveries of the pipeline
+	const deleteExpiredStmt = `
+		DELETE FROM pipeline_webhook_deliveries
+		WHERE pipeline_id = $1 AND created_at < $2`
+
+	_, err := wR.db.Exec(deleteExpiredStmt, d.PipelineID, expiredBefore)
+	if err != nil {
+		return nil, false, err
+	}
+
+	const claimStmt = `
+		INSERT INTO pipeline_webhook_deliveries (
+			pipeline_id, idempotency_key, created_at
+		) VALUES (
+			$1, $2, $3
+		) ON CONFLICT (pipeline_id, idempotency_key) DO NOTHING
+		RETURNING pipeline_id, idempotency_key, run_id, created_at`
+
+	var deliveryData webhookDeliverySQL
+	err = wR.db.Get(&deliveryData, claimStmt, d.PipelineID, d.IdempotencyKey, d.CreatedAt)
+	if err == nil {
+		return toWebhookDelivery(deliveryData), true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	const getStmt = `
+		SELECT pipeline_id, idempotency_key, run_id, created_at
+		FROM pipeline_webhook_deliveries
+		WHERE pipeline_id = $1 AND idempotency_key = $2`
+
+	err = wR.db.Get(&deliveryData, getStmt, d.PipelineID, d.IdempotencyKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released in the meantime, report it as in progress
+		return &d, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return toWebhookDelivery(deliveryData), false, nil
+}
+
+func (wR *PipelineWebhookRepository) CompleteDelivery(pipelineID int64, idempotencyKey string, runID int64) error {
+	const completeStmt = `
+		UPDATE pipeline_webhook_deliveries
+		SET run_id = $3
+		WHERE pipeline_id = $1 AND idempotency_key = $2`
+
+	result, err := wR.db.Exec(completeStmt, pipelineID, idempotencyKey, runID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (wR *PipelineWebhookRepository) ReleaseDelivery(pipelineID int64, idempotencyKey string) error {
+	const releaseStmt = `
+		DELETE FROM pipeline_webhook_deliveries
+		WHERE pipeline_id = $1 AND idempotency_key = $2`
+
+	result, err := wR.db.Exec(releaseStmt, pipelineID, idempotencyKey)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+type pipelineWebhookSQL struct {
+	PipelineID              int64          `db:"pipeline_id"`
+	Secret                  string         `db:"secret"`
+	PreviousSecret          sql.NullString `db:"previous_secret"`
+	PreviousSecretExpiresAt sql.NullTime   `db:"previous_secret_expires_at"`
+	// AllowedIPs jsonb (written as text, []byte would be sent as bytea)
+	AllowedIPs string       `db:"allowed_ips"`
+	CreatedAt  time.Time    `db:"created_at"`
+	UpdatedAt  sql.NullTime `db:"updated_at"`
+}
+
+func toPipelineWebhook(wSQL pipelineWebhookSQL) (*pipelines.Webhook, error) {
+	allowedIPs := []string{}
+	if len(wSQL.AllowedIPs) != 0 {
+		err := json.Unmarshal([]byte(wSQL.AllowedIPs), &allowedIPs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pipelines.Webhook{
+		PipelineID:              wSQL.PipelineID,
+		Secret:                  wSQL.Secret,
+		PreviousSecret:          wSQL.PreviousSecret.String,
+		PreviousSecretExpiresAt: wSQL.PreviousSecretExpiresAt.Time,
+		AllowedIPs:              allowedIPs,
+		CreatedAt:               wSQL.CreatedAt,
+		UpdatedAt:               wSQL.UpdatedAt.Time,
+	}, nil
+}
+
+func fromPipelineWebhook(wh pipelines.Webhook) (*pipelineWebhookSQL, error) {
+	allowedIPs := wh.AllowedIPs
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+	allowedIPsJSON, err := json.Marshal(allowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pipelineWebhookSQL{
+		PipelineID:              wh.PipelineID,
+		Secret:                  wh.Secret,
+		PreviousSecret:          sql.NullString{String: wh.PreviousSecret, Valid: wh.PreviousSecret != ""},
+		PreviousSecretExpiresAt: sql.NullTime{Time: wh.PreviousSecretExpiresAt, Valid: !wh.PreviousSecretExpiresAt.IsZero()},
+		AllowedIPs:              string(allowedIPsJSON),
+	}, nil
+}
+
+type webhookDeliverySQL struct {
+	PipelineID     int64         `db:"pipeline_id"`
+	IdempotencyKey string        `db:"idempotency_key"`
+	RunID          sql.NullInt64 `db:"run_id"`
+	CreatedAt      time.Time     `db:"created_at"`
+}
+
+func toWebhookDelivery(dSQL webhookDeliverySQL) *pipelines.WebhookDelivery {
+	return &pipelines.WebhookDelivery{
+		PipelineID:     dSQL.PipelineID,
+		IdempotencyKey: dSQL.IdempotencyKey,
+		RunID:          dSQL.RunID.Int64,
+		CreatedAt:      dSQL.CreatedAt,
+	}
+}
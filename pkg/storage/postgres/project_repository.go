@@ -3,6 +3,7 @@ package postgres
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -74,29 +75,122 @@ func (pR *ProjectRepository) Create(p projects.Project) (*projects.Project, erro
 	}
 	
 	const insertCollabStmt = `
-	INSERT INTO collaborators (project_id, user_id)
-	VALUES ($1, $2)
+	INSERT INTO collaborators (project_id, user_id, role)
+	VALUES ($1, $2, $3)
 	RETURNING id`
-	_, err = pR.db.Exec(insertCollabStmt, &p.ID, &p.CreatedBy)
+	_, err = pR.db.Exec(insertCollabStmt, &p.ID, &p.CreatedBy, projects.RoleOwner)
 	if err != nil {
 		return nil, err
 	}
 
 	return &p, nil
 }
-func (pR *ProjectRepository) AddCollaborator(userID int64, projectID int64) (error) {
+func (pR *ProjectRepository) AddCollaborator(userID int64, projectID int64, role projects.Role) error {
 	const insertCollabStmt = `
-	INSERT INTO collaborators (project_id, user_id)
-	VALUES ($1, $2)
+	INSERT INTO collaborators (project_id, user_id, role, created_at)
+	VALUES ($1, $2, $3, $4)
 	RETURNING id`
 
-	_, err := pR.db.Exec(insertCollabStmt, projectID, userID)
+	_, err := pR.db.Exec(insertCollabStmt, projectID, userID, role, time.Now())
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (pR *ProjectRepository) GetCollaborators(projectID int64) ([]projects.Collaborator, error) {
+	const selectCollabsStmt = `
+	SELECT
+		c.project_id, c.user_id, c.role, c.created_at,
+		u.name, u.display_name, u.email
+	FROM collaborators c JOIN users u ON u.id = c.user_id
+	WHERE c.project_id = $1
+	ORDER BY c.created_at`
+
+	collaboratorsSQL := []collaboratorSQL{}
+	err := pR.db.Select(&collaboratorsSQL, selectCollabsStmt, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	collaborators := make([]projects.Collaborator, len(collaboratorsSQL))
+	for i := 0; i < len(collaboratorsSQL); i++ {
+		collaborators[i] = *toCollaborator(collaboratorsSQL[i])
+	}
+
+	return collaborators, nil
+}
+
+func (pR *ProjectRepository) UpdateCollaboratorRole(userID int64, projectID int64, role projects.Role) error {
+	const updateRoleStmt = `
+	UPDATE collaborators
+	SET role = $3
+	WHERE project_id = $1 AND user_id = $2`
+
+	result, err := pR.db.Exec(updateRoleStmt, projectID, userID, role)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (pR *ProjectRepository) RemoveCollaborator(userID int64, projectID int64) error {
+	const deleteCollabStmt = `
+	DELETE FROM collaborators
+	WHERE project_id = $1 AND user_id = $2`
+
+	result, err := pR.db.Exec(deleteCollabStmt, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (pR *ProjectRepository) TransferOwnership(projectID int64, fromUserID int64, toUserID int64) error {
+	tx, err := pR.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	const updateRoleStmt = `
+	UPDATE collaborators
+	SET role = $3
+	WHERE project_id = $1 AND user_id = $2`
+
+	changes := []struct {
+		userID int64
+		role   projects.Role
+	}{{fromUserID, projects.RoleAdmin}, {toUserID, projects.RoleOwner}}
+	for _, change := range changes {
+		result, err := tx.Exec(updateRoleStmt, projectID, change.userID, change.role)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil || rowsAffected != 1 {
+			tx.Rollback()
+			return errors.New("Invalid ID")
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (pR *ProjectRepository) Update(projectID int64, p projects.Project) (*projects.Project, error) {
 	p.ID = projectID
 	p.UpdatedAt = time.Now()
@@ -129,35 +223,94 @@ func (pR *ProjectRepository) Update(projectID int64, p projects.Project) (*proje
 }
 
 func (pR *ProjectRepository) Delete(projectID int64) error {
+	tx, err := pR.db.Beginx()
+	if err != nil {
+		return err
+	}
+
 	const deleteCollabStmt = `
 		DELETE FROM collaborators
 		WHERE project_id = $1`
 
-	result, err := pR.db.Exec(deleteCollabStmt, projectID)
+	_, err = tx.Exec(deleteCollabStmt, projectID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected != 1 {
-		return errors.New("Invalid ID")
-	}
-	
 	const deleteProjectStmt = `
 		DELETE FROM projects
 		WHERE id = $1`
 
-	result, err = pR.db.Exec(deleteProjectStmt, projectID)
+	result, err := tx.Exec(deleteProjectStmt, projectID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	rowsAffected, err = result.RowsAffected()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil || rowsAffected != 1 {
+		tx.Rollback()
 		return errors.New("Invalid ID")
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+func (pR *ProjectRepository) GetRole(userID int64, projectID int64) (projects.Role, error) {
+	const getRoleStmt = `
+	SELECT role FROM collaborators
+	WHERE project_id = $1 AND user_id = $2`
+
+	var role string
+	err := pR.db.Get(&role, getRoleStmt, projectID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return projects.Role(role), nil
+}
+
+func (pR *ProjectRepository) GetResourceProjectID(resource projects.Resource) (int64, error) {
+	var getProjectIDStmt string
+	switch resource.Type {
+	case projects.ProjectResource:
+		getProjectIDStmt = `SELECT id FROM projects WHERE id = $1`
+	case projects.DeviceResource:
+		getProjectIDStmt = `SELECT project_id FROM devices WHERE id = $1`
+	case projects.EndpointResource:
+		getProjectIDStmt = `
+		SELECT d.project_id
+		FROM endpoints e JOIN devices d ON d.id = e.device_id
+		WHERE e.id = $1`
+	case projects.PipelineResource:
+		getProjectIDStmt = `SELECT project_id FROM pipelines WHERE id = $1`
+	case projects.InvocationResource:
+		getProjectIDStmt = `
+		SELECT d.project_id
+		FROM invocations i JOIN devices d ON d.id = i.device_id
+		WHERE i.id = $1`
+	case projects.RunResource:
+		getProjectIDStmt = `
+		SELECT p.project_id
+		FROM pipeline_runs r JOIN pipelines p ON p.id = r.pipeline_id
+		WHERE r.id = $1`
+	case projects.ScheduleResource:
+		getProjectIDStmt = `
+		SELECT p.project_id
+		FROM pipeline_schedules s JOIN pipelines p ON p.id = s.pipeline_id
+		WHERE s.id = $1`
+	default:
+		return 0, fmt.Errorf("unknown resource type (%s)", resource.Type)
+	}
+
+	var projectID int64
+	err := pR.db.Get(&projectID, getProjectIDStmt, resource.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return projectID, nil
 }
 
 type projectSQL struct {
@@ -200,3 +353,25 @@ func fromProject(p projects.Project) *projectSQL {
 	
 	return &pSQL
 }
+
+type collaboratorSQL struct {
+	ProjectID   int64          `db:"project_id"`
+	UserID      int64          `db:"user_id"`
+	Role        string         `db:"role"`
+	CreatedAt   time.Time      `db:"created_at"`
+	Name        string         `db:"name"`
+	DisplayName sql.NullString `db:"display_name"`
+	Email       sql.NullString `db:"email"`
+}
+
+func toCollaborator(cSQL collaboratorSQL) *projects.Collaborator {
+	return &projects.Collaborator{
+		ProjectID:   cSQL.ProjectID,
+		UserID:      cSQL.UserID,
+		Role:        projects.Role(cSQL.Role),
+		CreatedAt:   cSQL.CreatedAt,
+		Name:        cSQL.Name,
+		DisplayName: cSQL.DisplayName.String,
+		Email:       cSQL.Email.String,
+	}
+}
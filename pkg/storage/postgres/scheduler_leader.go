@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+)
+
+// schedulerLockID key of the advisory lock held by the scheduler leader
+const schedulerLockID = 0x7779726d0001
+
+// SchedulerLeader pipelines.Leader Postgres implementation. The leader holds
+// a session level advisory lock, so the lead is released as soon as its
+// connection drops (e.g. the replica dies).
+type SchedulerLeader struct {
+	db *sqlx.DB
+
+	mu sync.Mutex
+	// conn session holding the lock (nil if not leading)
+	conn *sql.Conn
+}
+
+// CreateSchedulerLeader Create new instance of postgres.SchedulerLeader
+func CreateSchedulerLeader(db *sqlx.DB) pipelines.Leader {
+	return &SchedulerLeader{db: db}
+}
+
+func (l *SchedulerLeader) IsLeader(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		err := l.conn.PingContext(ctx)
+		if err == nil {
+			return true, nil
+		}
+		// The session (and the lock with it) is gone
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, schedulerLockID).Scan(&locked)
+	if err != nil || !locked {
+		conn.Close()
+		return false, err
+	}
+
+	l.conn = conn
+	return true, nil
+}
@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/sessions"
+)
+
+// SessionRepository sessions.Repository Postgres implementation
+type SessionRepository struct {
+	db *sqlx.DB
+}
+
+// CreateSessionRepository Create new instance of postgres.SessionRepository
+func CreateSessionRepository(db *sqlx.DB) sessions.Repository {
+	return &SessionRepository{db}
+}
+
+func (sR *SessionRepository) GetByID(sessionID int64) (*sessions.Session, error) {
+	const getByIDStmt = `
+		SELECT
+			id, user_id, token_hash, user_agent, ip, created_at,
+			last_used_at, rotated_at, expires_at, revoked_at,
+			previous_token_hash, previous_token_expires_at
+		FROM sessions
+		WHERE id = $1`
+
+	var sessionData sessionSQL
+	err := sR.db.Get(&sessionData, getByIDStmt, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toSession(sessionData), nil
+}
+
+func (sR *SessionRepository) GetByTokenHash(tokenHash string) (*sessions.Session, error) {
+	const getByTokenHashStmt = `
+		SELECT
+			id, user_id, token_hash, user_agent, ip, created_at,
+			last_used_at, rotated_at, expires_at, revoked_at,
+			previous_token_hash, previous_token_expires_at
+		FROM sessions
+		WHERE token_hash = $1 OR previous_token_hash = $1`
+
+	var sessionData sessionSQL
+	err := sR.db.Get(&sessionData, getByTokenHashStmt, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toSession(sessionData), nil
+}
+
+func (sR *SessionRepository) GetActiveByUserID(userID int64, now time.Time) ([]sessions.Session, error) {
+	const getActiveStmt = `
+		SELECT
+			id, user_id, token_hash, user_agent, ip, created_at,
+			last_used_at, rotated_at, expires_at, revoked_at,
+			previous_token_hash, previous_token_expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC`
+
+	sessionsSQL := []sessionSQL{}
+	err := sR.db.Select(&sessionsSQL, getActiveStmt, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	userSessions := make([]sessions.Session, len(sessionsSQL))
+	for i := 0; i < len(sessionsSQL); i++ {
+		userSessions[i] = *toSession(sessionsSQL[i])
+	}
+
+	return userSessions, nil
+}
+
+func (sR *SessionRepository) Create(s sessions.Session) (*sessions.Session, error) {
+	sessionData := fromSession(s)
+
+	const insertSessionStmt = `
+		INSERT INTO sessions (
+			user_id, token_hash, user_agent, ip, created_at,
+			last_used_at, rotated_at, expires_at
+		) VALUES (
+			:user_id, :token_hash, :user_agent, :ip, :created_at,
+			:last_used_at, :rotated_at, :expires_at
+		) RETURNING id`
+
+	query, args, err := sqlx.Named(insertSessionStmt, sessionData)
+	if err != nil {
+		return nil, err
+	}
+	query = sqlx.Rebind(sqlx.DOLLAR, query)
+
+	err = sR.db.Get(&s.ID, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (sR *SessionRepository) Update(sessionID int64, s sessions.Session) (*sessions.Session, error) {
+	s.ID = sessionID
+	sessionData := fromSession(s)
+
+	const updateSessionStmt = `
+		UPDATE sessions
+		SET
+			last_used_at	= COALESCE(:last_used_at, last_used_at),
+			expires_at		= COALESCE(:expires_at, expires_at)
+		WHERE id = :id AND revoked_at IS NULL;`
+
+	result, err := sR.db.NamedExec(updateSessionStmt, sessionData)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return nil, errors.New("Invalid ID")
+	}
+
+	return sR.GetByID(sessionID)
+}
+
+func (sR *SessionRepository) Rotate(sessionID int64, oldTokenHash string, s sessions.Session) (*sessions.Session, error) {
+	s.ID = sessionID
+	s.PreviousTokenHash = oldTokenHash
+	sessionData := fromSession(s)
+
+	// The token hash condition makes concurrent rotations
+	// of the session fail but the first one
+	const rotateSessionStmt = `
+		UPDATE sessions
+		SET
+			token_hash					= :token_hash,
+			previous_token_hash			= :previous_token_hash,
+			previous_token_expires_at	= :previous_token_expires_at,
+			last_used_at				= :last_used_at,
+			rotated_at					= :rotated_at,
+			expires_at					= :expires_at
+		WHERE id = :id AND token_hash = :previous_token_hash AND revoked_at IS NULL;`
+
+	result, err := sR.db.NamedExec(rotateSessionStmt, sessionData)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected != 1 {
+		return nil, sessions.ErrTokenRotated
+	}
+
+	return sR.GetByID(sessionID)
+}
+
+func (sR *SessionRepository) Revoke(sessionID int64, revokedAt time.Time) error {
+	const revokeStmt = `
+		UPDATE sessions
+		SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := sR.db.Exec(revokeStmt, sessionID, revokedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return errors.New("Invalid ID")
+	}
+
+	return nil
+}
+
+func (sR *SessionRepository) RevokeByUserID(userID int64, revokedAt time.Time) error {
+	const revokeAllStmt = `
+		UPDATE sessions
+		SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := sR.db.Exec(revokeAllStmt, userID, revokedAt)
+	return err
+}
+
+type sessionSQL struct {
+	ID         int64          `db:"id"`
+	UserID     int64          `db:"user_id"`
+	TokenHash  sql.NullString `db:"token_hash"`
+	UserAgent  sql.NullString `db:"user_agent"`
+	IP         sql.NullString `db:"ip"`
+	CreatedAt  time.Time      `db:"created_at"`
+	LastUsedAt sql.NullTime   `db:"last_used_at"`
+	RotatedAt  sql.NullTime   `db:"rotated_at"`
+	ExpiresAt  sql.NullTime   `db:"expires_at"`
+	RevokedAt  sql.NullTime   `db:"revoked_at"`
+
+	PreviousTokenHash      sql.NullString `db:"previous_token_hash"`
+	PreviousTokenExpiresAt sql.NullTime   `db:"previous_token_expires_at"`
+}
+
+func toSession(sSQL sessionSQL) *sessions.Session {
+	return &sessions.Session{
+		ID:         sSQL.ID,
+		UserID:     sSQL.UserID,
+		TokenHash:  sSQL.TokenHash.String,
+		UserAgent:  sSQL.UserAgent.String,
+		IP:         sSQL.IP.String,
+		CreatedAt:  sSQL.CreatedAt,
+		LastUsedAt: sSQL.LastUsedAt.Time,
+		RotatedAt:  sSQL.RotatedAt.Time,
+		ExpiresAt:  sSQL.ExpiresAt.Time,
+		RevokedAt:  sSQL.RevokedAt.Time,
+
+		PreviousTokenHash:      sSQL.PreviousTokenHash.String,
+		PreviousTokenExpiresAt: sSQL.PreviousTokenExpiresAt.Time,
+	}
+}
+
+func fromSession(s sessions.Session) *sessionSQL {
+	return &sessionSQL{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		TokenHash:  sql.NullString{String: s.TokenHash, Valid: s.TokenHash != ""},
+		UserAgent:  sql.NullString{String: s.UserAgent, Valid: s.UserAgent != ""},
+		IP:         sql.NullString{String: s.IP, Valid: s.IP != ""},
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: sql.NullTime{Time: s.LastUsedAt, Valid: !s.LastUsedAt.IsZero()},
+		RotatedAt:  sql.NullTime{Time: s.RotatedAt, Valid: !s.RotatedAt.IsZero()},
+		ExpiresAt:  sql.NullTime{Time: s.ExpiresAt, Valid: !s.ExpiresAt.IsZero()},
+		RevokedAt:  sql.NullTime{Time: s.RevokedAt, Valid: !s.RevokedAt.IsZero()},
+
+		PreviousTokenHash:      sql.NullString{String: s.PreviousTokenHash, Valid: s.PreviousTokenHash != ""},
+		PreviousTokenExpiresAt: sql.NullTime{Time: s.PreviousTokenExpiresAt, Valid: !s.PreviousTokenExpiresAt.IsZero()},
+	}
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+)
+
+// ShadowRepository shadows.Repository Postgres implementation
+type ShadowRepository struct {
+	db *sqlx.DB
+}
+
+// CreateShadowRepository Create new instance of postgres.ShadowRepository
+func CreateShadowRepository(db *sqlx.DB) shadows.Repository {
+	return &ShadowRepository{db}
+}
+
+func (sR *ShadowRepository) Get(deviceID int64) (*shadows.Shadow, error) {
+	const getStmt = `
+		SELECT device_id, desired, reported, version, desired_updated_at, reported_updated_at
+		FROM device_shadows
+		WHERE device_id = $1`
+
+	var shadowData shadowSQL
+	err := sR.db.Get(&shadowData, getStmt, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toShadow(shadowData)
+}
+
+func (sR *ShadowRepository) Save(s shadows.Shadow, expectedVersion int64) (*shadows.Shadow, error) {
+	s.Version = expectedVersion + 1
+	shadowData, err := fromShadow(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first save inserts (conflicting with a concurrent insert),
+	// later ones only update the expected version
+	var saveStmt string
+	if expectedVersion == 0 {
+		saveStmt = `
+			INSERT INTO device_shadows (
+				device_id, desired, reported, version, desired_updated_at, reported_updated_at
+			) VALUES (
+				:device_id, :desired, :reported, :version, :desired_updated_at, :reported_updated_at
+			) ON CONFLICT (device_id) DO NOTHING`
+	} else {
+		saveStmt = `
+			UPDATE device_shadows
+			SET
+				desired = :desired,
+				reported = :reported,
+				version = :version,
+				desired_updated_at = :desired_updated_at,
+				reported_updated_at = :reported_updated_at
+			WHERE device_id = :device_id AND version = :version - 1`
+	}
+
+	result, err := sR.db.NamedExec(saveStmt, shadowData)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected != 1 {
+		return nil, shadows.ErrVersionConflict
+	}
+
+	return &s, nil
+}
+
+type shadowSQL struct {
+	DeviceID          int64        `db:"device_id"`
+	Desired           string       `db:"desired"`
+	Reported          string       `db:"reported"`
+	Version           int64        `db:"version"`
+	DesiredUpdatedAt  sql.NullTime `db:"desired_updated_at"`
+	ReportedUpdatedAt sql.NullTime `db:"reported_updated_at"`
+}
+
+func toShadow(sSQL shadowSQL) (*shadows.Shadow, error) {
+	s := shadows.Shadow{
+		DeviceID:          sSQL.DeviceID,
+		Version:           sSQL.Version,
+		DesiredUpdatedAt:  sSQL.DesiredUpdatedAt.Time,
+		ReportedUpdatedAt: sSQL.ReportedUpdatedAt.Time,
+	}
+	if err := json.Unmarshal([]byte(sSQL.Desired), &s.Desired); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(sSQL.Reported), &s.Reported); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func fromShadow(s shadows.Shadow) (*shadowSQL, error) {
+	desired, err := json.Marshal(nonNilDocument(s.Desired))
+	if err != nil {
+		return nil, err
+	}
+	reported, err := json.Marshal(nonNilDocument(s.Reported))
+	if err != nil {
+		return nil, err
+	}
+
+	return &shadowSQL{
+		DeviceID:          s.DeviceID,
+		Desired:           string(desired),
+		Reported:          string(reported),
+		Version:           s.Version,
+		DesiredUpdatedAt:  sql.NullTime{Time: s.DesiredUpdatedAt, Valid: !s.DesiredUpdatedAt.IsZero()},
+		ReportedUpdatedAt: sql.NullTime{Time: s.ReportedUpdatedAt, Valid: !s.ReportedUpdatedAt.IsZero()},
+	}, nil
+}
+
+// nonNilDocument nil documents are stored as empty JSON objects (not null)
+func nonNilDocument(doc shadows.Document) shadows.Document {
+	if doc == nil {
+		return shadows.Document{}
+	}
+	return doc
+}
@@ -15,8 +15,17 @@ import (
 // 		DB_PASSWORD=admin
 // 		DB_NAME=dev
 // 		DB_PORT=5432
-// 		DB_SCHEMA_PATH=cmd/db-init/schema.sql
 func GetFromEnv() (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", ConnInfoFromEnv())
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// ConnInfoFromEnv Get postgresql connection string from environment variables (see GetFromEnv)
+func ConnInfoFromEnv() string {
 	host := os.Getenv("DB_HOST")
 	port := os.Getenv("DB_PORT")
 	user := os.Getenv("DB_USER")
@@ -28,10 +37,5 @@ func GetFromEnv() (*sqlx.DB, error) {
 		host, port, user, password, dbname,
 	)
 
-	db, err := sqlx.Connect("postgres", psqlInfo)
-	if err != nil {
-		return nil, err
-	}
-
-	return db, nil
+	return psqlInfo
 }
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tnynlabs/wyrm/pkg/telemetry"
+)
+
+// telemetryPartitionLockID is the postgres advisory lock key held while creating
+// telemetry partitions so that API replicas never create the same partition at once.
+const telemetryPartitionLockID = 7_123_000_002
+
+// TelemetryRepository telemetry.Repository Postgres implementation
+type TelemetryRepository struct {
+	db *sqlx.DB
+
+	// names of the partitions known to exist
+	partitions sync.Map
+}
+
+// CreateTelemetryRepository Create new instance of postgres.TelemetryRepository
+func CreateTelemetryRepository(db *sqlx.DB) telemetry.Repository {
+	return &TelemetryRepository{db: db}
+}
+
+func (tR *TelemetryRepository) CreateBatch(points []telemetry.Datapoint) error {
+	pointsData := make([]datapointSQL, len(points))
+	for i, p := range points {
+		err := tR.ensurePartition(p.Timestamp)
+		if err != nil {
+			return err
+		}
+		pointsData[i] = fromDatapoint(p)
+	}
+
+	const insertStmt = `
+		INSERT INTO telemetry (
+			device_id, metric, value, recorded_at
+		) VALUES (
+			:device_id, :metric, :value, :recorded_at
+		)`
+
+	_, err := tR.db.NamedExec(insertStmt, pointsData)
+	return err
+}
+
+func (tR *TelemetryRepository) Get(q telemetry.Query) ([]telemetry.Datapoint, error) {
+	const getStmt = `
+		SELECT device_id, metric, value, recorded_at
+		FROM telemetry
+		WHERE
+			device_id = $1 AND
+			recorded_at >= $2 AND recorded_at < $3 AND
+			($4::text = '' OR metric = $4)
+		ORDER BY recorded_at, metric
+		LIMIT $5 OFFSET $6`
+
+	pointsSQL := []datapointSQL{}
+	err := tR.db.Select(&pointsSQL, getStmt, q.DeviceID, q.From, q.To, q.Metric, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]telemetry.Datapoint, len(pointsSQL))
+	for i := 0; i < len(pointsSQL); i++ {
+		points[i] = toDatapoint(pointsSQL[i])
+	}
+
+	return points, nil
+}
+
+func (tR *TelemetryRepository) GetAggregates(q telemetry.Query) ([]telemetry.Aggregate, error) {
+	const getAggregatesStmt = `
+		SELECT
+			metric,
+			to_timestamp(floor(extract(epoch FROM recorded_at) / $5) * $5) AS bucket_start,
+			min(value) AS min, max(value) AS max, avg(value) AS avg, count(*) AS count
+		FROM telemetry
+		WHERE
+			device_id = $1 AND
+			recorded_at >= $2 AND recorded_at < $3 AND
+			($4::text = '' OR metric = $4)
+		GROUP BY metric, bucket_start
+		ORDER BY bucket_start, metric
+		LIMIT $6 OFFSET $7`
+
+	aggregatesSQL := []aggregateSQL{}
+	bucketSeconds := q.Bucket.Seconds()
+	err := tR.db.Select(&aggregatesSQL, getAggregatesStmt,
+		q.DeviceID, q.From, q.To, q.Metric, bucketSeconds, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := make([]telemetry.Aggregate, len(aggregatesSQL))
+	for i, a := range aggregatesSQL {
+		aggregates[i] = telemetry.Aggregate{
+			Metric:      a.Metric,
+			BucketStart: a.BucketStart,
+			Min:         a.Min,
+			Max:         a.Max,
+			Avg:         a.Avg,
+			Count:       a.Count,
+		}
+	}
+
+	return aggregates, nil
+}
+
+// ensurePartition creates the monthly partition holding t (with its index and
+// device foreign key, which postgres 10 does not allow on the partitioned table)
+func (tR *TelemetryRepository) ensurePartition(t time.Time) error {
+	t = t.UTC()
+	// Partition names have 4 digit years
+	if t.Year() < 1 || t.Year() > 9999 {
+		return fmt.Errorf("Timestamp %v is out of range", t)
+	}
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	name := fmt.Sprintf("telemetry_y%04dm%02d", from.Year(), from.Month())
+	if _, ok := tR.partitions.Load(name); ok {
+		return nil
+	}
+
+	tx, err := tR.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, telemetryPartitionLockID)
+	if err != nil {
+		return err
+	}
+
+	var existing sql.NullString
+	err = tx.Get(&existing, `SELECT to_regclass($1)::text`, name)
+	if err != nil {
+		return err
+	}
+
+	if !existing.Valid {
+		// name is generated from the date only so it is safe to format into the statements
+		createStmts := []string{
+			fmt.Sprintf(`CREATE TABLE %s PARTITION OF telemetry FOR VALUES FROM ('%s') TO ('%s')`,
+				name, from.Format(time.RFC3339), to.Format(time.RFC3339)),
+			fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT FK_%s_devices FOREIGN KEY ( device_id ) REFERENCES devices ( "id" ) ON DELETE CASCADE`,
+				name, name),
+			fmt.Sprintf(`CREATE INDEX idx_%s_device_time ON %s ( device_id, recorded_at )`, name, name),
+		}
+		for _, stmt := range createStmts {
+			if _, err = tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	tR.partitions.Store(name, struct{}{})
+	return nil
+}
+
+type datapointSQL struct {
+	DeviceID   int64     `db:"device_id"`
+	Metric     string    `db:"metric"`
+	Value      float64   `db:"value"`
+	RecordedAt time.Time `db:"recorded_at"`
+}
+
+type aggregateSQL struct {
+	Metric      string    `db:"metric"`
+	BucketStart time.Time `db:"bucket_start"`
+	Min         float64   `db:"min"`
+	Max         float64   `db:"max"`
+	Avg         float64   `db:"avg"`
+	Count       int64     `db:"count"`
+}
+
+func toDatapoint(pSQL datapointSQL) telemetry.Datapoint {
+	return telemetry.Datapoint{
+		DeviceID:  pSQL.DeviceID,
+		Metric:    pSQL.Metric,
+		Value:     pSQL.Value,
+		Timestamp: pSQL.RecordedAt,
+	}
+}
+
+func fromDatapoint(p telemetry.Datapoint) datapointSQL {
+	return datapointSQL{
+		DeviceID:   p.DeviceID,
+		Metric:     p.Metric,
+		Value:      p.Value,
+		RecordedAt: p.Timestamp,
+	}
+}
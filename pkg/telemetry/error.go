@@ -0,0 +1,9 @@
+package telemetry
+
+import "github.com/tnynlabs/wyrm/pkg/utils"
+
+const (
+	InvalidInputCode   = utils.ServiceErrCode("INVALID_INPUT")
+	InvalidAuthKeyCode = utils.ServiceErrCode("INVALID_AUTH_KEY")
+	DeviceNotFoundCode = utils.ServiceErrCode("DEVICE_NOT_FOUND")
+)
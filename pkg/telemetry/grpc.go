@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/telemetry/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IngestServer protobuf.TelemetryIngestServer implementation
+type IngestServer struct {
+	protobuf.UnimplementedTelemetryIngestServer
+
+	telemetryService Service
+}
+
+// CreateIngestServer Create new instance of telemetry.IngestServer
+func CreateIngestServer(telemetryService Service) *IngestServer {
+	return &IngestServer{telemetryService: telemetryService}
+}
+
+func (s *IngestServer) Ingest(ctx context.Context, req *protobuf.IngestRequest) (*protobuf.IngestResponse, error) {
+	accepted, err := s.telemetryService.Ingest(req.GetAuthKey(), FromProtobuf(req.GetDatapoints()))
+	if err != nil {
+		serviceErr := utils.ToServiceErr(err)
+		switch serviceErr.Code {
+		case InvalidAuthKeyCode:
+			return nil, status.Error(codes.Unauthenticated, serviceErr.Message)
+		case InvalidInputCode:
+			return nil, status.Error(codes.InvalidArgument, serviceErr.Message)
+		default:
+			return nil, status.Error(codes.Internal, serviceErr.Message)
+		}
+	}
+
+	return &protobuf.IngestResponse{Accepted: int64(accepted)}, nil
+}
+
+// FromProtobuf converts protobuf datapoints (a zero timestamp is kept as is)
+func FromProtobuf(points []*protobuf.Datapoint) []Datapoint {
+	result := make([]Datapoint, len(points))
+	for i, p := range points {
+		result[i] = Datapoint{
+			Metric: p.GetMetric(),
+			Value:  p.GetValue(),
+		}
+		if p.GetTimestampMs() != 0 {
+			result[i].Timestamp = time.Unix(0, p.GetTimestampMs()*int64(time.Millisecond))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,265 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+const (
+	// MaxBatchSize max number of datapoints accepted in a single ingestion
+	MaxBatchSize = 1000
+	// MaxMetricLength max length of metric names
+	MaxMetricLength = 128
+	// MaxClockSkew how far in the future datapoint timestamps can be
+	MaxClockSkew = 5 * time.Minute
+	// MaxBackfill how far in the past datapoint timestamps can be
+	// (every month of datapoints is stored in its own partition)
+	MaxBackfill = 30 * 24 * time.Hour
+
+	// DefaultRange time range returned when a query has no start time
+	DefaultRange = time.Hour
+	// DefaultLimit and MaxLimit page sizes of telemetry queries
+	DefaultLimit = 1000
+	MaxLimit     = 10000
+)
+
+// Datapoint a single reading of a device metric
+type Datapoint struct {
+	DeviceID  int64
+	Metric    string
+	Value     float64
+	Timestamp time.Time
+}
+
+// Aggregate summary of the datapoints of a metric in a time bucket
+type Aggregate struct {
+	Metric      string
+	BucketStart time.Time
+	Min         float64
+	Max         float64
+	Avg         float64
+	Count       int64
+}
+
+// Query selects the datapoints of a device in [From, To)
+type Query struct {
+	DeviceID int64
+	// Metric (optional) only returns datapoints of this metric
+	Metric string
+	From   time.Time
+	To     time.Time
+	// Bucket (optional) downsamples datapoints into buckets of this size
+	// (aligned to the unix epoch)
+	Bucket time.Duration
+	Limit  int
+	Offset int
+}
+
+// Repository defines the telemetry.Repository operations
+type Repository interface {
+	CreateBatch(points []Datapoint) error
+	// Get returns the datapoints matching q ordered by timestamp
+	Get(q Query) ([]Datapoint, error)
+	// GetAggregates returns the aggregates of q.Bucket sized buckets ordered by bucket start
+	GetAggregates(q Query) ([]Aggregate, error)
+}
+
+// Service defines the telemetry.Service operations
+type Service interface {
+	// Ingest stores the datapoints of the device owning authKey,
+	// returns the number of stored datapoints
+	Ingest(authKey string, points []Datapoint) (int, error)
+	Get(q Query) ([]Datapoint, error)
+	GetAggregates(q Query) ([]Aggregate, error)
+}
+
+type service struct {
+	telemetryRepo Repository
+	deviceService devices.Service
+	bus           events.Bus
+}
+
+// CreateService Create new instance of Telemetry Service
+// (stored datapoints are published to bus)
+func CreateService(repo Repository, deviceService devices.Service, bus events.Bus) Service {
+	return &service{repo, deviceService, bus}
+}
+
+func (s *service) Ingest(authKey string, points []Datapoint) (int, error) {
+	device, err := s.deviceService.GetByKey(authKey)
+	if err != nil {
+		return 0, &utils.ServiceErr{
+			Code:    InvalidAuthKeyCode,
+			Message: "Invalid device auth key",
+		}
+	}
+
+	if len(points) == 0 || len(points) > MaxBatchSize {
+		return 0, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("A batch should have 1 to %d datapoints", MaxBatchSize),
+		}
+	}
+
+	now := time.Now()
+	batch := make([]Datapoint, len(points))
+	for i, p := range points {
+		if err := validateDatapoint(p, now); err != nil {
+			err.Message = fmt.Sprintf("datapoints[%d]: %s", i, err.Message)
+			return 0, err
+		}
+
+		if p.Timestamp.IsZero() {
+			p.Timestamp = now
+		}
+		p.DeviceID = device.ID
+		batch[i] = p
+	}
+
+	err = s.telemetryRepo.CreateBatch(batch)
+	if err != nil {
+		log.Printf("Failed storing telemetry of device %d (error: %v)", device.ID, err)
+		return 0, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed storing telemetry",
+		}
+	}
+
+	for _, p := range batch {
+		data, _ := json.Marshal(datapointEvent{
+			Metric:    p.Metric,
+			Value:     p.Value,
+			Timestamp: p.Timestamp,
+		})
+		s.bus.Publish(events.Event{
+			Type:      events.TelemetryReceived,
+			ProjectID: device.ProjectID,
+			DeviceID:  device.ID,
+			Data:      data,
+		})
+	}
+
+	return len(batch), nil
+}
+
+// datapointEvent data of telemetry events
+type datapointEvent struct {
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (s *service) Get(q Query) ([]Datapoint, error) {
+	q, err := s.normalizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := s.telemetryRepo.Get(q)
+	if err != nil {
+		log.Printf("Failed getting telemetry of device %d (error: %v)", q.DeviceID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting telemetry",
+		}
+	}
+
+	return points, nil
+}
+
+func (s *service) GetAggregates(q Query) ([]Aggregate, error) {
+	q, err := s.normalizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Bucket < time.Second || q.Bucket%time.Second != 0 {
+		return nil, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Bucket should be a whole number of seconds",
+		}
+	}
+
+	aggregates, err := s.telemetryRepo.GetAggregates(q)
+	if err != nil {
+		log.Printf("Failed aggregating telemetry of device %d (error: %v)", q.DeviceID, err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed getting telemetry",
+		}
+	}
+
+	return aggregates, nil
+}
+
+// normalizeQuery checks the device exists and fills the query defaults
+func (s *service) normalizeQuery(q Query) (Query, error) {
+	_, err := s.deviceService.GetByID(q.DeviceID)
+	if err != nil {
+		return q, &utils.ServiceErr{
+			Code:    DeviceNotFoundCode,
+			Message: "Invalid Device ID",
+		}
+	}
+
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultRange)
+	}
+	if !q.From.Before(q.To) {
+		return q, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "from should be before to",
+		}
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxLimit || q.Offset < 0 {
+		return q, &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("limit should be between 1 and %d and offset positive", MaxLimit),
+		}
+	}
+
+	return q, nil
+}
+
+func validateDatapoint(p Datapoint, now time.Time) *utils.ServiceErr {
+	if p.Metric == "" || len(p.Metric) > MaxMetricLength {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("metric should have 1 to %d characters", MaxMetricLength),
+		}
+	}
+	if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "value should be a finite number",
+		}
+	}
+	if p.Timestamp.After(now.Add(MaxClockSkew)) {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "timestamp is in the future",
+		}
+	}
+	if p.Timestamp.Before(now.Add(-MaxBackfill)) {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("timestamp should be at most %d days in the past", MaxBackfill/(24*time.Hour)),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,8 @@
+package templates
+
+import "github.com/tnynlabs/wyrm/pkg/utils"
+
+const (
+	ProjectNotFoundCode = utils.ServiceErrCode("PROJECT_NOT_FOUND")
+	InvalidInputCode    = utils.ServiceErrCode("INVALID_INPUT")
+)
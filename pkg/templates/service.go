@@ -0,0 +1,308 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/endpoints"
+	"github.com/tnynlabs/wyrm/pkg/pipelines"
+	"github.com/tnynlabs/wyrm/pkg/projects"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+)
+
+// TemplateFormat format identifier of project templates
+const TemplateFormat = "wyrm.project-template/v1"
+
+// Template a portable deployment: the devices of a project (with their
+// endpoints) and its pipelines, which refer to the template devices by
+// reference (see pipelines.Bundle). Applying a template to a project
+// creates all of them, so identical deployments can be stamped out.
+type Template struct {
+	Format      string             `json:"format"`
+	DisplayName string             `json:"display_name,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Devices     []Device           `json:"devices"`
+	Pipelines   []pipelines.Bundle `json:"pipelines"`
+}
+
+type Device struct {
+	// Ref reference of the device in the pipelines of the template
+	Ref         string     `json:"ref"`
+	DisplayName string     `json:"display_name"`
+	Description string     `json:"description,omitempty"`
+	Endpoints   []Endpoint `json:"endpoints"`
+}
+
+type Endpoint struct {
+	Pattern     string `json:"pattern"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	TimeoutMs   int64  `json:"timeout_ms,omitempty"`
+	Method      string `json:"method,omitempty"`
+
+	RequestSchema  json.RawMessage `json:"request_schema,omitempty"`
+	ResponseSchema json.RawMessage `json:"response_schema,omitempty"`
+}
+
+// Deployment resources created by applying a template
+type Deployment struct {
+	// Devices created devices (including their auth keys)
+	Devices   []devices.Device
+	Endpoints []endpoints.Endpoint
+	Pipelines []pipelines.Pipeline
+}
+
+type Service interface {
+	// Export builds the template of the project
+	Export(ctx context.Context, projectID int64) (*Template, error)
+	// Apply creates the devices, endpoints and pipelines of the template in
+	// the project (pipelines are created by userID). Nothing is created if
+	// any of them is invalid.
+	Apply(ctx context.Context, projectID int64, userID int64, t Template) (*Deployment, error)
+}
+
+type service struct {
+	projectService  projects.Service
+	deviceService   devices.Service
+	endpointService endpoints.Service
+	pipelineService pipelines.Service
+}
+
+func CreateService(pService projects.Service, dService devices.Service, eService endpoints.Service, plService pipelines.Service) Service {
+	return &service{pService, dService, eService, plService}
+}
+
+func (s *service) Export(ctx context.Context, projectID int64) (*Template, error) {
+	project, err := s.projectService.GetByID(projectID)
+	if err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid project ID",
+		}
+	}
+
+	projectDevices, err := s.deviceService.GetByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(projectDevices, func(i, j int) bool {
+		return projectDevices[i].ID < projectDevices[j].ID
+	})
+	refs := pipelines.AssignDeviceRefs(projectDevices)
+
+	t := Template{
+		Format:      TemplateFormat,
+		DisplayName: project.DisplayName,
+		Description: project.Description,
+		Devices:     []Device{},
+		Pipelines:   []pipelines.Bundle{},
+	}
+	for _, d := range projectDevices {
+		deviceEndpoints, err := s.endpointService.GetbyDeviceID(d.ID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(deviceEndpoints, func(i, j int) bool {
+			return deviceEndpoints[i].Pattern < deviceEndpoints[j].Pattern
+		})
+
+		device := Device{
+			Ref:         refs[d.ID].Ref,
+			DisplayName: d.DisplayName,
+			Description: d.Description,
+			Endpoints:   make([]Endpoint, len(deviceEndpoints)),
+		}
+		for i, ep := range deviceEndpoints {
+			device.Endpoints[i] = Endpoint{
+				Pattern:        ep.Pattern,
+				DisplayName:    ep.DisplayName,
+				Description:    ep.Description,
+				TimeoutMs:      ep.Timeout.Milliseconds(),
+				Method:         ep.Method,
+				RequestSchema:  rawSchema(ep.RequestSchema),
+				ResponseSchema: rawSchema(ep.ResponseSchema),
+			}
+		}
+		t.Devices = append(t.Devices, device)
+	}
+
+	projectPipelines, err := s.pipelineService.GetByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(projectPipelines, func(i, j int) bool {
+		return projectPipelines[i].ID < projectPipelines[j].ID
+	})
+	for _, p := range projectPipelines {
+		bundle, err := pipelines.NewBundle(p, refs)
+		if err != nil {
+			return nil, &utils.ServiceErr{
+				Code:    pipelines.InvalidPipelineCode,
+				Message: fmt.Sprintf("Pipeline %q can not be exported (%v)", p.DisplayName, err),
+			}
+		}
+		t.Pipelines = append(t.Pipelines, *bundle)
+	}
+
+	return &t, nil
+}
+
+func rawSchema(schema string) json.RawMessage {
+	if schema == "" {
+		return nil
+	}
+	return json.RawMessage(schema)
+}
+
+func (s *service) Apply(ctx context.Context, projectID int64, userID int64, t Template) (*Deployment, error) {
+	if _, err := s.projectService.GetByID(projectID); err != nil {
+		return nil, &utils.ServiceErr{
+			Code:    ProjectNotFoundCode,
+			Message: "Invalid project ID",
+		}
+	}
+	if err := validateTemplate(t); err != nil {
+		return nil, err
+	}
+
+	deployment := &Deployment{
+		Devices:   []devices.Device{},
+		Endpoints: []endpoints.Endpoint{},
+		Pipelines: []pipelines.Pipeline{},
+	}
+	deviceIDs := make(map[string]int64)
+	for _, d := range t.Devices {
+		device, err := s.deviceService.Create(devices.Device{
+			DisplayName: d.DisplayName,
+			Description: d.Description,
+			ProjectID:   projectID,
+		})
+		if err != nil {
+			s.rollback(ctx, deployment)
+			return nil, applyErr(err, fmt.Sprintf("Device %q", d.Ref))
+		}
+		deployment.Devices = append(deployment.Devices, *device)
+		deviceIDs[d.Ref] = device.ID
+
+		for _, ep := range d.Endpoints {
+			endpoint, err := s.endpointService.Create(endpoints.Endpoint{
+				DeviceID:       device.ID,
+				Pattern:        ep.Pattern,
+				DisplayName:    ep.DisplayName,
+				Description:    ep.Description,
+				Timeout:        time.Duration(ep.TimeoutMs) * time.Millisecond,
+				Method:         ep.Method,
+				RequestSchema:  string(ep.RequestSchema),
+				ResponseSchema: string(ep.ResponseSchema),
+			})
+			if err != nil {
+				s.rollback(ctx, deployment)
+				return nil, applyErr(err, fmt.Sprintf("Endpoint %q of device %q", ep.Pattern, d.Ref))
+			}
+			deployment.Endpoints = append(deployment.Endpoints, *endpoint)
+		}
+	}
+
+	for _, bundle := range t.Pipelines {
+		mapping := make(map[string]int64)
+		for _, device := range bundle.Devices {
+			mapping[device.Ref] = deviceIDs[device.Ref]
+		}
+
+		pipeline, err := s.pipelineService.Import(ctx, projectID, userID, bundle, mapping)
+		if err != nil {
+			s.rollback(ctx, deployment)
+			return nil, applyErr(err, fmt.Sprintf("Pipeline %q", bundle.DisplayName))
+		}
+		deployment.Pipelines = append(deployment.Pipelines, *pipeline)
+	}
+
+	return deployment, nil
+}
+
+// validateTemplate checks the template structure before anything is created
+// (device references have to be unique and pipelines may only refer to
+// devices of the template)
+func validateTemplate(t Template) error {
+	if t.Format != TemplateFormat {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: fmt.Sprintf("Unsupported template format %q (expected %q)", t.Format, TemplateFormat),
+		}
+	}
+
+	issues := []pipelines.GraphIssue{}
+	refs := make(map[string]bool)
+	for i, d := range t.Devices {
+		if d.Ref == "" || refs[d.Ref] {
+			issues = append(issues, pipelines.GraphIssue{
+				Location: fmt.Sprintf("/devices/%d/ref", i),
+				Message:  "Device references should be unique and not empty",
+			})
+		}
+		if d.DisplayName == "" {
+			issues = append(issues, pipelines.GraphIssue{
+				Location: fmt.Sprintf("/devices/%d/display_name", i),
+				Message:  "Missing display name",
+			})
+		}
+		refs[d.Ref] = true
+	}
+	for i, bundle := range t.Pipelines {
+		for j, device := range bundle.Devices {
+			if !refs[device.Ref] {
+				issues = append(issues, pipelines.GraphIssue{
+					Location: fmt.Sprintf("/pipelines/%d/devices/%d/ref", i, j),
+					Message:  fmt.Sprintf("Unknown device reference %q", device.Ref),
+				})
+			}
+		}
+	}
+	if len(issues) != 0 {
+		return &utils.ServiceErr{
+			Code:    InvalidInputCode,
+			Message: "Invalid template",
+			Details: issues,
+		}
+	}
+
+	return nil
+}
+
+// rollback deletes the resources created by a failed Apply
+func (s *service) rollback(ctx context.Context, deployment *Deployment) {
+	for _, p := range deployment.Pipelines {
+		if err := s.pipelineService.Delete(ctx, p.ID); err != nil {
+			log.Printf("Failed deleting pipeline %d of a failed template (error: %v)", p.ID, err)
+		}
+	}
+	for _, ep := range deployment.Endpoints {
+		if err := s.endpointService.Delete(ep.ID); err != nil {
+			log.Printf("Failed deleting endpoint %d of a failed template (error: %v)", ep.ID, err)
+		}
+	}
+	for _, d := range deployment.Devices {
+		if err := s.deviceService.Delete(d.ID); err != nil {
+			log.Printf("Failed deleting device %d of a failed template (error: %v)", d.ID, err)
+		}
+	}
+}
+
+// applyErr prefixes the error message with the template resource that failed
+func applyErr(err error, resource string) error {
+	serviceErr := utils.ToServiceErr(err)
+	if serviceErr.Code == utils.UnexpectedCode {
+		return serviceErr
+	}
+
+	return &utils.ServiceErr{
+		Code:    serviceErr.Code,
+		Message: fmt.Sprintf("%s: %s", resource, serviceErr.Message),
+		Details: serviceErr.Details,
+	}
+}
@@ -4,4 +4,7 @@ import "github.com/tnynlabs/wyrm/pkg/utils"
 
 const (
 	ConnectionErrorCode = utils.ServiceErrCode("CONNECTION_ERROR")
+	TimeoutCode         = utils.ServiceErrCode("TIMEOUT")
+	DeviceOfflineCode   = utils.ServiceErrCode("DEVICE_OFFLINE")
+	CanceledCode        = utils.ServiceErrCode("CANCELED")
 )
@@ -0,0 +1,155 @@
+package tunnels
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tnynlabs/wyrm/pkg/devices"
+	"github.com/tnynlabs/wyrm/pkg/events"
+	"github.com/tnynlabs/wyrm/pkg/shadows"
+	"github.com/tnynlabs/wyrm/pkg/tunnels/protobuf"
+	"github.com/tnynlabs/wyrm/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// RegistryServer protobuf.DeviceRegistryServer implementation,
+// records device connectivity reported by tunnel managers
+// and publishes it (and device messages) as events
+type RegistryServer struct {
+	protobuf.UnimplementedDeviceRegistryServer
+
+	deviceService devices.Service
+	shadowService shadows.Service
+	bus           events.Bus
+}
+
+// CreateRegistryServer Create new instance of tunnels.RegistryServer
+func CreateRegistryServer(deviceService devices.Service, shadowService shadows.Service, bus events.Bus) *RegistryServer {
+	return &RegistryServer{deviceService: deviceService, shadowService: shadowService, bus: bus}
+}
+
+func (s *RegistryServer) DeviceConnected(ctx context.Context, req *protobuf.DeviceConnectedRequest) (*emptypb.Empty, error) {
+	conn := devices.Connection{
+		ID:         req.GetConnectionId(),
+		ManagerID:  req.GetManagerId(),
+		RemoteAddr: req.GetRemoteAddr(),
+		Metadata:   req.GetMetadata(),
+	}
+	err := s.deviceService.SetOnline(req.GetDeviceId(), conn)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	// Delivered after replying as the manager may only accept calls for the device once it is registered
+	go s.shadowService.SyncDevice(req.GetDeviceId())
+
+	device, err := s.deviceService.GetByID(req.GetDeviceId())
+	if err == nil {
+		s.bus.Publish(events.Event{
+			Type:      events.DeviceConnected,
+			ProjectID: device.ProjectID,
+			DeviceID:  device.ID,
+		})
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// DeviceDisconnected marks the device offline. Only disconnections of the
+// current connection are published (the device may have reconnected already).
+// Note: devices marked offline by ManagerStarted are not published.
+func (s *RegistryServer) DeviceDisconnected(ctx context.Context, req *protobuf.DeviceDisconnectedRequest) (*emptypb.Empty, error) {
+	device, err := s.deviceService.GetByID(req.GetDeviceId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	err = s.deviceService.SetOffline(req.GetDeviceId(), req.GetConnectionId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	if device.Status == devices.StatusOnline && device.Connection.ID == req.GetConnectionId() {
+		s.bus.Publish(events.Event{
+			Type:      events.DeviceDisconnected,
+			ProjectID: device.ProjectID,
+			DeviceID:  device.ID,
+		})
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *RegistryServer) DeviceHeartbeat(ctx context.Context, req *protobuf.DeviceHeartbeatRequest) (*emptypb.Empty, error) {
+	err := s.deviceService.Heartbeat(req.GetDeviceId(), req.GetConnectionId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *RegistryServer) ManagerStarted(ctx context.Context, req *protobuf.ManagerStartedRequest) (*emptypb.Empty, error) {
+	if req.GetManagerId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "manager ID is required")
+	}
+
+	err := s.deviceService.SetManagerOffline(req.GetManagerId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *RegistryServer) ReportShadowState(ctx context.Context, req *protobuf.ReportShadowStateRequest) (*emptypb.Empty, error) {
+	var reported shadows.Document
+	err := json.Unmarshal([]byte(req.GetReported()), &reported)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "reported state should be a JSON object")
+	}
+
+	_, err = s.shadowService.UpdateReported(req.GetDeviceId(), reported)
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *RegistryServer) DeviceMessage(ctx context.Context, req *protobuf.DeviceMessageRequest) (*emptypb.Empty, error) {
+	if req.GetPattern() == "" {
+		return nil, status.Error(codes.InvalidArgument, "pattern is required")
+	}
+
+	device, err := s.deviceService.GetByID(req.GetDeviceId())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+
+	s.bus.Publish(events.Event{
+		Type:      events.DeviceMessage,
+		ProjectID: device.ProjectID,
+		DeviceID:  device.ID,
+		Pattern:   req.GetPattern(),
+		Data:      events.RawData(req.GetData()),
+	})
+
+	return &emptypb.Empty{}, nil
+}
+
+// toGrpcErr maps service errors to grpc status errors
+// Note: device and shadow services share their error codes
+func toGrpcErr(err error) error {
+	serviceErr := utils.ToServiceErr(err)
+	switch serviceErr.Code {
+	case devices.DeviceNotFoundCode:
+		return status.Error(codes.NotFound, serviceErr.Message)
+	case devices.InvalidInputCode:
+		return status.Error(codes.InvalidArgument, serviceErr.Message)
+	default:
+		return status.Error(codes.Internal, serviceErr.Message)
+	}
+}
@@ -0,0 +1,43 @@
+package tunnels
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RegistryTokenKey metadata key of the token tunnel managers
+// authenticate to the device registry with
+const RegistryTokenKey = "x-registry-token"
+
+// RegistryAuth rejects device registry calls without the token shared
+// with the tunnel managers (anyone reaching the registry could mark
+// devices online and publish device messages otherwise)
+func RegistryAuth(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(RegistryTokenKey)
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "Invalid registry token")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RegistryToken credentials of tunnel managers calling the device registry
+// (e.g. grpc.WithPerRPCCredentials(tunnels.RegistryToken(token)))
+type RegistryToken string
+
+func (t RegistryToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{RegistryTokenKey: string(t)}, nil
+}
+
+// RequireTransportSecurity the registry is served on the internal network
+func (t RegistryToken) RequireTransportSecurity() bool {
+	return false
+}
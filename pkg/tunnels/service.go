@@ -3,15 +3,32 @@ package tunnels
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/tnynlabs/wyrm/pkg/tunnels/protobuf"
 	"github.com/tnynlabs/wyrm/pkg/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	// DefaultInvokeTimeout max time to wait for a device response
+	// (used when neither the caller nor the endpoint sets a deadline)
+	DefaultInvokeTimeout = 30 * time.Second
+	// defaultNotifyTimeout max time to wait for the tunnel manager to
+	// acknowledge a notification (revocations, shadow deltas)
+	defaultNotifyTimeout = 10 * time.Second
+)
+
+// Service calls to the tunnel manager. If ctx has no deadline a default
+// timeout is used, so a hung device never blocks the caller forever.
 type Service interface {
-	InvokeDevice(deviceID int64, pattern string, data string) (*InvokeResponse, error)
-	RevokeDevice(deviceID int64)
+	InvokeDevice(ctx context.Context, deviceID int64, pattern string, data string) (*InvokeResponse, error)
+	// RevokeDevice drops live tunnels of the device after gracePeriod
+	RevokeDevice(ctx context.Context, deviceID int64, gracePeriod time.Duration) error
+	// SendShadowDelta delivers a shadow delta (JSON object) to a connected device
+	SendShadowDelta(ctx context.Context, deviceID int64, version int64, delta string) error
 }
 
 type httpGrpcService struct {
@@ -28,24 +45,87 @@ func CreateHttpGrpcService(target string) Service {
 	return &httpGrpcService{client}
 }
 
-func (s *httpGrpcService) InvokeDevice(deviceID int64, pattern string, data string) (*InvokeResponse, error) {
+func (s *httpGrpcService) InvokeDevice(ctx context.Context, deviceID int64, pattern string, data string) (*InvokeResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultInvokeTimeout)
+	defer cancel()
+
 	invokeRequest := protobuf.InvokeRequest{
 		DeviceId: deviceID,
 		Pattern:  pattern,
 		Data:     data,
 	}
-	invokeResp, err := s.client.InvokeDevice(context.Background(), &invokeRequest)
+	invokeResp, err := s.client.InvokeDevice(ctx, &invokeRequest)
 	if err != nil {
-		return nil, &utils.ServiceErr{
-			Code:    ConnectionErrorCode,
-			Message: "Invalid Key",
-		}
+		return nil, toServiceErr(ctx, err)
 	}
 	return &InvokeResponse{Data: invokeResp.Data}, nil
 }
 
-func (s *httpGrpcService) RevokeDevice(deviceID int64) {
+func (s *httpGrpcService) RevokeDevice(ctx context.Context, deviceID int64, gracePeriod time.Duration) error {
+	ctx, cancel := withDefaultTimeout(ctx, defaultNotifyTimeout)
+	defer cancel()
+
+	revokeRequest := protobuf.RevokeRequest{
+		DeviceId:           deviceID,
+		GracePeriodSeconds: int64(gracePeriod / time.Second),
+	}
+	_, err := s.client.RevokeDevice(ctx, &revokeRequest)
+	if err != nil {
+		return toServiceErr(ctx, err)
+	}
+	return nil
+}
+
+func (s *httpGrpcService) SendShadowDelta(ctx context.Context, deviceID int64, version int64, delta string) error {
+	ctx, cancel := withDefaultTimeout(ctx, defaultNotifyTimeout)
+	defer cancel()
+
+	shadowDelta := protobuf.ShadowDelta{
+		DeviceId: deviceID,
+		Version:  version,
+		Delta:    delta,
+	}
+	_, err := s.client.SyncShadow(ctx, &shadowDelta)
+	if err != nil {
+		return toServiceErr(ctx, err)
+	}
+	return nil
+}
+
+// withDefaultTimeout adds a timeout to ctx unless it already has a deadline
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
 
+// toServiceErr maps errors of tunnel manager calls to service errors.
+// The tunnel manager replies with NotFound when the device has no live tunnel.
+func toServiceErr(ctx context.Context, err error) error {
+	code := status.Code(err)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded || code == codes.DeadlineExceeded:
+		return &utils.ServiceErr{
+			Code:    TimeoutCode,
+			Message: "Device did not respond in time",
+		}
+	case ctx.Err() == context.Canceled || code == codes.Canceled:
+		return &utils.ServiceErr{
+			Code:    CanceledCode,
+			Message: "Request canceled",
+		}
+	case code == codes.NotFound:
+		return &utils.ServiceErr{
+			Code:    DeviceOfflineCode,
+			Message: "Device is not connected",
+		}
+	default:
+		return &utils.ServiceErr{
+			Code:    ConnectionErrorCode,
+			Message: "Failed reaching tunnel manager",
+		}
+	}
 }
 
 type InvokeResponse struct {
@@ -0,0 +1,271 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const saltLength = 16
+
+var errInvalidHash = errors.New("invalid encoded password hash")
+
+// PasswordHasher hashes passwords into self describing encoded strings
+// (i.e. the encoded hash carries its algorithm, parameters and salt).
+type PasswordHasher interface {
+	// Hash generates a new encoded hash of the password
+	Hash(pwd string) (string, error)
+	// Verify checks the password against an encoded hash produced by this algorithm
+	Verify(pwd, encodedHash string) (bool, error)
+	// NeedsRehash reports if the encoded hash was produced using another
+	// algorithm or weaker parameters than the hasher's current ones
+	NeedsRehash(encodedHash string) bool
+}
+
+// HasherFromName Create a PasswordHasher with default parameters
+// Supported names: "argon2id" (default), "bcrypt" and "scrypt"
+func HasherFromName(name string) (PasswordHasher, error) {
+	switch name {
+	case "", "argon2id":
+		return DefaultArgon2idHasher(), nil
+	case "bcrypt":
+		return DefaultBcryptHasher(), nil
+	case "scrypt":
+		return DefaultScryptHasher(), nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm (%s)", name)
+	}
+}
+
+// verifyAny checks a password against an encoded hash produced by any supported
+// algorithm (including legacy unsalted-format sha256 hashes stored with a separate salt).
+func verifyAny(pwd, encodedHash, legacySalt string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return DefaultArgon2idHasher().Verify(pwd, encodedHash)
+	case strings.HasPrefix(encodedHash, "$scrypt$"):
+		return DefaultScryptHasher().Verify(pwd, encodedHash)
+	case strings.HasPrefix(encodedHash, "$2"):
+		return DefaultBcryptHasher().Verify(pwd, encodedHash)
+	case !strings.HasPrefix(encodedHash, "$"):
+		return verifyLegacySHA256(pwd, encodedHash, legacySalt), nil
+	default:
+		return false, errInvalidHash
+	}
+}
+
+// verifyLegacySHA256 checks hashes generated before PasswordHasher existed
+// (single sha256 over pwd+salt). Such hashes are always rehashed on login.
+func verifyLegacySHA256(pwd, encodedHash, salt string) bool {
+	hashSlice := sha256.Sum256([]byte(pwd + salt))
+	hash := base64.StdEncoding.EncodeToString(hashSlice[:])
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(encodedHash)) == 1
+}
+
+// BcryptHasher bcrypt PasswordHasher
+// Encoded format: bcrypt's own modular crypt format (e.g. $2a$12$...)
+type BcryptHasher struct {
+	Cost int
+}
+
+// DefaultBcryptHasher Create BcryptHasher with recommended parameters
+func DefaultBcryptHasher() *BcryptHasher {
+	return &BcryptHasher{Cost: 12}
+}
+
+func (h *BcryptHasher) Hash(pwd string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(pwd, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(pwd))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost < h.Cost
+}
+
+// ScryptHasher scrypt PasswordHasher
+// Encoded format: $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>
+type ScryptHasher struct {
+	LogN   uint8
+	R      int
+	P      int
+	KeyLen int
+}
+
+// DefaultScryptHasher Create ScryptHasher with recommended parameters
+func DefaultScryptHasher() *ScryptHasher {
+	return &ScryptHasher{LogN: 15, R: 8, P: 1, KeyLen: 32}
+}
+
+func (h *ScryptHasher) Hash(pwd string) (string, error) {
+	salt, err := genSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(pwd), salt, 1<<h.LogN, h.R, h.P, h.KeyLen)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s",
+		h.LogN, h.R, h.P, encodeB64(salt), encodeB64(key)), nil
+}
+
+func (h *ScryptHasher) Verify(pwd, encodedHash string) (bool, error) {
+	params, salt, key, err := decodeScrypt(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey, err := scrypt.Key([]byte(pwd), salt, 1<<params.LogN, params.R, params.P, len(key))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func (h *ScryptHasher) NeedsRehash(encodedHash string) bool {
+	params, _, key, err := decodeScrypt(encodedHash)
+	if err != nil {
+		return true
+	}
+	return params.LogN < h.LogN || params.R < h.R || params.P < h.P || len(key) < h.KeyLen
+}
+
+func decodeScrypt(encodedHash string) (*ScryptHasher, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	var params ScryptHasher
+	_, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.LogN, &params.R, &params.P)
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	salt, err := decodeB64(parts[3])
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+	key, err := decodeB64(parts[4])
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	return &params, salt, key, nil
+}
+
+// Argon2idHasher argon2id PasswordHasher
+// Encoded format (PHC string): $argon2id$v=19$m=<memory KiB>,t=<time>,p=<threads>$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	KeyLen  uint32
+}
+
+// DefaultArgon2idHasher Create Argon2idHasher with recommended parameters
+func DefaultArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Memory: 64 * 1024, Time: 3, Threads: 2, KeyLen: 32}
+}
+
+func (h *Argon2idHasher) Hash(pwd string) (string, error) {
+	salt, err := genSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(pwd), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads, encodeB64(salt), encodeB64(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(pwd, encodedHash string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(pwd), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, _, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.Memory || params.Time < h.Time ||
+		params.Threads < h.Threads || uint32(len(key)) < h.KeyLen
+}
+
+func decodeArgon2id(encodedHash string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	var params Argon2idHasher
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	salt, err := decodeB64(parts[4])
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+	key, err := decodeB64(parts[5])
+	if err != nil {
+		return nil, nil, nil, errInvalidHash
+	}
+
+	return &params, salt, key, nil
+}
+
+func genSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+func encodeB64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
+}
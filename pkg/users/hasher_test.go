@@ -0,0 +1,125 @@
+package users
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// Cheap parameters, the tests check the encoding and not the hashing cost
+func testHashers() map[string]PasswordHasher {
+	return map[string]PasswordHasher{
+		"bcrypt":   &BcryptHasher{Cost: 4},
+		"scrypt":   &ScryptHasher{LogN: 4, R: 8, P: 1, KeyLen: 32},
+		"argon2id": &Argon2idHasher{Memory: 64, Time: 1, Threads: 1, KeyLen: 32},
+	}
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	for name, hasher := range testHashers() {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			if ok, err := hasher.Verify("correct horse", hash); err != nil || !ok {
+				t.Errorf("Verify(correct password) = %v, %v, want true", ok, err)
+			}
+			if ok, err := hasher.Verify("wrong horse", hash); err != nil || ok {
+				t.Errorf("Verify(wrong password) = %v, %v, want false", ok, err)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Error("NeedsRehash of a hash with the current parameters")
+			}
+
+			other, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if other == hash {
+				t.Error("hashes of the same password are equal (salt not random)")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	tests := []struct {
+		name     string
+		weak     PasswordHasher
+		stronger PasswordHasher
+	}{
+		{"bcrypt cost", &BcryptHasher{Cost: 4}, &BcryptHasher{Cost: 5}},
+		{"scrypt cost", &ScryptHasher{LogN: 4, R: 8, P: 1, KeyLen: 32}, &ScryptHasher{LogN: 5, R: 8, P: 1, KeyLen: 32}},
+		{"argon2id memory", &Argon2idHasher{Memory: 64, Time: 1, Threads: 1, KeyLen: 32}, &Argon2idHasher{Memory: 128, Time: 1, Threads: 1, KeyLen: 32}},
+		{"argon2id time", &Argon2idHasher{Memory: 64, Time: 1, Threads: 1, KeyLen: 32}, &Argon2idHasher{Memory: 64, Time: 2, Threads: 1, KeyLen: 32}},
+		{"bcrypt to argon2id", &BcryptHasher{Cost: 4}, &Argon2idHasher{Memory: 64, Time: 1, Threads: 1, KeyLen: 32}},
+		{"argon2id to scrypt", &Argon2idHasher{Memory: 64, Time: 1, Threads: 1, KeyLen: 32}, &ScryptHasher{LogN: 4, R: 8, P: 1, KeyLen: 32}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.weak.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !tt.stronger.NeedsRehash(hash) {
+				t.Error("NeedsRehash = false, want true")
+			}
+		})
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	for name, hasher := range testHashers() {
+		hash, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s Hash: %v", name, err)
+		}
+		if ok, err := verifyAny("correct horse", hash, ""); err != nil || !ok {
+			t.Errorf("verifyAny(%s hash) = %v, %v, want true", name, ok, err)
+		}
+		if ok, _ := verifyAny("wrong horse", hash, ""); ok {
+			t.Errorf("verifyAny(%s hash, wrong password) = true", name)
+		}
+	}
+
+	legacy := legacyHash("correct horse", "saltsalt")
+	if ok, err := verifyAny("correct horse", legacy, "saltsalt"); err != nil || !ok {
+		t.Errorf("verifyAny(legacy hash) = %v, %v, want true", ok, err)
+	}
+	if ok, _ := verifyAny("correct horse", legacy, "othersalt"); ok {
+		t.Error("verifyAny(legacy hash, wrong salt) = true")
+	}
+
+	if _, err := verifyAny("correct horse", "$md5$abc", ""); err == nil {
+		t.Error("verifyAny(unknown algorithm) did not fail")
+	}
+}
+
+func TestDecodeInvalidHashes(t *testing.T) {
+	hashes := []string{
+		"$argon2id$v=19$m=64,t=1,p=1$short",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$scrypt$ln=4,r=8$c2FsdA$a2V5",
+		"$scrypt$ln=4,r=8,p=1$!!$a2V5",
+	}
+	for _, hash := range hashes {
+		var err error
+		if strings.HasPrefix(hash, "$argon2id$") {
+			_, _, _, err = decodeArgon2id(hash)
+		} else {
+			_, _, _, err = decodeScrypt(hash)
+		}
+		if err == nil {
+			t.Errorf("decoding %q did not fail", hash)
+		}
+	}
+}
+
+func legacyHash(pwd, salt string) string {
+	hash := sha256.Sum256([]byte(pwd + salt))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
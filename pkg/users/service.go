@@ -1,8 +1,6 @@
 package users
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
 	"log"
 	"regexp"
 	"time"
@@ -26,21 +24,12 @@ type User struct {
 
 	// Note: Never show in output
 	AuthKey string
+	// PwdHash is an encoded hash produced by a PasswordHasher
 	PwdHash string
+	// PwdSalt is only used by legacy sha256 hashes (new hashes embed their salt)
 	PwdSalt string
 }
 
-// setPwd generates a salted hash of the password
-// and fills PwdSalt and PwdHash accordingly
-func (u *User) setPwd(pwd string) {
-	u.PwdSalt = utils.GenString(8)
-	u.PwdHash = genPwdHash(pwd, u.PwdSalt)
-}
-
-func (u *User) isValidPwd(pwd string) bool {
-	return genPwdHash(pwd, u.PwdSalt) == u.PwdHash
-}
-
 // Repository defines the user.Repository operations
 // Storage implementations should follow this interface (e.g. Postgres, In Memory, ...etc)
 type Repository interface {
@@ -71,11 +60,21 @@ type Service interface {
 
 type service struct {
 	userRepo Repository
+	hasher   PasswordHasher
 }
 
 // CreateService Create new instance of User Service
+// Passwords are hashed using argon2id (see CreateServiceWithHasher)
 func CreateService(repo Repository) Service {
-	return &service{repo}
+	return CreateServiceWithHasher(repo, DefaultArgon2idHasher())
+}
+
+// CreateServiceWithHasher Create new instance of User Service
+// that hashes new passwords using the given hasher
+// Note: existing hashes of other algorithms are still accepted
+// and are transparently rehashed on successful login
+func CreateServiceWithHasher(repo Repository, hasher PasswordHasher) Service {
+	return &service{repo, hasher}
 }
 
 func (s *service) GetByKey(key string) (*User, error) {
@@ -145,7 +144,16 @@ func (s *service) CreateWithPwd(u User, pwd string) (*User, error) {
 		}
 	}
 
-	u.setPwd(pwd)
+	pwdHash, err := s.hasher.Hash(pwd)
+	if err != nil {
+		log.Printf("Failed hashing password (error: %v)", err)
+		return nil, &utils.ServiceErr{
+			Code:    utils.UnexpectedCode,
+			Message: "Failed creating new user",
+		}
+	}
+	u.PwdHash = pwdHash
+	u.PwdSalt = ""
 	u.AuthKey = utils.GenString(64) // Let's hope no collisions lol
 
 	newUser, err := s.userRepo.Create(u)
@@ -214,16 +222,41 @@ func (s *service) AuthWithEmailPwd(email, pwd string) (*User, error) {
 		}
 	}
 
-	if !user.isValidPwd(pwd) {
+	valid, err := verifyAny(pwd, user.PwdHash, user.PwdSalt)
+	if err != nil {
+		log.Printf("Failed verifying password of user %d (error: %v)", user.ID, err)
+	}
+	if !valid {
 		return nil, &utils.ServiceErr{
 			Code:    InvalidInputCode,
 			Message: "Invalid email or password",
 		}
 	}
 
+	if s.hasher.NeedsRehash(user.PwdHash) {
+		s.rehashPwd(user, pwd)
+	}
+
 	return user, nil
 }
 
+// rehashPwd upgrades the stored hash of an authenticated user to the
+// current hasher. Failures are only logged since the old hash still works.
+func (s *service) rehashPwd(user *User, pwd string) {
+	pwdHash, err := s.hasher.Hash(pwd)
+	if err != nil {
+		log.Printf("Failed rehashing password of user %d (error: %v)", user.ID, err)
+		return
+	}
+
+	_, err = s.userRepo.Update(user.ID, User{PwdHash: pwdHash})
+	if err != nil {
+		log.Printf("Failed storing rehashed password of user %d (error: %v)", user.ID, err)
+		return
+	}
+	user.PwdHash = pwdHash
+}
+
 func (s *service) GetByEmail(email string) (*User, error) {
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil {
@@ -258,9 +291,3 @@ func isValidEmail(email string) bool {
 	re := regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 	return re.MatchString(email)
 }
-
-func genPwdHash(pwd, salt string) string {
-	hashSlice := sha256.Sum256([]byte(pwd + salt))
-	hash := base64.StdEncoding.EncodeToString(hashSlice[:])
-	return hash
-}
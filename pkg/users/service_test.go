@@ -0,0 +1,67 @@
+package users_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/tnynlabs/wyrm/pkg/storage/memory"
+	"github.com/tnynlabs/wyrm/pkg/users"
+)
+
+func TestAuthRehashesLegacyPasswords(t *testing.T) {
+	repo := memory.CreateUserRepository(memory.CreateDB())
+	service := users.CreateServiceWithHasher(repo, &users.BcryptHasher{Cost: 4})
+
+	// Stored before PasswordHasher existed (sha256 over pwd+salt)
+	legacy := sha256.Sum256([]byte("Password123!" + "saltsalt"))
+	user, err := repo.Create(users.User{
+		Name:    "alice",
+		Email:   "alice@example.com",
+		PwdHash: base64.StdEncoding.EncodeToString(legacy[:]),
+		PwdSalt: "saltsalt",
+	})
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	if _, err := service.AuthWithEmailPwd("alice@example.com", "wrong"); err == nil {
+		t.Fatal("authenticated with a wrong password")
+	}
+	stored, _ := repo.GetByID(user.ID)
+	if strings.HasPrefix(stored.PwdHash, "$") {
+		t.Fatal("password rehashed after a failed login")
+	}
+
+	if _, err := service.AuthWithEmailPwd("alice@example.com", "Password123!"); err != nil {
+		t.Fatalf("authenticating with the legacy password: %v", err)
+	}
+	stored, _ = repo.GetByID(user.ID)
+	if !strings.HasPrefix(stored.PwdHash, "$2") {
+		t.Fatalf("stored hash %q was not rehashed with bcrypt", stored.PwdHash)
+	}
+
+	// The rehashed password keeps working
+	if _, err := service.AuthWithEmailPwd("alice@example.com", "Password123!"); err != nil {
+		t.Fatalf("authenticating after the rehash: %v", err)
+	}
+}
+
+func TestAuthUpgradesWeakerHashes(t *testing.T) {
+	repo := memory.CreateUserRepository(memory.CreateDB())
+	weak := users.CreateServiceWithHasher(repo, &users.BcryptHasher{Cost: 4})
+	_, err := weak.CreateWithPwd(users.User{Name: "alice", DisplayName: "A", Email: "alice@example.com"}, "Password123!")
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	stronger := users.CreateServiceWithHasher(repo, &users.BcryptHasher{Cost: 5})
+	user, err := stronger.AuthWithEmailPwd("alice@example.com", "Password123!")
+	if err != nil {
+		t.Fatalf("authenticating: %v", err)
+	}
+	if !strings.HasPrefix(user.PwdHash, "$2a$05$") {
+		t.Errorf("hash %q was not upgraded to cost 5", user.PwdHash)
+	}
+}
@@ -11,6 +11,9 @@ const UnexpectedCode = ServiceErrCode("UNEXPECTED")
 type ServiceErr struct {
 	Code    ServiceErrCode `json:"code"`
 	Message string         `json:"message"`
+	// Details optional structured information about the error
+	// (e.g. the list of validation failures)
+	Details interface{} `json:"details,omitempty"`
 }
 
 func (e *ServiceErr) Error() string {
@@ -14,4 +14,12 @@ protoc \
     --go_opt=paths=source_relative \
     --go-grpc_out=$BASE_PATH \
     --go-grpc_opt=paths=source_relative \
-    $BASE_PATH/pipelines/protobuf/*.proto
\ No newline at end of file
+    $BASE_PATH/pipelines/protobuf/*.proto
+
+protoc \
+    --proto_path=$BASE_PATH \
+    --go_out=$BASE_PATH \
+    --go_opt=paths=source_relative \
+    --go-grpc_out=$BASE_PATH \
+    --go-grpc_opt=paths=source_relative \
+    $BASE_PATH/telemetry/protobuf/*.proto
\ No newline at end of file
@@ -1,2 +1,3 @@
 rm pkg/tunnels/protobuf/*.pb*
-rm pkg/pipelines/protobuf/*.pb*
\ No newline at end of file
+rm pkg/pipelines/protobuf/*.pb*
+rm pkg/telemetry/protobuf/*.pb*
\ No newline at end of file
@@ -1 +0,0 @@
-go run cmd/db_init/main.go
\ No newline at end of file
@@ -0,0 +1 @@
+go run cmd/wyrm/main.go migrate ${@:-up}